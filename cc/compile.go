@@ -0,0 +1,968 @@
+package cc
+
+import (
+	"fmt"
+	"github.com/DrItanium/cores/iris16"
+	"github.com/DrItanium/cores/registration/parser"
+	"strings"
+)
+
+// Compile turns a C program into iris16 assembly. The subset has int and
+// unsigned (both 16 bits), pointers, arrays, functions with recursion and
+// string literals.
+//
+//	int fib(int n) {
+//		return n < 2 ? n : fib(n - 1) + fib(n - 2);
+//	}
+//
+//	void puts(int *s) {
+//		while (*s)
+//			putchar(*s++);
+//	}
+//
+//	int main() {
+//		puts("hello\n");
+//		return fib(10);
+//	}
+//
+// Statements are if, while, do, for, break, continue, return and blocks.
+// Expressions have the C operators besides the comma and sizeof, casts work
+// between int, unsigned and pointers. Numbers are decimal, hex, octal or
+// characters. Pointers step a word at a time since everything is a word, a
+// string literal is an array of ints with one character each.
+//
+// Globals, arrays and strings live in the data segment starting at address
+// one so that no object is at the null pointer. Each call gets a frame on the
+// stack segment, the caller pushes the arguments in order and uses call, the
+// callee pushes r6 and points it at the saved value. Parameters are below r6
+// and locals above it. Pointers only reach the data segment so locals can't
+// have their address taken and arrays have to be globals. Return values come
+// back in r7 and r8 onward hold intermediate values which are pushed around
+// calls.
+//
+// putchar(c) writes c with the putc system call, the machine terminates once
+// main returns.
+func Compile(src string) (string, error) {
+	if tokens, err := lex(src); err != nil {
+		return "", err
+	} else if prog, err := parse(tokens); err != nil {
+		return "", err
+	} else {
+		g := generator{
+			globals:   make(map[string]*symbol),
+			functions: make(map[string]*function),
+			defined:   make(map[string]bool),
+			next:      1,
+		}
+		return g.program(prog)
+	}
+}
+
+// Assemble runs the output of Compile through the iris16 parser and gives back
+// the image
+func Assemble(asm string) ([]byte, error) {
+	return parser.Assemble(iris16.RegistrationName(), asm)
+}
+
+const (
+	framePointer = iris16.UserRegisterBegin
+	returnValue  = framePointer + 1
+	firstTemp    = returnValue + 1
+	lastTemp     = iris16.RegisterCount - 1
+)
+
+var builtins = map[string]int{
+	"putchar": 1,
+}
+
+// Globals have a label in the data segment, locals an offset from the frame
+// pointer
+type symbol struct {
+	name    string
+	ctype   *ctype
+	global  bool
+	label   string
+	address int
+	offset  int
+}
+
+type generator struct {
+	code      []string
+	data      []string
+	globals   map[string]*symbol
+	functions map[string]*function
+	defined   map[string]bool
+	next      int
+	strings   int
+	labels    int
+	// the function being compiled
+	fn        *function
+	scopes    []map[string]*symbol
+	slots     int
+	maxSlots  int
+	breaks    []string
+	continues []string
+}
+
+func errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("Error: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (this *generator) emit(format string, args ...interface{}) {
+	this.code = append(this.code, fmt.Sprintf(format, args...))
+}
+
+func (this *generator) place(label string) {
+	this.code = append(this.code, label+":")
+}
+
+func (this *generator) label() string {
+	this.labels++
+	return fmt.Sprintf("L$%d", this.labels)
+}
+
+func functionLabel(name string) string {
+	return "f$" + name
+}
+
+// Words are placed at an address of the data segment, the next free address
+// is moved past them
+func (this *generator) allocate(label string, size int, line int) (int, error) {
+	address := this.next
+	if this.next += size; this.next > iris16.MemorySize {
+		return 0, errorf(line, "%s doesn't fit into the data segment", label)
+	}
+	return address, nil
+}
+
+func (this *generator) placeData(label string, address int, values []int64) {
+	this.data = append(this.data, fmt.Sprintf(".org #%d", address), label+":")
+	for _, v := range values {
+		this.data = append(this.data, fmt.Sprintf(".word #%d", uint16(v)))
+	}
+}
+
+// string literals are placed into the data segment with a terminating zero
+func (this *generator) stringLiteral(text string, line int) (string, int, error) {
+	this.strings++
+	label := fmt.Sprintf("s$%d", this.strings)
+	values := stringValues(text)
+	if address, err := this.allocate(label, len(values), line); err != nil {
+		return "", 0, err
+	} else {
+		this.placeData(label, address, values)
+		return label, address, nil
+	}
+}
+
+func stringValues(text string) []int64 {
+	var values []int64
+	for _, r := range text {
+		values = append(values, int64(r))
+	}
+	return append(values, 0)
+}
+
+// numbers have to fit into a word as either an int or an unsigned
+func number(value int64, line int) (int64, error) {
+	if value < -32768 || value > 65535 {
+		return 0, errorf(line, "%d doesn't fit in 16 bits", value)
+	} else {
+		return value, nil
+	}
+}
+
+func (this *generator) declareGlobal(name string, line int) error {
+	if _, ok := builtins[name]; ok {
+		return errorf(line, "%s is a builtin function", name)
+	} else if _, ok := this.globals[name]; ok {
+		return errorf(line, "%s is already defined", name)
+	} else {
+		return nil
+	}
+}
+
+func (this *generator) program(prog *program) (string, error) {
+	// every global gets its address first so that initializers can refer to
+	// any of them
+	for _, d := range prog.globals {
+		if err := this.declareGlobal(d.name, d.line); err != nil {
+			return "", err
+		} else if _, ok := this.functions[d.name]; ok {
+			return "", errorf(d.line, "%s is already defined", d.name)
+		} else if d.ctype.kind == kindVoid || (d.ctype.kind == kindArray && d.ctype.elem.kind == kindVoid) {
+			return "", errorf(d.line, "%s can't be void", d.name)
+		}
+		size := 1
+		if d.ctype.kind == kindArray {
+			if d.ctype.length < 0 {
+				if d.list != nil {
+					d.ctype.length = len(d.list)
+				} else if d.init != nil && d.init.kind == exprString {
+					d.ctype.length = len(stringValues(d.init.name))
+				} else {
+					return "", errorf(d.line, "The length of %s is missing", d.name)
+				}
+			}
+			size = d.ctype.length
+		} else if d.list != nil {
+			return "", errorf(d.line, "%s isn't an array", d.name)
+		}
+		label := "g$" + d.name
+		if address, err := this.allocate(label, size, d.line); err != nil {
+			return "", err
+		} else {
+			this.globals[d.name] = &symbol{name: d.name, ctype: d.ctype, global: true, label: label, address: address}
+		}
+	}
+	for _, f := range prog.functions {
+		if err := this.declareFunction(f); err != nil {
+			return "", err
+		}
+	}
+	for _, d := range prog.globals {
+		if err := this.initialize(d); err != nil {
+			return "", err
+		}
+	}
+	if main, ok := this.functions["main"]; !ok || !this.defined["main"] {
+		return "", fmt.Errorf("Error: No main function")
+	} else if len(main.params) != 0 {
+		return "", errorf(main.line, "main can't take any parameters")
+	}
+	this.emit("call %s", functionLabel("main"))
+	this.emit("system #%d, r0, r0", iris16.SystemCallTerminate)
+	for _, f := range prog.functions {
+		if f.body != nil {
+			if err := this.function(f); err != nil {
+				return "", err
+			}
+		}
+	}
+	for _, f := range prog.functions {
+		if !this.defined[f.name] {
+			return "", errorf(f.line, "%s is declared but never defined", f.name)
+		}
+	}
+	lines := append([]string{".data"}, this.data...)
+	lines = append(append(lines, ".code"), this.code...)
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// prototypes and definitions have to agree on the number of parameters
+func (this *generator) declareFunction(f *function) error {
+	if err := this.declareGlobal(f.name, f.line); err != nil {
+		return err
+	} else if f.ret.kind == kindArray {
+		return errorf(f.line, "%s can't return an array", f.name)
+	} else if prev, ok := this.functions[f.name]; ok {
+		if len(prev.params) != len(f.params) {
+			return errorf(f.line, "%s was declared with %d parameters", f.name, len(prev.params))
+		} else if f.body != nil && this.defined[f.name] {
+			return errorf(f.line, "%s is already defined", f.name)
+		}
+	}
+	if f.body != nil || this.functions[f.name] == nil {
+		this.functions[f.name] = f
+	}
+	if f.body != nil {
+		this.defined[f.name] = true
+	}
+	return nil
+}
+
+func (this *generator) initialize(d *decl) error {
+	sym := this.globals[d.name]
+	var values []int64
+	switch {
+	case d.list != nil:
+		if len(d.list) > d.ctype.length {
+			return errorf(d.line, "Too many values for %s", d.name)
+		}
+		for _, e := range d.list {
+			if v, err := this.constant(e); err != nil {
+				return err
+			} else {
+				values = append(values, v)
+			}
+		}
+	case d.init != nil && d.ctype.kind == kindArray:
+		if d.init.kind != exprString {
+			return errorf(d.line, "%s can only be initialized with a list or a string", d.name)
+		} else if values = stringValues(d.init.name); len(values) > d.ctype.length {
+			return errorf(d.line, "The string is too long for %s", d.name)
+		}
+	case d.init != nil:
+		if v, err := this.constant(d.init); err != nil {
+			return err
+		} else {
+			values = []int64{v}
+		}
+	}
+	this.placeData(sym.label, sym.address, values)
+	return nil
+}
+
+// Globals are initialized with constant expressions, the address of a global
+// or a string counts as a constant
+func (this *generator) constant(e *expr) (int64, error) {
+	switch e.kind {
+	case exprNumber:
+		return number(e.value, e.line)
+	case exprString:
+		_, address, err := this.stringLiteral(e.name, e.line)
+		return int64(address), err
+	case exprCast:
+		return this.constant(e.args[0])
+	case exprName:
+		if sym, ok := this.globals[e.name]; ok && sym.ctype.kind == kindArray {
+			return int64(sym.address), nil
+		}
+	case exprUnary:
+		if e.op == "&" && e.args[0].kind == exprName {
+			if sym, ok := this.globals[e.args[0].name]; ok {
+				return int64(sym.address), nil
+			}
+		} else if v, err := this.constant(e.args[0]); err != nil {
+			return 0, err
+		} else {
+			switch e.op {
+			case "-":
+				return -v, nil
+			case "~":
+				return ^v, nil
+			}
+		}
+	case exprBinary:
+		if lhs, err := this.constant(e.args[0]); err != nil {
+			return 0, err
+		} else if rhs, err := this.constant(e.args[1]); err != nil {
+			return 0, err
+		} else {
+			switch e.op {
+			case "+":
+				return number(lhs+rhs, e.line)
+			case "-":
+				return number(lhs-rhs, e.line)
+			case "*":
+				return number(lhs*rhs, e.line)
+			}
+		}
+	}
+	return 0, errorf(e.line, "Globals can only be initialized with constants")
+}
+
+// The frame is set up once the number of locals is known, r3 is the stack
+// pointer
+func (this *generator) function(f *function) error {
+	this.fn = f
+	this.scopes = []map[string]*symbol{make(map[string]*symbol)}
+	this.slots, this.maxSlots = 0, 0
+	for i, param := range f.params {
+		if param.ctype.kind == kindVoid {
+			return errorf(param.line, "Parameter %s can't be void", param.name)
+		} else if _, ok := this.scopes[0][param.name]; ok {
+			return errorf(param.line, "Parameter %s is given twice", param.name)
+		}
+		this.scopes[0][param.name] = &symbol{name: param.name, ctype: param.ctype, offset: i - len(f.params)}
+	}
+	this.place(functionLabel(f.name))
+	this.emit("push r%d", framePointer)
+	this.emit("move r%d = r%d", framePointer, iris16.StackPointer)
+	reserve := len(this.code)
+	this.emit("")
+	for _, s := range f.body {
+		if err := this.statement(s); err != nil {
+			return err
+		}
+	}
+	this.code[reserve] = this.adjust(iris16.StackPointer, iris16.StackPointer, this.maxSlots)
+	this.place(functionLabel(f.name) + "$ret")
+	this.emit("move r%d = r%d", iris16.StackPointer, framePointer)
+	this.emit("pop r%d", framePointer)
+	this.emit("return")
+	return nil
+}
+
+// dest = src + offset, arithmetic immediates are only eight bits wide
+func (this *generator) adjust(dest, src, offset int) string {
+	switch {
+	case offset >= 0 && offset <= 255:
+		return fmt.Sprintf("add r%d = r%d, #%d", dest, src, offset)
+	case offset < 0 && offset >= -255:
+		return fmt.Sprintf("sub r%d = r%d, #%d", dest, src, -offset)
+	default:
+		return fmt.Sprintf("set r%d = #%d\nadd r%d = r%d, r%d", dest, uint16(offset), dest, src, dest)
+	}
+}
+
+func (this *generator) lookup(name string, line int) (*symbol, error) {
+	for i := len(this.scopes) - 1; i >= 0; i-- {
+		if sym, ok := this.scopes[i][name]; ok {
+			return sym, nil
+		}
+	}
+	if sym, ok := this.globals[name]; ok {
+		return sym, nil
+	} else if _, ok := this.functions[name]; ok {
+		return nil, errorf(line, "%s is a function", name)
+	} else {
+		return nil, errorf(line, "%s is not defined", name)
+	}
+}
+
+// slots of a scope are handed out again once it ends
+func (this *generator) scoped(body func() error) error {
+	this.scopes = append(this.scopes, make(map[string]*symbol))
+	slots := this.slots
+	err := body()
+	this.scopes = this.scopes[:len(this.scopes)-1]
+	this.slots = slots
+	return err
+}
+
+func (this *generator) loop(breakLabel, continueLabel string, body *stmt) error {
+	this.breaks = append(this.breaks, breakLabel)
+	this.continues = append(this.continues, continueLabel)
+	err := this.statement(body)
+	this.breaks = this.breaks[:len(this.breaks)-1]
+	this.continues = this.continues[:len(this.continues)-1]
+	return err
+}
+
+func (this *generator) statement(s *stmt) error {
+	switch s.kind {
+	case stmtDecl:
+		for _, d := range s.decls {
+			if err := this.local(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	case stmtExpr:
+		_, err := this.value(s.value, firstTemp)
+		return err
+	case stmtBlock:
+		return this.scoped(func() error {
+			for _, s := range s.block {
+				if err := this.statement(s); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case stmtIf:
+		otherwise, end := this.label(), this.label()
+		if err := this.branchUnless(s.value, otherwise); err != nil {
+			return err
+		} else if err := this.scoped(func() error { return this.statement(s.body) }); err != nil {
+			return err
+		}
+		if s.orElse != nil {
+			this.emit("branch %s", end)
+		}
+		this.place(otherwise)
+		if s.orElse != nil {
+			if err := this.scoped(func() error { return this.statement(s.orElse) }); err != nil {
+				return err
+			}
+			this.place(end)
+		}
+		return nil
+	case stmtWhile:
+		top, end := this.label(), this.label()
+		this.place(top)
+		if err := this.branchUnless(s.value, end); err != nil {
+			return err
+		} else if err := this.scoped(func() error { return this.loop(end, top, s.body) }); err != nil {
+			return err
+		}
+		this.emit("branch %s", top)
+		this.place(end)
+		return nil
+	case stmtDo:
+		top, next, end := this.label(), this.label(), this.label()
+		this.place(top)
+		if err := this.scoped(func() error { return this.loop(end, next, s.body) }); err != nil {
+			return err
+		}
+		this.place(next)
+		if err := this.branchUnless(s.value, end); err != nil {
+			return err
+		}
+		this.emit("branch %s", top)
+		this.place(end)
+		return nil
+	case stmtFor:
+		return this.scoped(func() error {
+			top, next, end := this.label(), this.label(), this.label()
+			if s.init != nil {
+				if err := this.statement(s.init); err != nil {
+					return err
+				}
+			}
+			this.place(top)
+			if s.value != nil {
+				if err := this.branchUnless(s.value, end); err != nil {
+					return err
+				}
+			}
+			if err := this.scoped(func() error { return this.loop(end, next, s.body) }); err != nil {
+				return err
+			}
+			this.place(next)
+			if s.post != nil {
+				if _, err := this.value(s.post, firstTemp); err != nil {
+					return err
+				}
+			}
+			this.emit("branch %s", top)
+			this.place(end)
+			return nil
+		})
+	case stmtReturn:
+		if s.value != nil {
+			if this.fn.ret.kind == kindVoid {
+				return errorf(s.line, "%s doesn't return a value", this.fn.name)
+			} else if err := this.operand(s.value, firstTemp); err != nil {
+				return err
+			}
+			this.emit("move r%d = r%d", returnValue, firstTemp)
+		}
+		this.emit("branch %s$ret", functionLabel(this.fn.name))
+		return nil
+	case stmtBreak, stmtContinue:
+		targets, name := this.breaks, "break"
+		if s.kind == stmtContinue {
+			targets, name = this.continues, "continue"
+		}
+		if len(targets) == 0 {
+			return errorf(s.line, "%s outside of a loop", name)
+		}
+		this.emit("branch %s", targets[len(targets)-1])
+		return nil
+	default:
+		return errorf(s.line, "Unknown statement")
+	}
+}
+
+// locals get the next slot of the frame
+func (this *generator) local(d *decl) error {
+	scope := this.scopes[len(this.scopes)-1]
+	if d.ctype.kind == kindArray {
+		return errorf(d.line, "Array %s has to be a global, pointers can't reach the stack", d.name)
+	} else if d.ctype.kind == kindVoid {
+		return errorf(d.line, "%s can't be void", d.name)
+	} else if _, ok := scope[d.name]; ok {
+		return errorf(d.line, "%s is already defined", d.name)
+	}
+	this.slots++
+	if this.slots > this.maxSlots {
+		this.maxSlots = this.slots
+	}
+	sym := &symbol{name: d.name, ctype: d.ctype, offset: this.slots}
+	if d.init != nil {
+		if err := this.operand(d.init, firstTemp); err != nil {
+			return err
+		}
+		this.emit("%s", this.adjust(firstTemp+1, framePointer, sym.offset))
+		this.emit("store r%d = r%d, stack", firstTemp+1, firstTemp)
+	}
+	// the initializer can't see the new variable
+	scope[d.name] = sym
+	return nil
+}
+
+// jump to the label when the condition is false
+func (this *generator) branchUnless(e *expr, label string) error {
+	if err := this.operand(e, firstTemp); err != nil {
+		return err
+	}
+	this.emit("eq r%d = r%d, r0", firstTemp+1, firstTemp)
+	this.emit("branch %s if r%d", label, firstTemp+1)
+	return nil
+}
+
+// a value which can't be void
+func (this *generator) operand(e *expr, r int) error {
+	if t, err := this.value(e, r); err != nil {
+		return err
+	} else if t.kind == kindVoid {
+		return errorf(e.line, "Can't use a void value")
+	} else {
+		return nil
+	}
+}
+
+// Compute the address of an lvalue into register r, locals are in the stack
+// segment and everything else is in the data segment
+func (this *generator) lvalue(e *expr, r int) (*ctype, bool, error) {
+	if r+2 > lastTemp {
+		return nil, false, errorf(e.line, "Expression is too complex")
+	}
+	switch e.kind {
+	case exprName:
+		sym, err := this.lookup(e.name, e.line)
+		if err != nil {
+			return nil, false, err
+		} else if sym.global {
+			this.emit("set r%d = %s", r, sym.label)
+			return sym.ctype, false, nil
+		} else {
+			this.emit("%s", this.adjust(r, framePointer, sym.offset))
+			return sym.ctype, true, nil
+		}
+	case exprUnary:
+		if e.op == "*" {
+			if t, err := this.value(e.args[0], r); err != nil {
+				return nil, false, err
+			} else if !t.pointer() {
+				return nil, false, errorf(e.line, "Can't dereference a %s", t)
+			} else if t.elem.kind == kindVoid {
+				return nil, false, errorf(e.line, "Can't dereference a void pointer")
+			} else {
+				return t.elem, false, nil
+			}
+		}
+	case exprIndex:
+		base, index := e.args[0], e.args[1]
+		t, err := this.value(base, r)
+		if err != nil {
+			return nil, false, err
+		}
+		it, err := this.value(index, r+1)
+		if err != nil {
+			return nil, false, err
+		}
+		// index[pointer] is as valid as pointer[index]
+		if !t.pointer() && it.pointer() {
+			t, it = it, t
+		}
+		if !t.pointer() || it.pointer() {
+			return nil, false, errorf(e.line, "Only pointers and arrays can be indexed with a number")
+		} else if t.elem.kind == kindVoid {
+			return nil, false, errorf(e.line, "Can't index a void pointer")
+		}
+		this.emit("add r%d = r%d, r%d", r, r, r+1)
+		return t.elem, false, nil
+	}
+	return nil, false, errorf(e.line, "Expression can't be assigned to")
+}
+
+func segment(stack bool) string {
+	if stack {
+		return ", stack"
+	} else {
+		return ""
+	}
+}
+
+// load an assignable value, the address is left in r+1
+func (this *generator) load(e *expr, r int) (*ctype, bool, error) {
+	t, stack, err := this.lvalue(e, r+1)
+	if err != nil {
+		return nil, false, err
+	} else if t.kind == kindArray {
+		return nil, false, errorf(e.line, "Can't assign to an array")
+	}
+	this.emit("load r%d = r%d%s", r, r+1, segment(stack))
+	return t, stack, nil
+}
+
+var arithmetic = map[string][2]string{
+	// unsigned and signed forms
+	"+":  {"add", "add"},
+	"-":  {"sub", "sub"},
+	"*":  {"mul", "mul"},
+	"/":  {"div", "sdiv"},
+	"%":  {"rem", "srem"},
+	"&":  {"and", "and"},
+	"|":  {"or", "or"},
+	"^":  {"xor", "xor"},
+	"<<": {"shiftleft", "shiftleft"},
+	">>": {"shiftright", "sshiftright"},
+	"==": {"eq", "eq"},
+	"!=": {"ne", "ne"},
+	"<":  {"lt", "slt"},
+	"<=": {"le", "sle"},
+	">":  {"gt", "sgt"},
+	">=": {"ge", "sge"},
+}
+
+// The type of an arithmetic result, anything unsigned makes it unsigned and
+// pointers keep their type when offset by a number
+func (this *generator) combine(op string, lhs, rhs *ctype, line int) (*ctype, error) {
+	switch {
+	case op == "+" && lhs.pointer() && rhs.pointer():
+		return nil, errorf(line, "Can't add two pointers")
+	case op == "-" && lhs.pointer() && rhs.pointer():
+		return typeInt, nil
+	case (op == "+" || op == "-") && lhs.pointer():
+		return lhs, nil
+	case op == "+" && rhs.pointer():
+		return rhs, nil
+	case lhs.unsigned() || rhs.unsigned():
+		return typeUnsigned, nil
+	default:
+		return typeInt, nil
+	}
+}
+
+// the operation applied by an arithmetic instruction, r = r op s
+func (this *generator) operate(op string, lhs, rhs *ctype, r, s int, line int) (*ctype, error) {
+	t, err := this.combine(op, lhs, rhs, line)
+	if err != nil {
+		return nil, err
+	}
+	form := 1
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		if lhs.unsigned() || rhs.unsigned() {
+			form = 0
+		}
+		t = typeInt
+	case ">>":
+		if lhs.unsigned() {
+			form = 0
+		}
+	default:
+		if t.unsigned() {
+			form = 0
+		}
+	}
+	this.emit("%s r%d = r%d, r%d", arithmetic[op][form], r, r, s)
+	return t, nil
+}
+
+// Evaluate an expression into register r, the registers after it are free to
+// use
+func (this *generator) value(e *expr, r int) (*ctype, error) {
+	if r+2 > lastTemp {
+		return nil, errorf(e.line, "Expression is too complex")
+	}
+	switch e.kind {
+	case exprNumber:
+		if v, err := number(e.value, e.line); err != nil {
+			return nil, err
+		} else {
+			this.emit("set r%d = #%d", r, uint16(v))
+			if v > 32767 {
+				return typeUnsigned, nil
+			}
+			return typeInt, nil
+		}
+	case exprString:
+		if label, _, err := this.stringLiteral(e.name, e.line); err != nil {
+			return nil, err
+		} else {
+			this.emit("set r%d = %s", r, label)
+			return pointerTo(typeInt), nil
+		}
+	case exprName:
+		if sym, err := this.lookup(e.name, e.line); err != nil {
+			return nil, err
+		} else if sym.ctype.kind == kindArray {
+			this.emit("set r%d = %s", r, sym.label)
+			return sym.ctype.decay(), nil
+		}
+		t, _, err := this.load(e, r)
+		return t, err
+	case exprIndex:
+		t, _, err := this.load(e, r)
+		return t, err
+	case exprUnary:
+		return this.unary(e, r)
+	case exprBinary:
+		if e.op == "&&" || e.op == "||" {
+			return this.logical(e, r)
+		} else if lhs, err := this.value(e.args[0], r); err != nil {
+			return nil, err
+		} else if rhs, err := this.value(e.args[1], r+1); err != nil {
+			return nil, err
+		} else if lhs.kind == kindVoid || rhs.kind == kindVoid {
+			return nil, errorf(e.line, "Can't use a void value")
+		} else {
+			return this.operate(e.op, lhs, rhs, r, r+1, e.line)
+		}
+	case exprAssign:
+		return this.assign(e, r)
+	case exprIncDec:
+		t, stack, err := this.load(e.args[0], r)
+		if err != nil {
+			return nil, err
+		}
+		step := "incr"
+		if e.op == "--" {
+			step = "decr"
+		}
+		if e.postfix {
+			this.emit("%s r%d = r%d", step, r+2, r)
+			this.emit("store r%d = r%d%s", r+1, r+2, segment(stack))
+		} else {
+			this.emit("%s r%d = r%d", step, r, r)
+			this.emit("store r%d = r%d%s", r+1, r, segment(stack))
+		}
+		return t, nil
+	case exprCall:
+		return this.call(e, r)
+	case exprCast:
+		if t, err := this.value(e.args[0], r); err != nil {
+			return nil, err
+		} else if t.kind == kindVoid && e.ctype.kind != kindVoid {
+			return nil, errorf(e.line, "Can't use a void value")
+		} else {
+			return e.ctype, nil
+		}
+	case exprCond:
+		otherwise, end := this.label(), this.label()
+		if err := this.operand(e.args[0], r); err != nil {
+			return nil, err
+		}
+		this.emit("eq r%d = r%d, r0", r+1, r)
+		this.emit("branch %s if r%d", otherwise, r+1)
+		t, err := this.value(e.args[1], r)
+		if err != nil {
+			return nil, err
+		}
+		this.emit("branch %s", end)
+		this.place(otherwise)
+		if _, err := this.value(e.args[2], r); err != nil {
+			return nil, err
+		}
+		this.place(end)
+		return t, nil
+	default:
+		return nil, errorf(e.line, "Unknown expression")
+	}
+}
+
+func (this *generator) unary(e *expr, r int) (*ctype, error) {
+	switch e.op {
+	case "&":
+		if t, stack, err := this.lvalue(e.args[0], r); err != nil {
+			return nil, err
+		} else if stack {
+			return nil, errorf(e.line, "Can't take the address of the local %s, pointers only reach the data segment", e.args[0].name)
+		} else if t.kind == kindArray {
+			return t.decay(), nil
+		} else {
+			return pointerTo(t), nil
+		}
+	case "*":
+		t, _, err := this.load(e, r)
+		return t, err
+	}
+	t, err := this.value(e.args[0], r)
+	if err != nil {
+		return nil, err
+	} else if t.kind == kindVoid {
+		return nil, errorf(e.line, "Can't use a void value")
+	}
+	switch e.op {
+	case "-":
+		this.emit("sub r%d = r0, r%d", r, r)
+	case "~":
+		this.emit("not r%d = r%d", r, r)
+	case "!":
+		this.emit("eq r%d = r%d, r0", r, r)
+		t = typeInt
+	}
+	return t, nil
+}
+
+// the right side is skipped once the left side decides the result
+func (this *generator) logical(e *expr, r int) (*ctype, error) {
+	end := this.label()
+	if err := this.operand(e.args[0], r); err != nil {
+		return nil, err
+	}
+	this.emit("ne r%d = r%d, r0", r, r)
+	if e.op == "&&" {
+		this.emit("eq r%d = r%d, r0", r+1, r)
+		this.emit("branch %s if r%d", end, r+1)
+	} else {
+		this.emit("branch %s if r%d", end, r)
+	}
+	if err := this.operand(e.args[1], r); err != nil {
+		return nil, err
+	}
+	this.emit("ne r%d = r%d, r0", r, r)
+	this.place(end)
+	return typeInt, nil
+}
+
+// the value is evaluated before the address, compound assignments load the
+// old value first
+func (this *generator) assign(e *expr, r int) (*ctype, error) {
+	if e.op == "=" {
+		if err := this.operand(e.args[1], r); err != nil {
+			return nil, err
+		}
+		t, stack, err := this.lvalue(e.args[0], r+1)
+		if err != nil {
+			return nil, err
+		} else if t.kind == kindArray {
+			return nil, errorf(e.line, "Can't assign to an array")
+		}
+		this.emit("store r%d = r%d%s", r+1, r, segment(stack))
+		return t, nil
+	}
+	t, stack, err := this.load(e.args[0], r)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := this.value(e.args[1], r+2)
+	if err != nil {
+		return nil, err
+	} else if rhs.kind == kindVoid {
+		return nil, errorf(e.line, "Can't use a void value")
+	} else if _, err := this.operate(strings.TrimSuffix(e.op, "="), t, rhs, r, r+2, e.line); err != nil {
+		return nil, err
+	}
+	this.emit("store r%d = r%d%s", r+1, r, segment(stack))
+	return t, nil
+}
+
+// The registers before r are pushed, the arguments are pushed in order and
+// popped again once the callee returns
+func (this *generator) call(e *expr, r int) (*ctype, error) {
+	if arity, ok := builtins[e.name]; ok {
+		if len(e.args) != arity {
+			return nil, errorf(e.line, "%s takes %d arguments but was given %d", e.name, arity, len(e.args))
+		} else if err := this.operand(e.args[0], r); err != nil {
+			return nil, err
+		}
+		this.emit("system #%d, r%d, r%d", iris16.SystemCallPutc, r, r)
+		return typeInt, nil
+	}
+	f, ok := this.functions[e.name]
+	if !ok {
+		if _, err := this.lookup(e.name, e.line); err == nil {
+			return nil, errorf(e.line, "%s is not a function", e.name)
+		} else {
+			return nil, errorf(e.line, "Function %s is not defined", e.name)
+		}
+	} else if len(e.args) != len(f.params) {
+		return nil, errorf(e.line, "%s takes %d arguments but was given %d", e.name, len(f.params), len(e.args))
+	} else if len(e.args) > 255 {
+		return nil, errorf(e.line, "Too many arguments")
+	}
+	for i := firstTemp; i < r; i++ {
+		this.emit("push r%d", i)
+	}
+	for _, arg := range e.args {
+		if err := this.operand(arg, firstTemp); err != nil {
+			return nil, err
+		}
+		this.emit("push r%d", firstTemp)
+	}
+	this.emit("call %s", functionLabel(f.name))
+	if len(e.args) > 0 {
+		this.emit("sub r%d = r%d, #%d", iris16.StackPointer, iris16.StackPointer, len(e.args))
+	}
+	for i := r - 1; i >= firstTemp; i-- {
+		this.emit("pop r%d", i)
+	}
+	this.emit("move r%d = r%d", r, returnValue)
+	return f.ret, nil
+}
@@ -0,0 +1,189 @@
+package cc
+
+import (
+	"bytes"
+	"github.com/DrItanium/cores/iris16"
+	"testing"
+)
+
+func run(t *testing.T, src string) string {
+	asm, err := Compile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, err := Assemble(asm)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, asm)
+	}
+	core, err := iris16.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make(chan byte, len(image))
+	for _, b := range image {
+		in <- b
+	}
+	close(in)
+	var out bytes.Buffer
+	core.SetOutput(&out)
+	if err := core.InstallProgram(in); err != nil {
+		t.Fatal(err)
+	} else if err := core.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+const library = `
+void puts(int *s) {
+	while (*s)
+		putchar(*s++);
+}
+
+void printn(int n) {
+	if (n < 0) {
+		putchar('-');
+		n = -n;
+	}
+	if (n > 9)
+		printn(n / 10);
+	putchar('0' + n % 10);
+}
+
+void printu(unsigned n) {
+	if (n > 9)
+		printu(n / 10);
+	putchar('0' + n % 10);
+}
+`
+
+func Test_Programs(t *testing.T) {
+	for _, test := range []struct {
+		name, src, expect string
+	}{
+		{"recursion", `
+int fib(int n) { return n < 2 ? n : fib(n - 1) + fib(n - 2); }
+int fact(int n) { if (n <= 1) return 1; return n * fact(n - 1); }
+int main() {
+	printn(fib(15)); putchar(' ');
+	printn(fact(7)); putchar(' ');
+	printn(fib(3) + fact(3) * fib(4));
+	return 0;
+}`, "610 5040 20"},
+		{"pointers", `
+int a = 3, b = 4;
+int *p = &b;
+void swap(int *x, int *y) { int t = *x; *x = *y; *y = t; }
+int main() {
+	swap(&a, &b);
+	printn(a); printn(b); printn(*p);
+	*p += 10;
+	printn(b);
+	printn(&b - &a);
+}`, "433131"},
+		{"strings", `
+int greeting[] = "hi ";
+int *words[3];
+int length(int *s) { int *e = s; while (*e) e++; return e - s; }
+int main() {
+	words[0] = greeting; words[1] = "there"; words[2] = "\n";
+	for (int i = 0; i < 3; i++)
+		puts(words[i]);
+	printn(length(words[1]));
+	putchar(greeting[1]);
+}`, "hi there\n5i"},
+		{"signedness", `
+int main() {
+	int i = -7;
+	unsigned u = 65529;
+	printn(i / 2); putchar(' ');
+	printu(u / 2); putchar(' ');
+	printn(i >> 1); putchar(' ');
+	printu(u >> 1); putchar(' ');
+	printn(i < 1); printn(u < 1); printn((unsigned)i == u);
+	putchar(' ');
+	printn(-i % 4); printn(~0); printn(40000);
+}`, "-3 32764 -4 32764 101 3-1-25536"},
+		{"loops", `
+int main() {
+	int i;
+	for (i = 0; i < 10; ++i) {
+		if (i == 2) continue;
+		if (i == 6) break;
+		printn(i);
+	}
+	i = 3;
+	do { printn(i); } while (--i);
+	while (1) { if (i++ == 4) break; }
+	printn(i);
+	int total = 0, j = 0;
+	while (j < 5) { total += j++; }
+	printn(total);
+}`, "01345321510"},
+		{"operators", `
+int x = 12;
+int main() {
+	x *= 3; printn(x); putchar(' ');
+	x -= 6; x /= 5; printn(x); putchar(' ');
+	x <<= 4; x |= 1; x ^= 3; printn(x); putchar(' ');
+	printn(x-- + --x); putchar(' ');
+	printn(!x || x && 0); printn(5 & 3 | 8);
+	printn(x > 90 ? x < 100 ? 1 : 2 : 3);
+}`, "36 6 98 194 091"},
+		{"tables", `
+int primes[] = { 2, 3, 5, 7, 11 };
+int squares[8] = { 0, 1, 4 };
+int *second = primes + 1;
+int sum(int *a, int n) { int total = 0; while (n--) total += *a++; return total; }
+int main() {
+	printn(sum(primes, 5)); putchar(' ');
+	for (int i = 3; i < 8; i++)
+		squares[i] = i * i;
+	printn(sum(squares, 8)); putchar(' ');
+	printn(second[2]); printn(1[primes]);
+}`, "28 140 73"},
+		{"prototypes", `
+int odd(int n);
+int even(int n) { return n == 0 ? 1 : odd(n - 1); }
+int odd(int n) { return n == 0 ? 0 : even(n - 1); }
+void nothing(void) { }
+int main() {
+	nothing();
+	printn(even(10)); printn(odd(7)); printn(even(3));
+}`, "110"},
+	} {
+		if output := run(t, library+test.src); output != test.expect {
+			t.Errorf("%s: expected %q but got %q", test.name, test.expect, output)
+		}
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	for _, src := range []string{
+		"int f() { return 0; }",
+		"int main(int a) { }",
+		"int main() { x = 1; }",
+		"int x; int x; int main() { }",
+		"int putchar(int c) { } int main() { }",
+		"int f(int a); int main() { f(1); }",
+		"int f(int a) { } int main() { f(); }",
+		"int a[2]; int main() { a = 1; }",
+		"int main() { int a[2]; }",
+		"int main() { int x; int *p = &x; }",
+		"int main() { int x = 70000; }",
+		"int x; int y = x; int main() { }",
+		"int a[2] = { 1, 2, 3 }; int main() { }",
+		"void f() { } int main() { int x = f(); }",
+		"void f() { return 1; } int main() { }",
+		"int main() { break; }",
+		"int main() { 1 = 2; }",
+		"int main() { int x; *x = 1; }",
+		"int main() { if (1) { }",
+		"int main() { int *p; int *q; p + q; }",
+		"int main() { \"unterminated; }",
+	} {
+		if _, err := Compile(src); err == nil {
+			t.Errorf("%q was accepted", src)
+		}
+	}
+}
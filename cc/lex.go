@@ -0,0 +1,133 @@
+// cc compiles a small subset of C down to iris16 assembly
+package cc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenName
+	tokenNumber
+	tokenString
+	tokenPunct
+)
+
+type token struct {
+	Type  tokenType
+	Text  string
+	Value int64
+	line  int
+}
+
+func (this token) String() string {
+	if this.Type == tokenEOF {
+		return "end of input"
+	} else {
+		return this.Text
+	}
+}
+
+// longest first so that <<= isn't read as <<
+var punctuation = []string{
+	"<<=", ">>=",
+	"==", "!=", "<=", ">=", "&&", "||", "++", "--", "<<", ">>",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+	"{", "}", "(", ")", "[", "]", ";", ",", "=", "+", "-", "*", "/", "%",
+	"<", ">", "!", "~", "&", "|", "^", "?", ":",
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for len(src) > 0 {
+		r, width := utf8.DecodeRuneInString(src)
+		switch {
+		case r == '\n':
+			line++
+			src = src[width:]
+		case unicode.IsSpace(r):
+			src = src[width:]
+		case strings.HasPrefix(src, "//"):
+			if end := strings.IndexByte(src, '\n'); end < 0 {
+				src = ""
+			} else {
+				src = src[end:]
+			}
+		case strings.HasPrefix(src, "/*"):
+			if end := strings.Index(src, "*/"); end < 0 {
+				return nil, fmt.Errorf("Error: line %d: Unterminated comment", line)
+			} else {
+				line += strings.Count(src[:end], "\n")
+				src = src[end+2:]
+			}
+		case r == '_' || unicode.IsLetter(r):
+			end := strings.IndexFunc(src, func(r rune) bool {
+				return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			if end < 0 {
+				end = len(src)
+			}
+			tokens = append(tokens, token{Type: tokenName, Text: src[:end], line: line})
+			src = src[end:]
+		case unicode.IsDigit(r):
+			end := strings.IndexFunc(src, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			if end < 0 {
+				end = len(src)
+			}
+			// a u suffix is accepted and ignored, the type comes from context
+			text := strings.TrimRight(src[:end], "uU")
+			if v, err := strconv.ParseUint(text, 0, 64); err != nil {
+				return nil, fmt.Errorf("Error: line %d: Malformed number %s", line, src[:end])
+			} else {
+				tokens = append(tokens, token{Type: tokenNumber, Text: src[:end], Value: int64(v), line: line})
+			}
+			src = src[end:]
+		case r == '\'':
+			if value, _, tail, err := strconv.UnquoteChar(src[1:], '\''); err != nil || !strings.HasPrefix(tail, "'") {
+				return nil, fmt.Errorf("Error: line %d: Malformed character literal", line)
+			} else {
+				end := len(src) - len(tail) + 1
+				tokens = append(tokens, token{Type: tokenNumber, Text: src[:end], Value: int64(value), line: line})
+				src = src[end:]
+			}
+		case r == '"':
+			var text []rune
+			rest := src[1:]
+			for !strings.HasPrefix(rest, "\"") {
+				if rest == "" || rest[0] == '\n' {
+					return nil, fmt.Errorf("Error: line %d: Unterminated string literal", line)
+				} else if value, _, tail, err := strconv.UnquoteChar(rest, '"'); err != nil {
+					return nil, fmt.Errorf("Error: line %d: Malformed string literal", line)
+				} else {
+					text = append(text, value)
+					rest = tail
+				}
+			}
+			tokens = append(tokens, token{Type: tokenString, Text: string(text), line: line})
+			src = rest[1:]
+		default:
+			found := false
+			for _, p := range punctuation {
+				if strings.HasPrefix(src, p) {
+					tokens = append(tokens, token{Type: tokenPunct, Text: p, line: line})
+					src = src[len(p):]
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("Error: line %d: Unexpected character %q", line, r)
+			}
+		}
+	}
+	return append(tokens, token{Type: tokenEOF, line: line}), nil
+}
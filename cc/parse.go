@@ -0,0 +1,698 @@
+package cc
+
+import (
+	"fmt"
+)
+
+type kind int
+
+const (
+	kindInt kind = iota
+	kindUnsigned
+	kindVoid
+	kindPointer
+	kindArray
+)
+
+// Every scalar is a single 16-bit word, pointers are unsigned word addresses
+// into the data segment
+type ctype struct {
+	kind   kind
+	elem   *ctype
+	length int
+}
+
+var (
+	typeInt      = &ctype{kind: kindInt}
+	typeUnsigned = &ctype{kind: kindUnsigned}
+	typeVoid     = &ctype{kind: kindVoid}
+)
+
+func pointerTo(t *ctype) *ctype {
+	return &ctype{kind: kindPointer, elem: t}
+}
+
+func (this *ctype) String() string {
+	switch this.kind {
+	case kindInt:
+		return "int"
+	case kindUnsigned:
+		return "unsigned"
+	case kindVoid:
+		return "void"
+	case kindPointer:
+		return this.elem.String() + " *"
+	default:
+		return fmt.Sprintf("%s[%d]", this.elem, this.length)
+	}
+}
+
+// arrays turn into pointers to their first element when used as values
+func (this *ctype) decay() *ctype {
+	if this.kind == kindArray {
+		return pointerTo(this.elem)
+	} else {
+		return this
+	}
+}
+
+func (this *ctype) unsigned() bool {
+	return this.kind == kindUnsigned || this.kind == kindPointer
+}
+
+func (this *ctype) pointer() bool {
+	return this.kind == kindPointer
+}
+
+type exprKind int
+
+const (
+	exprNumber exprKind = iota
+	exprString
+	exprName
+	exprUnary
+	exprBinary
+	exprAssign
+	exprIncDec
+	exprCall
+	exprIndex
+	exprCast
+	exprCond
+)
+
+// op holds the operator of unary, binary, assignment and increment
+// expressions. A string literal keeps its text in name.
+type expr struct {
+	kind    exprKind
+	op      string
+	postfix bool
+	name    string
+	value   int64
+	args    []*expr
+	ctype   *ctype
+	line    int
+}
+
+type stmtKind int
+
+const (
+	stmtDecl stmtKind = iota
+	stmtExpr
+	stmtIf
+	stmtWhile
+	stmtDo
+	stmtFor
+	stmtReturn
+	stmtBreak
+	stmtContinue
+	stmtBlock
+)
+
+type stmt struct {
+	kind   stmtKind
+	decls  []*decl
+	value  *expr
+	init   *stmt
+	post   *expr
+	body   *stmt
+	orElse *stmt
+	block  []*stmt
+	line   int
+}
+
+// Arrays can be initialized with a list of values or a string
+type decl struct {
+	name  string
+	ctype *ctype
+	init  *expr
+	list  []*expr
+	line  int
+}
+
+// Prototypes have no body
+type function struct {
+	name   string
+	ret    *ctype
+	params []*decl
+	body   []*stmt
+	line   int
+}
+
+type program struct {
+	globals   []*decl
+	functions []*function
+}
+
+type _parser struct {
+	tokens []token
+	pos    int
+}
+
+func (this *_parser) peek() token {
+	return this.tokens[this.pos]
+}
+
+func (this *_parser) peekAt(offset int) token {
+	if this.pos+offset < len(this.tokens) {
+		return this.tokens[this.pos+offset]
+	} else {
+		return this.tokens[len(this.tokens)-1]
+	}
+}
+
+func (this *_parser) next() token {
+	t := this.tokens[this.pos]
+	if t.Type != tokenEOF {
+		this.pos++
+	}
+	return t
+}
+
+func (this *_parser) is(text string) bool {
+	t := this.peek()
+	return (t.Type == tokenPunct || t.Type == tokenName) && t.Text == text
+}
+
+// consume the token if it is the given text
+func (this *_parser) accept(text string) bool {
+	if this.is(text) {
+		this.next()
+		return true
+	} else {
+		return false
+	}
+}
+
+func (this *_parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Error: line %d: %s", this.peek().line, fmt.Sprintf(format, args...))
+}
+
+func (this *_parser) expect(text string) error {
+	if !this.accept(text) {
+		return this.errorf("Expected %s but found %s", text, this.peek())
+	} else {
+		return nil
+	}
+}
+
+var keywords = map[string]bool{
+	"int":      true,
+	"unsigned": true,
+	"void":     true,
+	"if":       true,
+	"else":     true,
+	"while":    true,
+	"do":       true,
+	"for":      true,
+	"return":   true,
+	"break":    true,
+	"continue": true,
+}
+
+func (this *_parser) name() (string, error) {
+	if t := this.peek(); t.Type != tokenName || keywords[t.Text] {
+		return "", this.errorf("Expected a name but found %s", t)
+	} else {
+		this.next()
+		return t.Text, nil
+	}
+}
+
+func (this *_parser) startsType() bool {
+	return this.is("int") || this.is("unsigned") || this.is("void")
+}
+
+// int, unsigned, unsigned int or void
+func (this *_parser) baseType() (*ctype, error) {
+	switch {
+	case this.accept("int"):
+		return typeInt, nil
+	case this.accept("unsigned"):
+		this.accept("int")
+		return typeUnsigned, nil
+	case this.accept("void"):
+		return typeVoid, nil
+	default:
+		return nil, this.errorf("Expected a type but found %s", this.peek())
+	}
+}
+
+func (this *_parser) pointers(t *ctype) *ctype {
+	for this.accept("*") {
+		t = pointerTo(t)
+	}
+	return t
+}
+
+// A name with its pointers and an optional array length, the length may be
+// left out when an initializer gives it
+func (this *_parser) declarator(base *ctype) (*decl, error) {
+	d := &decl{ctype: this.pointers(base), line: this.peek().line}
+	var err error
+	if d.name, err = this.name(); err != nil {
+		return nil, err
+	} else if this.accept("[") {
+		d.ctype = &ctype{kind: kindArray, elem: d.ctype, length: -1}
+		if !this.is("]") {
+			if t := this.next(); t.Type != tokenNumber || t.Value < 1 {
+				return nil, this.errorf("The length of %s has to be a positive number", d.name)
+			} else {
+				d.ctype.length = int(t.Value)
+			}
+		}
+		if err := this.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func (this *_parser) initializer(d *decl) error {
+	if !this.accept("=") {
+		return nil
+	} else if d.ctype.kind == kindArray && this.accept("{") {
+		for !this.accept("}") {
+			if len(d.list) > 0 {
+				if err := this.expect(","); err != nil {
+					return err
+				} else if this.accept("}") {
+					break
+				}
+			}
+			if e, err := this.assignment(); err != nil {
+				return err
+			} else {
+				d.list = append(d.list, e)
+			}
+		}
+		if len(d.list) == 0 {
+			return this.errorf("Empty initializer for %s", d.name)
+		}
+		return nil
+	} else {
+		var err error
+		d.init, err = this.assignment()
+		return err
+	}
+}
+
+// declarations that share a base type, int a, *b = &a, c[3];
+func (this *_parser) declarations(base *ctype, first *decl) ([]*decl, error) {
+	decls := []*decl{first}
+	for d := first; ; {
+		if err := this.initializer(d); err != nil {
+			return nil, err
+		} else if !this.accept(",") {
+			return decls, this.expect(";")
+		} else if next, err := this.declarator(base); err != nil {
+			return nil, err
+		} else {
+			d = next
+			decls = append(decls, d)
+		}
+	}
+}
+
+func parse(tokens []token) (*program, error) {
+	p := _parser{tokens: tokens}
+	var prog program
+	for p.peek().Type != tokenEOF {
+		base, err := p.baseType()
+		if err != nil {
+			return nil, err
+		}
+		d, err := p.declarator(base)
+		if err != nil {
+			return nil, err
+		}
+		if p.is("(") && d.ctype.kind != kindArray {
+			if f, err := p.function(d); err != nil {
+				return nil, err
+			} else {
+				prog.functions = append(prog.functions, f)
+			}
+		} else if decls, err := p.declarations(base, d); err != nil {
+			return nil, err
+		} else {
+			prog.globals = append(prog.globals, decls...)
+		}
+	}
+	return &prog, nil
+}
+
+func (this *_parser) function(d *decl) (*function, error) {
+	f := &function{name: d.name, ret: d.ctype, line: d.line}
+	this.next()
+	if this.is("void") && this.peekAt(1).Text == ")" {
+		this.next()
+	}
+	for !this.accept(")") {
+		if len(f.params) > 0 {
+			if err := this.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		if base, err := this.baseType(); err != nil {
+			return nil, err
+		} else if param, err := this.declarator(base); err != nil {
+			return nil, err
+		} else {
+			// array parameters are pointers
+			param.ctype = param.ctype.decay()
+			f.params = append(f.params, param)
+		}
+	}
+	if this.accept(";") {
+		return f, nil
+	}
+	body, err := this.block()
+	if err == nil && body == nil {
+		body = []*stmt{}
+	}
+	f.body = body
+	return f, err
+}
+
+func (this *_parser) block() ([]*stmt, error) {
+	if err := this.expect("{"); err != nil {
+		return nil, err
+	}
+	var body []*stmt
+	for !this.accept("}") {
+		if this.peek().Type == tokenEOF {
+			return nil, this.errorf("Missing }")
+		} else if s, err := this.statement(); err != nil {
+			return nil, err
+		} else {
+			body = append(body, s)
+		}
+	}
+	return body, nil
+}
+
+func (this *_parser) statement() (*stmt, error) {
+	line := this.peek().line
+	switch {
+	case this.startsType():
+		base, err := this.baseType()
+		if err != nil {
+			return nil, err
+		} else if d, err := this.declarator(base); err != nil {
+			return nil, err
+		} else if decls, err := this.declarations(base, d); err != nil {
+			return nil, err
+		} else {
+			return &stmt{kind: stmtDecl, decls: decls, line: line}, nil
+		}
+	case this.is("{"):
+		block, err := this.block()
+		return &stmt{kind: stmtBlock, block: block, line: line}, err
+	case this.accept(";"):
+		return &stmt{kind: stmtBlock, line: line}, nil
+	case this.accept("if"):
+		s := &stmt{kind: stmtIf, line: line}
+		var err error
+		if s.value, err = this.condition(); err != nil {
+			return nil, err
+		} else if s.body, err = this.statement(); err != nil {
+			return nil, err
+		} else if this.accept("else") {
+			s.orElse, err = this.statement()
+		}
+		return s, err
+	case this.accept("while"):
+		s := &stmt{kind: stmtWhile, line: line}
+		var err error
+		if s.value, err = this.condition(); err != nil {
+			return nil, err
+		}
+		s.body, err = this.statement()
+		return s, err
+	case this.accept("do"):
+		s := &stmt{kind: stmtDo, line: line}
+		var err error
+		if s.body, err = this.statement(); err != nil {
+			return nil, err
+		} else if err := this.expect("while"); err != nil {
+			return nil, err
+		} else if s.value, err = this.condition(); err != nil {
+			return nil, err
+		}
+		return s, this.expect(";")
+	case this.accept("for"):
+		return this.forStatement(line)
+	case this.accept("return"):
+		s := &stmt{kind: stmtReturn, line: line}
+		if !this.is(";") {
+			var err error
+			if s.value, err = this.expression(); err != nil {
+				return nil, err
+			}
+		}
+		return s, this.expect(";")
+	case this.accept("break"):
+		return &stmt{kind: stmtBreak, line: line}, this.expect(";")
+	case this.accept("continue"):
+		return &stmt{kind: stmtContinue, line: line}, this.expect(";")
+	}
+	e, err := this.expression()
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{kind: stmtExpr, value: e, line: line}, this.expect(";")
+}
+
+// every part of a for loop is optional, a missing condition is always true
+func (this *_parser) forStatement(line int) (*stmt, error) {
+	s := &stmt{kind: stmtFor, line: line}
+	var err error
+	if err := this.expect("("); err != nil {
+		return nil, err
+	}
+	if !this.accept(";") {
+		if s.init, err = this.statement(); err != nil {
+			return nil, err
+		} else if s.init.kind != stmtDecl && s.init.kind != stmtExpr {
+			return nil, this.errorf("The first part of a for loop has to be a declaration or an expression")
+		}
+	}
+	if !this.is(";") {
+		if s.value, err = this.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if err := this.expect(";"); err != nil {
+		return nil, err
+	}
+	if !this.is(")") {
+		if s.post, err = this.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if err := this.expect(")"); err != nil {
+		return nil, err
+	}
+	s.body, err = this.statement()
+	return s, err
+}
+
+func (this *_parser) condition() (*expr, error) {
+	if err := this.expect("("); err != nil {
+		return nil, err
+	} else if e, err := this.expression(); err != nil {
+		return nil, err
+	} else {
+		return e, this.expect(")")
+	}
+}
+
+func (this *_parser) expression() (*expr, error) {
+	return this.assignment()
+}
+
+var assignments = map[string]bool{
+	"=": true, "+=": true, "-=": true, "*=": true, "/=": true, "%=": true,
+	"&=": true, "|=": true, "^=": true, "<<=": true, ">>=": true,
+}
+
+// assignments group to the right
+func (this *_parser) assignment() (*expr, error) {
+	lhs, err := this.conditional()
+	if err != nil {
+		return nil, err
+	} else if t := this.peek(); t.Type == tokenPunct && assignments[t.Text] {
+		this.next()
+		if rhs, err := this.assignment(); err != nil {
+			return nil, err
+		} else {
+			return &expr{kind: exprAssign, op: t.Text, args: []*expr{lhs, rhs}, line: t.line}, nil
+		}
+	} else {
+		return lhs, nil
+	}
+}
+
+func (this *_parser) conditional() (*expr, error) {
+	cond, err := this.binary(0)
+	if err != nil {
+		return nil, err
+	} else if line := this.peek().line; !this.accept("?") {
+		return cond, nil
+	} else if yes, err := this.expression(); err != nil {
+		return nil, err
+	} else if err := this.expect(":"); err != nil {
+		return nil, err
+	} else if no, err := this.conditional(); err != nil {
+		return nil, err
+	} else {
+		return &expr{kind: exprCond, args: []*expr{cond, yes, no}, line: line}, nil
+	}
+}
+
+// binary operators from the loosest binding to the tightest
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (this *_parser) binary(level int) (*expr, error) {
+	if level == len(precedence) {
+		return this.unary()
+	}
+	lhs, err := this.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		found := false
+		for _, op := range precedence[level] {
+			if t := this.peek(); t.Type == tokenPunct && t.Text == op {
+				this.next()
+				if rhs, err := this.binary(level + 1); err != nil {
+					return nil, err
+				} else {
+					lhs = &expr{kind: exprBinary, op: op, args: []*expr{lhs, rhs}, line: t.line}
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return lhs, nil
+		}
+	}
+}
+
+func (this *_parser) unary() (*expr, error) {
+	t := this.peek()
+	if t.Type == tokenPunct {
+		switch t.Text {
+		case "-", "!", "~", "*", "&":
+			this.next()
+			if e, err := this.unary(); err != nil {
+				return nil, err
+			} else {
+				return &expr{kind: exprUnary, op: t.Text, args: []*expr{e}, line: t.line}, nil
+			}
+		case "++", "--":
+			this.next()
+			if e, err := this.unary(); err != nil {
+				return nil, err
+			} else {
+				return &expr{kind: exprIncDec, op: t.Text, args: []*expr{e}, line: t.line}, nil
+			}
+		case "(":
+			// a type in parentheses is a cast
+			if next := this.peekAt(1); next.Type == tokenName && (next.Text == "int" || next.Text == "unsigned" || next.Text == "void") {
+				this.next()
+				base, err := this.baseType()
+				if err != nil {
+					return nil, err
+				}
+				to := this.pointers(base)
+				if err := this.expect(")"); err != nil {
+					return nil, err
+				} else if e, err := this.unary(); err != nil {
+					return nil, err
+				} else {
+					return &expr{kind: exprCast, ctype: to, args: []*expr{e}, line: t.line}, nil
+				}
+			}
+		}
+	}
+	return this.postfix()
+}
+
+func (this *_parser) postfix() (*expr, error) {
+	e, err := this.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		line := this.peek().line
+		switch {
+		case this.accept("["):
+			if index, err := this.expression(); err != nil {
+				return nil, err
+			} else if err := this.expect("]"); err != nil {
+				return nil, err
+			} else {
+				e = &expr{kind: exprIndex, args: []*expr{e, index}, line: line}
+			}
+		case this.is("("):
+			if e.kind != exprName {
+				return nil, this.errorf("Only functions can be called")
+			}
+			this.next()
+			call := &expr{kind: exprCall, name: e.name, line: e.line}
+			for !this.accept(")") {
+				if len(call.args) > 0 {
+					if err := this.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				if arg, err := this.assignment(); err != nil {
+					return nil, err
+				} else {
+					call.args = append(call.args, arg)
+				}
+			}
+			e = call
+		case this.is("++") || this.is("--"):
+			e = &expr{kind: exprIncDec, op: this.next().Text, postfix: true, args: []*expr{e}, line: line}
+		default:
+			return e, nil
+		}
+	}
+}
+
+func (this *_parser) primary() (*expr, error) {
+	t := this.peek()
+	switch {
+	case t.Type == tokenNumber:
+		this.next()
+		return &expr{kind: exprNumber, value: t.Value, line: t.line}, nil
+	case t.Type == tokenString:
+		this.next()
+		return &expr{kind: exprString, name: t.Text, line: t.line}, nil
+	case this.accept("("):
+		if e, err := this.expression(); err != nil {
+			return nil, err
+		} else {
+			return e, this.expect(")")
+		}
+	}
+	if name, err := this.name(); err != nil {
+		return nil, err
+	} else {
+		return &expr{kind: exprName, name: name, line: t.line}, nil
+	}
+}
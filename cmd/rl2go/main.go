@@ -0,0 +1,77 @@
+// translate iris16 memory images into go programs
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/DrItanium/cores/iris16"
+	"io/ioutil"
+	"os"
+)
+
+var input = flag.String("input", "", "iris16 memory image to translate (leave blank for stdin)")
+var output = flag.String("output", "", "go source file to generate (leave blank for stdout)")
+var pkg = flag.String("package", "main", "package name of the generated code, anything other than main omits the main function")
+
+func main() {
+	if listUsage, err, code := body(); err != nil {
+		if listUsage {
+			flag.Usage()
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(code)
+	}
+}
+
+func body() (bool, error, int) {
+	flag.Parse()
+	if *pkg == "" {
+		return true, fmt.Errorf("No package name specified"), 1
+	}
+	in := os.Stdin
+	if *input != "" {
+		if file, err := os.Open(*input); err != nil {
+			return false, err, 2
+		} else {
+			defer file.Close()
+			in = file
+		}
+	}
+	image, err := ioutil.ReadAll(in)
+	if err != nil {
+		return false, err, 2
+	}
+	core, err := iris16.New()
+	if err != nil {
+		return false, err, 3
+	}
+	data := make(chan byte, 1024)
+	go func() {
+		for _, b := range image {
+			data <- b
+		}
+		close(data)
+	}()
+	if err := core.InstallProgram(data); err != nil {
+		return false, fmt.Errorf("Couldn't install memory image: %s", err), 4
+	}
+	var out *os.File
+	if *output == "" {
+		out = os.Stdout
+	} else {
+		if file, err := os.Create(*output); err != nil {
+			return false, err, 5
+		} else {
+			defer file.Close()
+			out = file
+		}
+	}
+	w := bufio.NewWriter(out)
+	if err := core.TranslateToGo(w, *pkg); err != nil {
+		return false, err, 6
+	} else if err := w.Flush(); err != nil {
+		return false, err, 5
+	}
+	return false, nil, 0
+}
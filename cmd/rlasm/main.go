@@ -7,6 +7,7 @@ import (
 	"fmt"
 	_ "github.com/DrItanium/cores/registration"
 	"github.com/DrItanium/cores/registration/parser"
+	"io"
 	"os"
 	"strings"
 )
@@ -16,6 +17,38 @@ var input = flag.String("input", "", "input file to be processed (leave blank fo
 var output = flag.String("output", "", "output file (leave blank for stdout)")
 var listTargets = flag.Bool("list-targets", false, "display registered targets and exit")
 var debug = flag.Bool("debug", false, "enable debug")
+var exts = flag.String("extensions", "", "comma separated list of extension packs whose mnemonics should be available")
+var listing = flag.String("listing", "", "file to write an assembly listing to (leave blank for none)")
+var options = flag.String("options", "", "comma separated list of name=value options for the target")
+
+type extensionTarget interface {
+	InstallExtension(name string) error
+}
+
+type optionTarget interface {
+	SetOption(name, value string) error
+}
+
+type listingTarget interface {
+	WriteListing(w io.Writer) error
+}
+
+func writeListing(p parser.Parser) error {
+	if *listing == "" {
+		return nil
+	} else if l, ok := p.(listingTarget); !ok {
+		return fmt.Errorf("Target %s can't produce listings!", *target)
+	} else if file, err := os.Create(*listing); err != nil {
+		return err
+	} else {
+		defer file.Close()
+		w := bufio.NewWriter(file)
+		if err := l.WriteListing(w); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+}
 
 func listRegisteredTargets() {
 	fmt.Fprintln(os.Stderr, "Supported targets: ")
@@ -74,6 +107,30 @@ func body() (bool, bool, error, int) {
 		if p, err := parser.New(*target); err != nil {
 			return false, false, err, 6
 		} else {
+			if *exts != "" {
+				if e, ok := p.(extensionTarget); !ok {
+					return false, false, fmt.Errorf("Target %s does not support extensions!", *target), 10
+				} else {
+					for _, name := range strings.Split(*exts, ",") {
+						if err := e.InstallExtension(strings.TrimSpace(name)); err != nil {
+							return false, false, err, 10
+						}
+					}
+				}
+			}
+			if *options != "" {
+				if opt, ok := p.(optionTarget); !ok {
+					return false, false, fmt.Errorf("Target %s does not take any options!", *target), 11
+				} else {
+					for _, option := range strings.Split(*options, ",") {
+						if kv := strings.SplitN(strings.TrimSpace(option), "=", 2); len(kv) != 2 {
+							return false, false, fmt.Errorf("Option %s is not of the form name=value", option), 11
+						} else if err := opt.SetOption(kv[0], kv[1]); err != nil {
+							return false, false, err, 11
+						}
+					}
+				}
+			}
 			c, e, e2, e3, b := make(chan parser.Entry, 1024), make(chan error), make(chan error), make(chan error), make(chan byte, 512)
 			// scanner goroutine
 			go func(scanner *bufio.Scanner, c chan parser.Entry, e chan error) {
@@ -116,6 +173,8 @@ func body() (bool, bool, error, int) {
 					e <- err
 				} else if err := p.Process(); err != nil {
 					e <- err
+				} else if err := writeListing(p); err != nil {
+					e <- err
 				} else if err := p.Dump(o); err != nil {
 					e <- err
 				} else {
@@ -0,0 +1,54 @@
+// compiler for a subset of C targeting iris16
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/DrItanium/cores/cc"
+	"io/ioutil"
+	"os"
+)
+
+var input = flag.String("input", "", "input file to be processed (leave blank for stdin)")
+var output = flag.String("output", "", "output file (leave blank for stdout)")
+var assembly = flag.Bool("S", false, "write the generated assembly instead of an image")
+
+func main() {
+	if err, code := body(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(code)
+	}
+}
+
+func body() (error, int) {
+	flag.Parse()
+	var src []byte
+	var err error
+	if *input == "" {
+		src, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		src, err = ioutil.ReadFile(*input)
+	}
+	if err != nil {
+		return err, 4
+	}
+	asm, err := cc.Compile(string(src))
+	if err != nil {
+		return err, 9
+	}
+	result := []byte(asm)
+	if !*assembly {
+		if result, err = cc.Assemble(asm); err != nil {
+			return err, 9
+		}
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(result)
+	} else {
+		err = ioutil.WriteFile(*output, result, 0644)
+	}
+	if err != nil {
+		return err, 5
+	}
+	return nil, 0
+}
@@ -0,0 +1,51 @@
+// metacompiler for the iris16 forth system
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/DrItanium/cores/forth"
+	"io/ioutil"
+	"os"
+)
+
+var input = flag.String("input", "", "forth source to compile into the image after the system words (leave blank for none)")
+var output = flag.String("output", "", "output file (leave blank for stdout)")
+var assembly = flag.Bool("S", false, "write the generated assembly instead of an image")
+
+func main() {
+	if err, code := body(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(code)
+	}
+}
+
+func body() (error, int) {
+	flag.Parse()
+	var src []byte
+	var err error
+	if *input != "" {
+		if src, err = ioutil.ReadFile(*input); err != nil {
+			return err, 4
+		}
+	}
+	asm, err := forth.Metacompile(string(src))
+	if err != nil {
+		return err, 9
+	}
+	result := []byte(asm)
+	if !*assembly {
+		if result, err = forth.Assemble(asm); err != nil {
+			return err, 9
+		}
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(result)
+	} else {
+		err = ioutil.WriteFile(*output, result, 0644)
+	}
+	if err != nil {
+		return err, 5
+	}
+	return nil, 0
+}
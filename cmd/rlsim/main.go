@@ -6,14 +6,42 @@ import (
 	"fmt"
 	_ "github.com/DrItanium/cores/registration"
 	"github.com/DrItanium/cores/registration/machine"
+	"github.com/DrItanium/cores/trace"
+	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 )
 
 var target = flag.String("target", "", "Target machine to simulate")
 var listTargets = flag.Bool("list-targets", false, "List supported machines and exit")
 var input = flag.String("input", "", "input file to be processed (leave blank for stdin)")
 var debug = flag.Bool("debug", false, "enable/disable debugging")
+var gdb = flag.String("gdb", "", "wait for a gdb connection on the given host:port or unix socket path")
+var engine = flag.String("engine", "", "execution engine to use (leave blank for the target's default)")
+var exts = flag.String("extensions", "", "comma separated list of extension packs to install into the target")
+var dot = flag.String("dot", "", "write the datapath of the target as a graphviz graph to the given file")
+var options = flag.String("options", "", "comma separated list of name=value options for the target")
+var vcd = flag.String("vcd", "", "record every value sent between the units of the target and write it to the given file as a vcd waveform")
+
+type gdbTarget interface {
+	ServeGdb(address string) error
+}
+type engineTarget interface {
+	SetEngine(name string) error
+}
+type extensionTarget interface {
+	InstallExtension(name string) error
+}
+type optionTarget interface {
+	SetOption(name, value string) error
+}
+type dotTarget interface {
+	WriteDot(w io.Writer) error
+}
+type traceTarget interface {
+	Trace(recorder *trace.Recorder) error
+}
 
 func listRegisteredTargets() {
 	fmt.Fprintln(os.Stderr, "Supported targets: ")
@@ -59,6 +87,37 @@ func body() (bool, bool, error, int) {
 		if mach, err0 := machine.New(*target); err0 != nil {
 			return false, false, err0, 5
 		} else {
+			if *engine != "" {
+				if e, ok := mach.(engineTarget); !ok {
+					return false, false, fmt.Errorf("Target %s does not support alternate execution engines!", *target), 10
+				} else if err := e.SetEngine(*engine); err != nil {
+					return false, false, err, 10
+				}
+			}
+			if *exts != "" {
+				if e, ok := mach.(extensionTarget); !ok {
+					return false, false, fmt.Errorf("Target %s does not support extensions!", *target), 11
+				} else {
+					for _, name := range strings.Split(*exts, ",") {
+						if err := e.InstallExtension(strings.TrimSpace(name)); err != nil {
+							return false, false, err, 11
+						}
+					}
+				}
+			}
+			if *options != "" {
+				if o, ok := mach.(optionTarget); !ok {
+					return false, false, fmt.Errorf("Target %s does not take any options!", *target), 14
+				} else {
+					for _, option := range strings.Split(*options, ",") {
+						if kv := strings.SplitN(strings.TrimSpace(option), "=", 2); len(kv) != 2 {
+							return false, false, fmt.Errorf("Option %s is not of the form name=value", option), 14
+						} else if err := o.SetOption(kv[0], kv[1]); err != nil {
+							return false, false, err, 14
+						}
+					}
+				}
+			}
 			// install the program
 			done, done2 := make(chan error), make(chan error)
 			data := make(chan byte, 1024)
@@ -79,12 +138,52 @@ func body() (bool, bool, error, int) {
 					}
 				}
 			}
+			if *dot != "" {
+				if d, ok := mach.(dotTarget); !ok {
+					return false, false, fmt.Errorf("Target %s can't describe its datapath!", *target), 12
+				} else if err := writeFile(*dot, d.WriteDot); err != nil {
+					return false, false, err, 12
+				}
+			}
+			var recorder *trace.Recorder
+			if *vcd != "" {
+				// values go straight to the file since a long run records far
+				// more of them than should be kept in memory
+				if t, ok := mach.(traceTarget); !ok {
+					return false, false, fmt.Errorf("Target %s does not support tracing!", *target), 13
+				} else if file, err := os.Create(*vcd); err != nil {
+					return false, false, err, 13
+				} else {
+					defer file.Close()
+					recorder = trace.NewRecorder()
+					if err := t.Trace(recorder); err != nil {
+						return false, false, err, 13
+					} else if err := recorder.Stream(file, *target); err != nil {
+						return false, false, err, 13
+					}
+					// a failed run is when the recording matters most so
+					// whatever was recorded is written out on every path
+					defer recorder.Flush()
+				}
+			}
 			mach.Startup()
-			if err := mach.Run(); err != nil {
+			if *gdb != "" {
+				if dbg, ok := mach.(gdbTarget); !ok {
+					return false, false, fmt.Errorf("Target %s does not support gdb debugging!", *target), 9
+				} else if err := dbg.ServeGdb(*gdb); err != nil {
+					return false, false, fmt.Errorf("Something went wrong during the gdb session: %s!", err), 8
+				}
+			} else if err := mach.Run(); err != nil {
 				fmt.Printf("Something went wrong during machine execution: %s!", err)
+				mach.Shutdown()
 				return false, false, fmt.Errorf("Something went wrong during machine execution: %s!", err), 8
 			}
 			mach.Shutdown()
+			if recorder != nil {
+				if err := recorder.Flush(); err != nil {
+					return false, false, err, 13
+				}
+			}
 		}
 		return false, false, nil, 0
 	}
@@ -104,3 +203,14 @@ func loadMemoryImage(file *os.File, done chan error, data chan byte) {
 		done <- err
 	}(file, done, data)
 }
+
+func writeFile(path string, fn func(io.Writer) error) error {
+	if file, err := os.Create(path); err != nil {
+		return err
+	} else if err := fn(file); err != nil {
+		file.Close()
+		return err
+	} else {
+		return file.Close()
+	}
+}
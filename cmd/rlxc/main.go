@@ -0,0 +1,79 @@
+// compiler for the xc language
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/DrItanium/cores/xand"
+	"github.com/DrItanium/cores/xc"
+	"io/ioutil"
+	"os"
+)
+
+var target = flag.String("target", "xand16", "xand target to compile for")
+var input = flag.String("input", "", "input file to be processed (leave blank for stdin)")
+var output = flag.String("output", "", "output file (leave blank for stdout)")
+var listTargets = flag.Bool("list-targets", false, "display supported targets and exit")
+var assembly = flag.Bool("S", false, "write the generated assembly instead of an image")
+
+func listSupportedTargets() {
+	fmt.Fprintln(os.Stderr, "Supported targets: ")
+	for name := range xand.Targets {
+		fmt.Fprintln(os.Stderr, "\t - ", name)
+	}
+}
+
+func main() {
+	if listTargets, listUsage, err, code := body(); err != nil {
+		if listUsage {
+			flag.Usage()
+		}
+		if listTargets {
+			listSupportedTargets()
+		}
+		if str := err.Error(); len(str) > 0 {
+			fmt.Fprintln(os.Stderr, str)
+		}
+		os.Exit(code)
+	}
+}
+
+func body() (bool, bool, error, int) {
+	flag.Parse()
+	if *listTargets {
+		return true, false, fmt.Errorf(""), 1
+	}
+	config, ok := xand.Targets[*target]
+	if !ok {
+		return true, true, fmt.Errorf("%s is not a supported target!", *target), 3
+	}
+	var src []byte
+	var err error
+	if *input == "" {
+		src, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		src, err = ioutil.ReadFile(*input)
+	}
+	if err != nil {
+		return false, false, err, 4
+	}
+	asm, err := xc.Compile(string(src), config)
+	if err != nil {
+		return false, false, err, 9
+	}
+	result := []byte(asm)
+	if !*assembly {
+		if result, err = xc.Assemble(asm, *target); err != nil {
+			return false, false, err, 9
+		}
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(result)
+	} else {
+		err = ioutil.WriteFile(*output, result, 0644)
+	}
+	if err != nil {
+		return false, false, err, 5
+	}
+	return false, false, nil, 0
+}
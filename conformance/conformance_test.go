@@ -0,0 +1,418 @@
+package conformance
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/DrItanium/cores"
+	"github.com/DrItanium/cores/iris16"
+	"github.com/DrItanium/cores/iris2"
+	_ "github.com/DrItanium/cores/registration"
+	"github.com/DrItanium/cores/registration/machine"
+	"github.com/DrItanium/cores/registration/parser"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// how to find things in the memory image of a target
+type layout struct {
+	// word size in bytes
+	width int
+	// are words sign extended
+	signed bool
+	// offset of each segment in the image in words, "" is used for targets with a single segment
+	segments map[string]int
+	// directory under testdata with programs shared by a family of targets
+	shared string
+}
+
+var iris16Segments = map[string]int{
+	"data":      iris16.MemorySize * 2,
+	"microcode": iris16.MemorySize * 3,
+	"stack":     iris16.MemorySize * 4,
+	"procedure": iris16.MemorySize * 5,
+}
+
+var layouts = map[string]layout{
+	// iris2 instructions are a single word wide
+	"iris2": {width: 8, signed: true, segments: map[string]int{
+		"data":      iris2.MemorySize,
+		"microcode": iris2.MemorySize * 2,
+		"stack":     iris2.MemorySize * 3,
+		"procedure": iris2.MemorySize * 4,
+	}},
+	"iris16":          {width: 2, segments: iris16Segments},
+	"iris16-ext":      {width: 2, segments: iris16Segments},
+	"iris16-smp":      {width: 2, segments: iris16Segments},
+	"iris16-smp-free": {width: 2, segments: iris16Segments},
+	"xand":            {width: 1, signed: true, segments: map[string]int{"": 0}, shared: "xand-common"},
+	"xand16":          {width: 2, signed: true, segments: map[string]int{"": 0}, shared: "xand-common"},
+	"xand32":          {width: 4, signed: true, segments: map[string]int{"": 0}, shared: "xand-common"},
+	"xand64":          {width: 8, signed: true, segments: map[string]int{"": 0}, shared: "xand-common"},
+	"xand8":           {width: 1, signed: true, segments: map[string]int{"": 0}, shared: "xand-common"},
+}
+
+type registerTarget interface {
+	Register(index byte) iris16.Word
+}
+type wideRegisterTarget interface {
+	Register(index byte) iris2.Word
+}
+type outputTarget interface {
+	SetOutput(w io.Writer)
+}
+type inputTarget interface {
+	SetInput(r io.Reader)
+}
+
+type expectation struct {
+	line   int
+	kind   string
+	seg    string
+	index  int64
+	value  int64
+	output string
+}
+
+type program struct {
+	path    string
+	source  []string
+	expects []expectation
+	fails   bool
+	input   string
+}
+
+func parseNumber(str string) (int64, error) {
+	return strconv.ParseInt(strings.TrimPrefix(str, "r"), 0, 64)
+}
+
+func parseExpectation(line int, text string) (expectation, error) {
+	e := expectation{line: line}
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return e, fmt.Errorf("empty expectation")
+	}
+	e.kind = fields[0]
+	var err error
+	switch {
+	case e.kind == "output":
+		e.output, err = strconv.Unquote(strings.TrimSpace(strings.TrimPrefix(text, "output")))
+	case e.kind == "register" && len(fields) == 4 && fields[2] == "=":
+		if e.index, err = parseNumber(fields[1]); err == nil {
+			e.value, err = parseNumber(fields[3])
+		}
+	case e.kind == "memory" && len(fields) == 4 && fields[2] == "=":
+		if e.index, err = parseNumber(fields[1]); err == nil {
+			e.value, err = parseNumber(fields[3])
+		}
+	case e.kind == "memory" && len(fields) == 5 && fields[3] == "=":
+		e.seg = fields[1]
+		if e.index, err = parseNumber(fields[2]); err == nil {
+			e.value, err = parseNumber(fields[4])
+		}
+	default:
+		err = fmt.Errorf("malformed expectation %q", text)
+	}
+	return e, err
+}
+
+func loadProgram(path string) (*program, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	p := &program{path: path}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		p.source = append(p.source, text)
+		if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, ";!") {
+			if body := strings.TrimSpace(trimmed[2:]); body == "error" {
+				p.fails = true
+			} else if strings.HasPrefix(body, "input ") {
+				if p.input, err = strconv.Unquote(strings.TrimSpace(strings.TrimPrefix(body, "input"))); err != nil {
+					return nil, fmt.Errorf("%s:%d: %s", path, line, err)
+				}
+			} else if e, err := parseExpectation(line, body); err != nil {
+				return nil, fmt.Errorf("%s:%d: %s", path, line, err)
+			} else {
+				p.expects = append(p.expects, e)
+			}
+		}
+	}
+	return p, scanner.Err()
+}
+
+func dump(d cores.Dumper) ([]byte, error) {
+	out := make(chan byte, 4096)
+	done := make(chan []byte)
+	go func() {
+		var b []byte
+		for v := range out {
+			b = append(b, v)
+		}
+		done <- b
+	}()
+	err := d.Dump(out)
+	close(out)
+	return <-done, err
+}
+
+func assemble(target string, p *program) ([]byte, error) {
+	asm, err := parser.New(target)
+	if err != nil {
+		return nil, err
+	}
+	lines := make(chan parser.Entry, len(p.source))
+	for i, line := range p.source {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			lines <- parser.Entry{Line: line, Index: i + 1}
+		}
+	}
+	close(lines)
+	if err := asm.Parse(lines); err != nil {
+		return nil, err
+	} else if err := asm.Process(); err != nil {
+		return nil, err
+	} else {
+		return dump(asm)
+	}
+}
+
+func (this layout) word(image []byte, seg string, index int64) (int64, error) {
+	base, ok := this.segments[seg]
+	if !ok {
+		return 0, fmt.Errorf("unknown segment %q", seg)
+	}
+	offset := (int64(base) + index) * int64(this.width)
+	if index < 0 || offset+int64(this.width) > int64(len(image)) {
+		return 0, fmt.Errorf("address %d is outside of the %q segment", index, seg)
+	}
+	var value uint64
+	for i := this.width - 1; i >= 0; i-- {
+		value = value<<8 | uint64(image[offset+int64(i)])
+	}
+	if shift := uint(64 - 8*this.width); this.signed {
+		return int64(value<<shift) >> shift, nil
+	} else {
+		return int64(value), nil
+	}
+}
+
+func run(t *testing.T, target string, p *program) {
+	image, err := assemble(target, p)
+	if err != nil {
+		t.Fatalf("Assembly failed: %s", err)
+	}
+	m, err := machine.New(target)
+	if err != nil {
+		t.Fatalf("Couldn't construct %s machine: %s", target, err)
+	}
+	input := make(chan byte, len(image))
+	for _, b := range image {
+		input <- b
+	}
+	close(input)
+	if err := m.InstallProgram(input); err != nil {
+		t.Fatalf("Couldn't install program: %s", err)
+	}
+	var console bytes.Buffer
+	if o, ok := m.(outputTarget); ok {
+		o.SetOutput(&console)
+	}
+	// programs never get to see the input of the test itself
+	if i, ok := m.(inputTarget); ok {
+		i.SetInput(strings.NewReader(p.input))
+	} else if p.input != "" {
+		t.Fatalf("%s has no console input", target)
+	}
+	if err := m.Startup(); err != nil {
+		t.Fatalf("Startup failed: %s", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	select {
+	case err := <-done:
+		if err != nil && !p.fails {
+			t.Fatalf("Execution failed: %s", err)
+		} else if err == nil && p.fails {
+			t.Fatalf("Execution was expected to fail")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Program did not terminate")
+	}
+	final, err := dump(m)
+	if err != nil {
+		t.Fatalf("Couldn't dump the final memory image: %s", err)
+	}
+	for _, e := range p.expects {
+		switch e.kind {
+		case "output":
+			if got := console.String(); got != e.output {
+				t.Errorf("line %d: expected output %q but got %q", e.line, e.output, got)
+			}
+		case "register":
+			var got int64
+			if r, ok := m.(registerTarget); ok {
+				got = int64(r.Register(byte(e.index)))
+			} else if r, ok := m.(wideRegisterTarget); ok {
+				got = int64(r.Register(byte(e.index)))
+			} else {
+				t.Errorf("line %d: %s has no registers to check", e.line, target)
+				continue
+			}
+			if got != e.value {
+				t.Errorf("line %d: expected r%d to be %d but it is %d", e.line, e.index, e.value, got)
+			}
+		case "memory":
+			if got, err := layouts[target].word(final, e.seg, e.index); err != nil {
+				t.Errorf("line %d: %s", e.line, err)
+			} else if got != e.value {
+				t.Errorf("line %d: expected %s[%d] to be %d but it is %d", e.line, e.seg, e.index, e.value, got)
+			}
+		}
+	}
+	if err := m.Shutdown(); err != nil {
+		t.Errorf("Shutdown failed: %s", err)
+	}
+}
+
+func programs(t *testing.T, target string) []*program {
+	paths, err := filepath.Glob(filepath.Join("testdata", target, "*.asm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared := layouts[target].shared; shared != "" {
+		if more, err := filepath.Glob(filepath.Join("testdata", shared, "*.asm")); err != nil {
+			t.Fatal(err)
+		} else {
+			paths = append(paths, more...)
+		}
+	}
+	if len(paths) == 0 {
+		t.Fatalf("No conformance programs found for %s", target)
+	}
+	var progs []*program
+	for _, path := range paths {
+		if p, err := loadProgram(path); err != nil {
+			t.Fatal(err)
+		} else {
+			progs = append(progs, p)
+		}
+	}
+	return progs
+}
+
+func Test_Conformance(t *testing.T) {
+	for target := range layouts {
+		for _, p := range programs(t, target) {
+			p := p
+			t.Run(target+"/"+filepath.Base(p.path), func(t *testing.T) {
+				run(t, target, p)
+			})
+		}
+	}
+}
+
+// jump op encodings: call = 1, return = 2, conditional = 4, if then else = 8, immediate = 16
+var iris16JumpForms = map[byte]string{
+	0x00: "branch register",
+	0x10: "branch immediate",
+	0x01: "call register",
+	0x11: "call immediate",
+	0x04: "conditional branch register",
+	0x14: "conditional branch immediate",
+	0x05: "conditional call register",
+	0x15: "conditional call immediate",
+	0x02: "return",
+	0x06: "conditional return",
+	0x08: "if then else branch",
+	0x09: "if then else call",
+}
+
+// Make sure every operation iris16 defines shows up in at least one of the
+// conformance programs
+func Test_Iris16Coverage(t *testing.T) {
+	seen := make(map[[2]byte]bool)
+	for _, p := range programs(t, "iris16") {
+		image, err := assemble("iris16", p)
+		if err != nil {
+			t.Fatalf("%s: %s", p.path, err)
+		}
+		for i := 0; i < iris16.MemorySize; i++ {
+			raw := iris16.Instruction(uint32(image[i*4]) | uint32(image[i*4+1])<<8 | uint32(image[i*4+2])<<16 | uint32(image[i*4+3])<<24)
+			if raw == 0 {
+				continue
+			} else if di, err := raw.Decode(); err == nil {
+				seen[[2]byte{di.Group, di.Op}] = true
+			}
+		}
+	}
+	groups := []struct {
+		name  string
+		group byte
+		count int
+	}{
+		{"arithmetic", iris16.InstructionGroupArithmetic, iris16.ArithmeticOpCount},
+		{"move", iris16.InstructionGroupMove, iris16.MoveOpCount},
+		{"compare", iris16.InstructionGroupCompare, iris16.CompareOpCount},
+	}
+	for _, g := range groups {
+		for op := 0; op < g.count; op++ {
+			if !seen[[2]byte{g.group, byte(op)}] {
+				t.Errorf("%s op %d is not covered by any conformance program", g.name, op)
+			}
+		}
+	}
+	for op, name := range iris16JumpForms {
+		if !seen[[2]byte{iris16.InstructionGroupJump, op}] {
+			t.Errorf("jump form %s is not covered by any conformance program", name)
+		}
+	}
+}
+
+// Both xand cores run the same image, the difference is the cost of the
+// channel units in xand8. Every iteration builds a fresh machine since the
+// program rewrites itself.
+func Benchmark_XandThroughput(b *testing.B) {
+	p, err := loadProgram(filepath.Join("testdata", "xand-common", "multiply.asm"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	image, err := assemble("xand", p)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, target := range []string{"xand", "xand8"} {
+		b.Run(target, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m, err := machine.New(target)
+				if err != nil {
+					b.Fatal(err)
+				}
+				input := make(chan byte, len(image))
+				for _, v := range image {
+					input <- v
+				}
+				close(input)
+				if err := m.InstallProgram(input); err != nil {
+					b.Fatal(err)
+				} else if err := m.Startup(); err != nil {
+					b.Fatal(err)
+				} else if err := m.Run(); err != nil {
+					b.Fatal(err)
+				} else if err := m.Shutdown(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
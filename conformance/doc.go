@@ -0,0 +1,17 @@
+// Package conformance holds assembly level conformance tests for every target.
+//
+// Each target has a directory under testdata full of small assembly programs.
+// Programs that behave the same on every width of xand live in
+// testdata/xand-common and are run against each of the xand targets.
+// The expected results of a program are declared in its header with comment
+// lines starting with ";!":
+//
+//	;! register r6 = 5           final value of a register
+//	;! memory data 0x10 = 7      final value of a memory cell, the segment is only
+//	                             needed on targets with more than one segment
+//	;! output "A\n"              everything written to the console
+//	;! input "abc"               what the program reads from the console
+//	;! error                     execution must fail
+//
+// The programs are assembled with parser.New and executed with machine.New.
+package conformance
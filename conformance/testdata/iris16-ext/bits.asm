@@ -0,0 +1,20 @@
+; the bit manipulation extension
+;! register r10 = 8
+;! register r11 = 8
+;! register r12 = 4
+;! register r13 = 24
+;! register r14 = 6144
+;! register r15 = 13330
+.code
+set r6 = #xF0F0
+popcount r10 = r6
+set r6 = #xF0
+clz r11 = r6
+ctz r12 = r6
+set r6 = #x8001
+set r7 = #4
+rotl r13 = r6, r7
+rotr r14 = r6, r7
+set r6 = #x1234
+bswap r15 = r6
+system #0, r0, r0
@@ -0,0 +1,13 @@
+; macros and extension mnemonics side by side
+;! register r10 = 3
+;! register r11 = 2
+.microcode
+.macro plus
+add a = b, c
+.endmacro
+.code
+set r6 = #1
+set r7 = #2
+plus r10 = r6, r7
+popcount r11 = r10
+system #0, r0, r0
@@ -0,0 +1,10 @@
+; the random number extension is deterministic for a given seed
+;! register r10 = 13600
+;! register r11 = 55154
+.code
+set r6 = #xFF00
+set r7 = #42
+store r6 = r7, io
+system #32, r10, r10
+load r11 = r6, io
+system #0, r0, r0
@@ -0,0 +1,21 @@
+; every core increments a shared counter fifty times while holding semaphore 0,
+; the first core makes the semaphore available to begin with
+;! memory data 0 = 200
+.code
+system #3, r6, r6
+set r7 = #16
+ne r8 = r6, r0
+branch start if r8
+store r7 = r1, io
+start: set r9 = #50
+acquire: load r10 = r7, io
+eq r11 = r10, r0
+branch acquire if r11
+load r12 = r0
+incr r12 = r12
+store r0 = r12
+store r7 = r1, io
+decr r9 = r9
+ne r11 = r9, r0
+branch acquire if r11
+system #0, r0, r0
@@ -0,0 +1,14 @@
+; every core prints its id and saves it into the shared data segment, round
+; robin scheduling interleaves the cores one instruction at a time
+;! output "01230123"
+;! memory data 0 = 0
+;! memory data 1 = 1
+;! memory data 2 = 2
+;! memory data 3 = 3
+.code
+system #3, r6, r6
+add r7 = r6, #48
+system #2, r7, r7
+system #2, r7, r7
+store r6 = r6
+system #0, r0, r0
@@ -0,0 +1,26 @@
+; every core but the first sends ten times its id to the first which adds them up
+;! memory data 0 = 60
+.code
+system #3, r6, r6
+set r7 = #1
+set r8 = #2
+set r9 = #3
+eq r10 = r6, r0
+branch collect if r10
+mul r11 = r6, #10
+; select core 0 as the target and send
+store r0 = r0, io
+store r7 = r11, io
+system #0, r0, r0
+collect: system #4, r12, r12
+decr r12 = r12
+wait: load r13 = r8, io
+ne r10 = r13, r12
+branch wait if r10
+loop: load r14 = r9, io
+add r15 = r15, r14
+decr r12 = r12
+ne r10 = r12, r0
+branch loop if r10
+store r0 = r15
+system #0, r0, r0
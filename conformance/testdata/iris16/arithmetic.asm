@@ -0,0 +1,42 @@
+; register forms of every arithmetic operation
+;! register r10 = 12
+;! register r11 = 2
+;! register r12 = 35
+;! register r13 = 1
+;! register r14 = 2
+;! register r15 = 224
+;! register r16 = 7
+;! register r17 = 5
+;! register r18 = 7
+;! register r19 = 2
+;! register r20 = 65528
+;! register r21 = 8
+;! register r22 = 6
+;! register r23 = 14
+;! register r24 = 3
+;! register r25 = 65534
+;! register r26 = 0
+.code
+set r6 = #7
+set r7 = #5
+add r10 = r6, r7
+sub r11 = r6, r7
+mul r12 = r6, r7
+div r13 = r6, r7
+rem r14 = r6, r7
+shiftleft r15 = r6, r7
+shiftright r16 = r15, r7
+and r17 = r6, r7
+or r18 = r6, r7
+xor r19 = r6, r7
+not r20 = r6
+incr r21 = r6
+decr r22 = r6
+double r23 = r6
+halve r24 = r6
+; unsigned wrap around
+sub r25 = r7, r6
+; everything is shifted out
+set r8 = #16
+shiftleft r26 = r6, r8
+system #0, r0, r0
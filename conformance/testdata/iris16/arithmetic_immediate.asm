@@ -0,0 +1,20 @@
+; immediate forms of the arithmetic operations, immediates are 8 bits wide
+;! register r10 = 1010
+;! register r11 = 990
+;! register r12 = 3000
+;! register r13 = 333
+;! register r14 = 1
+;! register r15 = 16000
+;! register r16 = 62
+;! register r17 = 1255
+.code
+set r6 = #1000
+add r10 = r6, #10
+sub r11 = r6, #10
+mul r12 = r6, #3
+div r13 = r6, #3
+rem r14 = r6, #3
+shiftleft r15 = r6, #4
+shiftright r16 = r6, #4
+add r17 = r6, #255
+system #0, r0, r0
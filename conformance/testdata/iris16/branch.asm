@@ -0,0 +1,37 @@
+; the branch forms, r20 collects a bit for every branch that behaved correctly
+;! register r20 = 63
+;! register r21 = 0
+.code
+set r10 = #1
+set r11 = #0
+branch one
+set r21 = #1
+one: or r20 = r20, r1
+set r6 = two
+branch r6
+set r21 = #1
+two: set r7 = #2
+or r20 = r20, r7
+branch three if r10
+set r21 = #1
+three: set r7 = #4
+or r20 = r20, r7
+branch fail if r11
+set r7 = #8
+or r20 = r20, r7
+set r6 = four
+branch r6 if r10
+set r21 = #1
+four: set r8 = five
+set r9 = fail
+branch if r10 then r8 else r9
+five: set r7 = #16
+or r20 = r20, r7
+set r8 = fail
+set r9 = six
+branch if r11 then r8 else r9
+six: set r7 = #32
+or r20 = r20, r7
+system #0, r0, r0
+fail: set r21 = #1
+system #0, r0, r0
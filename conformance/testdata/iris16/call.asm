@@ -0,0 +1,37 @@
+; the call and return forms, r20 collects a bit for every procedure that ran
+;! register r20 = 31
+;! register r21 = 0
+;! register r5 = 65535
+.code
+set r10 = #1
+set r11 = #0
+call first
+set r6 = second
+call r6
+call third if r10
+call fail if r11
+set r6 = fourth
+call r6 if r10
+set r8 = fifth
+set r9 = fail
+call if r10 then r8 else r9
+system #0, r0, r0
+first: or r20 = r20, r1
+return
+second: set r7 = #2
+or r20 = r20, r7
+return if r10
+set r21 = #1
+return
+third: set r7 = #4
+or r20 = r20, r7
+return if r11
+return
+fourth: set r7 = #8
+or r20 = r20, r7
+return
+fifth: set r7 = #16
+or r20 = r20, r7
+return
+fail: set r21 = #1
+return
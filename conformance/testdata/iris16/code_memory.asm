@@ -0,0 +1,13 @@
+; read an instruction out of the code segment and write it somewhere else
+;! register r7 = 2577
+;! register r8 = 42
+;! register r10 = 42
+.code
+set r6 = template
+load r6 = r7, r8, code
+set r6 = slot
+store r6 = r7, r8, code
+slot: system #0, r0, r0
+system #0, r0, r0
+template: set r10 = #42
+system #0, r0, r0
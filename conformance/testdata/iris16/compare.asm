@@ -0,0 +1,66 @@
+; every compare operation, r6 = 3 and r7 = 5
+; destinations of & and ^ forms start out as 1, the | forms start out as 0
+;! register r10 = 1
+;! register r11 = 1
+;! register r12 = 1
+;! register r13 = 0
+;! register r14 = 0
+;! register r15 = 0
+;! register r16 = 1
+;! register r17 = 1
+;! register r18 = 1
+;! register r19 = 1
+;! register r20 = 0
+;! register r21 = 0
+;! register r22 = 0
+;! register r23 = 0
+;! register r24 = 1
+;! register r25 = 1
+;! register r26 = 1
+;! register r27 = 1
+;! register r28 = 0
+;! register r29 = 0
+;! register r30 = 1
+;! register r31 = 1
+;! register r32 = 0
+;! register r33 = 0
+.code
+set r6 = #3
+set r7 = #5
+set r11 = #1
+set r13 = #1
+set r15 = #1
+set r17 = #1
+set r19 = #1
+set r21 = #1
+set r23 = #1
+set r25 = #1
+set r27 = #1
+set r29 = #1
+set r31 = #1
+set r33 = #1
+eq r10 = r6, r6
+eq r11 & r6, r6
+eq r12 | r6, r6
+eq r13 ^ r6, r6
+ne r14 = r6, r6
+ne r15 & r6, r6
+ne r16 | r6, r7
+ne r17 ^ r6, r6
+lt r18 = r6, r7
+lt r19 & r6, r7
+lt r20 | r7, r6
+lt r21 ^ r6, r7
+gt r22 = r6, r7
+gt r23 & r6, r7
+gt r24 | r7, r6
+gt r25 ^ r6, r7
+le r26 = r6, r6
+le r27 & r6, r7
+le r28 | r7, r6
+le r29 ^ r6, r6
+ge r30 = r7, r6
+ge r31 & r6, r6
+ge r32 | r6, r7
+ge r33 ^ r6, r6
+system #0, r0, r0
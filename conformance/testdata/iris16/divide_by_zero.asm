@@ -0,0 +1,8 @@
+; dividing by zero stops the machine
+;! error
+;! register r6 = 9
+.code
+set r6 = #9
+div r7 = r6, r0
+set r6 = #10
+system #0, r0, r0
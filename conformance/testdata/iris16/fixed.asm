@@ -0,0 +1,20 @@
+; Q8.8 fixed point multiply and divide
+;! register r10 = 864
+;! register r11 = 64672
+;! register r12 = 0
+;! register r13 = 1
+;! register r14 = 384
+;! register r15 = 65280
+.code
+set r6 = #x0180
+set r7 = #x0240
+set r8 = #xFE80
+set r9 = #x0001
+set r16 = #x0080
+qmul r10 = r6, r7
+qmul r11 = r8, r7
+qmul r12 = r9, r9
+qmul r13 = r16, r9
+qdiv r14 = r7, r6
+qdiv r15 = r8, r6
+system #0, r0, r0
@@ -0,0 +1,59 @@
+; half precision floating point, every result is rounded to nearest even. The
+; binary operations are two address so each result starts as a copy of its
+; first source
+;! register r10 = 16896
+;! register r11 = 48128
+;! register r12 = 17408
+;! register r13 = 14336
+;! register r14 = 13653
+;! register r15 = 1
+;! register r16 = 0
+;! register r17 = 1
+;! register r18 = 51456
+;! register r19 = 0
+;! register r20 = 65534
+;! register r21 = 15872
+;! register r22 = 64832
+;! register r23 = 31744
+;! register r24 = 32256
+;! register r25 = 0
+;! register r26 = 13516
+.code
+set r6 = #x3C00
+set r7 = #x4000
+move r10 = r6
+fadd r10 = r10, r7
+move r11 = r6
+fsub r11 = r11, r7
+move r12 = r7
+fmul r12 = r12, r7
+move r13 = r6
+fdiv r13 = r13, r7
+move r14 = r6
+fdiv r14 = r14, r10
+move r15 = r6
+feq r15 = r15, r6
+move r16 = r7
+flt r16 = r16, r6
+move r17 = r6
+fle r17 = r17, r7
+set r8 = #65526
+itof r18 = r8
+ftoi r19 = r13
+set r9 = #xC180
+ftoi r20 = r9
+set r27 = #x0180
+qtof r21 = r27
+ftoq r22 = r9
+set r28 = #x7BFF
+move r23 = r28
+fmul r23 = r23, r7
+move r24 = r23
+fsub r24 = r24, r23
+move r25 = r24
+feq r25 = r25, r24
+set r29 = #x2E66
+set r30 = #x4200
+move r26 = r29
+fmul r26 = r26, r30
+system #0, r0, r0
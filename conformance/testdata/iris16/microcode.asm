@@ -0,0 +1,63 @@
+; new instructions defined as microcode routines
+;! register r10 = 25
+;! register r11 = 1010
+;! register r12 = 60
+;! register r13 = 7
+;! register r14 = 8
+;! memory data 3 = 99
+.microcode
+; a = b * b + c * c
+.macro sumsq
+mul t0 = b, b
+mul t1 = c, c
+add a = t0, t1
+.endmacro
+.macro addi
+add a = a, i
+.endmacro
+; a = the sum of c words in the data segment starting at b
+.macro sumrange
+set t0 = #0
+move t1 = b
+move t2 = c
+set t3 = #1
+branch sumrange_loop if t2
+move a = t0
+return
+sumrange_loop: load a = t1
+add t0 = t0, a
+add t1 = t1, t3
+sub t2 = t2, t3
+branch sumrange_loop if t2
+move a = t0
+.endmacro
+.macro pushpair
+push a
+push b
+.endmacro
+.macro poke
+store a = b
+.endmacro
+.data
+.org #x10
+.word #10
+.word #20
+.word #30
+.code
+set r6 = #3
+set r7 = #4
+sumsq r10 = r6, r7
+set r11 = #10
+addi r11 = #1000
+set r8 = #x10
+set r9 = #3
+sumrange r12 = r8, r9
+set r6 = #7
+set r7 = #8
+pushpair r6 = r7
+pop r14
+pop r13
+set r6 = #99
+set r7 = #3
+poke r7 = r6
+system #0, r0, r0
@@ -0,0 +1,49 @@
+; register moves, the stack and the word segments
+;! register r6 = 2
+;! register r7 = 1
+;! register r8 = 2
+;! register r9 = 77
+;! register r10 = 5
+;! register r11 = 6
+;! register r12 = 6
+;! register r13 = 300
+;! register r14 = 301
+;! register r15 = 302
+;! register r3 = 65535
+;! memory data 16 = 77
+;! memory microcode 17 = 300
+;! memory stack 18 = 301
+;! memory procedure 19 = 302
+;! memory data 32 = 4660
+.data
+.org #x20
+.word #x1234
+.code
+set r6 = #1
+set r7 = #2
+swap r6 = r7
+move r8 = r6
+set r20 = #16
+set r21 = #77
+store r20 = r21
+load r9 = r20
+set r20 = #17
+set r21 = #300
+store r20 = r21, microcode
+load r13 = r20, microcode
+set r20 = #18
+set r21 = #301
+store r20 = r21, stack
+load r14 = r20, stack
+set r20 = #19
+set r21 = #302
+store r20 = r21, procedure
+load r15 = r20, procedure
+set r22 = #5
+set r23 = #6
+push r22
+push r23
+peek r12
+pop r11
+pop r10
+system #0, r0, r0
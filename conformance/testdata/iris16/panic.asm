@@ -0,0 +1,5 @@
+; the panic system call stops the machine with an error
+;! error
+.code
+system #1, r0, r0
+system #0, r0, r0
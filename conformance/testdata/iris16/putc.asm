@@ -0,0 +1,15 @@
+; console output through the putc system call
+;! output "Hi!é\n"
+.code
+set r6 = #72
+system #2, r6, r6
+set r6 = #105
+system #2, r6, r6
+set r6 = #33
+system #2, r6, r6
+; two different registers make up a full rune
+set r6 = #233
+system #2, r6, r0
+set r6 = #10
+system #2, r6, r6
+system #0, r0, r0
@@ -0,0 +1,7 @@
+; the immediate form of remainder faults too
+;! error
+;! register r7 = 0
+.code
+set r6 = #9
+rem r7 = r6, #0
+system #0, r0, r0
@@ -0,0 +1,66 @@
+; signed arithmetic and compares, a 32-bit sum and difference using the carry
+; held in the status register
+;! register r10 = 65533
+;! register r11 = 65535
+;! register r12 = 65534
+;! register r13 = 1
+;! register r14 = 0
+;! register r15 = 1
+;! register r16 = 1
+;! register r17 = 0
+;! register r18 = 65534
+;! register r20 = 0
+;! register r21 = 2
+;! register r22 = 65535
+;! register r23 = 65535
+;! register r24 = 1
+;! register r25 = 32768
+;! register r26 = 2
+;! register r27 = 1
+;! register r28 = 1
+;! register r29 = 0
+;! register r30 = 0
+;! register r31 = 1
+;! register r32 = 0
+.code
+set r6 = #65526
+set r7 = #3
+sdiv r10 = r6, r7
+srem r11 = r6, r7
+sshiftright r12 = r6, #3
+slt r13 = r6, r7
+sgt r14 = r6, r7
+sle r15 = r6, r6
+sge r16 = r7, r6
+sge r16 & r6, r6
+lt r17 = r6, r7
+sshiftright r18 = r6, r7
+set r28 = #1
+slt r28 & r6, r7
+slt r29 | r7, r6
+set r30 = #1
+slt r30 ^ r6, r7
+sle r31 | r6, r7
+set r32 = #1
+sle r32 ^ r6, r6
+; 0x0001ffff + 0x00000001
+system #6, r0, r0
+set r20 = #65535
+set r21 = #1
+addc r20 = r20, r1
+addc r21 = r21, r0
+; 0x00000000 - 0x00000001
+system #6, r0, r0
+set r22 = #0
+set r23 = #0
+subb r22 = r22, r1
+subb r23 = r23, r0
+system #5, r24, r24
+; 0x7fff + 1 overflows without carrying out
+system #6, r0, r0
+set r25 = #32767
+addc r25 = r25, r1
+system #5, r26, r26
+system #6, r1, r1
+system #5, r27, r27
+system #0, r0, r0
@@ -0,0 +1,12 @@
+; add up the numbers from 1 to 10 and save the result
+;! register r7 = 55
+;! register r6 = 0
+;! memory data 0 = 55
+.code
+set r6 = #10
+loop: add r7 = r7, r6
+decr r6 = r6
+gt r8 = r6, r0
+branch loop if r8
+store r0 = r7, data
+system #0, r0, r0
@@ -0,0 +1,18 @@
+; counted loop with a call and the stack
+;! register r10 = 55
+;! register r11 = 10
+;! memory stack 0 = 55
+.code
+set r10 = #0
+set r11 = #0
+set r12 = #10
+loop:
+incr r11 = r11
+add r10 = r10, r11
+lt r13 = r11, r12
+branch loop if r13
+call save
+system #0, r0, r0
+save:
+push r10
+return
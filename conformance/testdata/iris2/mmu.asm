@@ -0,0 +1,46 @@
+; demand paging, the first store to a data page faults and the handler maps
+; the page before retrying the store
+;! register r12 = 42
+;! register r20 = 0
+;! register r21 = 5
+;! memory data 2053 = 42
+;! memory data 4096 = 131079
+.code
+set r8 = #1
+set r7 = #x1100
+system #4, r8, r7
+set r8 = #2
+set r7 = #1
+system #4, r8, r7
+set r8 = #3
+set r7 = #x1000
+system #4, r8, r7
+set r8 = #4
+set r7 = #4
+system #4, r8, r7
+set r8 = #5
+set r7 = handler
+system #4, r8, r7
+set r8 = #0
+set r7 = #1
+system #4, r8, r7
+set r10 = #5
+set r11 = #42
+store r10 = r11
+load r12 = r10
+system #0, r0, r0
+handler:
+set r8 = #7
+system #3, r20, r8
+set r8 = #6
+system #3, r21, r8
+shiftright r22 = r21, #10
+set r23 = #x1000
+add r23 = r23, r22
+; present, readable and writable in frame 2
+set r24 = #131079
+store r23 = r24
+system #6, r0, r0
+.data
+.org #x1100
+.word #9
@@ -0,0 +1,13 @@
+; a page fault without a handler stops the core
+;! error
+.code
+set r8 = #2
+set r7 = #1
+system #4, r8, r7
+set r8 = #0
+system #4, r8, r7
+set r10 = #5
+load r11 = r10
+system #0, r0, r0
+.data
+.word #9
@@ -0,0 +1,23 @@
+; 64-bit words and 32-bit immediates
+;! register r10 = 4294967296
+;! register r11 = -2147483648
+;! register r12 = 8589934592
+;! register r13 = 2147483647
+;! register r14 = 1
+;! memory data 3 = 4294967296
+;! memory data 4 = -1
+.code
+set r6 = #x7FFFFFFF
+set r7 = #1
+add r8 = r6, r7
+add r10 = r8, r8
+set r11 = #-2147483648
+shiftleft r12 = r10, #1
+move r13 = r6
+lt r14 = r11, r6
+set r15 = #3
+store r15 = r10
+set r16 = #-1
+set r15 = #4
+store r15 = r16
+system #0, r0, r0
@@ -0,0 +1,11 @@
+; add x to y using a scratch cell
+;! memory 13 = 12
+;! memory 14 = 0
+xand t x ...
+xand y t ...
+xand t t ...
+; a negative operand halts the machine before the instruction runs
+xand #-1 #-1 #-1
+x: #5
+y: #7
+t: #0
@@ -0,0 +1,21 @@
+; data directives, literal forms and label arithmetic
+;! memory 16 = 15
+;! memory 17 = 5
+;! memory 18 = 65
+;! memory 19 = 18
+;! memory 20 = 8
+;! memory 23 = 10
+;! memory 40 = 18
+;! memory 44 = 32
+;! memory 45 = -16
+jmp start
+.org 16
+table: .word #x0F, #b101, #'A', table+2, end-table
+.fill 3, #'\n' ; a semicolon inside quotes: #';'
+end:
+start: mov res table+3
+halt
+.org 40
+res: #0
+.org 44
+.word #' ',-table
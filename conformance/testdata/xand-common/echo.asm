@@ -0,0 +1,11 @@
+; copy the console input to the output, the input cell gives -1 at the end
+;! input "xand\n"
+;! output "xand\n"
+loop: getc c
+inc c
+jz c done
+dec c
+putc c
+jmp loop
+done: halt
+c: #0
@@ -0,0 +1,12 @@
+; hello world through the memory mapped console
+;! output "Hi!\n"
+putc h
+putc i
+; storing to the output cell writes the result, which is 0 - (-33)
+xand OUT bang ...
+putc nl
+halt
+h: #72
+i: #105
+bang: #-33
+nl: #10
@@ -0,0 +1,19 @@
+; multiply 6 by 7 with the macro library, jz must leave its operand alone
+;! memory 78 = 0
+;! memory 80 = 42
+;! memory 81 = 43
+;! memory 82 = -3
+loop: jz n done
+add acc x
+dec n
+jmp loop
+done: mov out acc
+inc out
+jz neg done
+jz out done
+halt
+n: #6
+x: #7
+acc: #0
+out: #0
+neg: #-3
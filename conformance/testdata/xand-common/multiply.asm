@@ -0,0 +1,12 @@
+; multiply 3 by 4 through repeated addition
+;! memory 12 = 0
+;! memory 15 = 12
+loop: xand acc neg4 ...
+xand n one stop
+xand t t loop
+stop: xand t t #-1
+n: #3
+one: #1
+neg4: #-4
+acc: #0
+t: #0
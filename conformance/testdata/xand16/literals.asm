@@ -0,0 +1,8 @@
+; hex and binary literals are bit patterns of a 16-bit word
+;! memory 8 = -1
+;! memory 9 = -32768
+;! memory 10 = 255
+;! memory 11 = -1
+halt
+.org 8
+.word #xFFFF, #x8000, #xFF, #b1111111111111111
@@ -0,0 +1,11 @@
+; values that do not fit in a byte
+;! memory 13 = 3000
+;! memory 14 = 0
+xand t x ...
+xand y t ...
+xand t t ...
+; a negative operand halts the machine before the instruction runs
+xand #-1 #-1 #-1
+x: #1000
+y: #2000
+t: #0
@@ -0,0 +1,8 @@
+; hex and binary literals are bit patterns of a 32-bit word
+;! memory 8 = -1
+;! memory 9 = -2147483648
+;! memory 10 = 65535
+;! memory 11 = -1
+halt
+.org 8
+.word #xFFFFFFFF, #x80000000, #xFFFF, #b11111111111111111111111111111111
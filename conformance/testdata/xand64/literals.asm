@@ -0,0 +1,8 @@
+; hex and binary literals are bit patterns of a 64-bit word
+;! memory 8 = -1
+;! memory 9 = -9223372036854775808
+;! memory 10 = 4294967295
+;! memory 11 = -1
+halt
+.org 8
+.word #xFFFFFFFFFFFFFFFF, #x8000000000000000, #xFFFFFFFF, #b1111111111111111111111111111111111111111111111111111111111111111
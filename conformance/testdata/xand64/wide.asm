@@ -0,0 +1,11 @@
+; values that do not fit in 32 bits
+;! memory 13 = 30000000000
+;! memory 14 = 0
+xand t x ...
+xand y t ...
+xand t t ...
+; a negative operand halts the machine before the instruction runs
+xand #-1 #-1 #-1
+x: #10000000000
+y: #20000000000
+t: #0
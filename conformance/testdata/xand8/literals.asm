@@ -0,0 +1,8 @@
+; hex and binary literals are bit patterns of a 8-bit word
+;! memory 8 = -1
+;! memory 9 = -128
+;! memory 10 = 127
+;! memory 11 = -1
+halt
+.org 8
+.word #xFF, #x80, #x7F, #b11111111
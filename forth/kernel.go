@@ -0,0 +1,362 @@
+// forth is a Forth system for iris16 built by a metacompiler
+package forth
+
+import (
+	"fmt"
+	"github.com/DrItanium/cores/iris16"
+	"github.com/DrItanium/cores/iris16/extensions"
+)
+
+// The inner interpreter is indirect threaded, every word has a code field
+// holding the code address which runs it followed by its parameters:
+//
+//	r6 ip, the next cell of threaded code
+//	r7 w, the code field of the word being run
+//	r3 data stack pointer into the stack segment
+//	r5 return stack pointer into the call segment
+//
+// Both stacks start empty at 0xFFFF and grow up the same way push and call
+// do. Flags are 0 and -1, memory is word addressed so a character takes a
+// whole cell. The metacompiler places a cell labeled boot holding the word to
+// start with.
+var kernel = fmt.Sprintf(`.code
+.org #0
+	set r6 = boot
+	branch next
+next:
+	load r7 = r6
+	incr r6 = r6
+	load r8 = r7
+	branch r8
+docol:
+	incr r5 = r5
+	store r5 = r6, procedure
+	incr r6 = r7
+	branch next
+dovar:
+	incr r8 = r7
+	push r8
+	branch next
+docon:
+	incr r8 = r7
+	load r8 = r8
+	push r8
+	branch next
+p$exit:
+	load r6 = r5, procedure
+	decr r5 = r5
+	branch next
+p$lit:
+	load r8 = r6
+	incr r6 = r6
+	push r8
+	branch next
+p$branch:
+	load r6 = r6
+	branch next
+p$0branch:
+	pop r8
+	eq r9 = r8, r0
+	branch p$branch if r9
+	incr r6 = r6
+	branch next
+p$execute:
+	pop r7
+	load r8 = r7
+	branch r8
+p$do:
+	pop r9
+	pop r8
+	incr r5 = r5
+	store r5 = r8, procedure
+	incr r5 = r5
+	store r5 = r9, procedure
+	branch next
+p$loop:
+	load r9 = r5, procedure
+	incr r9 = r9
+	decr r10 = r5
+	load r8 = r10, procedure
+	eq r11 = r9, r8
+	branch p$loopdone if r11
+	store r5 = r9, procedure
+	load r6 = r6
+	branch next
+p$loopdone:
+	sub r5 = r5, #2
+	incr r6 = r6
+	branch next
+p$i:
+	load r8 = r5, procedure
+	push r8
+	branch next
+p$j:
+	sub r8 = r5, #2
+	load r8 = r8, procedure
+	push r8
+	branch next
+p$unloop:
+	sub r5 = r5, #2
+	branch next
+p$dup:
+	peek r8
+	push r8
+	branch next
+p$drop:
+	pop r8
+	branch next
+p$swap:
+	pop r8
+	pop r9
+	push r8
+	push r9
+	branch next
+p$over:
+	pop r8
+	peek r9
+	push r8
+	push r9
+	branch next
+p$rot:
+	pop r10
+	pop r9
+	pop r8
+	push r9
+	push r10
+	push r8
+	branch next
+p$pick:
+	pop r8
+	sub r9 = r3, r8
+	load r9 = r9, stack
+	push r9
+	branch next
+p$depth:
+	incr r8 = r3
+	push r8
+	branch next
+p$tor:
+	pop r8
+	incr r5 = r5
+	store r5 = r8, procedure
+	branch next
+p$fromr:
+	load r8 = r5, procedure
+	decr r5 = r5
+	push r8
+	branch next
+p$rfetch:
+	load r8 = r5, procedure
+	push r8
+	branch next
+p$fetch:
+	pop r8
+	load r8 = r8
+	push r8
+	branch next
+p$store:
+	pop r8
+	pop r9
+	store r8 = r9
+	branch next
+p$plus:
+	pop r9
+	pop r8
+	add r8 = r8, r9
+	push r8
+	branch next
+p$minus:
+	pop r9
+	pop r8
+	sub r8 = r8, r9
+	push r8
+	branch next
+p$star:
+	pop r9
+	pop r8
+	mul r8 = r8, r9
+	push r8
+	branch next
+p$slashmod:
+	pop r9
+	pop r8
+	srem r10 = r8, r9
+	sdiv r11 = r8, r9
+	push r10
+	push r11
+	branch next
+p$uslashmod:
+	pop r9
+	pop r8
+	rem r10 = r8, r9
+	div r11 = r8, r9
+	push r10
+	push r11
+	branch next
+p$oneplus:
+	pop r8
+	incr r8 = r8
+	push r8
+	branch next
+p$oneminus:
+	pop r8
+	decr r8 = r8
+	push r8
+	branch next
+p$and:
+	pop r9
+	pop r8
+	and r8 = r8, r9
+	push r8
+	branch next
+p$or:
+	pop r9
+	pop r8
+	or r8 = r8, r9
+	push r8
+	branch next
+p$xor:
+	pop r9
+	pop r8
+	xor r8 = r8, r9
+	push r8
+	branch next
+p$invert:
+	pop r8
+	not r8 = r8
+	push r8
+	branch next
+p$lshift:
+	pop r9
+	pop r8
+	shiftleft r8 = r8, r9
+	push r8
+	branch next
+p$rshift:
+	pop r9
+	pop r8
+	shiftright r8 = r8, r9
+	push r8
+	branch next
+p$equal:
+	pop r9
+	pop r8
+	eq r8 = r8, r9
+	sub r8 = r0, r8
+	push r8
+	branch next
+p$less:
+	pop r9
+	pop r8
+	slt r8 = r8, r9
+	sub r8 = r0, r8
+	push r8
+	branch next
+p$uless:
+	pop r9
+	pop r8
+	lt r8 = r8, r9
+	sub r8 = r0, r8
+	push r8
+	branch next
+p$zeroequal:
+	pop r8
+	eq r8 = r8, r0
+	sub r8 = r0, r8
+	push r8
+	branch next
+p$zeroless:
+	pop r8
+	slt r8 = r8, r0
+	sub r8 = r0, r8
+	push r8
+	branch next
+p$emit:
+	pop r8
+	system #%d, r8, r8
+	branch next
+p$key:
+	system #%d, r8, r8
+	push r8
+	branch next
+p$spfetch:
+	move r8 = r3
+	push r8
+	branch next
+p$spstore:
+	pop r8
+	move r3 = r8
+	branch next
+p$rpfetch:
+	move r8 = r5
+	push r8
+	branch next
+p$rpstore:
+	pop r8
+	move r5 = r8
+	branch next
+p$abort:
+	set r3 = #xFFFF
+	set r5 = #xFFFF
+	set r6 = boot
+	branch next
+p$bye:
+	system #%d, r0, r0
+`, iris16.SystemCallPutc, extensions.SystemCallGetc, iris16.SystemCallTerminate)
+
+// the words written in assembly and the labels of their code
+var primitives = []struct {
+	name, label string
+}{
+	{"exit", "p$exit"},
+	{"lit", "p$lit"},
+	{"branch", "p$branch"},
+	{"0branch", "p$0branch"},
+	{"execute", "p$execute"},
+	{"(do)", "p$do"},
+	{"(loop)", "p$loop"},
+	{"i", "p$i"},
+	{"j", "p$j"},
+	{"unloop", "p$unloop"},
+	{"dup", "p$dup"},
+	{"drop", "p$drop"},
+	{"swap", "p$swap"},
+	{"over", "p$over"},
+	{"rot", "p$rot"},
+	{"pick", "p$pick"},
+	{"depth", "p$depth"},
+	{">r", "p$tor"},
+	{"r>", "p$fromr"},
+	{"r@", "p$rfetch"},
+	{"@", "p$fetch"},
+	{"!", "p$store"},
+	{"+", "p$plus"},
+	{"-", "p$minus"},
+	{"*", "p$star"},
+	{"(/mod)", "p$slashmod"},
+	{"(u/mod)", "p$uslashmod"},
+	{"1+", "p$oneplus"},
+	{"1-", "p$oneminus"},
+	{"and", "p$and"},
+	{"or", "p$or"},
+	{"xor", "p$xor"},
+	{"invert", "p$invert"},
+	{"lshift", "p$lshift"},
+	{"rshift", "p$rshift"},
+	{"=", "p$equal"},
+	{"<", "p$less"},
+	{"u<", "p$uless"},
+	{"0=", "p$zeroequal"},
+	{"0<", "p$zeroless"},
+	{"emit", "p$emit"},
+	{"key", "p$key"},
+	{"sp@", "p$spfetch"},
+	{"sp!", "p$spstore"},
+	{"rp@", "p$rpfetch"},
+	{"rp!", "p$rpstore"},
+	{"abort", "p$abort"},
+	{"bye", "p$bye"},
+}
+
+// constants holding the code addresses the target needs to build new words
+var codeAddresses = []string{"docol", "dovar", "docon"}
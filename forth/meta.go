@@ -0,0 +1,458 @@
+package forth
+
+import (
+	"fmt"
+	"github.com/DrItanium/cores/iris16"
+	"github.com/DrItanium/cores/registration/parser"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	flagImmediate = 0x100
+	lengthMask    = 0xFF
+	// data segment cell holding the word boot starts with, headers come
+	// after it so that no word sits at the null address
+	bootCell       = 1
+	dictionaryBase = 2
+)
+
+// Metacompile builds the dictionary of the system words followed by the words
+// of src and returns the whole image as iris16 assembly. The image boots into
+// quit which interprets lines read through the getc system call of the
+// console extension.
+//
+// Outside of definitions the metacompiler only knows numbers, :, variable,
+// constant, create, ",", allot and immediate. Definitions are compiled the
+// way the target would compile them, the words if, else, then, begin, until,
+// again, while, repeat, do, loop, recurse, ['], [char] and ." are carried out
+// by the metacompiler and any other immediate word can't be used since it
+// can only run on the target.
+func Metacompile(src string) (string, error) {
+	m := metacompiler{words: make(map[string]*word)}
+	m.image = make([]cell, dictionaryBase)
+	for _, prim := range primitives {
+		m.words[prim.name] = m.header(prim.name)
+		m.label(prim.label)
+	}
+	for _, label := range codeAddresses {
+		m.words[label] = m.header(label)
+		m.label("docon")
+		m.label(label)
+	}
+	if err := m.compile(system); err != nil {
+		return "", err
+	} else if err := m.compile(src); err != nil {
+		return "", err
+	}
+	// the target takes over where the metacompiler stopped
+	for name, value := range map[string]int{"dp": len(m.image), "latest": m.latest} {
+		m.image[m.words[name].xt+1] = cell{value: value}
+	}
+	m.image[bootCell] = cell{value: m.words["quit"].xt}
+	if len(m.image) > iris16.MemorySize {
+		return "", fmt.Errorf("Error: The dictionary takes %d cells which doesn't fit into the data segment", len(m.image))
+	}
+	lines := []string{kernel, ".data", ".org #0"}
+	for i, c := range m.image {
+		if i == bootCell {
+			lines = append(lines, "boot:")
+		}
+		if c.label != "" {
+			lines = append(lines, ".word "+c.label)
+		} else {
+			lines = append(lines, fmt.Sprintf(".word #%d", uint16(c.value)))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// Assemble runs the output of Metacompile through the iris16 parser, the image
+// needs a core with the console extension installed
+func Assemble(asm string) ([]byte, error) {
+	return parser.Assemble(iris16.RegistrationName(), asm)
+}
+
+// a cell of the data segment is either a number or the code address of a
+// kernel label
+type cell struct {
+	value int
+	label string
+}
+
+type word struct {
+	xt        int
+	header    int
+	immediate bool
+}
+
+// the address left by if, while and do along with what has to use it
+type control struct {
+	kind    string
+	address int
+}
+
+type metacompiler struct {
+	image  []cell
+	words  map[string]*word
+	latest int
+	last   *word
+	// numbers given outside of a definition
+	stack []int
+	// the definition being compiled
+	current string
+	pending *word
+	control []control
+	// the source being read
+	text string
+	pos  int
+	line int
+}
+
+func (this *metacompiler) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Error: line %d: %s", this.line, fmt.Sprintf(format, args...))
+}
+
+func (this *metacompiler) here() int {
+	return len(this.image)
+}
+
+func (this *metacompiler) comma(value int) {
+	this.image = append(this.image, cell{value: value})
+}
+
+func (this *metacompiler) label(name string) {
+	this.image = append(this.image, cell{label: name})
+}
+
+// Lay down a header and give back the word it starts, the name can only be
+// found once the word is added to the dictionary
+func (this *metacompiler) header(name string) *word {
+	w := &word{header: this.here()}
+	this.comma(this.latest)
+	this.latest, this.last = w.header, w
+	this.comma(len(name))
+	for _, r := range name {
+		this.comma(int(r))
+	}
+	w.xt = this.here()
+	return w
+}
+
+func (this *metacompiler) define(name string) (*word, error) {
+	if err := this.checkName(name); err != nil {
+		return nil, err
+	}
+	w := this.header(name)
+	this.words[name] = w
+	return w, nil
+}
+
+func (this *metacompiler) compileWord(name string) error {
+	if w, ok := this.words[name]; !ok {
+		return this.errorf("%s is not defined", name)
+	} else {
+		this.comma(w.xt)
+		return nil
+	}
+}
+
+func (this *metacompiler) pop(name string) (int, error) {
+	if len(this.stack) == 0 {
+		return 0, this.errorf("%s needs a number", name)
+	}
+	value := this.stack[len(this.stack)-1]
+	this.stack = this.stack[:len(this.stack)-1]
+	return value, nil
+}
+
+// Read the next blank delimited name, the delimiter is consumed as well
+func (this *metacompiler) parseName() string {
+	for this.pos < len(this.text) && unicode.IsSpace(rune(this.text[this.pos])) {
+		if this.text[this.pos] == '\n' {
+			this.line++
+		}
+		this.pos++
+	}
+	start := this.pos
+	for this.pos < len(this.text) && !unicode.IsSpace(rune(this.text[this.pos])) {
+		this.pos++
+	}
+	name := this.text[start:this.pos]
+	if this.pos < len(this.text) {
+		if this.text[this.pos] == '\n' {
+			this.line++
+		}
+		this.pos++
+	}
+	return name
+}
+
+// Read up to the delimiter on the current line
+func (this *metacompiler) parse(delimiter byte) (string, error) {
+	end := strings.IndexAny(this.text[this.pos:], string(delimiter)+"\n")
+	if end < 0 || this.text[this.pos+end] != delimiter {
+		return "", this.errorf("Missing a closing %c", delimiter)
+	}
+	text := this.text[this.pos : this.pos+end]
+	this.pos += end + 1
+	return text, nil
+}
+
+func (this *metacompiler) char() (int, error) {
+	if name := this.parseName(); name == "" {
+		return 0, this.errorf("Missing a character")
+	} else {
+		return int(name[0]), nil
+	}
+}
+
+func number(name string) (int, bool) {
+	if v, err := strconv.ParseInt(name, 10, 32); err != nil || v < -32768 || v > 65535 {
+		return 0, false
+	} else {
+		return int(v), true
+	}
+}
+
+func (this *metacompiler) compile(src string) error {
+	this.text, this.pos, this.line = src, 0, 1
+	for {
+		name := this.parseName()
+		if name == "" {
+			break
+		}
+		var err error
+		switch name {
+		case "\\":
+			// skip the rest of the line unless the name already ended it
+			if this.text[this.pos-1] != '\n' {
+				if end := strings.IndexByte(this.text[this.pos:], '\n'); end < 0 {
+					this.pos = len(this.text)
+				} else {
+					this.pos += end
+				}
+			}
+		case "(":
+			_, err = this.parse(')')
+		default:
+			if this.pending != nil {
+				err = this.compileName(name)
+			} else {
+				err = this.interpret(name)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if this.pending != nil {
+		return this.errorf("Definition of %s is missing a ;", this.current)
+	}
+	return nil
+}
+
+func (this *metacompiler) interpret(name string) error {
+	if v, ok := number(name); ok {
+		this.stack = append(this.stack, v)
+		return nil
+	}
+	switch name {
+	case ":":
+		this.current = this.parseName()
+		if err := this.checkName(this.current); err != nil {
+			return err
+		}
+		this.pending = this.header(this.current)
+		this.label("docol")
+	case "variable", "create":
+		if _, err := this.define(this.parseName()); err != nil {
+			return err
+		}
+		this.label("dovar")
+		if name == "variable" {
+			this.comma(0)
+		}
+	case "constant":
+		if value, err := this.pop(name); err != nil {
+			return err
+		} else if _, err := this.define(this.parseName()); err != nil {
+			return err
+		} else {
+			this.label("docon")
+			this.comma(value)
+		}
+	case ",":
+		if value, err := this.pop(name); err != nil {
+			return err
+		} else {
+			this.comma(value)
+		}
+	case "allot":
+		if count, err := this.pop(name); err != nil {
+			return err
+		} else if count < 0 {
+			return this.errorf("Can't allot %d cells", count)
+		} else {
+			this.image = append(this.image, make([]cell, count)...)
+		}
+	case "immediate":
+		if this.last == nil {
+			return this.errorf("There is no word to make immediate")
+		}
+		this.image[this.last.header+1].value |= flagImmediate
+		this.last.immediate = true
+	case "char":
+		if c, err := this.char(); err != nil {
+			return err
+		} else {
+			this.stack = append(this.stack, c)
+		}
+	default:
+		if _, ok := this.words[name]; ok {
+			return this.errorf("%s can only be used inside of a definition", name)
+		} else {
+			return this.errorf("%s is not defined", name)
+		}
+	}
+	return nil
+}
+
+func (this *metacompiler) checkName(name string) error {
+	if name == "" {
+		return this.errorf("Missing a name")
+	} else if len(name) > lengthMask {
+		return this.errorf("The name %s is too long", name)
+	} else {
+		return nil
+	}
+}
+
+func (this *metacompiler) push(kind string, address int) {
+	this.control = append(this.control, control{kind, address})
+}
+
+func (this *metacompiler) resolve(name, kind string) (int, error) {
+	if len(this.control) == 0 || this.control[len(this.control)-1].kind != kind {
+		return 0, this.errorf("%s in %s is unbalanced", name, this.current)
+	}
+	c := this.control[len(this.control)-1]
+	this.control = this.control[:len(this.control)-1]
+	return c.address, nil
+}
+
+// Compile a name into the definition, control words leave the address which
+// has to be filled in on the control stack
+func (this *metacompiler) compileName(name string) error {
+	if v, ok := number(name); ok {
+		this.compileWord("lit")
+		this.comma(v)
+		return nil
+	}
+	switch name {
+	case ";":
+		if len(this.control) != 0 {
+			return this.errorf("%s in %s is unbalanced", this.control[len(this.control)-1].kind, this.current)
+		}
+		this.compileWord("exit")
+		this.words[this.current] = this.pending
+		this.pending = nil
+	case "if":
+		this.compileWord("0branch")
+		this.push("if", this.here())
+		this.comma(0)
+	case "while":
+		if dest, err := this.resolve(name, "begin"); err != nil {
+			return err
+		} else {
+			this.compileWord("0branch")
+			this.push("if", this.here())
+			this.comma(0)
+			this.push("begin", dest)
+		}
+	case "else":
+		if orig, err := this.resolve(name, "if"); err != nil {
+			return err
+		} else {
+			this.compileWord("branch")
+			this.push("if", this.here())
+			this.comma(0)
+			this.image[orig].value = this.here()
+		}
+	case "then":
+		if orig, err := this.resolve(name, "if"); err != nil {
+			return err
+		} else {
+			this.image[orig].value = this.here()
+		}
+	case "begin":
+		this.push("begin", this.here())
+	case "until", "again", "repeat":
+		if dest, err := this.resolve(name, "begin"); err != nil {
+			return err
+		} else {
+			if name == "until" {
+				this.compileWord("0branch")
+			} else {
+				this.compileWord("branch")
+			}
+			this.comma(dest)
+			if name == "repeat" {
+				if orig, err := this.resolve(name, "if"); err != nil {
+					return err
+				} else {
+					this.image[orig].value = this.here()
+				}
+			}
+		}
+	case "do":
+		this.compileWord("(do)")
+		this.push("do", this.here())
+	case "loop":
+		if dest, err := this.resolve(name, "do"); err != nil {
+			return err
+		} else {
+			this.compileWord("(loop)")
+			this.comma(dest)
+		}
+	case "recurse":
+		this.comma(this.pending.xt)
+	case "[']":
+		if w, ok := this.words[this.parseName()]; !ok {
+			return this.errorf("['] needs a defined word")
+		} else {
+			this.compileWord("lit")
+			this.comma(w.xt)
+		}
+	case "[char]":
+		if c, err := this.char(); err != nil {
+			return err
+		} else {
+			this.compileWord("lit")
+			this.comma(c)
+		}
+	case ".\"":
+		if text, err := this.parse('"'); err != nil {
+			return err
+		} else if err := this.compileWord("(.\")"); err != nil {
+			return err
+		} else {
+			this.comma(len(text))
+			for _, r := range text {
+				this.comma(int(r))
+			}
+		}
+	case ":":
+		return this.errorf("Definition of %s is missing a ;", this.current)
+	default:
+		if w, ok := this.words[name]; !ok {
+			return this.errorf("%s is not defined", name)
+		} else if w.immediate {
+			return this.errorf("The immediate word %s can only be run on the target", name)
+		} else {
+			this.comma(w.xt)
+		}
+	}
+	return nil
+}
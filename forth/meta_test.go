@@ -0,0 +1,100 @@
+package forth
+
+import (
+	"bytes"
+	"github.com/DrItanium/cores/iris16"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, src, input string) string {
+	asm, err := Metacompile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, err := Assemble(asm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	core, err := iris16.New()
+	if err != nil {
+		t.Fatal(err)
+	} else if err := core.InstallExtension("console"); err != nil {
+		t.Fatal(err)
+	}
+	in := make(chan byte, len(image))
+	for _, b := range image {
+		in <- b
+	}
+	close(in)
+	var out bytes.Buffer
+	core.SetOutput(&out)
+	core.SetInput(strings.NewReader(input))
+	if err := core.InstallProgram(in); err != nil {
+		t.Fatal(err)
+	} else if err := core.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func Test_Interpreter(t *testing.T) {
+	for _, test := range []struct {
+		name, input, expect string
+	}{
+		{"arithmetic", "2 3 + . 7 2 /mod . . -7 2 / . hex ff decimal . 65535 u. -1 .\n",
+			"5 3 1 -3 255 65535 -1  ok\n"},
+		{"definitions", ": sq dup * ;\n: fact dup 1 > if dup 1- recurse * else drop 1 then ;\n7 sq . 7 fact .\n",
+			" ok\n ok\n49 5040  ok\n"},
+		{"loops", ": grid 3 0 do 2 0 do i j + . loop loop ;\n: down begin dup while dup . 1- repeat drop ;\ngrid 3 down\n",
+			" ok\n ok\n0 1 1 2 2 3 3 2 1  ok\n"},
+		{"memory", "variable x 42 x ! 3 x +! x ?\ncreate a 1 , 2 , 3 , a 2 + @ .\n10 constant ten ' ten execute .\n",
+			"45  ok\n3  ok\n10  ok\n"},
+		{"strings", ": hi .\" hello, world\" cr ;\nhi .\" direct\" cr\nchar A . ( skipped ) 1 . \\ 2 .\n",
+			" ok\nhello, world\ndirect\n ok\n65 1  ok\n"},
+		{"errors", "1 2 nosuch 3 .\n.s 1 0 / 4 .\n: broken ; .s\n",
+			"nosuch ?\ndivision by zero\n ok\n"},
+		{"immediate", ": now .\" now\" ; immediate\n: later now ;\nlater\n",
+			" ok\nnow ok\n ok\n"},
+	} {
+		if output := run(t, "", test.input); output != test.expect {
+			t.Errorf("%s: expected %q but got %q", test.name, test.expect, output)
+		}
+	}
+}
+
+func Test_Metacompile(t *testing.T) {
+	src := `
+\ words compiled ahead of time
+variable total
+create squares 0 , 1 , 4 , 9 ,
+: sum ( n -- ) 0 total ! 0 do squares i + @ total +! loop total @ ;
+: greet ." hi " [char] ! emit cr ;
+`
+	if output := run(t, src, "4 sum . greet\n"); output != "14 hi !\n ok\n" {
+		t.Errorf("expected %q but got %q", "14 hi !\n ok\n", output)
+	}
+}
+
+func Test_MetacompileErrors(t *testing.T) {
+	for _, src := range []string{
+		": unfinished 1 2",
+		": a if ;",
+		": a then ;",
+		": a begin 1 while ;",
+		": a loop ;",
+		"nosuch",
+		": a nosuch ;",
+		"dup",
+		"constant c",
+		": a ( unclosed ;",
+		": a .\" unclosed ;",
+		": a [ ;",
+		": a : b ; ;",
+		":",
+	} {
+		if _, err := Metacompile(src); err == nil {
+			t.Errorf("%q was accepted", src)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package forth
+
+// The words of the system written in Forth, the metacompiler builds them on
+// top of the primitives before anything else. The text interpreter reads a
+// line at a time into tib, quit runs once the image boots.
+const system = `
+variable state
+variable dp
+variable latest
+variable >in
+variable #tib
+create base 10 ,
+create tib 256 allot
+65535 constant sp0
+65535 constant rp0
+32 constant bl
+-1 constant true
+0 constant false
+
+: nip swap drop ;
+: tuck swap over ;
+: -rot rot rot ;
+: 2dup over over ;
+: 2drop drop drop ;
+: ?dup dup if dup then ;
+: <> = 0= ;
+: > swap < ;
+: 0> 0 swap < ;
+: negate 0 swap - ;
+: abs dup 0< if negate then ;
+: min 2dup > if swap then drop ;
+: max 2dup < if swap then drop ;
+: +! dup @ rot + swap ! ;
+: here dp @ ;
+: , here ! 1 dp +! ;
+: allot dp +! ;
+: cells ;
+: cell+ 1+ ;
+
+: cr 10 emit ;
+: space bl emit ;
+: spaces begin dup 0> while space 1- repeat drop ;
+: type begin dup while swap dup @ emit 1+ swap 1- repeat 2drop ;
+: count dup 1+ swap @ ;
+: (.") r> count 2dup + >r type ;
+
+: /mod dup 0= if ." division by zero" cr abort then (/mod) ;
+: u/mod dup 0= if ." division by zero" cr abort then (u/mod) ;
+: / /mod nip ;
+: mod /mod drop ;
+
+: decimal 10 base ! ;
+: hex 16 base ! ;
+: >digit dup 9 > if 7 + then 48 + ;
+: (u.) base @ u/mod ?dup if recurse then >digit emit ;
+: u. (u.) space ;
+: . dup 0< if [char] - emit negate then u. ;
+: ? @ . ;
+: .s depth begin dup while dup pick . 1- repeat drop ;
+
+\ reading a line of input, false once the input runs dry
+: refill
+  0 #tib ! 0 >in !
+  begin key dup 10 <> over -1 <> and while
+    #tib @ 256 < if tib #tib @ + ! 1 #tib +! else drop then
+  repeat
+  -1 = #tib @ 0= and 0= ;
+: source tib #tib @ ;
+: more? >in @ #tib @ < ;
+: current tib >in @ + @ ;
+: skip begin more? while current bl > if exit then 1 >in +! repeat ;
+: parse-name
+  skip tib >in @ + 0
+  begin more? while
+    current bl > 0= if 1 >in +! exit then
+    1+ 1 >in +!
+  repeat ;
+: parse
+  >r tib >in @ + 0
+  begin more? while
+    current r@ = if 1 >in +! r> drop exit then
+    1+ 1 >in +!
+  repeat r> drop ;
+
+\ headers are a link to the previous header, the length of the name along
+\ with the flags and then the name a character to a cell
+: >xt 1+ dup @ 255 and + 1+ ;
+: same?
+  begin dup while
+    >r over @ over @ <> if r> drop 2drop 0 exit then
+    1+ swap 1+ swap r> 1-
+  repeat drop 2drop true ;
+: name= 1+ dup @ 767 and rot <> if 2drop false exit then dup @ 255 and >r 1+ r> same? ;
+: find
+  latest @ begin dup while
+    >r 2dup r@ name= if
+      2drop r> dup >xt swap 1+ @ 256 and if 1 else -1 then exit
+    then r> @
+  repeat ;
+
+: digit
+  dup [char] a < 0= if 32 - then
+  dup [char] A < 0= if 7 - else dup [char] 9 > if drop false exit then then
+  48 - dup 0< over base @ < 0= or if drop false else true then ;
+: number?
+  over @ [char] - = over 1 > and >r
+  2dup r@ if 1- swap 1+ swap then
+  0 -rot
+  begin dup while
+    over @ digit 0= if r> drop 2drop drop false exit then
+    >r rot base @ * r> + -rot
+    1- swap 1+ swap
+  repeat
+  2drop nip nip r> if negate then true ;
+
+: unknown type ."  ?" cr abort ;
+: interpret
+  begin parse-name dup while
+    find ?dup if
+      1+ state @ 0= or if execute else , then
+    else
+      number? if state @ if ['] lit , , then else unknown then
+    then
+  repeat 2drop ;
+: quit
+  0 state ! rp0 rp!
+  begin refill while
+    interpret state @ 0= if ."  ok" cr then
+  repeat bye ;
+
+: header
+  parse-name dup 0= if ." missing name" cr abort then
+  here latest @ , latest ! dup ,
+  begin dup while swap dup @ , 1+ swap 1- repeat 2drop ;
+: immediate latest @ 1+ dup @ 256 or swap ! ;
+: hide latest @ 1+ dup @ 512 xor swap ! ;
+: create header dovar , ;
+: variable create 0 , ;
+: constant header docon , , ;
+: [ 0 state ! ; immediate
+: ] true state ! ;
+: : header docol , hide ] ;
+: ; ['] exit , hide 0 state ! ; immediate
+: ' parse-name find 0= if unknown then ;
+: ['] ' ['] lit , , ; immediate
+: char parse-name drop @ ;
+: [char] char ['] lit , , ; immediate
+: literal ['] lit , , ; immediate
+: recurse latest @ >xt , ; immediate
+: if ['] 0branch , here 0 , ; immediate
+: then here swap ! ; immediate
+: else ['] branch , here 0 , swap here swap ! ; immediate
+: begin here ; immediate
+: until ['] 0branch , , ; immediate
+: again ['] branch , , ; immediate
+: while ['] 0branch , here 0 , swap ; immediate
+: repeat ['] branch , , here swap ! ; immediate
+: do ['] (do) , here ; immediate
+: loop ['] (loop) , , ; immediate
+: ( [char] ) parse 2drop ; immediate
+: \ #tib @ >in ! ; immediate
+: ."
+  [char] " parse state @ if
+    ['] (.") , dup , begin dup while swap dup @ , 1+ swap 1- repeat 2drop
+  else type then ; immediate
+: words latest @ begin dup while dup 1+ count 255 and type space @ repeat drop cr ;
+`
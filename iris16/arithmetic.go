@@ -26,6 +26,16 @@ const (
 	ArithmeticOpRemImmediate
 	ArithmeticOpShiftLeftImmediate
 	ArithmeticOpShiftRightImmediate
+	ArithmeticOpSignedDiv
+	ArithmeticOpSignedRem
+	ArithmeticOpSignedShiftRight
+	ArithmeticOpSignedShiftRightImmediate
+	ArithmeticOpAddWithCarry
+	ArithmeticOpSubWithBorrow
+	ArithmeticOpFixedMul
+	ArithmeticOpFixedDiv
+	ArithmeticOpFloat
+	ArithmeticOpFloatConvert
 	// always last
 	ArithmeticOpCount
 )
@@ -57,6 +67,29 @@ func genericDivide(a, b Word, denomIsOne, otherwise binOp) (Word, error) {
 		return otherwise(a, b)
 	}
 }
+func signedDiv(a, b Word) (Word, error) {
+	return genericDivide(a, b, func(a, _ Word) (Word, error) { return a, nil }, func(a, b Word) (Word, error) { return Word(int16(a) / int16(b)), nil })
+}
+func signedRem(a, b Word) (Word, error) {
+	return genericDivide(a, b, func(_, _ Word) (Word, error) { return 0, nil }, func(a, b Word) (Word, error) { return Word(int16(a) % int16(b)), nil })
+}
+func signedShiftRight(a, b Word) (Word, error) {
+	return Word(int16(a) >> b), nil
+}
+
+// Q8.8 fixed point, eight integer bits (including the sign) and eight
+// fraction bits. Products are rounded to the nearest value with halves going
+// up, quotients are truncated towards zero like sdiv. Both wrap on overflow.
+func fixedMul(a, b Word) (Word, error) {
+	return Word((int32(int16(a))*int32(int16(b)) + 0x80) >> 8), nil
+}
+func fixedDiv(a, b Word) (Word, error) {
+	if b == 0 {
+		return 0, fmt.Errorf(DivideByZeroMessage)
+	} else {
+		return Word((int32(int16(a)) << 8) / int32(int16(b))), nil
+	}
+}
 func div(a, b Word) (Word, error) {
 	return genericDivide(a, b, func(a, _ Word) (Word, error) { return a, nil }, func(a, b Word) (Word, error) { return a / b, nil })
 }
@@ -68,12 +101,12 @@ var unimplementedArithmeticOp = ArithmeticOp{
 	ImmediateForm: false,
 	Fn:            unimplementedBinaryOp,
 }
-var arithmeticOps = [31]ArithmeticOp{
-	ArithmeticOp{false, func(a, b Word) (Word, error) { return a + b, nil }},  // add
-	ArithmeticOp{false, func(a, b Word) (Word, error) { return a - b, nil }},  // sub
-	ArithmeticOp{false, func(a, b Word) (Word, error) { return a * b, nil }},  // mul
-	ArithmeticOp{false, div},                                                  // divide
-	ArithmeticOp{false, rem},                                                  // remainder
+var arithmeticOps = [32]ArithmeticOp{
+	ArithmeticOp{false, func(a, b Word) (Word, error) { return a + b, nil }}, // add
+	ArithmeticOp{false, func(a, b Word) (Word, error) { return a - b, nil }}, // sub
+	ArithmeticOp{false, func(a, b Word) (Word, error) { return a * b, nil }}, // mul
+	ArithmeticOp{false, div}, // divide
+	ArithmeticOp{false, rem}, // remainder
 	ArithmeticOp{false, func(a, b Word) (Word, error) { return a << b, nil }}, // shift left
 	ArithmeticOp{false, func(a, b Word) (Word, error) { return a >> b, nil }}, // shift right
 	ArithmeticOp{false, func(a, b Word) (Word, error) { return a & b, nil }},  // binary and
@@ -87,19 +120,20 @@ var arithmeticOps = [31]ArithmeticOp{
 	ArithmeticOp{true, func(a, b Word) (Word, error) { return a + b, nil }},   // immediate form of add
 	ArithmeticOp{true, func(a, b Word) (Word, error) { return a - b, nil }},   // immediate form of sub
 	ArithmeticOp{true, func(a, b Word) (Word, error) { return a * b, nil }},   // immediate form of mul
-	ArithmeticOp{true, div},                                                   // immediate form of div
-	ArithmeticOp{true, rem},                                                   // immediate form of rem
-	ArithmeticOp{true, func(a, b Word) (Word, error) { return a << b, nil }},  // immediate form of shift left
-	ArithmeticOp{true, func(a, b Word) (Word, error) { return a >> b, nil }},  // immediate form of shift right
-	unimplementedArithmeticOp,
-	unimplementedArithmeticOp,
-	unimplementedArithmeticOp,
-	unimplementedArithmeticOp,
-	unimplementedArithmeticOp,
-	unimplementedArithmeticOp,
-	unimplementedArithmeticOp,
-	unimplementedArithmeticOp,
-	unimplementedArithmeticOp,
+	ArithmeticOp{true, div}, // immediate form of div
+	ArithmeticOp{true, rem}, // immediate form of rem
+	ArithmeticOp{true, func(a, b Word) (Word, error) { return a << b, nil }}, // immediate form of shift left
+	ArithmeticOp{true, func(a, b Word) (Word, error) { return a >> b, nil }}, // immediate form of shift right
+	ArithmeticOp{false, signedDiv},                                           // signed divide
+	ArithmeticOp{false, signedRem},                                           // signed remainder
+	ArithmeticOp{false, signedShiftRight},                                    // arithmetic shift right
+	ArithmeticOp{true, signedShiftRight},                                     // immediate form of arithmetic shift right
+	ArithmeticOp{false, unimplementedBinaryOp},                               // add with carry, needs the status register
+	ArithmeticOp{false, unimplementedBinaryOp},                               // sub with borrow, needs the status register
+	ArithmeticOp{false, fixedMul},                                            // Q8.8 multiply
+	ArithmeticOp{false, fixedDiv},                                            // Q8.8 divide
+	ArithmeticOp{false, unimplementedBinaryOp},                               // half precision float, see float.go
+	ArithmeticOp{false, unimplementedBinaryOp},                               // half precision conversions, see float.go
 }
 
 func init() {
@@ -107,7 +141,60 @@ func init() {
 		panic("Too many arithmetic operations defined! Programmer failure!")
 	}
 }
+
+// The source register takes up the middle byte of an arithmetic instruction so
+// the immediate forms only have room for an 8-bit immediate in the last byte
+func (this *DecodedInstruction) arithmeticImmediate() Word {
+	return Word(this.Data[2])
+}
+
+// Add with carry and sub with borrow touch the status register and the float
+// operations are selected by their last byte, everything else is a pure
+// function of its operands
+func specialArithmetic(op byte) bool {
+	switch op {
+	case ArithmeticOpAddWithCarry, ArithmeticOpSubWithBorrow, ArithmeticOpFloat, ArithmeticOpFloatConvert:
+		return true
+	default:
+		return false
+	}
+}
+func addWithCarry(core *Core, a, b Word) Word {
+	carry := core.status & StatusCarry
+	wide := uint32(a) + uint32(b) + uint32(carry)
+	result := Word(wide)
+	core.status = 0
+	if wide > 0xFFFF {
+		core.status |= StatusCarry
+	}
+	if (a^result)&(b^result)&0x8000 != 0 {
+		core.status |= StatusOverflow
+	}
+	return result
+}
+func subWithBorrow(core *Core, a, b Word) Word {
+	borrow := core.status & StatusCarry
+	result := a - b - borrow
+	core.status = 0
+	if uint32(a) < uint32(b)+uint32(borrow) {
+		core.status |= StatusCarry
+	}
+	if (a^b)&(a^result)&0x8000 != 0 {
+		core.status |= StatusOverflow
+	}
+	return result
+}
 func arithmetic(core *Core, inst *DecodedInstruction) error {
+	switch inst.Op {
+	case ArithmeticOpAddWithCarry:
+		return core.SetRegister(inst.Data[0], addWithCarry(core, core.Register(inst.Data[1]), core.Register(inst.Data[2])))
+	case ArithmeticOpSubWithBorrow:
+		return core.SetRegister(inst.Data[0], subWithBorrow(core, core.Register(inst.Data[1]), core.Register(inst.Data[2])))
+	case ArithmeticOpFloat:
+		return floatArithmetic(core, inst)
+	case ArithmeticOpFloatConvert:
+		return floatConvert(core, inst)
+	}
 	var arg0, arg1 Word
 	var err error
 	dest := inst.Data[0]
@@ -115,7 +202,7 @@ func arithmetic(core *Core, inst *DecodedInstruction) error {
 	result := Word(0)
 	invoke := arithmeticOps[inst.Op]
 	if invoke.ImmediateForm {
-		arg1 = inst.Immediate()
+		arg1 = inst.arithmeticImmediate()
 	} else {
 		arg1 = core.Register(inst.Data[2])
 	}
@@ -125,3 +212,11 @@ func arithmetic(core *Core, inst *DecodedInstruction) error {
 		return core.SetRegister(dest, result)
 	}
 }
+
+func NewDecodedInstructionArithmetic(op, dest, src0, src1 byte) (*DecodedInstruction, error) {
+	if op >= ArithmeticOpCount {
+		return nil, NewError(ErrorOpValueOutOfRange, uint(op))
+	} else {
+		return NewDecodedInstruction(InstructionGroupArithmetic, op, dest, src0, src1)
+	}
+}
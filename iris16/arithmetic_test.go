@@ -109,3 +109,81 @@ func Test_Rem_2(t *testing.T) {
 		t.Logf("Modulus by zero did cause execution to fail: %s", err)
 	}
 }
+
+func Test_SignedDiv_1(t *testing.T) {
+	if core, err := New(); err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	} else if di, err := NewDecodedInstructionArithmetic(ArithmeticOpSignedDiv, UserRegisterBegin+2, UserRegisterBegin, UserRegisterBegin+1); err != nil {
+		t.Errorf("Couldn't construct arithmetic instruction: %s", err)
+	} else if err := core.SetRegister(UserRegisterBegin, 0xFFF6); err != nil {
+		t.Errorf("Couldn't set register %d to -10: %s", UserRegisterBegin, err)
+	} else if err := core.SetRegister(UserRegisterBegin+1, 3); err != nil {
+		t.Errorf("Couldn't set register %d to 3: %s", UserRegisterBegin+1, err)
+	} else if err := core.Invoke(di); err != nil {
+		t.Errorf("Execution failed: %s", err)
+	} else if val := core.Register(di.Data[0]); val != 0xFFFD {
+		t.Errorf("Dividing -10 by 3 did not yield -3, value is %d!", int16(val))
+	}
+}
+
+func Test_AddWithCarry_1(t *testing.T) {
+	// 0x0001FFFF + 0x00000001 as two sixteen bit halves
+	if core, err := New(); err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	} else if di, err := NewDecodedInstructionArithmetic(ArithmeticOpAddWithCarry, UserRegisterBegin, UserRegisterBegin, TrueRegister); err != nil {
+		t.Errorf("Couldn't construct arithmetic instruction: %s", err)
+	} else if hi, err := NewDecodedInstructionArithmetic(ArithmeticOpAddWithCarry, UserRegisterBegin+1, UserRegisterBegin+1, FalseRegister); err != nil {
+		t.Errorf("Couldn't construct arithmetic instruction: %s", err)
+	} else if err := core.SetRegister(UserRegisterBegin, 0xFFFF); err != nil {
+		t.Errorf("Couldn't set register %d: %s", UserRegisterBegin, err)
+	} else if err := core.SetRegister(UserRegisterBegin+1, 1); err != nil {
+		t.Errorf("Couldn't set register %d: %s", UserRegisterBegin+1, err)
+	} else if err := core.Invoke(di); err != nil {
+		t.Errorf("Execution failed: %s", err)
+	} else if core.Status() != StatusCarry {
+		t.Errorf("Adding 1 to 0xFFFF did not set the carry, status is %x!", core.Status())
+	} else if err := core.Invoke(hi); err != nil {
+		t.Errorf("Execution failed: %s", err)
+	} else if lo, hi := core.Register(UserRegisterBegin), core.Register(UserRegisterBegin+1); lo != 0 || hi != 2 {
+		t.Errorf("Expected 0x00020000 but got 0x%04x%04x!", hi, lo)
+	} else if core.Status() != 0 {
+		t.Errorf("The carry was not consumed, status is %x!", core.Status())
+	}
+}
+
+func Test_AddLeavesStatus(t *testing.T) {
+	// only adc and sbb touch the status register
+	core, err := New()
+	if err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	}
+	core.SetStatus(StatusOverflow)
+	if di, err := NewDecodedInstructionArithmetic(ArithmeticOpAdd, UserRegisterBegin, UserRegisterBegin, TrueRegister); err != nil {
+		t.Errorf("Couldn't construct arithmetic instruction: %s", err)
+	} else if err := core.SetRegister(UserRegisterBegin, 0xFFFF); err != nil {
+		t.Errorf("Couldn't set register %d: %s", UserRegisterBegin, err)
+	} else if err := core.Invoke(di); err != nil {
+		t.Errorf("Execution failed: %s", err)
+	} else if core.Status() != StatusOverflow {
+		t.Errorf("add changed the status register to %x!", core.Status())
+	}
+}
+
+// the immediate of an arithmetic instruction is only the last byte, the
+// middle byte is the source register and must not leak into the immediate
+func Test_ArithmeticImmediateIsLastByte(t *testing.T) {
+	di, err := NewDecodedInstructionArithmetic(ArithmeticOpAddImmediate, 6, 7, 5)
+	if err != nil {
+		t.Fatalf("Couldn't construct arithmetic instruction: %s", err)
+	}
+	term, _ := NewDecodedInstruction(InstructionGroupMisc, MiscOpSystemCall, SystemCallTerminate, 0, 0)
+	for _, engine := range []string{EngineInterpreter, EngineThreaded} {
+		core := coreWithProgram(t, engine, []*DecodedInstruction{di, term})
+		core.SetRegister(7, 10)
+		if err := core.Run(); err != nil {
+			t.Errorf("%s: Execution failed: %s", engine, err)
+		} else if r6 := core.Register(6); r6 != 15 {
+			t.Errorf("%s: Adding the immediate 5 to 10 yielded %d instead of 15", engine, r6)
+		}
+	}
+}
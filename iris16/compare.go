@@ -28,6 +28,16 @@ const (
 	CompareOpGreaterThanOrEqualToAnd
 	CompareOpGreaterThanOrEqualToOr
 	CompareOpGreaterThanOrEqualToXor
+	// signed forms, greater than and greater than or equal to are the same
+	// instructions with their operands swapped
+	CompareOpSignedLessThan
+	CompareOpSignedLessThanAnd
+	CompareOpSignedLessThanOr
+	CompareOpSignedLessThanXor
+	CompareOpSignedLessThanOrEqualTo
+	CompareOpSignedLessThanOrEqualToAnd
+	CompareOpSignedLessThanOrEqualToOr
+	CompareOpSignedLessThanOrEqualToXor
 
 	CompareOpCount
 )
@@ -63,6 +73,8 @@ const (
 	CompareBodyGt
 	CompareBodyLe
 	CompareBodyGe
+	CompareBodySignedLt
+	CompareBodySignedLe
 	CompareBodyError
 )
 
@@ -75,6 +87,8 @@ var bodyOps = []compareOpBody{
 	func(a, b Word) (bool, error) { return a > b, nil },
 	func(a, b Word) (bool, error) { return a <= b, nil },
 	func(a, b Word) (bool, error) { return a >= b, nil },
+	func(a, b Word) (bool, error) { return int16(a) < int16(b), nil },
+	func(a, b Word) (bool, error) { return int16(a) <= int16(b), nil },
 	func(a, b Word) (bool, error) { return false, fmt.Errorf("Invalid compare body op!") },
 }
 
@@ -117,17 +131,18 @@ var compareOps = [32]compareOp{
 	{CompareBodyGe, CombineAnd},
 	{CompareBodyGe, CombineOr},
 	{CompareBodyGe, CombineXor},
-	errorCompareOp,
-	errorCompareOp,
-	errorCompareOp,
-	errorCompareOp,
-	errorCompareOp,
-	errorCompareOp,
-	errorCompareOp,
-	errorCompareOp,
+	{CompareBodySignedLt, CombineNone},
+	{CompareBodySignedLt, CombineAnd},
+	{CompareBodySignedLt, CombineOr},
+	{CompareBodySignedLt, CombineXor},
+	{CompareBodySignedLe, CombineNone},
+	{CompareBodySignedLe, CombineAnd},
+	{CompareBodySignedLe, CombineOr},
+	{CompareBodySignedLe, CombineXor},
 }
 
-func boolToWord(val bool) Word {
+// Convert a boolean to the word the compare instructions write, 1 or 0
+func BoolToWord(val bool) Word {
 	if val {
 		return 1
 	} else {
@@ -138,6 +153,6 @@ func compare(core *Core, inst *DecodedInstruction) error {
 	if val, err := compareOps[inst.Op].Invoke(core.PredicateValue(inst.Data[0]), core.Register(inst.Data[1]), core.Register(inst.Data[2])); err != nil {
 		return err
 	} else {
-		return core.SetRegister(inst.Data[0], boolToWord(val))
+		return core.SetRegister(inst.Data[0], BoolToWord(val))
 	}
 }
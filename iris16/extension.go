@@ -0,0 +1,189 @@
+// named extension packs for iris16 cores
+package iris16
+
+import (
+	"fmt"
+	"github.com/DrItanium/cores/registration/machine"
+	"github.com/DrItanium/cores/registration/parser"
+	"sort"
+)
+
+// The assembler name of an extended operation, it accepts the same operand
+// forms as a microcode macro
+type Mnemonic struct {
+	Group, Op byte
+}
+
+// An extension pack bundles new simulator behaviour together with the
+// assembler syntax needed to use it. Execution units can only replace the
+// extended groups (5 through 7) and system calls can't replace the built in
+// ones.
+type Extension struct {
+	Name        string
+	Units       map[byte]ExecutionUnit
+	SystemCalls map[byte]SystemCall
+	// called once per core so that every core gets its own devices
+	Devices   func() []IoDevice
+	Mnemonics map[string]Mnemonic
+}
+
+var extensions map[string]*Extension
+
+func (this *Extension) validate() error {
+	if this.Name == "" {
+		return fmt.Errorf("Extensions must have a name")
+	}
+	for group := range this.Units {
+		if group < InstructionGroupExtendedBegin || group >= MajorOperationGroupCount {
+			return fmt.Errorf("Extension %s can only provide execution units for groups %d through %d, not %d", this.Name, InstructionGroupExtendedBegin, MajorOperationGroupCount-1, group)
+		}
+	}
+	for offset := range this.SystemCalls {
+		if offset < NumberOfSystemCalls {
+			return fmt.Errorf("Extension %s can't replace built in system call %d", this.Name, offset)
+		}
+	}
+	for name, m := range this.Mnemonics {
+		if _, ok := keywords[name]; ok {
+			return fmt.Errorf("Mnemonic %s of extension %s clashes with a built in keyword", name, this.Name)
+		} else if m.Group < InstructionGroupExtendedBegin || m.Group >= MajorOperationGroupCount {
+			return fmt.Errorf("Mnemonic %s of extension %s must be in an extended group", name, this.Name)
+		} else if m.Op >= 32 {
+			return fmt.Errorf("Mnemonic %s of extension %s has an op (%d) which is too large", name, this.Name, m.Op)
+		}
+	}
+	return nil
+}
+
+func RegisterExtension(ext *Extension) error {
+	if extensions == nil {
+		extensions = make(map[string]*Extension)
+	}
+	if err := ext.validate(); err != nil {
+		return err
+	} else if _, ok := extensions[ext.Name]; ok {
+		return fmt.Errorf("Extension %s is already registered!", ext.Name)
+	} else {
+		extensions[ext.Name] = ext
+		return nil
+	}
+}
+func GetRegisteredExtensions() []string {
+	var names []string
+	for name, _ := range extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+func lookupExtension(name string) (*Extension, error) {
+	if ext, ok := extensions[name]; !ok {
+		return nil, fmt.Errorf("%s does not refer to a registered iris16 extension!", name)
+	} else {
+		return ext, nil
+	}
+}
+
+func (this *Core) InstallExtension(name string) error {
+	ext, err := lookupExtension(name)
+	if err != nil {
+		return err
+	}
+	// check everything before touching the core so a refused extension
+	// leaves nothing behind
+	for _, installed := range this.extensions {
+		if installed == name {
+			return fmt.Errorf("Extension %s is already installed", name)
+		}
+		other, _ := lookupExtension(installed)
+		for group := range ext.Units {
+			if _, ok := other.Units[group]; ok {
+				return fmt.Errorf("Extension %s wants group %d which extension %s already provides", name, group, installed)
+			}
+		}
+		for offset := range ext.SystemCalls {
+			if _, ok := other.SystemCalls[offset]; ok {
+				return fmt.Errorf("Extension %s wants system call %d which extension %s already provides", name, offset, installed)
+			}
+		}
+	}
+	for group, unit := range ext.Units {
+		if err := this.InstallExecutionUnit(group, unit); err != nil {
+			return err
+		}
+	}
+	for offset, fn := range ext.SystemCalls {
+		if err := this.InstallSystemCall(offset, fn); err != nil {
+			return err
+		}
+	}
+	if ext.Devices != nil {
+		for _, dev := range ext.Devices() {
+			if err := this.RegisterIoDevice(dev); err != nil {
+				return err
+			}
+		}
+	}
+	this.extensions = append(this.extensions, name)
+	return nil
+}
+
+// Make the mnemonics of an extension available to the assembler. Macros
+// defined afterwards stay out of the groups the extension takes over.
+func (this *_parser) InstallExtension(name string) error {
+	ext, err := lookupExtension(name)
+	if err != nil {
+		return err
+	}
+	for mnemonic, m := range ext.Mnemonics {
+		if _, ok := this.macros[mnemonic]; ok {
+			return fmt.Errorf("Mnemonic %s of extension %s is already defined", mnemonic, name)
+		}
+		this.macros[mnemonic] = Word(m.Group-InstructionGroupExtendedBegin)*32 + Word(m.Op)
+	}
+	for group := range ext.Units {
+		this.reservedGroups[group] = true
+	}
+	return nil
+}
+
+// Register a new target made up of an iris16 core with the given extensions
+// installed, both the simulator and the assembler use the given name
+func RegisterTarget(name string, exts ...string) error {
+	for _, ext := range exts {
+		if _, err := lookupExtension(ext); err != nil {
+			return err
+		}
+	}
+	newCore := func(a ...interface{}) (machine.Machine, error) {
+		if core, err := New(); err != nil {
+			return nil, err
+		} else {
+			for _, ext := range exts {
+				if err := core.InstallExtension(ext); err != nil {
+					return nil, err
+				}
+			}
+			return core, nil
+		}
+	}
+	newParser := func(a ...interface{}) (parser.Parser, error) {
+		if p, err := generateParser(a...); err != nil {
+			return nil, err
+		} else {
+			for _, ext := range exts {
+				if err := p.(*_parser).InstallExtension(ext); err != nil {
+					return nil, err
+				}
+			}
+			return p, nil
+		}
+	}
+	if err := machine.Register(name, machine.Registrar(newCore)); err != nil {
+		return err
+	} else if err := parser.Register(name, parser.Registrar(newParser)); err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
@@ -0,0 +1,84 @@
+package iris16
+
+import "testing"
+
+func init() {
+	if err := RegisterExtension(&Extension{
+		Name: "test-double",
+		Units: map[byte]ExecutionUnit{
+			InstructionGroupExtendedBegin: func(core *Core, inst *DecodedInstruction) error {
+				return core.SetRegister(inst.Data[0], core.Register(inst.Data[1])*2)
+			},
+		},
+		Mnemonics: map[string]Mnemonic{"twice": {Group: InstructionGroupExtendedBegin, Op: 0}},
+	}); err != nil {
+		panic(err)
+	} else if err := RegisterExtension(&Extension{
+		Name:  "test-clash",
+		Units: map[byte]ExecutionUnit{InstructionGroupExtendedBegin: extendedUnit},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func Test_ExtensionValidation(t *testing.T) {
+	for _, ext := range []*Extension{
+		{},
+		{Name: "bad-group", Units: map[byte]ExecutionUnit{InstructionGroupMove: extendedUnit}},
+		{Name: "bad-syscall", SystemCalls: map[byte]SystemCall{SystemCallPutc: putcSystemCall}},
+		{Name: "bad-mnemonic", Mnemonics: map[string]Mnemonic{"add": {Group: InstructionGroupExtendedBegin}}},
+		{Name: "test-double"},
+	} {
+		if err := RegisterExtension(ext); err == nil {
+			t.Errorf("Extension %q should have been rejected", ext.Name)
+		}
+	}
+}
+
+func Test_ExtensionInstall(t *testing.T) {
+	core, err := New()
+	if err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	} else if err := core.InstallExtension("test-double"); err != nil {
+		t.Fatalf("Couldn't install extension: %s", err)
+	} else if err := core.InstallExtension("test-double"); err == nil {
+		t.Fatalf("Installing an extension twice should fail")
+	} else if err := core.InstallExtension("test-clash"); err == nil {
+		t.Fatalf("Two extensions can't provide the same group")
+	}
+	p, err := generateParser()
+	if err != nil {
+		t.Fatalf("Couldn't create parser %s", err)
+	} else if err := p.(*_parser).InstallExtension("test-double"); err != nil {
+		t.Fatalf("Couldn't install extension into the parser: %s", err)
+	}
+	asm, err := assembleWith(p.(*_parser), `
+	.microcode
+	.macro plus
+	add a = b, c
+	.endmacro
+	.code
+	twice r7 = r6
+	plus r8 = r7, r7
+	`)
+	if err != nil {
+		t.Fatalf("Couldn't assemble program: %s", err)
+	}
+	// the extension owns group 5 so the macro has to go in group 6
+	if inst, _ := asm.CodeMemory(1).Decode(); inst.Group != InstructionGroupExtendedBegin+1 || inst.Op != 0 {
+		t.Fatalf("The macro should be group %d op 0 but is group %d op %d", InstructionGroupExtendedBegin+1, inst.Group, inst.Op)
+	}
+	copy(core.code[:], asm.code[:])
+	copy(core.ucode[:], asm.ucode[:])
+	core.flushDecodedInstructions()
+	core.SetRegister(6, 21)
+	for i := 0; i < 2; i++ {
+		if err := core.ExecuteCurrentInstruction(); err != nil {
+			t.Fatalf("Execution failed: %s", err)
+		}
+		core.AdvanceProgramCounter()
+	}
+	if r8 := core.Register(8); r8 != 84 {
+		t.Fatalf("Expected 84 but got %d", r8)
+	}
+}
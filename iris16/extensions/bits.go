@@ -0,0 +1,54 @@
+// extension packs shipped with iris16
+package extensions
+
+import (
+	"github.com/DrItanium/cores/iris16"
+	"math/bits"
+)
+
+// bit manipulation operations that take too many instructions to do by hand,
+// they take over the last extended group
+const (
+	BitsGroup = iris16.MajorOperationGroupCount - 1
+)
+const (
+	BitsOpPopCount = iota
+	BitsOpCountLeadingZeros
+	BitsOpCountTrailingZeros
+	BitsOpRotateLeft
+	BitsOpRotateRight
+	BitsOpByteSwap
+	BitsOpCount
+)
+
+var bitsOps = [BitsOpCount]func(b, c iris16.Word) iris16.Word{
+	func(b, _ iris16.Word) iris16.Word { return iris16.Word(bits.OnesCount16(uint16(b))) },
+	func(b, _ iris16.Word) iris16.Word { return iris16.Word(bits.LeadingZeros16(uint16(b))) },
+	func(b, _ iris16.Word) iris16.Word { return iris16.Word(bits.TrailingZeros16(uint16(b))) },
+	func(b, c iris16.Word) iris16.Word { return iris16.Word(bits.RotateLeft16(uint16(b), int(c&0xF))) },
+	func(b, c iris16.Word) iris16.Word { return iris16.Word(bits.RotateLeft16(uint16(b), -int(c&0xF))) },
+	func(b, _ iris16.Word) iris16.Word { return iris16.Word(bits.ReverseBytes16(uint16(b))) },
+}
+
+func bitsUnit(core *iris16.Core, inst *iris16.DecodedInstruction) error {
+	if inst.Op >= BitsOpCount {
+		return iris16.NewError(iris16.ErrorUndefinedExtendedOperation, uint(inst.Op))
+	}
+	b, c := core.Register(inst.Data[1]), core.Register(inst.Data[2])
+	return core.SetRegister(inst.Data[0], bitsOps[inst.Op](b, c))
+}
+
+func registerBits() error {
+	return iris16.RegisterExtension(&iris16.Extension{
+		Name:  "bits",
+		Units: map[byte]iris16.ExecutionUnit{BitsGroup: bitsUnit},
+		Mnemonics: map[string]iris16.Mnemonic{
+			"popcount": {Group: BitsGroup, Op: BitsOpPopCount},
+			"clz":      {Group: BitsGroup, Op: BitsOpCountLeadingZeros},
+			"ctz":      {Group: BitsGroup, Op: BitsOpCountTrailingZeros},
+			"rotl":     {Group: BitsGroup, Op: BitsOpRotateLeft},
+			"rotr":     {Group: BitsGroup, Op: BitsOpRotateRight},
+			"bswap":    {Group: BitsGroup, Op: BitsOpByteSwap},
+		},
+	})
+}
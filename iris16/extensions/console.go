@@ -0,0 +1,30 @@
+package extensions
+
+import (
+	"github.com/DrItanium/cores/iris16"
+	"io"
+)
+
+// console input to go with the putc system call, system #x21, rA, rA reads the
+// next byte of input into rA and gives 0xFFFF once the input runs dry
+const (
+	SystemCallGetc = 0x21
+)
+
+func getcSystemCall(core *iris16.Core, inst *iris16.DecodedInstruction) error {
+	var b [1]byte
+	if _, err := io.ReadFull(core.Input(), b[:]); err == io.EOF {
+		return core.SetRegister(inst.Data[1], 0xFFFF)
+	} else if err != nil {
+		return err
+	} else {
+		return core.SetRegister(inst.Data[1], iris16.Word(b[0]))
+	}
+}
+
+func registerConsole() error {
+	return iris16.RegisterExtension(&iris16.Extension{
+		Name:        "console",
+		SystemCalls: map[byte]iris16.SystemCall{SystemCallGetc: getcSystemCall},
+	})
+}
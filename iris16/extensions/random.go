@@ -0,0 +1,67 @@
+package extensions
+
+import (
+	"github.com/DrItanium/cores/iris16"
+)
+
+// a pseudo random number generator, the seed is written through io memory and
+// numbers are read either through io memory or the random system call
+const (
+	RandomAddress    = 0xFF00
+	SystemCallRandom = 0x20
+)
+
+// 16-bit xorshift, the same seed always gives the same sequence
+type randomDevice struct {
+	state iris16.Word
+}
+
+func (this *randomDevice) Begin() iris16.Word {
+	return RandomAddress
+}
+func (this *randomDevice) End() iris16.Word {
+	return RandomAddress
+}
+func (this *randomDevice) RespondsTo(address iris16.Word) bool {
+	return address == RandomAddress
+}
+func (this *randomDevice) Startup() error {
+	return nil
+}
+func (this *randomDevice) Shutdown() error {
+	return nil
+}
+func (this *randomDevice) Load(_ iris16.Word) (iris16.Word, error) {
+	x := this.state
+	x ^= x << 7
+	x ^= x >> 9
+	x ^= x << 8
+	this.state = x
+	return x, nil
+}
+func (this *randomDevice) Store(_, value iris16.Word) error {
+	if value == 0 {
+		// xorshift gets stuck on zero
+		value = 1
+	}
+	this.state = value
+	return nil
+}
+
+func randomSystemCall(core *iris16.Core, inst *iris16.DecodedInstruction) error {
+	if value, err := core.IoMemory(RandomAddress); err != nil {
+		return err
+	} else {
+		return core.SetRegister(inst.Data[1], value)
+	}
+}
+
+func registerRandom() error {
+	return iris16.RegisterExtension(&iris16.Extension{
+		Name:        "random",
+		SystemCalls: map[byte]iris16.SystemCall{SystemCallRandom: randomSystemCall},
+		Devices: func() []iris16.IoDevice {
+			return []iris16.IoDevice{&randomDevice{state: 1}}
+		},
+	})
+}
@@ -0,0 +1,18 @@
+package extensions
+
+import (
+	"github.com/DrItanium/cores/iris16"
+)
+
+// The extensions are registered here rather than in the init of their own
+// files so that the target never depends on the order files are initialized in
+func init() {
+	for _, register := range []func() error{registerBits, registerConsole, registerRandom} {
+		if err := register(); err != nil {
+			panic(err)
+		}
+	}
+	if err := iris16.RegisterTarget("iris16-ext", "bits", "console", "random"); err != nil {
+		panic(err)
+	}
+}
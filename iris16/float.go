@@ -0,0 +1,144 @@
+package iris16
+
+import (
+	"fmt"
+	"math"
+)
+
+// IEEE 754 half precision (binary16) operations on plain words. Every result
+// is computed exactly in float64 and rounded once to the nearest half, ties to
+// even, so the results match hardware half precision units bit for bit.
+//
+// Only two arithmetic slots were left so float operations are selected by the
+// last byte of the instruction. ArithmeticOpFloat is two address, the
+// destination is also the first source (fadd r10 = r10, r7), and
+// ArithmeticOpFloatConvert takes a single source (itof r10 = r8).
+const (
+	FloatOpAdd = iota
+	FloatOpSub
+	FloatOpMul
+	FloatOpDiv
+	FloatOpEqual
+	FloatOpLessThan
+	FloatOpLessThanOrEqualTo
+	// always last
+	FloatOpCount
+)
+const (
+	FloatConvertFromInteger = iota
+	FloatConvertToInteger
+	FloatConvertFromFixed
+	FloatConvertToFixed
+	// always last
+	FloatConvertCount
+)
+
+const (
+	halfSignBit      = 0x8000
+	halfInfinity     = 0x7C00
+	halfQuietNaN     = 0x7E00
+	halfMantissaBits = 10
+	halfExponentBias = 15
+	// the smallest magnitude which rounds up to infinity, halfway between the
+	// largest finite half (65504) and 65536
+	halfOverflow = 65520
+)
+
+func halfToFloat64(h Word) float64 {
+	exponent := int((h >> halfMantissaBits) & 0x1F)
+	mantissa := float64(h & 0x3FF)
+	var f float64
+	switch exponent {
+	case 0:
+		// subnormal
+		f = math.Ldexp(mantissa, 1-halfExponentBias-halfMantissaBits)
+	case 0x1F:
+		if mantissa != 0 {
+			return math.NaN()
+		}
+		f = math.Inf(1)
+	default:
+		f = math.Ldexp(mantissa+(1<<halfMantissaBits), exponent-halfExponentBias-halfMantissaBits)
+	}
+	if h&halfSignBit != 0 {
+		return -f
+	} else {
+		return f
+	}
+}
+
+func halfFromFloat64(f float64) Word {
+	if math.IsNaN(f) {
+		return halfQuietNaN
+	}
+	var sign Word
+	if math.Signbit(f) {
+		sign = halfSignBit
+		f = -f
+	}
+	if f >= halfOverflow {
+		return sign | halfInfinity
+	} else if f < math.Ldexp(1, 1-halfExponentBias) {
+		// subnormal, this can round up into the smallest normal which has
+		// the same encoding as a mantissa of 1024
+		return sign | Word(math.RoundToEven(math.Ldexp(f, halfExponentBias-1+halfMantissaBits)))
+	}
+	_, exp := math.Frexp(f)
+	exponent := exp - 1
+	mantissa := math.RoundToEven((math.Ldexp(f, -exponent) - 1) * (1 << halfMantissaBits))
+	if mantissa == 1<<halfMantissaBits {
+		mantissa = 0
+		exponent++
+	}
+	return sign | Word(exponent+halfExponentBias)<<halfMantissaBits | Word(mantissa)
+}
+
+// saturate a rounded value into the range of a signed word, NaN becomes zero
+func saturate(f float64) Word {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f >= math.MaxInt16:
+		return math.MaxInt16
+	case f <= math.MinInt16:
+		return Word(0x8000)
+	default:
+		return Word(int16(f))
+	}
+}
+
+var floatOps = [FloatOpCount]func(a, b float64) Word{
+	func(a, b float64) Word { return halfFromFloat64(a + b) },
+	func(a, b float64) Word { return halfFromFloat64(a - b) },
+	func(a, b float64) Word { return halfFromFloat64(a * b) },
+	func(a, b float64) Word { return halfFromFloat64(a / b) },
+	// comparisons are false whenever either side is NaN
+	func(a, b float64) Word { return BoolToWord(a == b) },
+	func(a, b float64) Word { return BoolToWord(a < b) },
+	func(a, b float64) Word { return BoolToWord(a <= b) },
+}
+
+// integers and fixed point values are signed
+var floatConversions = [FloatConvertCount]func(Word) Word{
+	func(raw Word) Word { return halfFromFloat64(float64(int16(raw))) },
+	func(raw Word) Word { return saturate(math.Trunc(halfToFloat64(raw))) },
+	func(raw Word) Word { return halfFromFloat64(float64(int16(raw)) / 256) },
+	func(raw Word) Word { return saturate(math.RoundToEven(halfToFloat64(raw) * 256)) },
+}
+
+func floatArithmetic(core *Core, inst *DecodedInstruction) error {
+	if inst.Data[2] >= FloatOpCount {
+		return fmt.Errorf("Illegal float operation %d", inst.Data[2])
+	} else {
+		a, b := halfToFloat64(core.Register(inst.Data[0])), halfToFloat64(core.Register(inst.Data[1]))
+		return core.SetRegister(inst.Data[0], floatOps[inst.Data[2]](a, b))
+	}
+}
+
+func floatConvert(core *Core, inst *DecodedInstruction) error {
+	if inst.Data[2] >= FloatConvertCount {
+		return fmt.Errorf("Illegal float conversion %d", inst.Data[2])
+	} else {
+		return core.SetRegister(inst.Data[0], floatConversions[inst.Data[2]](core.Register(inst.Data[1])))
+	}
+}
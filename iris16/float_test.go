@@ -0,0 +1,69 @@
+package iris16
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_HalfRoundTrip(t *testing.T) {
+	// every half other than NaN is exactly representable as a float64
+	for i := 0; i < 0x10000; i++ {
+		h := Word(i)
+		if f := halfToFloat64(h); math.IsNaN(f) {
+			continue
+		} else if back := halfFromFloat64(f); back != h {
+			t.Fatalf("%04x became %v and then %04x", h, f, back)
+		}
+	}
+}
+
+func Test_HalfRounding(t *testing.T) {
+	for _, c := range []struct {
+		in  float64
+		out Word
+	}{
+		{1 + math.Ldexp(1, -11), 0x3C00},   // tie between 1 and the next half goes to even
+		{1 + 3*math.Ldexp(1, -11), 0x3C02}, // and up when the even one is above
+		{65519, 0x7BFF},
+		{65520, 0x7C00},
+		{math.Ldexp(1, -25), 0x0000},      // half of the smallest subnormal ties to zero
+		{math.Ldexp(3, -25), 0x0002},      // one and a half rounds to two
+		{-math.Ldexp(1, -14), 0x8400},     // smallest normal
+		{math.Ldexp(1023.5, -24), 0x0400}, // the largest subnormal rounds into the normals
+	} {
+		if got := halfFromFloat64(c.in); got != c.out {
+			t.Errorf("%v should round to %04x but got %04x", c.in, c.out, got)
+		}
+	}
+}
+
+func Test_FloatArithmeticSlots(t *testing.T) {
+	core, err := assemble(`
+	.code
+	set r6 = #x3C00
+	set r7 = #x4000
+	fadd r6 = r6, r7
+	set r8 = #65526
+	itof r9 = r8
+	system #0, r0, r0
+	`)
+	if err != nil {
+		t.Fatalf("Couldn't assemble program: %s", err)
+	} else if inst, _ := core.CodeMemory(2).Decode(); inst.Group != InstructionGroupArithmetic || inst.Op != ArithmeticOpFloat || inst.Data[2] != FloatOpAdd {
+		t.Fatalf("fadd should be arithmetic op %d selecting %d but is %v", ArithmeticOpFloat, FloatOpAdd, inst)
+	} else if inst, _ := core.CodeMemory(4).Decode(); inst.Group != InstructionGroupArithmetic || inst.Op != ArithmeticOpFloatConvert || inst.Data[2] != FloatConvertFromInteger {
+		t.Fatalf("itof should be arithmetic op %d selecting %d but is %v", ArithmeticOpFloatConvert, FloatConvertFromInteger, inst)
+	} else if err := core.Run(); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	} else if r6 := core.Register(6); r6 != 0x4200 {
+		t.Errorf("1 + 2 should be %04x but got %04x", 0x4200, r6)
+	} else if r9 := core.Register(9); r9 != 0xC900 {
+		t.Errorf("-10 should convert to %04x but got %04x", 0xC900, r9)
+	}
+	if _, err := assemble(".code\nfadd r6 = r7, r8"); err == nil {
+		t.Errorf("A float operation whose destination isn't its first source assembled")
+	}
+	if _, err := assemble(".code\nfadd r6 = r6, #1"); err == nil {
+		t.Errorf("A float operation with an immediate assembled")
+	}
+}
@@ -0,0 +1,21 @@
+package iris16
+
+import "testing"
+
+func FuzzInstructionRoundTrip(f *testing.F) {
+	f.Add(uint32(0))
+	f.Add(uint32(0x00410611))
+	f.Add(uint32(0xFFFFFFFF))
+	f.Fuzz(func(t *testing.T, raw uint32) {
+		inst := Instruction(raw)
+		if di, err := inst.Decode(); err != nil {
+			t.Fatalf("Couldn't decode %08x: %s", raw, err)
+		} else if back := di.Encode(); *back != inst {
+			t.Fatalf("%08x was encoded back into %08x", raw, uint32(*back))
+		} else if other, err := back.Decode(); err != nil {
+			t.Fatalf("Couldn't decode %08x a second time: %s", raw, err)
+		} else if *other != *di {
+			t.Fatalf("%08x decoded differently the second time", raw)
+		}
+	})
+}
@@ -0,0 +1,630 @@
+// gdb remote serial protocol stub for the iris16 core
+package iris16
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// gdb sees memory as a single flat byte addressed space so each segment is
+// placed into its own window. The code segment is four bytes per instruction,
+// every other segment is two bytes per word.
+const (
+	gdbSegmentShift = 20
+	gdbSegmentMask  = (1 << gdbSegmentShift) - 1
+)
+
+// the largest packet we tell gdb we can take, memory reads are hex encoded so
+// at most half of it can be read at once
+const gdbPacketSize = 0x4000
+const (
+	// registers past the general purpose registers
+	gdbRegisterPc = RegisterCount + iota
+	gdbRegisterSp
+	gdbRegisterCp
+	gdbRegisterPredicate
+	gdbRegisterCount
+)
+
+var gdbSpecialRegisters = []struct {
+	name    string
+	bitsize int
+	kind    string
+}{
+	{"pc", 32, "code_ptr"},
+	{"sp", 16, "uint16"},
+	{"cp", 16, "uint16"},
+	{"pred", 16, "uint16"},
+}
+
+func gdbTargetDescription() string {
+	var buf bytes.Buffer
+	buf.WriteString("<?xml version=\"1.0\"?>\n<!DOCTYPE target SYSTEM \"gdb-target.dtd\">\n<target version=\"1.0\">\n")
+	buf.WriteString("  <feature name=\"org.dritanium.iris16.core\">\n")
+	for i := 0; i < RegisterCount; i++ {
+		fmt.Fprintf(&buf, "    <reg name=\"r%d\" bitsize=\"16\" type=\"uint16\" regnum=\"%d\"/>\n", i, i)
+	}
+	for i, reg := range gdbSpecialRegisters {
+		fmt.Fprintf(&buf, "    <reg name=\"%s\" bitsize=\"%d\" type=\"%s\" regnum=\"%d\"/>\n", reg.name, reg.bitsize, reg.kind, RegisterCount+i)
+	}
+	buf.WriteString("  </feature>\n</target>\n")
+	return buf.String()
+}
+
+// Listen on the given address (a host:port pair or a unix socket path) for a
+// single gdb connection and let it drive the core. Detaching runs the rest of
+// the program to completion, killing the session stops it where it is.
+func (this *Core) ServeGdb(address string) error {
+	network := "tcp"
+	if !strings.Contains(address, ":") || strings.Contains(address, "/") {
+		network = "unix"
+	}
+	if listener, err := net.Listen(network, address); err != nil {
+		return err
+	} else {
+		defer listener.Close()
+		fmt.Fprintf(os.Stderr, "Waiting for gdb connection on %s %s\n", network, address)
+		if conn, err := listener.Accept(); err != nil {
+			return err
+		} else {
+			defer conn.Close()
+			return this.serveGdbConnection(conn)
+		}
+	}
+}
+
+type gdbWatchKey struct {
+	kind          byte
+	address, size uint64
+}
+
+type gdbSession struct {
+	core        *Core
+	conn        io.ReadWriter
+	in          chan byte
+	noAck       bool
+	exited      bool
+	breakpoints map[Word]bool
+	watches     map[gdbWatchKey]int
+}
+
+func (this *Core) serveGdbConnection(conn io.ReadWriter) error {
+	session := gdbSession{
+		core:        this,
+		conn:        conn,
+		in:          make(chan byte, 4096),
+		breakpoints: make(map[Word]bool),
+		watches:     make(map[gdbWatchKey]int),
+	}
+	go session.receive()
+	return session.serve()
+}
+
+func (this *gdbSession) receive() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := this.conn.Read(buf)
+		for _, b := range buf[:n] {
+			this.in <- b
+		}
+		if err != nil {
+			close(this.in)
+			return
+		}
+	}
+}
+
+const gdbInterrupt = 0x03
+
+// read the next packet, returns the contents and whether the connection is still open
+func (this *gdbSession) readPacket() (string, bool) {
+	for {
+		b, more := <-this.in
+		if !more {
+			return "", false
+		}
+		switch b {
+		case '$':
+			var body bytes.Buffer
+			var sum byte
+			for {
+				if c, more := <-this.in; !more {
+					return "", false
+				} else if c == '#' {
+					break
+				} else {
+					body.WriteByte(c)
+					sum += c
+				}
+			}
+			hi, more0 := <-this.in
+			lo, more1 := <-this.in
+			if !more0 || !more1 {
+				return "", false
+			}
+			if expected, err := strconv.ParseUint(string([]byte{hi, lo}), 16, 8); err != nil || byte(expected) != sum {
+				if !this.noAck {
+					this.conn.Write([]byte{'-'})
+				}
+				continue
+			}
+			if !this.noAck {
+				this.conn.Write([]byte{'+'})
+			}
+			return body.String(), true
+		case gdbInterrupt:
+			// stop requests while already stopped just get the stop reason again
+			return "?", true
+		}
+		// acks and anything else outside of a packet are dropped
+	}
+}
+
+func gdbEscape(data string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '#', '$', '}', '*':
+			buf.WriteByte('}')
+			buf.WriteByte(c ^ 0x20)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+func (this *gdbSession) sendPacket(data string) error {
+	var sum byte
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	packet := fmt.Sprintf("$%s#%02x", data, sum)
+	for {
+		if _, err := io.WriteString(this.conn, packet); err != nil {
+			return err
+		} else if this.noAck {
+			return nil
+		}
+		// wait for the acknowledgement and resend on a nack
+		for {
+			if b, more := <-this.in; !more {
+				return fmt.Errorf("gdb connection closed while waiting for an acknowledgement")
+			} else if b == '+' {
+				return nil
+			} else if b == '-' {
+				break
+			}
+		}
+	}
+}
+
+func (this *gdbSession) serve() error {
+	for {
+		packet, open := this.readPacket()
+		if !open {
+			return nil
+		}
+		if len(packet) == 0 {
+			continue
+		}
+		var reply string
+		switch packet[0] {
+		case '?':
+			reply = this.stopReason()
+		case 'g':
+			reply = this.readRegisters()
+		case 'G':
+			reply = this.writeRegisters(packet[1:])
+		case 'p':
+			reply = this.readRegister(packet[1:])
+		case 'P':
+			reply = this.writeRegister(packet[1:])
+		case 'm':
+			reply = this.readMemory(packet[1:])
+		case 'M':
+			reply = this.writeMemory(packet[1:])
+		case 'c':
+			reply = this.resume(packet[1:], false)
+		case 's':
+			reply = this.resume(packet[1:], true)
+		case 'Z':
+			reply = this.insertPoint(packet[1:])
+		case 'z':
+			reply = this.removePoint(packet[1:])
+		case 'H', 'T':
+			reply = "OK"
+		case 'D':
+			if err := this.sendPacket("OK"); err != nil {
+				return err
+			} else if this.exited {
+				return nil
+			} else {
+				this.core.ResumeExecution()
+				return this.core.Run()
+			}
+		case 'k':
+			return nil
+		case 'q', 'Q':
+			reply = this.query(packet)
+		}
+		if err := this.sendPacket(reply); err != nil {
+			return err
+		}
+		if packet == "QStartNoAckMode" {
+			this.noAck = true
+		}
+	}
+}
+
+func (this *gdbSession) query(packet string) string {
+	switch {
+	case strings.HasPrefix(packet, "qSupported"):
+		return fmt.Sprintf("PacketSize=%x;qXfer:features:read+;QStartNoAckMode+;swbreak+;hwbreak+", gdbPacketSize)
+	case packet == "QStartNoAckMode":
+		return "OK"
+	case packet == "qAttached":
+		return "1"
+	case packet == "qC":
+		return "QC1"
+	case packet == "qfThreadInfo":
+		return "m1"
+	case packet == "qsThreadInfo":
+		return "l"
+	case strings.HasPrefix(packet, "qXfer:features:read:target.xml:"):
+		var offset, length uint64
+		if _, err := fmt.Sscanf(strings.TrimPrefix(packet, "qXfer:features:read:target.xml:"), "%x,%x", &offset, &length); err != nil {
+			return "E00"
+		}
+		desc := gdbTargetDescription()
+		if offset >= uint64(len(desc)) {
+			return "l"
+		} else if end := offset + length; end < uint64(len(desc)) {
+			return "m" + gdbEscape(desc[offset:end])
+		} else {
+			return "l" + gdbEscape(desc[offset:])
+		}
+	default:
+		return ""
+	}
+}
+
+func (this *gdbSession) stopReason() string {
+	if this.exited {
+		return "W00"
+	} else {
+		return "S05"
+	}
+}
+
+func gdbEncodeValue(value uint64, bytes int) string {
+	buf := make([]byte, bytes)
+	for i := range buf {
+		buf[i] = byte(value >> (8 * uint(i)))
+	}
+	return hex.EncodeToString(buf)
+}
+func gdbDecodeValue(str string) (uint64, error) {
+	if buf, err := hex.DecodeString(str); err != nil {
+		return 0, err
+	} else {
+		var value uint64
+		for i := len(buf) - 1; i >= 0; i-- {
+			value = (value << 8) | uint64(buf[i])
+		}
+		return value, nil
+	}
+}
+
+func (this *gdbSession) registerValue(index int) (string, error) {
+	core := this.core
+	switch {
+	case index < RegisterCount:
+		return gdbEncodeValue(uint64(core.Register(byte(index))), 2), nil
+	case index == gdbRegisterPc:
+		return gdbEncodeValue(uint64(core.instructionPointer)*4, 4), nil
+	case index == gdbRegisterSp:
+		return gdbEncodeValue(uint64(core.stackPointer), 2), nil
+	case index == gdbRegisterCp:
+		return gdbEncodeValue(uint64(core.callPointer), 2), nil
+	case index == gdbRegisterPredicate:
+		return gdbEncodeValue(uint64(core.predicate), 2), nil
+	default:
+		return "", fmt.Errorf("Register %d does not exist!", index)
+	}
+}
+func gdbRegisterWidth(index int) int {
+	if index == gdbRegisterPc {
+		return 8
+	} else {
+		return 4
+	}
+}
+func (this *gdbSession) setRegisterValue(index int, value uint64) error {
+	core := this.core
+	switch {
+	case index == FalseRegister, index == TrueRegister:
+		// gdb writes back every register so quietly ignore the constant ones
+		return nil
+	case index < RegisterCount:
+		return core.SetRegister(byte(index), Word(value))
+	case index == gdbRegisterPc:
+		return core.SetRegister(InstructionPointer, Word(value/4))
+	case index == gdbRegisterSp:
+		return core.SetRegister(StackPointer, Word(value))
+	case index == gdbRegisterCp:
+		return core.SetRegister(CallPointer, Word(value))
+	case index == gdbRegisterPredicate:
+		return core.SetRegister(PredicateRegister, Word(value))
+	default:
+		return fmt.Errorf("Register %d does not exist!", index)
+	}
+}
+
+func (this *gdbSession) readRegisters() string {
+	var buf bytes.Buffer
+	for i := 0; i < gdbRegisterCount; i++ {
+		value, _ := this.registerValue(i)
+		buf.WriteString(value)
+	}
+	return buf.String()
+}
+func (this *gdbSession) writeRegisters(data string) string {
+	for i := 0; i < gdbRegisterCount && len(data) > 0; i++ {
+		width := gdbRegisterWidth(i)
+		if len(data) < width {
+			return "E00"
+		} else if value, err := gdbDecodeValue(data[:width]); err != nil {
+			return "E00"
+		} else if err := this.setRegisterValue(i, value); err != nil {
+			return "E01"
+		}
+		data = data[width:]
+	}
+	return "OK"
+}
+func (this *gdbSession) readRegister(data string) string {
+	if index, err := strconv.ParseUint(data, 16, 16); err != nil {
+		return "E00"
+	} else if value, err := this.registerValue(int(index)); err != nil {
+		return "E01"
+	} else {
+		return value
+	}
+}
+func (this *gdbSession) writeRegister(data string) string {
+	parts := strings.SplitN(data, "=", 2)
+	if len(parts) != 2 {
+		return "E00"
+	} else if index, err := strconv.ParseUint(parts[0], 16, 16); err != nil {
+		return "E00"
+	} else if value, err := gdbDecodeValue(parts[1]); err != nil {
+		return "E00"
+	} else if err := this.setRegisterValue(int(index), value); err != nil {
+		return "E01"
+	} else {
+		return "OK"
+	}
+}
+
+// split a gdb address into its segment and the byte offset within that segment
+func gdbSplitAddress(address uint64) (segment, uint64, error) {
+	seg, offset := segment(address>>gdbSegmentShift), address&gdbSegmentMask
+	switch {
+	case seg == codeSegment && offset < MemorySize*4:
+		return seg, offset, nil
+	case seg > codeSegment && seg < numSegments && offset < MemorySize*2:
+		return seg, offset, nil
+	default:
+		return 0, 0, fmt.Errorf("Address %x is not mapped", address)
+	}
+}
+func gdbJoinAddress(seg segment, offset uint64) uint64 {
+	return (uint64(seg) << gdbSegmentShift) | offset
+}
+
+// Debugger accesses go straight to memory so they don't trigger watchpoints.
+// The io segment has no memory behind it and touching a device has side
+// effects (consuming input, firing watchpoints) so the debugger can't access it.
+func (this *gdbSession) loadByte(address uint64) (byte, error) {
+	if seg, offset, err := gdbSplitAddress(address); err != nil {
+		return 0, err
+	} else if seg == codeSegment {
+		return byte(this.core.code[offset/4] >> (8 * (offset % 4))), nil
+	} else if seg == ioSegment {
+		return 0, fmt.Errorf("The debugger can't access io address %x", offset/2)
+	} else {
+		return byte(this.core.wordSegment(seg)[offset/2] >> (8 * (offset % 2))), nil
+	}
+}
+func (this *gdbSession) storeByte(address uint64, value byte) error {
+	if seg, offset, err := gdbSplitAddress(address); err != nil {
+		return err
+	} else if seg == codeSegment {
+		shift := 8 * (offset % 4)
+		inst := this.core.code[offset/4]
+		inst = (inst &^ (0xFF << shift)) | (Instruction(value) << shift)
+		return this.core.SetCodeMemory(Word(offset/4), inst)
+	} else if seg == ioSegment {
+		return fmt.Errorf("The debugger can't access io address %x", offset/2)
+	} else {
+		shift := 8 * (offset % 2)
+		mem := this.core.wordSegment(seg)
+		mem[offset/2] = (mem[offset/2] &^ (0xFF << shift)) | (Word(value) << shift)
+		return nil
+	}
+}
+
+func gdbParseRange(data string) (uint64, uint64, error) {
+	parts := strings.SplitN(data, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Malformed address range %s", data)
+	} else if address, err := strconv.ParseUint(parts[0], 16, 64); err != nil {
+		return 0, 0, err
+	} else if length, err := strconv.ParseUint(parts[1], 16, 64); err != nil {
+		return 0, 0, err
+	} else {
+		return address, length, nil
+	}
+}
+func (this *gdbSession) readMemory(data string) string {
+	if address, length, err := gdbParseRange(data); err != nil {
+		return "E00"
+	} else {
+		// gdb takes a short read as a partial one and asks for the rest
+		if length > gdbPacketSize/2 {
+			length = gdbPacketSize / 2
+		}
+		buf := make([]byte, 0, length)
+		for i := uint64(0); i < length; i++ {
+			if b, err := this.loadByte(address + i); err != nil {
+				if i == 0 {
+					return "E01"
+				}
+				// partial reads are allowed
+				break
+			} else {
+				buf = append(buf, b)
+			}
+		}
+		return hex.EncodeToString(buf)
+	}
+}
+func (this *gdbSession) writeMemory(data string) string {
+	parts := strings.SplitN(data, ":", 2)
+	if len(parts) != 2 {
+		return "E00"
+	} else if address, length, err := gdbParseRange(parts[0]); err != nil {
+		return "E00"
+	} else if buf, err := hex.DecodeString(parts[1]); err != nil || uint64(len(buf)) != length {
+		return "E00"
+	} else {
+		for i, b := range buf {
+			if err := this.storeByte(address+uint64(i), b); err != nil {
+				return "E01"
+			}
+		}
+		return "OK"
+	}
+}
+
+func gdbParsePoint(data string) (gdbWatchKey, error) {
+	var key gdbWatchKey
+	parts := strings.Split(data, ",")
+	if len(parts) < 3 || len(parts[0]) != 1 {
+		return key, fmt.Errorf("Malformed breakpoint packet %s", data)
+	} else if address, err := strconv.ParseUint(parts[1], 16, 64); err != nil {
+		return key, err
+	} else if size, err := strconv.ParseUint(parts[2], 16, 64); err != nil {
+		return key, err
+	} else {
+		key.kind, key.address, key.size = parts[0][0], address, size
+		return key, nil
+	}
+}
+
+var gdbWatchAccesses = map[byte]WatchAccess{
+	'2': WatchWrite,
+	'3': WatchRead,
+	'4': WatchAccessAny,
+}
+
+func (this *gdbSession) insertPoint(data string) string {
+	if key, err := gdbParsePoint(data); err != nil {
+		return "E00"
+	} else if key.kind == '0' || key.kind == '1' {
+		if seg, offset, err := gdbSplitAddress(key.address); err != nil || seg != codeSegment {
+			return "E01"
+		} else {
+			this.breakpoints[Word(offset/4)] = true
+			return "OK"
+		}
+	} else if access, ok := gdbWatchAccesses[key.kind]; !ok {
+		return ""
+	} else if seg, offset, err := gdbSplitAddress(key.address); err != nil || seg == codeSegment || key.size == 0 {
+		return "E01"
+	} else if wp, err := NewWatchpoint(seg.String(), Word(offset/2), Word((offset+key.size-1)/2), access); err != nil {
+		return "E01"
+	} else {
+		wp.Break = true
+		this.watches[key] = this.core.AddWatchpoint(wp)
+		return "OK"
+	}
+}
+func (this *gdbSession) removePoint(data string) string {
+	if key, err := gdbParsePoint(data); err != nil {
+		return "E00"
+	} else if key.kind == '0' || key.kind == '1' {
+		if seg, offset, err := gdbSplitAddress(key.address); err != nil || seg != codeSegment {
+			return "E01"
+		} else {
+			delete(this.breakpoints, Word(offset/4))
+			return "OK"
+		}
+	} else if id, ok := this.watches[key]; !ok {
+		return "E01"
+	} else {
+		delete(this.watches, key)
+		this.core.RemoveWatchpoint(id)
+		return "OK"
+	}
+}
+
+var gdbWatchStopNames = map[WatchAccess]string{
+	WatchWrite:     "watch",
+	WatchRead:      "rwatch",
+	WatchAccessAny: "awatch",
+}
+
+func (this *gdbSession) resume(data string, step bool) string {
+	core := this.core
+	if this.exited {
+		return "W00"
+	}
+	if len(data) > 0 {
+		if address, err := strconv.ParseUint(data, 16, 64); err != nil {
+			return "E00"
+		} else {
+			core.SetRegister(InstructionPointer, Word(address/4))
+		}
+	}
+	for first := true; ; first = false {
+		if !first {
+			if step {
+				return "S05"
+			} else if this.breakpoints[core.instructionPointer] {
+				return "T05swbreak:;"
+			}
+			select {
+			case b, more := <-this.in:
+				if !more || b == gdbInterrupt {
+					return "S02"
+				}
+			default:
+			}
+		}
+		core.lastWatchpointHit = nil
+		if err := core.ExecuteCurrentInstruction(); err != nil {
+			this.sendPacket("O" + hex.EncodeToString([]byte(fmt.Sprintf("%s\n", err))))
+			return "S04"
+		} else if err := core.AdvanceProgramCounter(); err != nil {
+			this.sendPacket("O" + hex.EncodeToString([]byte(fmt.Sprintf("%s\n", err))))
+			return "S04"
+		}
+		if core.TerminateExecution() {
+			if hit := core.lastWatchpointHit; hit != nil {
+				core.ResumeExecution()
+				return fmt.Sprintf("T05%s:%x;", gdbWatchStopNames[hit.Watchpoint.Access], gdbJoinAddress(hit.Watchpoint.seg, uint64(hit.Address)*2))
+			} else {
+				this.exited = true
+				return "W00"
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package iris16
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+)
+
+type gdbClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (this *gdbClient) request(packet string) string {
+	var sum byte
+	for i := 0; i < len(packet); i++ {
+		sum += packet[i]
+	}
+	fmt.Fprintf(this.conn, "$%s#%02x", packet, sum)
+	if ack, err := this.reader.ReadByte(); err != nil || ack != '+' {
+		this.t.Fatalf("Packet %s was not acknowledged: %c %v", packet, ack, err)
+	}
+	if _, err := this.reader.ReadString('$'); err != nil {
+		this.t.Fatalf("Couldn't read reply to %s: %s", packet, err)
+	}
+	reply, err := this.reader.ReadString('#')
+	if err != nil {
+		this.t.Fatalf("Couldn't read reply to %s: %s", packet, err)
+	}
+	this.reader.Discard(2)
+	this.conn.Write([]byte{'+'})
+	return reply[:len(reply)-1]
+}
+
+func Test_GdbBreakpointAndContinue(t *testing.T) {
+	core, err := New()
+	if err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	}
+	set, _ := NewDecodedInstructionImmediate(InstructionGroupMove, MoveOpSet, UserRegisterBegin, 5)
+	incr, _ := NewDecodedInstructionArithmetic(ArithmeticOpIncrement, UserRegisterBegin, UserRegisterBegin, 0)
+	term, _ := NewDecodedInstruction(InstructionGroupMisc, MiscOpSystemCall, SystemCallTerminate, 0, 0)
+	for i, di := range []*DecodedInstruction{set, incr, term} {
+		core.SetCodeMemory(Word(i), *di.Encode())
+	}
+	server, conn := net.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- core.serveGdbConnection(server)
+		server.Close()
+	}()
+	client := gdbClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	if reply := client.request("?"); reply != "S05" {
+		t.Errorf("Initial stop reason was %s", reply)
+	}
+	if reply := client.request("Z0,4,4"); reply != "OK" {
+		t.Errorf("Couldn't insert breakpoint: %s", reply)
+	}
+	if reply := client.request("c"); reply != "T05swbreak:;" {
+		t.Errorf("Continue didn't stop at the breakpoint: %s", reply)
+	}
+	if reply := client.request(fmt.Sprintf("p%x", UserRegisterBegin)); reply != "0500" {
+		t.Errorf("r%d should be 5 at the breakpoint but is %s", UserRegisterBegin, reply)
+	}
+	if reply := client.request(fmt.Sprintf("p%x", gdbRegisterPc)); reply != "04000000" {
+		t.Errorf("pc should be at the breakpoint but is %s", reply)
+	}
+	if reply := client.request("s"); reply != "S05" {
+		t.Errorf("Step didn't stop: %s", reply)
+	}
+	if reply := client.request(fmt.Sprintf("p%x", UserRegisterBegin)); reply != "0600" {
+		t.Errorf("r%d should be 6 after the step but is %s", UserRegisterBegin, reply)
+	}
+	if reply := client.request("M100010,2:3412"); reply != "OK" {
+		t.Errorf("Couldn't write data memory: %s", reply)
+	} else if core.data[8] != 0x1234 {
+		t.Errorf("Data memory write went to the wrong place: %x", core.data[8])
+	}
+	if reply := client.request("c"); reply != "W00" {
+		t.Errorf("Program didn't exit: %s", reply)
+	}
+	fmt.Fprintf(conn, "$k#6b")
+	client.reader.ReadByte()
+	conn.Close()
+	if err := <-done; err != nil {
+		t.Errorf("gdb session failed: %s", err)
+	}
+}
+
+func Test_GdbMemoryAccessLimits(t *testing.T) {
+	core, err := New()
+	if err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	}
+	dev := &latchDevice{value: 0x1234}
+	if err := core.RegisterIoDevice(dev); err != nil {
+		t.Fatalf("Couldn't register device: %s", err)
+	}
+	server, conn := net.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- core.serveGdbConnection(server)
+		server.Close()
+	}()
+	client := gdbClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	// reads larger than a packet are cut short instead of being allocated
+	if reply := client.request("m100000,ffffffffffffffff"); len(reply) != gdbPacketSize {
+		t.Errorf("Oversized read returned %d hex digits instead of %d", len(reply), gdbPacketSize)
+	}
+	// devices are never touched by the debugger
+	if reply := client.request("m500080,2"); reply != "E01" {
+		t.Errorf("Reading an io address should fail but got %s", reply)
+	}
+	if reply := client.request("M500080,2:0000"); reply != "E01" {
+		t.Errorf("Writing an io address should fail but got %s", reply)
+	} else if dev.value != 0x1234 {
+		t.Errorf("Debugger write reached the device: %x", dev.value)
+	}
+	fmt.Fprintf(conn, "$k#6b")
+	client.reader.ReadByte()
+	conn.Close()
+	if err := <-done; err != nil {
+		t.Errorf("gdb session failed: %s", err)
+	}
+}
@@ -0,0 +1,525 @@
+// ahead of time translation of iris16 memory images into go source
+package iris16
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+)
+
+// Translate the memory image installed in the core into a standalone go
+// source file. The reachable parts of the code segment become one go
+// function per basic block and a switch based dispatcher handles indirect
+// branches. Instructions which can't be translated (system calls, io, code
+// stores, etc) and addresses that were not discovered at translation time are
+// run by the iris16 interpreter so the generated program behaves the same as
+// the image would under rlsim. Code which is written at runtime is not
+// retranslated.
+//
+// When pkg is main the generated file contains a main function, otherwise it
+// exposes New and Run so the program can be embedded into another go program.
+func (this *Core) TranslateToGo(out io.Writer, pkg string) error {
+	gen := goGenerator{core: this, leaders: make(map[Word]bool), reachable: make(map[Word]bool)}
+	gen.discover(0)
+	gen.emitImage()
+	for _, block := range gen.blocks() {
+		gen.emitBlock(block)
+	}
+	gen.emitDispatcher()
+	if pkg == "main" {
+		gen.emitMain()
+	}
+	// the imports depend on what the blocks ended up using
+	body := gen.buf.Bytes()
+	gen.buf = bytes.Buffer{}
+	gen.emitHeader(pkg)
+	gen.buf.Write(body)
+	if src, err := format.Source(gen.buf.Bytes()); err != nil {
+		return fmt.Errorf("Generated go code is malformed: %s", err)
+	} else {
+		_, err := out.Write(src)
+		return err
+	}
+}
+
+type goGenerator struct {
+	core      *Core
+	buf       bytes.Buffer
+	leaders   map[Word]bool
+	reachable map[Word]bool
+	// does the generated code construct errors itself
+	usesErrors bool
+}
+
+type goBlock struct {
+	start Word
+	insts []Word
+}
+
+func (this *goGenerator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&this.buf, format, args...)
+}
+
+func (this *goGenerator) decode(address Word) *DecodedInstruction {
+	var di DecodedInstruction
+	this.core.code[address].decodeInto(&di)
+	return &di
+}
+
+// Walk the code segment from the given entry point following fall through and
+// immediate branches. Indirect branch targets are only found at runtime and
+// are picked up by the dispatcher.
+func (this *goGenerator) discover(entry Word) {
+	this.leaders[entry] = true
+	work := []Word{entry}
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+		for !this.reachable[addr] {
+			// an empty cell decodes to add r0, r0, r0 which always faults
+			if this.core.code[addr] == 0 {
+				break
+			}
+			this.reachable[addr] = true
+			di := this.decode(addr)
+			if di.Group == InstructionGroupJump {
+				bb := branchBits(di.Op)
+				if bb.immediateForm() && !bb.returnForm() {
+					this.leaders[di.Immediate()] = true
+					work = append(work, di.Immediate())
+				}
+				this.leaders[addr+1] = true
+				if !bb.conditionalForm() && !bb.callForm() {
+					break
+				}
+			} else if di.Group == InstructionGroupMisc && di.Op == MiscOpSystemCall && di.Data[0] == SystemCallTerminate {
+				break
+			}
+			addr++
+		}
+	}
+}
+
+func (this *goGenerator) blocks() []*goBlock {
+	var starts []int
+	for addr := range this.leaders {
+		if this.reachable[addr] {
+			starts = append(starts, int(addr))
+		}
+	}
+	sort.Ints(starts)
+	var blocks []*goBlock
+	for _, start := range starts {
+		block := &goBlock{start: Word(start)}
+		for addr := Word(start); this.reachable[addr]; addr++ {
+			if addr != block.start && this.leaders[addr] {
+				break
+			}
+			block.insts = append(block.insts, addr)
+			if this.decode(addr).Group == InstructionGroupJump || addr == 0xFFFF {
+				break
+			}
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func (this *goGenerator) emitHeader(pkg string) {
+	this.printf("// Code generated by rl2go. DO NOT EDIT.\n\n")
+	this.printf("package %s\n\n", pkg)
+	this.printf("import (\n")
+	if this.usesErrors {
+		this.printf("\"errors\"\n")
+	}
+	this.printf("\"fmt\"\n")
+	if pkg == "main" {
+		this.printf("\"os\"\n")
+	}
+	this.printf("\"github.com/DrItanium/cores/iris16\"\n)\n\n")
+	this.printf(`func b2w(v bool) iris16.Word {
+	if v {
+		return 1
+	} else {
+		return 0
+	}
+}
+
+// run a single instruction through the interpreter
+func step(c *iris16.Core, ip iris16.Word) (iris16.Word, error) {
+	c.SetRegister(iris16.InstructionPointer, ip)
+	if err := c.ExecuteCurrentInstruction(); err != nil {
+		return ip, err
+	} else if err := c.AdvanceProgramCounter(); err != nil {
+		return ip, err
+	} else {
+		return c.InstructionAddress(), nil
+	}
+}
+
+`)
+}
+
+func (this *goGenerator) emitImage() {
+	this.printf("var codeImage = [][2]uint32{\n")
+	for addr, inst := range this.core.code {
+		if inst != 0 {
+			this.printf("{0x%04x, 0x%08x},\n", addr, uint32(inst))
+		}
+	}
+	this.printf("}\n\n")
+	segments := []struct {
+		name string
+		mem  *[MemorySize]Word
+	}{
+		{"dataImage", this.core.data},
+		{"microcodeImage", &this.core.ucode},
+		{"stackImage", &this.core.stack},
+		{"callImage", &this.core.call},
+	}
+	for _, seg := range segments {
+		this.printf("var %s = [][2]iris16.Word{\n", seg.name)
+		for addr, value := range seg.mem {
+			if value != 0 {
+				this.printf("{0x%04x, 0x%04x},\n", addr, value)
+			}
+		}
+		this.printf("}\n\n")
+	}
+	this.printf(`// Construct a new iris16 core with the translated memory image installed.
+// Io devices should be registered before calling Run.
+func New() (*iris16.Core, error) {
+	c, err := iris16.New()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range codeImage {
+		c.SetCodeMemory(iris16.Word(e[0]), iris16.Instruction(e[1]))
+	}
+	for _, e := range dataImage {
+		c.SetDataMemory(e[0], e[1])
+	}
+	for _, e := range microcodeImage {
+		c.SetMicrocodeMemory(e[0], e[1])
+	}
+	for _, e := range stackImage {
+		c.SetStackMemory(e[0], e[1])
+	}
+	for _, e := range callImage {
+		c.SetCallMemory(e[0], e[1])
+	}
+	return c, nil
+}
+
+`)
+}
+
+type goOperand struct {
+	expr     string
+	constant bool
+	value    Word
+}
+
+func (this *goGenerator) read(index byte, addr Word) goOperand {
+	switch index {
+	case FalseRegister:
+		return goOperand{expr: "0", constant: true, value: 0}
+	case TrueRegister:
+		return goOperand{expr: "1", constant: true, value: 1}
+	case InstructionPointer:
+		return goOperand{expr: fmt.Sprintf("0x%04x", addr), constant: true, value: addr}
+	case StackPointer, PredicateRegister, CallPointer:
+		return goOperand{expr: fmt.Sprintf("c.Register(%d)", index)}
+	default:
+		return goOperand{expr: fmt.Sprintf("g[%d]", index-UserRegisterBegin)}
+	}
+}
+func immediateOperand(value Word) goOperand {
+	return goOperand{expr: fmt.Sprintf("0x%04x", value), constant: true, value: value}
+}
+
+// writes to r0, r1 and ip are left to the interpreter so that the errors and
+// program counter behavior are identical
+func writable(index byte) bool {
+	return index != FalseRegister && index != TrueRegister && index != InstructionPointer
+}
+
+func (this *goGenerator) write(index byte, expr string) {
+	switch index {
+	case StackPointer, PredicateRegister, CallPointer:
+		this.printf("c.SetRegister(%d, %s)\n", index, expr)
+	default:
+		this.printf("g[%d] = %s\n", index-UserRegisterBegin, expr)
+	}
+}
+
+var goArithmeticOperators = [ArithmeticOpCount]string{
+	ArithmeticOpAdd:                 "%s + %s",
+	ArithmeticOpSub:                 "%s - %s",
+	ArithmeticOpMul:                 "%s * %s",
+	ArithmeticOpDiv:                 "%s / %s",
+	ArithmeticOpRem:                 "%s %% %s",
+	ArithmeticOpShiftLeft:           "%s << %s",
+	ArithmeticOpShiftRight:          "%s >> %s",
+	ArithmeticOpBinaryAnd:           "%s & %s",
+	ArithmeticOpBinaryOr:            "%s | %s",
+	ArithmeticOpBinaryNot:           "^%s%.0s",
+	ArithmeticOpBinaryXor:           "%s ^ %s",
+	ArithmeticOpIncrement:           "%s + 1%.0s",
+	ArithmeticOpDecrement:           "%s - 1%.0s",
+	ArithmeticOpDouble:              "%s << 1%.0s",
+	ArithmeticOpHalve:               "%s >> 1%.0s",
+	ArithmeticOpAddImmediate:        "%s + %s",
+	ArithmeticOpSubImmediate:        "%s - %s",
+	ArithmeticOpMulImmediate:        "%s * %s",
+	ArithmeticOpDivImmediate:        "%s / %s",
+	ArithmeticOpRemImmediate:        "%s %% %s",
+	ArithmeticOpShiftLeftImmediate:  "%s << %s",
+	ArithmeticOpShiftRightImmediate: "%s >> %s",
+}
+
+func (this *goGenerator) arithmetic(addr Word, di *DecodedInstruction) bool {
+	if di.Op >= ArithmeticOpCount || !writable(di.Data[0]) {
+		return false
+	} else if goArithmeticOperators[di.Op] == "" {
+		// the signed, carry and float forms are left to the interpreter
+		return false
+	}
+	op := arithmeticOps[di.Op]
+	arg0, arg1 := this.read(di.Data[1], addr), this.read(di.Data[2], addr)
+	if op.ImmediateForm {
+		arg1 = immediateOperand(di.arithmeticImmediate())
+	}
+	unary := di.Op >= ArithmeticOpBinaryNot && di.Op <= ArithmeticOpHalve && di.Op != ArithmeticOpBinaryXor
+	divides := di.Op == ArithmeticOpDiv || di.Op == ArithmeticOpRem || di.Op == ArithmeticOpDivImmediate || di.Op == ArithmeticOpRemImmediate
+	shifts := di.Op == ArithmeticOpShiftLeft || di.Op == ArithmeticOpShiftRight || di.Op == ArithmeticOpShiftLeftImmediate || di.Op == ArithmeticOpShiftRightImmediate
+	if arg0.constant && (arg1.constant || unary) {
+		// fold it here, go would reject overflowing constant expressions
+		if result, err := op.Invoke(arg0.value, arg1.value); err != nil {
+			return false
+		} else {
+			this.write(di.Data[0], fmt.Sprintf("0x%04x", result))
+		}
+	} else if divides && arg1.constant && arg1.value == 0 {
+		// always faults, let the interpreter report it
+		return false
+	} else {
+		if divides && !arg1.constant {
+			this.usesErrors = true
+			this.printf("if %s == 0 {\nreturn 0x%04x, errors.New(iris16.DivideByZeroMessage)\n}\n", arg1.expr, addr)
+		}
+		if shifts && arg1.constant && arg1.value >= 16 {
+			// everything gets shifted out, go vet rejects the oversized shift
+			this.write(di.Data[0], "0")
+		} else {
+			this.write(di.Data[0], fmt.Sprintf(goArithmeticOperators[di.Op], arg0.expr, arg1.expr))
+		}
+	}
+	return true
+}
+
+var goMemoryAccessors = map[segment][2]string{
+	dataSegment:      {"DataMemory", "SetDataMemory"},
+	microcodeSegment: {"MicrocodeMemory", "SetMicrocodeMemory"},
+	stackSegment:     {"StackMemory", "SetStackMemory"},
+	callSegment:      {"CallMemory", "SetCallMemory"},
+}
+
+func (this *goGenerator) move(addr Word, di *DecodedInstruction) bool {
+	switch di.Op {
+	case MoveOpMove:
+		if di.Data[0] == di.Data[1] && writable(di.Data[0]) {
+			// nop
+			return true
+		} else if writable(di.Data[0]) {
+			this.write(di.Data[0], this.read(di.Data[1], addr).expr)
+			return true
+		}
+	case MoveOpSwap:
+		if writable(di.Data[0]) && writable(di.Data[1]) {
+			this.printf("{\nt0, t1 := %s, %s\n", this.read(di.Data[0], addr).expr, this.read(di.Data[1], addr).expr)
+			this.write(di.Data[1], "t0")
+			this.write(di.Data[0], "t1")
+			this.printf("}\n")
+			return true
+		}
+	case MoveOpSet:
+		if writable(di.Data[0]) {
+			this.write(di.Data[0], fmt.Sprintf("0x%04x", di.Immediate()))
+			return true
+		}
+	case MoveOpLoad:
+		if fns, ok := goMemoryAccessors[segment(di.Data[2])]; ok && writable(di.Data[0]) {
+			this.write(di.Data[0], fmt.Sprintf("c.%s(%s)", fns[0], this.read(di.Data[1], addr).expr))
+			return true
+		}
+	case MoveOpStore:
+		if fns, ok := goMemoryAccessors[segment(di.Data[2])]; ok {
+			this.printf("c.%s(%s, %s)\n", fns[1], this.read(di.Data[0], addr).expr, this.read(di.Data[1], addr).expr)
+			return true
+		}
+	case MoveOpPush:
+		this.printf("c.Push(%s)\n", this.read(di.Data[0], addr).expr)
+		return true
+	case MoveOpPop, MoveOpPeek:
+		if writable(di.Data[0]) {
+			if di.Op == MoveOpPop {
+				this.write(di.Data[0], "c.Pop()")
+			} else {
+				this.write(di.Data[0], "c.Peek()")
+			}
+			return true
+		}
+	}
+	return false
+}
+
+var goCompareOperators = [CompareBodyError]string{
+	CompareBodyEq:  "==",
+	CompareBodyNeq: "!=",
+	CompareBodyLt:  "<",
+	CompareBodyGt:  ">",
+	CompareBodyLe:  "<=",
+	CompareBodyGe:  ">=",
+}
+
+func (this *goGenerator) compare(addr Word, di *DecodedInstruction) bool {
+	if di.Op >= CompareOpCount || !writable(di.Data[0]) {
+		return false
+	}
+	op := compareOps[di.Op]
+	if goCompareOperators[op.Body] == "" {
+		return false
+	}
+	arg0, arg1 := this.read(di.Data[1], addr), this.read(di.Data[2], addr)
+	var cond string
+	if arg0.constant && arg1.constant {
+		result, _ := bodyOps[op.Body](arg0.value, arg1.value)
+		cond = fmt.Sprint(result)
+	} else {
+		cond = fmt.Sprintf("%s %s %s", arg0.expr, goCompareOperators[op.Body], arg1.expr)
+	}
+	old := this.read(di.Data[0], addr).expr
+	switch op.Combine {
+	case CombineAnd:
+		cond = fmt.Sprintf("%s != 0 && %s", old, cond)
+	case CombineOr:
+		cond = fmt.Sprintf("%s != 0 || %s", old, cond)
+	case CombineXor:
+		cond = fmt.Sprintf("(%s != 0) != (%s)", old, cond)
+	}
+	this.write(di.Data[0], fmt.Sprintf("b2w(%s)", cond))
+	return true
+}
+
+// Jumps always end a block, return the code which computes the next address
+func (this *goGenerator) jump(addr Word, di *DecodedInstruction) bool {
+	bb := branchBits(di.Op)
+	call, ret, imm, cond, ite := bb.callForm(), bb.returnForm(), bb.immediateForm(), bb.conditionalForm(), bb.ifThenElseForm()
+	if (call && ret) || (ite && cond) || (ret && imm) || (ite && (imm || ret)) {
+		return false
+	}
+	var target string
+	if imm {
+		target = fmt.Sprintf("0x%04x", di.Immediate())
+	} else if ite {
+		target = "t"
+		this.printf("t := %s\nif %s != 1 {\nt = %s\n}\n", this.read(di.Data[1], addr).expr, this.read(di.Data[0], addr).expr, this.read(di.Data[2], addr).expr)
+	} else if cond {
+		target = this.read(di.Data[1], addr).expr
+	} else {
+		target = this.read(di.Data[0], addr).expr
+	}
+	if cond {
+		this.printf("if %s == 1 {\n", this.read(di.Data[0], addr).expr)
+	}
+	if ret {
+		this.printf("return c.Return(), nil\n")
+	} else if call {
+		// call pushes the address after the current instruction
+		this.printf("var next iris16.Word = %s\nc.SetRegister(iris16.InstructionPointer, 0x%04x)\nreturn next, c.Call(next)\n", target, addr)
+	} else {
+		this.printf("return %s, nil\n", target)
+	}
+	if cond {
+		this.printf("}\nreturn 0x%04x, nil\n", addr+1)
+	}
+	return true
+}
+
+func (this *goGenerator) emitBlock(block *goBlock) {
+	this.printf("func block%04x(c *iris16.Core, g *[iris16.RegisterCount - iris16.UserRegisterBegin]iris16.Word) (iris16.Word, error) {\n", block.start)
+	for _, addr := range block.insts {
+		di := this.decode(addr)
+		this.printf("// %04x: %08x\n", addr, uint32(this.core.code[addr]))
+		var translated bool
+		switch di.Group {
+		case InstructionGroupArithmetic:
+			translated = this.arithmetic(addr, di)
+		case InstructionGroupMove:
+			translated = this.move(addr, di)
+		case InstructionGroupCompare:
+			translated = this.compare(addr, di)
+		case InstructionGroupJump:
+			translated = this.jump(addr, di)
+		}
+		if !translated {
+			this.printf("if next, err := step(c, 0x%04x); err != nil {\nreturn next, err\n}", addr)
+			this.printf(" else if next != 0x%04x || c.TerminateExecution() {\nreturn next, nil\n}\n", addr+1)
+		}
+		if translated && di.Group == InstructionGroupJump {
+			this.printf("}\n\n")
+			return
+		}
+	}
+	this.printf("return 0x%04x, nil\n}\n\n", block.insts[len(block.insts)-1]+1)
+}
+
+func (this *goGenerator) emitDispatcher() {
+	this.printf(`// Run the translated program until it terminates, the instruction pointer
+// of the core is used as the entry point
+func Run(c *iris16.Core) error {
+	g := c.UserRegisters()
+	ip := c.InstructionAddress()
+	var err error
+	for !c.TerminateExecution() {
+		switch ip {
+`)
+	for _, block := range this.blocks() {
+		this.printf("case 0x%04x:\nip, err = block%04x(c, g)\n", block.start, block.start)
+	}
+	this.printf(`		default:
+			ip, err = step(c, ip)
+		}
+		if err != nil {
+			c.SetRegister(iris16.InstructionPointer, ip)
+			return fmt.Errorf("ERROR during execution: %%s\n", err)
+		}
+	}
+	c.SetRegister(iris16.InstructionPointer, ip)
+	return nil
+}
+
+`)
+}
+
+func (this *goGenerator) emitMain() {
+	this.printf(`func main() {
+	if c, err := New(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	} else if err := c.Startup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	} else if err := Run(c); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	} else if err := c.Shutdown(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`)
+}
@@ -0,0 +1,328 @@
+package iris16
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_TranslateToGo(t *testing.T) {
+	core := coreWithProgram(t, EngineInterpreter, countdownProgram(1000))
+	core.SetDataMemory(0x10, 0xFDED)
+	var out bytes.Buffer
+	if err := core.TranslateToGo(&out, "countdown"); err != nil {
+		t.Fatalf("Translation failed: %s", err)
+	}
+	src := out.String()
+	for _, expect := range []string{
+		"package countdown",
+		// the loop body and the instruction after the conditional branch are blocks
+		"func block0000(",
+		"func block0001(",
+		"func block0006(",
+		"case 0x0001:",
+		// the terminate system call goes through the interpreter
+		"step(c, 0x0006)",
+		"{0x0010, 0xfded}",
+	} {
+		if !strings.Contains(src, expect) {
+			t.Errorf("Generated code is missing %q", expect)
+		}
+	}
+	if strings.Contains(src, "func main()") {
+		t.Error("A main function was generated for a non main package")
+	}
+}
+
+// prints the output of the program followed by the state it left behind, the
+// memory dump has to match writeState
+const goDriver = `package main
+
+import (
+	"fmt"
+	"github.com/DrItanium/cores/iris16"
+	"os"
+)
+
+func main() {
+	c, err := New()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	err = Run(c)
+	fmt.Println()
+	for i := 0; i < iris16.RegisterCount; i++ {
+		fmt.Printf("r%d=%x\n", i, c.Register(byte(i)))
+	}
+	for i := 0; i < iris16.MemorySize; i++ {
+		a := iris16.Word(i)
+		if v := c.CodeMemory(a); v != 0 {
+			fmt.Printf("code[%x]=%x\n", i, uint32(v))
+		}
+		if v := c.DataMemory(a); v != 0 {
+			fmt.Printf("data[%x]=%x\n", i, v)
+		}
+		if v := c.MicrocodeMemory(a); v != 0 {
+			fmt.Printf("microcode[%x]=%x\n", i, v)
+		}
+		if v := c.StackMemory(a); v != 0 {
+			fmt.Printf("stack[%x]=%x\n", i, v)
+		}
+		if v := c.CallMemory(a); v != 0 {
+			fmt.Printf("call[%x]=%x\n", i, v)
+		}
+	}
+	fmt.Printf("status=%x err=%v\n", c.Status(), err)
+}
+`
+
+func writeState(w io.Writer, c *Core, err error) {
+	fmt.Fprintln(w)
+	for i := 0; i < RegisterCount; i++ {
+		fmt.Fprintf(w, "r%d=%x\n", i, c.Register(byte(i)))
+	}
+	for i := 0; i < MemorySize; i++ {
+		a := Word(i)
+		if v := c.CodeMemory(a); v != 0 {
+			fmt.Fprintf(w, "code[%x]=%x\n", i, uint32(v))
+		}
+		if v := c.DataMemory(a); v != 0 {
+			fmt.Fprintf(w, "data[%x]=%x\n", i, v)
+		}
+		if v := c.MicrocodeMemory(a); v != 0 {
+			fmt.Fprintf(w, "microcode[%x]=%x\n", i, v)
+		}
+		if v := c.StackMemory(a); v != 0 {
+			fmt.Fprintf(w, "stack[%x]=%x\n", i, v)
+		}
+		if v := c.CallMemory(a); v != 0 {
+			fmt.Fprintf(w, "call[%x]=%x\n", i, v)
+		}
+	}
+	fmt.Fprintf(w, "status=%x err=%v\n", c.Status(), err)
+}
+
+// the programs below each go through a different part of the translator
+const translatedCalls = `
+.code
+set r10 = #1
+set r11 = #0
+call first
+set r6 = second
+call r6
+call third if r10
+call fail if r11
+set r6 = fourth
+call r6 if r10
+set r8 = fifth
+set r9 = fail
+call if r10 then r8 else r9
+call if r11 then r8 else r9
+system #0, r0, r0
+first: or r20 = r20, r1
+call nested
+return
+nested: incr r22 = r22
+return
+second: set r7 = #2
+or r20 = r20, r7
+return if r10
+set r21 = #1
+return
+third: set r7 = #4
+or r20 = r20, r7
+return if r11
+return
+fourth: set r7 = #8
+or r20 = r20, r7
+return
+fifth: set r7 = #16
+or r20 = r20, r7
+return
+fail: incr r21 = r21
+return
+`
+
+const translatedBranches = `
+.code
+set r10 = #1
+set r11 = #0
+set r12 = #5
+loop: decr r12 = r12
+add r13 = r13, #3
+gt r14 = r12, r0
+branch loop if r14
+branch one
+set r21 = #1
+one: or r20 = r20, r1
+set r6 = two
+branch r6
+set r21 = #1
+two: set r7 = #2
+or r20 = r20, r7
+branch three if r10
+set r21 = #1
+three: set r7 = #4
+or r20 = r20, r7
+branch fail if r11
+set r7 = #8
+or r20 = r20, r7
+set r6 = four
+branch r6 if r10
+set r21 = #1
+four: set r8 = five
+set r9 = fail
+branch if r10 then r8 else r9
+five: set r7 = #16
+or r20 = r20, r7
+set r8 = fail
+set r9 = six
+branch if r11 then r8 else r9
+six: set r7 = #32
+or r20 = r20, r7
+system #0, r0, r0
+fail: set r21 = #1
+system #0, r0, r0
+`
+
+const translatedMemory = `
+.data
+.org #x20
+.word #x1234
+.code
+set r6 = #1
+set r7 = #2
+swap r6 = r7
+move r8 = r6
+set r20 = #16
+set r21 = #77
+store r20 = r21
+load r9 = r20
+set r20 = #x20
+load r16 = r20, data
+set r20 = #17
+set r21 = #300
+store r20 = r21, microcode
+load r13 = r20, microcode
+set r20 = #18
+set r21 = #301
+store r20 = r21, stack
+load r14 = r20, stack
+set r20 = #19
+set r21 = #302
+store r20 = r21, procedure
+load r15 = r20, procedure
+set r6 = template
+load r6 = r17, r18, code
+set r6 = #x100
+store r6 = r17, r18, code
+set r22 = #5
+set r23 = #6
+push r22
+push r23
+peek r12
+pop r11
+pop r10
+set r20 = #x40
+store r20 = r21, io
+template: set r10 = #42
+`
+
+const translatedSystemCalls = `
+.code
+set r6 = #72
+system #2, r6, r6
+set r7 = #x263A
+set r8 = #0
+system #2, r7, r8
+set r9 = #3
+system #6, r9, r9
+system #5, r10, r10
+set r11 = #x8000
+add r11 = r11, r11
+addc r12 = r0, r0
+system #1, r0, r0
+`
+
+// Build the translation of a program with the go tool and make sure it ends
+// up in the same state as the interpreter running the same image
+func translationMatchesInterpreter(t *testing.T, core *Core) {
+	dir, err := ioutil.TempDir("", "rl2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var src bytes.Buffer
+	if err := core.TranslateToGo(&src, "main"); err != nil {
+		t.Fatalf("Translation failed: %s", err)
+	}
+	// the driver replaces the generated main so the state can be printed
+	code := strings.Replace(src.String(), "func main() {", "func generatedMain() {", 1)
+	if err := ioutil.WriteFile(filepath.Join(dir, "program.go"), []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := ioutil.WriteFile(filepath.Join(dir, "driver.go"), []byte(goDriver), 0644); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "program")
+	build := exec.Command("go", "build", "-o", binary, filepath.Join(dir, "program.go"), filepath.Join(dir, "driver.go"))
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		t.Fatalf("Building the generated program failed: %s", err)
+	}
+	// a mistranslated branch can turn into an endless loop
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, binary)
+	cmd.Stderr = os.Stderr
+	got, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Running the generated program failed: %s", err)
+	}
+	var expect bytes.Buffer
+	core.SetOutput(&expect)
+	writeState(&expect, core, core.Run())
+	if string(got) != expect.String() {
+		t.Errorf("The generated program ended with\n%s\nbut the interpreter ended with\n%s", got, expect.String())
+	}
+}
+
+func Test_TranslatedProgramMatchesInterpreter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Building the generated program takes a while")
+	} else if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("The go tool is not available")
+	}
+	t.Run("countdown", func(t *testing.T) {
+		prog := countdownProgram(1000)
+		putc, _ := NewDecodedInstruction(InstructionGroupMisc, MiscOpSystemCall, SystemCallPutc, 8, 8)
+		prog = append(prog[:len(prog)-1], putc, prog[len(prog)-1])
+		core := coreWithProgram(t, EngineInterpreter, prog)
+		core.SetDataMemory(0x10, 0xFDED)
+		translationMatchesInterpreter(t, core)
+	})
+	for _, program := range []struct {
+		name, source string
+	}{
+		{"calls", translatedCalls},
+		{"branches", translatedBranches},
+		{"memory", translatedMemory},
+		{"system calls", translatedSystemCalls},
+	} {
+		t.Run(program.name, func(t *testing.T) {
+			if core, err := assemble(program.source); err != nil {
+				t.Fatalf("Couldn't assemble program: %s", err)
+			} else {
+				translationMatchesInterpreter(t, core)
+			}
+		})
+	}
+}
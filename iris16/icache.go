@@ -0,0 +1,47 @@
+// pre-decoded instruction cache for the code segment
+package iris16
+
+// Each code address keeps the decoded form of the instruction stored there
+// along with the execution unit it dispatches to. Entries are filled the first
+// time an address is executed and thrown away whenever the code at that
+// address (or the set of installed execution units) changes.
+type decodedEntry struct {
+	valid bool
+	inst  DecodedInstruction
+	unit  ExecutionUnit
+}
+
+// allocation free form of Decode
+func (this Instruction) decodeInto(di *DecodedInstruction) {
+	di.Group = byte(this & 0x7)
+	di.Op = byte((this & 0xF8) >> 3)
+	di.Data[0] = byte(this >> 8)
+	di.Data[1] = byte(this >> 16)
+	di.Data[2] = byte(this >> 24)
+}
+
+func (this *Core) invalidateDecodedInstruction(address Word) {
+	this.decoded[address].valid = false
+	if this.threaded != nil {
+		this.threaded.invalidate(address)
+	}
+}
+
+func (this *Core) flushDecodedInstructions() {
+	for i := range this.decoded {
+		this.decoded[i].valid = false
+	}
+	if this.threaded != nil {
+		this.threaded.flush()
+	}
+}
+
+func (this *Core) decodedInstruction(address Word) *decodedEntry {
+	entry := &this.decoded[address]
+	if !entry.valid {
+		this.code[address].decodeInto(&entry.inst)
+		entry.unit = this.groups[entry.inst.Group]
+		entry.valid = true
+	}
+	return entry
+}
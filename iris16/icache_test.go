@@ -0,0 +1,71 @@
+package iris16
+
+import "testing"
+
+// incr r6 = r6; branch 0
+func loopProgram(b testing.TB) *Core {
+	core, err := New()
+	if err != nil {
+		b.Fatalf("Couldn't create core %s", err)
+	}
+	incr, _ := NewDecodedInstructionArithmetic(ArithmeticOpIncrement, UserRegisterBegin, UserRegisterBegin, 0)
+	var bb branchBits
+	bb.setImmediateForm(true)
+	jmp, _ := NewDecodedInstructionImmediate(InstructionGroupJump, byte(bb), 0, 0)
+	core.SetCodeMemory(0, *incr.Encode())
+	core.SetCodeMemory(1, *jmp.Encode())
+	return core
+}
+
+func Test_DecodeCacheInvalidatedByStoreCode(t *testing.T) {
+	core := loopProgram(t)
+	if err := core.ExecuteCurrentInstruction(); err != nil {
+		t.Fatalf("Execution failed: %s", err)
+	} else if core.Register(UserRegisterBegin) != 1 {
+		t.Fatalf("Increment did not execute")
+	}
+	// replace the increment with a decrement through the store code instruction
+	decr, _ := NewDecodedInstructionArithmetic(ArithmeticOpDecrement, UserRegisterBegin, UserRegisterBegin, 0)
+	raw := *decr.Encode()
+	core.SetRegister(UserRegisterBegin+1, 0)
+	core.SetRegister(UserRegisterBegin+2, Word(raw))
+	core.SetRegister(UserRegisterBegin+3, Word(raw>>16))
+	if store, err := NewDecodedInstruction(InstructionGroupMove, MoveOpStoreCode, UserRegisterBegin+1, UserRegisterBegin+2, UserRegisterBegin+3); err != nil {
+		t.Fatalf("Couldn't construct store code instruction: %s", err)
+	} else if err := core.Invoke(store); err != nil {
+		t.Fatalf("Store code failed: %s", err)
+	} else if err := core.ExecuteCurrentInstruction(); err != nil {
+		t.Fatalf("Execution failed: %s", err)
+	} else if val := core.Register(UserRegisterBegin); val != 0 {
+		t.Errorf("Stale decoded instruction was executed, r%d is %d", UserRegisterBegin, val)
+	}
+}
+
+func Test_DecodeAllocations(t *testing.T) {
+	core := loopProgram(t)
+	allocs := testing.AllocsPerRun(1000, func() {
+		core.ExecuteCurrentInstruction()
+		core.AdvanceProgramCounter()
+	})
+	if allocs != 0 {
+		t.Errorf("Cached dispatch allocated %f times per instruction", allocs)
+	}
+}
+
+func BenchmarkDispatchDecode(b *testing.B) {
+	core := loopProgram(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		core.Dispatch(core.CurrentInstruction())
+		core.AdvanceProgramCounter()
+	}
+}
+
+func BenchmarkDispatchCached(b *testing.B) {
+	core := loopProgram(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		core.ExecuteCurrentInstruction()
+		core.AdvanceProgramCounter()
+	}
+}
@@ -24,7 +24,7 @@ func init() {
 	}
 }
 
-var branchExtractionFunctions = map[byte][2]byte{
+var branchExtractionFunctions = [branchBitCount][2]byte{
 	branchBitReturnForm:      [2]byte{branchBitReturnFormMask, branchBitReturnForm},
 	branchBitIfThenElseForm:  [2]byte{branchBitIfThenElseFormMask, branchBitIfThenElseForm},
 	branchBitCallForm:        [2]byte{branchBitCallFormMask, branchBitCallForm},
@@ -150,7 +150,6 @@ func ifThenElseOp(core *Core, call, ret, imm bool, inst *DecodedInstruction) err
 		}
 		return branch(core, addr, call)
 	}
-	return nil
 }
 func jump(core *Core, inst *DecodedInstruction) error {
 	bb := branchBits(inst.Op)
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"github.com/DrItanium/cores/registration/machine"
+	"io"
 )
 
 func RegistrationName() string {
@@ -55,6 +56,7 @@ const (
 	ErrorEncodeByteOutOfRange
 	ErrorGroupValueOutOfRange
 	ErrorOpValueOutOfRange
+	ErrorUndefinedExtendedOperation
 )
 const (
 	// Instruction groups
@@ -82,6 +84,7 @@ var errorLookup = []string{
 	"Specified illegal byte offset %d to encode data into",
 	"Provided group id %d is larger than the space allotted to specifying the group",
 	"Provided op id %d is larger than the space allotted to specifying the op",
+	"Extended operation %d does not have a microcode routine",
 }
 
 type Word uint16
@@ -137,23 +140,7 @@ type DecodedInstruction struct {
 
 func (this Instruction) Decode() (*DecodedInstruction, error) {
 	var di DecodedInstruction
-	di.Group = this.group()
-	di.Op = this.op()
-	if value, err := this.register(1); err != nil {
-		return nil, err
-	} else {
-		di.Data[0] = value
-	}
-	if value, err := this.register(2); err != nil {
-		return nil, err
-	} else {
-		di.Data[1] = value
-	}
-	if value, err := this.register(3); err != nil {
-		return nil, err
-	} else {
-		di.Data[2] = value
-	}
+	this.decodeInto(&di)
 	return &di, nil
 }
 
@@ -200,7 +187,7 @@ type SystemCall ExecutionUnit
 type Core struct {
 	gpr   [RegisterCount - UserRegisterBegin]Word
 	code  [MemorySize]Instruction
-	data  [MemorySize]Word
+	data  *[MemorySize]Word
 	ucode [MemorySize]Word
 	stack [MemorySize]Word
 	call  [MemorySize]Word
@@ -210,10 +197,22 @@ type Core struct {
 	stackPointer       Word
 	callPointer        Word
 	predicate          Word
+	status             Word
 	advancePc          bool
 	terminateExecution bool
 	groups             [MajorOperationGroupCount]ExecutionUnit
 	systemCalls        [SystemCallCount]SystemCall
+	watchpoints        []*Watchpoint
+	lastWatchpointHit  *WatchpointHit
+	watchLog           io.Writer
+	output             io.Writer
+	// the last value which went through each io address, only kept while an
+	// io watchpoint is installed
+	ioValues   map[Word]Word
+	input      io.Reader
+	decoded    [MemorySize]decodedEntry
+	threaded   *threadedEngine
+	extensions []string
 }
 
 func (this *Core) SetRegister(index byte, value Word) error {
@@ -255,49 +254,70 @@ func (this *Core) Register(index byte) Word {
 	}
 }
 
+// Direct access to the user registers (r6 onward), used by translated code
+func (this *Core) UserRegisters() *[RegisterCount - UserRegisterBegin]Word {
+	return &this.gpr
+}
+
+// The status register holds the carry and overflow bits left behind by the
+// last add with carry or sub with borrow, no other instruction touches it
+func (this *Core) Status() Word {
+	return this.status
+}
+func (this *Core) SetStatus(value Word) {
+	this.status = value & (StatusCarry | StatusOverflow)
+}
+
 func (this *Core) CodeMemory(address Word) Instruction {
 	return this.code[address]
 }
 func (this *Core) SetCodeMemory(address Word, value Instruction) error {
 	this.code[address] = value
+	this.invalidateDecodedInstruction(address)
 	return nil
 }
 func (this *Core) Call(addr Word) error {
 	this.callPointer++
-	this.call[this.callPointer] = this.NextInstructionAddress()
+	this.storeSegmentWord(callSegment, this.callPointer, this.NextInstructionAddress())
 	return this.SetRegister(InstructionPointer, addr)
 }
 func (this *Core) Return() Word {
-	value := this.call[this.callPointer]
+	value := this.loadSegmentWord(callSegment, this.callPointer)
 	this.callPointer--
 	return value
 }
 func (this *Core) Push(value Word) {
 	this.stackPointer++
-	this.stack[this.stackPointer] = value
+	this.storeSegmentWord(stackSegment, this.stackPointer, value)
 }
 func (this *Core) Peek() Word {
-	return this.stack[this.stackPointer]
+	return this.loadSegmentWord(stackSegment, this.stackPointer)
 }
 func (this *Core) Pop() Word {
-	value := this.stack[this.stackPointer]
+	value := this.loadSegmentWord(stackSegment, this.stackPointer)
 	this.stackPointer--
 	return value
 }
 func (this *Core) DataMemory(address Word) Word {
-	return this.data[address]
+	return this.loadSegmentWord(dataSegment, address)
 }
 func (this *Core) SetDataMemory(address, value Word) error {
-	this.data[address] = value
+	this.storeSegmentWord(dataSegment, address, value)
 	return nil
 }
 
+// Make this core use the data segment of another core, this is how the cores
+// of a multiprocessor machine share memory
+func (this *Core) ShareDataSegment(other *Core) {
+	this.data = other.data
+}
+
 func (this *Core) MicrocodeMemory(address Word) Word {
-	return this.ucode[address]
+	return this.loadSegmentWord(microcodeSegment, address)
 }
 
 func (this *Core) SetMicrocodeMemory(address, value Word) error {
-	this.ucode[address] = value
+	this.storeSegmentWord(microcodeSegment, address, value)
 	return nil
 }
 
@@ -314,6 +334,7 @@ var defaultExecutionUnits = []struct {
 
 func New() (*Core, error) {
 	var c Core
+	c.data = new([MemorySize]Word)
 	c.advancePc = true
 	c.terminateExecution = false
 	if err := c.SetRegister(InstructionPointer, 0); err != nil {
@@ -326,7 +347,7 @@ func New() (*Core, error) {
 		return nil, err
 	}
 	for i := 0; i < MajorOperationGroupCount; i++ {
-		if err := c.InstallExecutionUnit(byte(i), defaultExtendedUnit); err != nil {
+		if err := c.InstallExecutionUnit(byte(i), extendedUnit); err != nil {
 			return nil, err
 		}
 	}
@@ -343,6 +364,8 @@ func New() (*Core, error) {
 	c.InstallSystemCall(SystemCallTerminate, terminateSystemCall)
 	c.InstallSystemCall(SystemCallPanic, panicSystemCall)
 	c.InstallSystemCall(SystemCallPutc, putcSystemCall)
+	c.InstallSystemCall(SystemCallGetStatus, getStatusSystemCall)
+	c.InstallSystemCall(SystemCallSetStatus, setStatusSystemCall)
 	return &c, nil
 }
 
@@ -351,6 +374,7 @@ func (this *Core) InstallExecutionUnit(group byte, fn ExecutionUnit) error {
 		return NewError(ErrorGroupValueOutOfRange, uint(group))
 	} else {
 		this.groups[group] = fn
+		this.flushDecodedInstructions()
 		return nil
 	}
 }
@@ -383,9 +407,6 @@ func (this *Core) HaltExecution() {
 func (this *Core) ResumeExecution() {
 	this.terminateExecution = false
 }
-func defaultExtendedUnit(core *Core, inst *DecodedInstruction) error {
-	return NewError(ErrorInvalidInstructionGroupProvided, uint(inst.Group))
-}
 
 func (this *Core) InstructionAddress() Word {
 	return this.Register(InstructionPointer)
@@ -434,10 +455,15 @@ func (this *Core) AdvanceProgramCounter() error {
 }
 
 func (this *Core) ExecuteCurrentInstruction() error {
-	return this.Dispatch(this.CurrentInstruction())
+	this.advancePc = true
+	entry := this.decodedInstruction(this.instructionPointer)
+	return entry.unit(this, &entry.inst)
 }
 
 func (this *Core) Run() error {
+	if this.threaded != nil {
+		return this.runThreaded()
+	}
 	for !this.TerminateExecution() {
 		if err := this.ExecuteCurrentInstruction(); err != nil {
 			return fmt.Errorf("ERROR during execution: %s\n", err)
@@ -487,7 +513,7 @@ func readInstruction(input <-chan byte) (Instruction, error) {
 
 }
 func (this *Core) InstallProgram(input <-chan byte) error {
-	installWords := func(data [MemorySize]Word, input <-chan byte) error {
+	installWords := func(data *[MemorySize]Word, input <-chan byte) error {
 		for i := 0; i < MemorySize; i++ {
 			if val, err := readWord(input); err != nil {
 				return err
@@ -504,13 +530,14 @@ func (this *Core) InstallProgram(input <-chan byte) error {
 			this.code[i] = inst
 		}
 	}
+	this.flushDecodedInstructions()
 	if err := installWords(this.data, input); err != nil {
 		return err
-	} else if err := installWords(this.ucode, input); err != nil {
+	} else if err := installWords(&this.ucode, input); err != nil {
 		return err
-	} else if err := installWords(this.stack, input); err != nil {
+	} else if err := installWords(&this.stack, input); err != nil {
 		return err
-	} else if err := installWords(this.call, input); err != nil {
+	} else if err := installWords(&this.call, input); err != nil {
 		return err
 	} else {
 		return nil
@@ -534,7 +561,7 @@ func (this *Core) Dump(output chan<- byte) error {
 			output <- v
 		}
 	}
-	dumpWords(this.data, output)
+	dumpWords(*this.data, output)
 	dumpWords(this.ucode, output)
 	dumpWords(this.stack, output)
 	dumpWords(this.call, output)
@@ -583,19 +610,19 @@ func (this segment) acceptsWords() bool {
 }
 
 func (this *Core) StackMemory(address Word) Word {
-	return this.stack[address]
+	return this.loadSegmentWord(stackSegment, address)
 }
 
 func (this *Core) SetStackMemory(address, value Word) error {
-	this.stack[address] = value
+	this.storeSegmentWord(stackSegment, address, value)
 	return nil
 }
 func (this *Core) CallMemory(address Word) Word {
-	return this.call[address]
+	return this.loadSegmentWord(callSegment, address)
 }
 
 func (this *Core) SetCallMemory(address, value Word) error {
-	this.call[address] = value
+	this.storeSegmentWord(callSegment, address, value)
 	return nil
 }
 func (this *Core) RegisterIoDevice(dev IoDevice) error {
@@ -621,7 +648,12 @@ type IoDevice interface {
 func (this *Core) IoMemory(address Word) (Word, error) {
 	for _, d := range this.io {
 		if d.RespondsTo(address) {
-			return d.Load(address)
+			if value, err := d.Load(address); err != nil {
+				return value, err
+			} else {
+				this.observeIo(WatchRead, address, value)
+				return value, nil
+			}
 		}
 	}
 	return 0, fmt.Errorf("Attempted to load from undeclared io address %x", address)
@@ -629,7 +661,12 @@ func (this *Core) IoMemory(address Word) (Word, error) {
 func (this *Core) SetIoMemory(address, value Word) error {
 	for _, d := range this.io {
 		if d.RespondsTo(address) {
-			return d.Store(address, value)
+			if err := d.Store(address, value); err != nil {
+				return err
+			} else {
+				this.observeIo(WatchWrite, address, value)
+				return nil
+			}
 		}
 	}
 	return fmt.Errorf("Attempted to store to undeclared io address %x", address)
@@ -0,0 +1,193 @@
+// executable microcode for the extended instruction groups
+package iris16
+
+import "fmt"
+
+// Instruction groups 5 through 7 don't have any built in operations, instead
+// every operation in them runs a routine stored in the microcode segment. The
+// first words of the microcode segment make up a dispatch table holding the
+// entry point of each extended operation, (group - 5) * 32 + op, with an entry
+// point of zero meaning the operation is undefined.
+const (
+	InstructionGroupExtendedBegin = 5
+	ExtendedOperationCount        = (MajorOperationGroupCount - InstructionGroupExtendedBegin) * 32
+	MicrocodeDispatchTableSize    = ExtendedOperationCount
+)
+
+const (
+	// Micro operations
+	MicroOpEnd           = iota // finish the extended instruction
+	MicroOpMove                 // d = s0
+	MicroOpAdd                  // d = s0 + s1
+	MicroOpSub                  // d = s0 - s1
+	MicroOpMul                  // d = s0 * s1
+	MicroOpDiv                  // d = s0 / s1
+	MicroOpRem                  // d = s0 % s1
+	MicroOpShiftLeft            // d = s0 << s1
+	MicroOpShiftRight           // d = s0 >> s1
+	MicroOpAnd                  // d = s0 & s1
+	MicroOpOr                   // d = s0 | s1
+	MicroOpXor                  // d = s0 ^ s1
+	MicroOpNot                  // d = ^s0
+	MicroOpEq                   // d = s0 == s1
+	MicroOpLessThan             // d = s0 < s1
+	MicroOpConstant             // d = the next microcode word
+	MicroOpLoad                 // d = data[s0]
+	MicroOpStore                // data[s0] = s1
+	MicroOpPush                 // push s0 onto the stack
+	MicroOpPop                  // d = pop the stack
+	MicroOpJump                 // continue at the microcode address in the next word
+	MicroOpJumpIfNotZero        // continue at the microcode address in the next word if s0 isn't zero
+	MicroOpCount
+)
+
+const (
+	// Micro operands
+	MicroOperandA         = iota // the register named by the first byte of the instruction
+	MicroOperandB                // the register named by the second byte of the instruction
+	MicroOperandC                // the register named by the third byte of the instruction
+	MicroOperandImmediate        // the immediate made out of the last two bytes, read only
+	MicroOperandT0               // scratch values private to a single extended instruction
+	MicroOperandT1
+	MicroOperandT2
+	MicroOperandT3
+	MicroOperandCount
+)
+
+func init() {
+	if MicroOpCount > 32 {
+		panic("Too many micro operations defined!")
+	} else if MicroOperandCount > 8 {
+		panic("Too many micro operands defined!")
+	}
+}
+
+// A micro instruction is a single microcode word, the low five bits are the
+// operation followed by three bit destination, first source and second source
+// operands
+type MicroInstruction Word
+
+func NewMicroInstruction(op, dest, src0, src1 byte) MicroInstruction {
+	return MicroInstruction(Word(op&0x1F) | (Word(dest&0x7) << 5) | (Word(src0&0x7) << 8) | (Word(src1&0x7) << 11))
+}
+func (this MicroInstruction) op() byte {
+	return byte(this & 0x1F)
+}
+func (this MicroInstruction) dest() byte {
+	return byte((this >> 5) & 0x7)
+}
+func (this MicroInstruction) src0() byte {
+	return byte((this >> 8) & 0x7)
+}
+func (this MicroInstruction) src1() byte {
+	return byte((this >> 11) & 0x7)
+}
+
+type microcodeState struct {
+	core *Core
+	inst *DecodedInstruction
+	temp [MicroOperandCount - MicroOperandT0]Word
+}
+
+func (this *microcodeState) operand(index byte) Word {
+	switch index {
+	case MicroOperandA, MicroOperandB, MicroOperandC:
+		return this.core.Register(this.inst.Data[index])
+	case MicroOperandImmediate:
+		return this.inst.Immediate()
+	default:
+		return this.temp[index-MicroOperandT0]
+	}
+}
+func (this *microcodeState) setOperand(index byte, value Word) error {
+	switch index {
+	case MicroOperandA, MicroOperandB, MicroOperandC:
+		return this.core.SetRegister(this.inst.Data[index], value)
+	case MicroOperandImmediate:
+		return fmt.Errorf("The immediate micro operand is read only")
+	default:
+		this.temp[index-MicroOperandT0] = value
+		return nil
+	}
+}
+
+func extendedUnit(core *Core, inst *DecodedInstruction) error {
+	slot := Word(inst.Group-InstructionGroupExtendedBegin)*32 + Word(inst.Op)
+	pc := core.MicrocodeMemory(slot)
+	if pc == 0 {
+		return NewError(ErrorUndefinedExtendedOperation, uint(slot))
+	}
+	state := microcodeState{core: core, inst: inst}
+	for {
+		mi := MicroInstruction(core.MicrocodeMemory(pc))
+		pc++
+		s0, s1 := state.operand(mi.src0()), state.operand(mi.src1())
+		var result Word
+		switch mi.op() {
+		case MicroOpEnd:
+			return nil
+		case MicroOpMove:
+			result = s0
+		case MicroOpAdd:
+			result = s0 + s1
+		case MicroOpSub:
+			result = s0 - s1
+		case MicroOpMul:
+			result = s0 * s1
+		case MicroOpDiv, MicroOpRem:
+			if s1 == 0 {
+				return fmt.Errorf("Divide by zero in microcode at %x", pc-1)
+			} else if mi.op() == MicroOpDiv {
+				result = s0 / s1
+			} else {
+				result = s0 % s1
+			}
+		case MicroOpShiftLeft:
+			result = s0 << s1
+		case MicroOpShiftRight:
+			result = s0 >> s1
+		case MicroOpAnd:
+			result = s0 & s1
+		case MicroOpOr:
+			result = s0 | s1
+		case MicroOpXor:
+			result = s0 ^ s1
+		case MicroOpNot:
+			result = ^s0
+		case MicroOpEq:
+			result = BoolToWord(s0 == s1)
+		case MicroOpLessThan:
+			result = BoolToWord(s0 < s1)
+		case MicroOpConstant:
+			result = core.MicrocodeMemory(pc)
+			pc++
+		case MicroOpLoad:
+			result = core.DataMemory(s0)
+		case MicroOpStore:
+			if err := core.SetDataMemory(s0, s1); err != nil {
+				return err
+			}
+			continue
+		case MicroOpPush:
+			core.Push(s0)
+			continue
+		case MicroOpPop:
+			result = core.Pop()
+		case MicroOpJump:
+			pc = core.MicrocodeMemory(pc)
+			continue
+		case MicroOpJumpIfNotZero:
+			if s0 != 0 {
+				pc = core.MicrocodeMemory(pc)
+			} else {
+				pc++
+			}
+			continue
+		default:
+			return fmt.Errorf("Illegal micro operation %d at %x", mi.op(), pc-1)
+		}
+		if err := state.setOperand(mi.dest(), result); err != nil {
+			return err
+		}
+	}
+}
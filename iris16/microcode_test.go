@@ -0,0 +1,83 @@
+package iris16
+
+import (
+	"github.com/DrItanium/cores/registration/parser"
+	"strings"
+	"testing"
+)
+
+func assemble(source string) (*Core, error) {
+	if p, err := generateParser(); err != nil {
+		return nil, err
+	} else {
+		return assembleWith(p.(*_parser), source)
+	}
+}
+func assembleWith(p *_parser, source string) (*Core, error) {
+	lines := make(chan parser.Entry)
+	go func() {
+		for i, line := range strings.Split(source, "\n") {
+			if line = strings.TrimSpace(line); len(line) > 0 {
+				lines <- parser.Entry{Line: line, Index: i + 1}
+			}
+		}
+		close(lines)
+	}()
+	if err := p.Parse(lines); err != nil {
+		return nil, err
+	} else if err := p.Process(); err != nil {
+		return nil, err
+	} else {
+		return p.core, nil
+	}
+}
+
+func Test_UndefinedExtendedOperation(t *testing.T) {
+	core, err := New()
+	if err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	}
+	inst, _ := NewDecodedInstruction(InstructionGroupExtendedBegin+1, 3, 6, 7, 8)
+	if err := core.Invoke(inst); err == nil {
+		t.Fatalf("Extended operation without a microcode routine didn't fail")
+	}
+}
+
+func Test_MacroRunsFromMicrocode(t *testing.T) {
+	core, err := assemble(`
+	.microcode
+	.macro triple
+	add t0 = b, b
+	add a = t0, b
+	.endmacro
+	.code
+	set r6 = #5
+	triple r7 = r6
+	system #0, r0, r0
+	`)
+	if err != nil {
+		t.Fatalf("Couldn't assemble program: %s", err)
+	} else if inst, _ := core.CodeMemory(1).Decode(); inst.Group != InstructionGroupExtendedBegin || inst.Op != 0 {
+		t.Fatalf("The first macro should be group %d op 0 but is group %d op %d", InstructionGroupExtendedBegin, inst.Group, inst.Op)
+	} else if entry := core.MicrocodeMemory(0); entry != MicrocodeDispatchTableSize {
+		t.Fatalf("The first macro should start right after the dispatch table but starts at %d", entry)
+	} else if err := core.Run(); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	} else if r7 := core.Register(7); r7 != 15 {
+		t.Fatalf("Expected 15 but got %d", r7)
+	}
+}
+
+func Test_MacroErrors(t *testing.T) {
+	for _, source := range []string{
+		".code\n.macro bad\n.endmacro",                  // outside of the microcode segment
+		".microcode\n.macro bad\nadd a = b, c",          // no endmacro
+		".microcode\n.macro bad\nmove i = a\n.endmacro", // writes to the immediate
+		".microcode\n.macro bad\nadd a = r6, c\n.endmacro",
+		".microcode\n.endmacro",
+	} {
+		if _, err := assemble(source); err == nil {
+			t.Errorf("%q should not have assembled", source)
+		}
+	}
+}
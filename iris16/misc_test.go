@@ -1,6 +1,10 @@
 package iris16
 
-import "testing"
+import (
+	"bytes"
+	"os"
+	"testing"
+)
 
 func Test_TerminateCall(t *testing.T) {
 	if core, err := New(); err != nil {
@@ -15,3 +19,22 @@ func Test_TerminateCall(t *testing.T) {
 		t.Logf("Terminate system call did tell core to terminate!")
 	}
 }
+
+func Test_PutcWritesToOutput(t *testing.T) {
+	var out bytes.Buffer
+	if core, err := New(); err != nil {
+		t.Fatalf("Couldn't create core: %s", err)
+	} else if core.Output() != os.Stdout {
+		t.Errorf("A new core doesn't write to stdout")
+	} else if inst, err := NewDecodedInstruction(InstructionGroupMisc, MiscOpSystemCall, SystemCallPutc, 6, 6); err != nil {
+		t.Fatalf("Couldn't create decoded instruction: %s", err)
+	} else {
+		core.SetOutput(&out)
+		core.SetRegister(6, 'A')
+		if err := core.Invoke(inst); err != nil {
+			t.Errorf("Couldn't invoke system command: %s", err)
+		} else if out.String() != "A" {
+			t.Errorf("putc wrote %q to the output instead of \"A\"", out.String())
+		}
+	}
+}
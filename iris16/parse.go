@@ -17,6 +17,7 @@ func generateParser(a ...interface{}) (parser.Parser, error) {
 		p.core = core
 		p.labels = make(labelMap)
 		p.aliases = make(map[string]byte)
+		p.macros = make(map[string]Word)
 		return &p, nil
 	}
 }
@@ -69,6 +70,8 @@ const (
 	typeDirectiveMicrocode
 	typeDirectiveCall
 	typeDirectiveStack
+	typeDirectiveMacro
+	typeDirectiveEndMacro
 	// keywords
 	// memory words
 	keywordSet
@@ -102,6 +105,24 @@ const (
 	keywordDecrement
 	keywordHalve
 	keywordDouble
+	keywordSignedDiv
+	keywordSignedRem
+	keywordSignedShiftRight
+	keywordAddWithCarry
+	keywordSubWithBorrow
+	keywordFixedMul
+	keywordFixedDiv
+	keywordFloatAdd
+	keywordFloatSub
+	keywordFloatMul
+	keywordFloatDiv
+	keywordFloatEqual
+	keywordFloatLessThan
+	keywordFloatLessThanOrEqualTo
+	keywordIntegerToFloat
+	keywordFloatToInteger
+	keywordFixedToFloat
+	keywordFloatToFixed
 	// compare words
 	keywordEqual
 	keywordNotEqual
@@ -109,6 +130,10 @@ const (
 	keywordGreaterThan
 	keywordLessThanOrEqualTo
 	keywordGreaterThanOrEqualTo
+	keywordSignedLessThan
+	keywordSignedGreaterThan
+	keywordSignedLessThanOrEqualTo
+	keywordSignedGreaterThanOrEqualTo
 	// misc words
 	keywordSystem
 )
@@ -149,7 +174,7 @@ func (this *node) parseLabel(val string) error {
 	nVal := strings.TrimSuffix(val, ":")
 	q, _ := utf8.DecodeRuneInString(nVal)
 	if !unicode.IsLetter(q) {
-		return fmt.Errorf("Label %s starts with a non letter %s!", nVal, q)
+		return fmt.Errorf("Label %s starts with a non letter %c!", nVal, q)
 	} else {
 		this.Type = typeLabel
 		this.Value = nVal
@@ -208,6 +233,8 @@ var directives = map[string]nodeType{
 	"microcode": typeDirectiveMicrocode,
 	"stack":     typeDirectiveStack,
 	"procedure": typeDirectiveCall,
+	"macro":     typeDirectiveMacro,
+	"endmacro":  typeDirectiveEndMacro,
 }
 
 func (this *node) parseDirective(val string) error {
@@ -228,41 +255,64 @@ func (this *node) parseAlias(str string) error {
 }
 
 var keywords = map[string]nodeType{
-	"branch":     keywordBranch,
-	"call":       keywordCall,
-	"return":     keywordReturn,
-	"if":         keywordIf,
-	"then":       keywordThen,
-	"else":       keywordElse,
-	"add":        keywordAdd,
-	"sub":        keywordSub,
-	"mul":        keywordMul,
-	"div":        keywordDiv,
-	"rem":        keywordRem,
-	"shiftleft":  keywordShiftLeft,
-	"shiftright": keywordShiftRight,
-	"and":        keywordAnd,
-	"or":         keywordOr,
-	"not":        keywordNot,
-	"xor":        keywordXor,
-	"halve":      keywordHalve,
-	"incr":       keywordIncrement,
-	"decr":       keywordDecrement,
-	"system":     keywordSystem,
-	"set":        keywordSet,
-	"move":       keywordMove,
-	"swap":       keywordSwap,
-	"load":       keywordLoad,
-	"store":      keywordStore,
-	"double":     keywordDouble,
-	"eq":         keywordEqual,
-	"ne":         keywordNotEqual,
-	"lt":         keywordLessThan,
-	"gt":         keywordGreaterThan,
-	"le":         keywordLessThanOrEqualTo,
-	"ge":         keywordGreaterThanOrEqualTo,
-	"push":       keywordPush,
-	"pop":        keywordPop,
+	"branch":      keywordBranch,
+	"call":        keywordCall,
+	"return":      keywordReturn,
+	"if":          keywordIf,
+	"then":        keywordThen,
+	"else":        keywordElse,
+	"add":         keywordAdd,
+	"sub":         keywordSub,
+	"mul":         keywordMul,
+	"div":         keywordDiv,
+	"rem":         keywordRem,
+	"shiftleft":   keywordShiftLeft,
+	"shiftright":  keywordShiftRight,
+	"and":         keywordAnd,
+	"or":          keywordOr,
+	"not":         keywordNot,
+	"xor":         keywordXor,
+	"halve":       keywordHalve,
+	"incr":        keywordIncrement,
+	"decr":        keywordDecrement,
+	"system":      keywordSystem,
+	"set":         keywordSet,
+	"move":        keywordMove,
+	"swap":        keywordSwap,
+	"load":        keywordLoad,
+	"store":       keywordStore,
+	"double":      keywordDouble,
+	"eq":          keywordEqual,
+	"ne":          keywordNotEqual,
+	"lt":          keywordLessThan,
+	"gt":          keywordGreaterThan,
+	"le":          keywordLessThanOrEqualTo,
+	"ge":          keywordGreaterThanOrEqualTo,
+	"sdiv":        keywordSignedDiv,
+	"srem":        keywordSignedRem,
+	"sshiftright": keywordSignedShiftRight,
+	"addc":        keywordAddWithCarry,
+	"subb":        keywordSubWithBorrow,
+	"qmul":        keywordFixedMul,
+	"qdiv":        keywordFixedDiv,
+	"fadd":        keywordFloatAdd,
+	"fsub":        keywordFloatSub,
+	"fmul":        keywordFloatMul,
+	"fdiv":        keywordFloatDiv,
+	"feq":         keywordFloatEqual,
+	"flt":         keywordFloatLessThan,
+	"fle":         keywordFloatLessThanOrEqualTo,
+	"itof":        keywordIntegerToFloat,
+	"ftoi":        keywordFloatToInteger,
+	"qtof":        keywordFixedToFloat,
+	"ftoq":        keywordFloatToFixed,
+	"slt":         keywordSignedLessThan,
+	"sgt":         keywordSignedGreaterThan,
+	"sle":         keywordSignedLessThanOrEqualTo,
+	"sge":         keywordSignedGreaterThanOrEqualTo,
+	"push":        keywordPush,
+	"pop":         keywordPop,
+	"peek":        keywordPeek,
 }
 
 func (this *node) parseGeneric(str string) error {
@@ -334,7 +384,7 @@ func (this *statement) AddUnknown(value string) {
 func (this *statement) String() string {
 	str := fmt.Sprintf("%d: ", this.index)
 	for _, n := range this.contents {
-		str += fmt.Sprintf(" %T: %s ", n, *n)
+		str += fmt.Sprintf(" %T: %v ", n, *n)
 	}
 	return str
 }
@@ -428,6 +478,10 @@ type _parser struct {
 	aliases              map[string]byte
 	indirectAddresses    []indirectAddress
 	deferredInstructions []deferredInstruction
+	macros               map[string]Word
+	nextMacro            Word
+	currMacro            string
+	reservedGroups       [MajorOperationGroupCount]bool
 }
 
 func (this *_parser) Defer(inst *DecodedInstruction, trouble *node) {
@@ -518,7 +572,7 @@ func (this *_parser) setData(nodes []*node) error {
 			case stackSegment:
 				this.core.stack[t] = val
 			}
-		case typeLabel:
+		case typeLabel, typeId:
 			this.indirectAddresses = append(this.indirectAddresses, indirectAddress{label: addr.Value.(string), seg: this.currSegment, address: this.addrs[this.currSegment]})
 		default:
 			return fmt.Errorf("word directives can only accept immediates and labels right now")
@@ -612,6 +666,9 @@ func (this *_parser) Process() error {
 			return fmt.Errorf("Error: line %d: msg: %s", stmt.index, err)
 		}
 	}
+	if this.currMacro != "" {
+		return fmt.Errorf("Macro %s is missing an .endmacro directive", this.currMacro)
+	}
 	// check the deferred labels now that we are done processing the whole file
 	for _, d := range this.deferredInstructions {
 		str := d.trouble.Value.(string)
@@ -651,6 +708,16 @@ func (this *_parser) parseStatement(stmt *statement) error {
 		return err
 	}
 	rest := stmt.Rest()
+	if this.currMacro != "" {
+		switch first.Type {
+		case typeComment, typeLabel:
+			// handled the same way inside and outside of a macro
+		case typeDirectiveEndMacro:
+			return this.endMacro(rest)
+		default:
+			return this.parseMicroOp(first, rest)
+		}
+	}
 	switch first.Type {
 	case typeComment: // do nothing, just continue
 		if len(rest) > 0 {
@@ -670,11 +737,11 @@ func (this *_parser) parseStatement(stmt *statement) error {
 		} else {
 			return nil
 		}
-	case keywordAdd, keywordSub, keywordMul, keywordDiv, keywordRem, keywordShiftLeft, keywordShiftRight, keywordAnd, keywordOr, keywordNot, keywordXor, keywordIncrement, keywordDecrement, keywordHalve:
+	case keywordAdd, keywordSub, keywordMul, keywordDiv, keywordRem, keywordShiftLeft, keywordShiftRight, keywordAnd, keywordOr, keywordNot, keywordXor, keywordIncrement, keywordDecrement, keywordHalve, keywordDouble, keywordSignedDiv, keywordSignedRem, keywordSignedShiftRight, keywordAddWithCarry, keywordSubWithBorrow, keywordFixedMul, keywordFixedDiv, keywordFloatAdd, keywordFloatSub, keywordFloatMul, keywordFloatDiv, keywordFloatEqual, keywordFloatLessThan, keywordFloatLessThanOrEqualTo, keywordIntegerToFloat, keywordFloatToInteger, keywordFixedToFloat, keywordFloatToFixed:
 		return this.parseArithmetic(first, rest)
-	case keywordMove, keywordSet, keywordSwap, keywordLoad, keywordStore, keywordPop, keywordPush:
+	case keywordMove, keywordSet, keywordSwap, keywordLoad, keywordStore, keywordPop, keywordPeek, keywordPush:
 		return this.parseMove(first, rest)
-	case keywordEqual, keywordNotEqual, keywordLessThan, keywordGreaterThan, keywordLessThanOrEqualTo, keywordGreaterThanOrEqualTo:
+	case keywordEqual, keywordNotEqual, keywordLessThan, keywordGreaterThan, keywordLessThanOrEqualTo, keywordGreaterThanOrEqualTo, keywordSignedLessThan, keywordSignedGreaterThan, keywordSignedLessThanOrEqualTo, keywordSignedGreaterThanOrEqualTo:
 		return this.parseCompare(first, rest)
 	case keywordSystem:
 		return this.parseMisc(first, rest)
@@ -697,20 +764,26 @@ func (this *_parser) parseStatement(stmt *statement) error {
 		return this.setPosition(rest)
 	case typeDirectiveWord:
 		return this.setData(rest)
+	case typeDirectiveMacro:
+		return this.beginMacro(rest)
+	case typeDirectiveEndMacro:
+		return fmt.Errorf(".endmacro found outside of a macro definition")
 	case typeComma:
 		return fmt.Errorf("Can't start a line with a comma")
 	case typeEquals:
 		return fmt.Errorf("Can't start a line with a equals sign")
 	case typeId:
+		if slot, ok := this.macros[first.Value.(string)]; ok {
+			return this.parseMacroInvocation(slot, rest)
+		}
 		return fmt.Errorf("Unknown node %s", first.Value)
 	default:
 		return fmt.Errorf("Unhandled nodeType %d: %s", first.Type, first.Value)
 	}
-	return nil
 }
 
-//Match: { "store", Register, Equals, Register, Comma, Register, Comma, SegmentCode },
-//Function: encodeStoreCodeOperation
+// Match: { "store", Register, Equals, Register, Comma, Register, Comma, SegmentCode },
+// Function: encodeStoreCodeOperation
 var segments = map[string]segment{
 	"code":      codeSegment,
 	"data":      dataSegment,
@@ -932,6 +1005,25 @@ var compareTable = map[nodeType]map[nodeType]byte{
 		typeOr:     CompareOpGreaterThanOrEqualToOr,
 		typeXor:    CompareOpGreaterThanOrEqualToXor,
 	},
+	keywordSignedLessThan: map[nodeType]byte{
+		typeEquals: CompareOpSignedLessThan,
+		typeAnd:    CompareOpSignedLessThanAnd,
+		typeOr:     CompareOpSignedLessThanOr,
+		typeXor:    CompareOpSignedLessThanXor,
+	},
+	keywordSignedLessThanOrEqualTo: map[nodeType]byte{
+		typeEquals: CompareOpSignedLessThanOrEqualTo,
+		typeAnd:    CompareOpSignedLessThanOrEqualToAnd,
+		typeOr:     CompareOpSignedLessThanOrEqualToOr,
+		typeXor:    CompareOpSignedLessThanOrEqualToXor,
+	},
+}
+
+// the signed greater than forms are encoded as signed less than forms with
+// their source operands swapped
+var swappedCompares = map[nodeType]nodeType{
+	keywordSignedGreaterThan:          keywordSignedLessThan,
+	keywordSignedGreaterThanOrEqualTo: keywordSignedLessThanOrEqualTo,
 }
 
 func (this *_parser) parseCompare(first *node, rest []*node) error {
@@ -961,7 +1053,12 @@ func (this *_parser) parseCompare(first *node, rest []*node) error {
 			return fmt.Errorf("Second source argument in a compare operation must be a register or alias")
 		} else {
 			// determine the corresponding op
-			d.Op = compareTable[first.Type][update.Type]
+			kind := first.Type
+			if swapped, ok := swappedCompares[kind]; ok {
+				kind = swapped
+				sv0, sv1 = sv1, sv0
+			}
+			d.Op = compareTable[kind][update.Type]
 			if dv, err := this.resolveRegister(dest); err != nil {
 				return err
 			} else if s0, err := this.resolveRegister(sv0); err != nil {
@@ -1001,7 +1098,7 @@ func (this *_parser) parseSystem(d *DecodedInstruction, rest []*node) error {
 			return err
 		} else if !rest[3].Type.isComma() {
 			return fmt.Errorf("second and third arguments in a system operation must be separated by a comma")
-		} else if sv1 := rest[2]; !sv1.Type.registerOrAlias() {
+		} else if sv1 := rest[4]; !sv1.Type.registerOrAlias() {
 			return fmt.Errorf("Third argument in system operation must be a register or alias")
 		} else if s1, err := this.resolveRegister(sv1); err != nil {
 			return err
@@ -1031,6 +1128,22 @@ func (this *_parser) parseMisc(first *node, rest []*node) error {
 	return this.installInstruction(d.Encode())
 }
 
+var floatOperationKeywords = map[nodeType]byte{
+	keywordFloatAdd:               FloatOpAdd,
+	keywordFloatSub:               FloatOpSub,
+	keywordFloatMul:               FloatOpMul,
+	keywordFloatDiv:               FloatOpDiv,
+	keywordFloatEqual:             FloatOpEqual,
+	keywordFloatLessThan:          FloatOpLessThan,
+	keywordFloatLessThanOrEqualTo: FloatOpLessThanOrEqualTo,
+}
+var floatConversionKeywords = map[nodeType]byte{
+	keywordIntegerToFloat: FloatConvertFromInteger,
+	keywordFloatToInteger: FloatConvertToInteger,
+	keywordFixedToFloat:   FloatConvertFromFixed,
+	keywordFloatToFixed:   FloatConvertToFixed,
+}
+
 func (this *_parser) parseArithmetic(t *node, nodes []*node) error {
 	var inst DecodedInstruction
 	inst.Group = InstructionGroupArithmetic
@@ -1069,6 +1182,8 @@ func (this *_parser) parseArithmetic(t *node, nodes []*node) error {
 				inst.Op = ArithmeticOpDecrement
 			case keywordNot:
 				inst.Op = ArithmeticOpBinaryNot
+			case keywordIntegerToFloat, keywordFloatToInteger, keywordFixedToFloat, keywordFloatToFixed:
+				inst.Op, inst.Data[2] = ArithmeticOpFloatConvert, floatConversionKeywords[t.Type]
 			default:
 				return fmt.Errorf("Illegal arithmetic operation %s", t.Value)
 			}
@@ -1120,6 +1235,8 @@ func (this *_parser) parseArithmetic(t *node, nodes []*node) error {
 					inst.Op = ArithmeticOpShiftLeftImmediate
 				case keywordShiftRight:
 					inst.Op = ArithmeticOpShiftRightImmediate
+				case keywordSignedShiftRight:
+					inst.Op = ArithmeticOpSignedShiftRightImmediate
 				default:
 					return fmt.Errorf("Arithmetic operation %s does not have an immediate form!", t.Value)
 				}
@@ -1145,12 +1262,35 @@ func (this *_parser) parseArithmetic(t *node, nodes []*node) error {
 					inst.Op = ArithmeticOpBinaryOr
 				case keywordXor:
 					inst.Op = ArithmeticOpBinaryXor
+				case keywordSignedDiv:
+					inst.Op = ArithmeticOpSignedDiv
+				case keywordSignedRem:
+					inst.Op = ArithmeticOpSignedRem
+				case keywordSignedShiftRight:
+					inst.Op = ArithmeticOpSignedShiftRight
+				case keywordAddWithCarry:
+					inst.Op = ArithmeticOpAddWithCarry
+				case keywordSubWithBorrow:
+					inst.Op = ArithmeticOpSubWithBorrow
+				case keywordFixedMul:
+					inst.Op = ArithmeticOpFixedMul
+				case keywordFixedDiv:
+					inst.Op = ArithmeticOpFixedDiv
+				case keywordFloatAdd, keywordFloatSub, keywordFloatMul, keywordFloatDiv, keywordFloatEqual, keywordFloatLessThan, keywordFloatLessThanOrEqualTo:
+					inst.Op = ArithmeticOpFloat
 				default:
 					return fmt.Errorf("Illegal arithmetic operation %s", t.Value)
 				}
 				// parse it like the other registers at this point
 				if sv1, err := this.resolveRegister(src1); err != nil {
 					return err
+				} else if inst.Op == ArithmeticOpFloat {
+					// the last byte selects the float operation so the
+					// destination doubles as the first source
+					if inst.Data[0] != inst.Data[1] {
+						return fmt.Errorf("The destination of %s is also its first source, both operands must be the same register", t.Value)
+					}
+					inst.Data[1], inst.Data[2] = sv1, floatOperationKeywords[t.Type]
 				} else {
 					inst.Data[2] = sv1
 				}
@@ -1325,3 +1465,307 @@ func (this *_parser) parseJump(first *node, rest []*node) error {
 		return this.installInstruction(d.Encode())
 	}
 }
+
+// Macro instructions are defined in the microcode segment with
+//
+//	.macro name
+//	  micro operations
+//	.endmacro
+//
+// and get the next free extended operation, group 5 op 0 first. Invoking
+// a macro looks like any other instruction: name [a [= b [, c]]] or
+// name a = immediate
+func (this *_parser) beginMacro(nodes []*node) error {
+	if this.currSegment != microcodeSegment {
+		return fmt.Errorf("Macros can only be defined in the microcode segment")
+	}
+	switch len(nodes) {
+	case 2:
+		if !nodes[1].Type.comment() {
+			return fmt.Errorf("Too many arguments provided to a macro directive")
+		}
+		fallthrough
+	case 1:
+		if nodes[0].Type != typeId {
+			return fmt.Errorf("The name of a macro must be a symbol that isn't a keyword or register")
+		}
+		name := nodes[0].Value.(string)
+		for this.nextMacro < ExtendedOperationCount && this.extendedOperationTaken(this.nextMacro) {
+			this.nextMacro++
+		}
+		if _, ok := this.macros[name]; ok {
+			return fmt.Errorf("Macro %s is already defined!", name)
+		} else if this.nextMacro >= ExtendedOperationCount {
+			return fmt.Errorf("Too many macros defined, only %d are available", ExtendedOperationCount)
+		}
+		// routines can't overlap the dispatch table
+		if this.addrs[microcodeSegment] < MicrocodeDispatchTableSize {
+			this.addrs[microcodeSegment] = MicrocodeDispatchTableSize
+		}
+		this.core.ucode[this.nextMacro] = this.addrs[microcodeSegment]
+		this.macros[name] = this.nextMacro
+		this.nextMacro++
+		this.currMacro = name
+		return nil
+	default:
+		return fmt.Errorf("The macro directive requires the name of the new instruction")
+	}
+}
+
+// extensions can take over whole groups or single operations
+func (this *_parser) extendedOperationTaken(slot Word) bool {
+	if this.reservedGroups[InstructionGroupExtendedBegin+slot/32] {
+		return true
+	}
+	for _, s := range this.macros {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+func (this *_parser) endMacro(nodes []*node) error {
+	if len(nodes) > 1 || (len(nodes) == 1 && !nodes[0].Type.comment()) {
+		return fmt.Errorf("The endmacro directive takes in no arguments!")
+	}
+	this.currMacro = ""
+	return this.installMicroWord(Word(NewMicroInstruction(MicroOpEnd, 0, 0, 0)))
+}
+func (this *_parser) installMicroWord(value Word) error {
+	this.core.ucode[this.addrs[microcodeSegment]] = value
+	this.addrs[microcodeSegment]++
+	return nil
+}
+
+var microOperands = map[string]byte{
+	"a":  MicroOperandA,
+	"b":  MicroOperandB,
+	"c":  MicroOperandC,
+	"i":  MicroOperandImmediate,
+	"t0": MicroOperandT0,
+	"t1": MicroOperandT1,
+	"t2": MicroOperandT2,
+	"t3": MicroOperandT3,
+}
+
+func (this *node) microOperand() (byte, error) {
+	if this.Type == typeId {
+		if v, ok := microOperands[this.Value.(string)]; ok {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("%v is not a micro operand, expected one of a, b, c, i, or t0 through t3", this.Value)
+}
+func (this *node) microDestination() (byte, error) {
+	if v, err := this.microOperand(); err != nil {
+		return 0, err
+	} else if v == MicroOperandImmediate {
+		return 0, fmt.Errorf("The immediate micro operand is read only")
+	} else {
+		return v, nil
+	}
+}
+
+var binaryMicroOps = map[nodeType]byte{
+	keywordAdd:        MicroOpAdd,
+	keywordSub:        MicroOpSub,
+	keywordMul:        MicroOpMul,
+	keywordDiv:        MicroOpDiv,
+	keywordRem:        MicroOpRem,
+	keywordShiftLeft:  MicroOpShiftLeft,
+	keywordShiftRight: MicroOpShiftRight,
+	keywordAnd:        MicroOpAnd,
+	keywordOr:         MicroOpOr,
+	keywordXor:        MicroOpXor,
+	keywordEqual:      MicroOpEq,
+	keywordLessThan:   MicroOpLessThan,
+}
+var unaryMicroOps = map[nodeType]byte{
+	keywordMove: MicroOpMove,
+	keywordNot:  MicroOpNot,
+	keywordLoad: MicroOpLoad,
+}
+
+// install the address of a microcode label into the next microcode word
+func (this *_parser) installMicroAddress(target *node) error {
+	if target.Type != typeId {
+		return fmt.Errorf("Expected a label but got %v", target.Value)
+	} else if v, err := this.resolveLabel(target.Value.(string)); err == nil {
+		return this.installMicroWord(v)
+	} else {
+		this.indirectAddresses = append(this.indirectAddresses, indirectAddress{label: target.Value.(string), seg: microcodeSegment, address: this.addrs[microcodeSegment]})
+		this.addrs[microcodeSegment]++
+		return nil
+	}
+}
+func (this *_parser) parseMicroOp(first *node, rest []*node) error {
+	if len(rest) > 0 && rest[len(rest)-1].Type.comment() {
+		rest = rest[:len(rest)-1]
+	}
+	if op, ok := binaryMicroOps[first.Type]; ok {
+		if len(rest) != 5 {
+			return fmt.Errorf("The micro operation %s requires a destination and two sources", first.Value)
+		} else if d, err := rest[0].microDestination(); err != nil {
+			return err
+		} else if rest[1].Type != typeEquals {
+			return fmt.Errorf("An = is necessary to separate the destination from the sources of a micro operation")
+		} else if s0, err := rest[2].microOperand(); err != nil {
+			return err
+		} else if rest[3].Type != typeComma {
+			return fmt.Errorf("The sources of a micro operation must be separated by a comma")
+		} else if s1, err := rest[4].microOperand(); err != nil {
+			return err
+		} else {
+			return this.installMicroWord(Word(NewMicroInstruction(op, d, s0, s1)))
+		}
+	} else if op, ok := unaryMicroOps[first.Type]; ok {
+		if len(rest) != 3 {
+			return fmt.Errorf("The micro operation %s requires a destination and a source", first.Value)
+		} else if d, err := rest[0].microDestination(); err != nil {
+			return err
+		} else if rest[1].Type != typeEquals {
+			return fmt.Errorf("An = is necessary to separate the destination from the source of a micro operation")
+		} else if s0, err := rest[2].microOperand(); err != nil {
+			return err
+		} else {
+			return this.installMicroWord(Word(NewMicroInstruction(op, d, s0, 0)))
+		}
+	}
+	switch first.Type {
+	case keywordStore:
+		if len(rest) != 3 {
+			return fmt.Errorf("The store micro operation requires an address and a value")
+		} else if addr, err := rest[0].microOperand(); err != nil {
+			return err
+		} else if rest[1].Type != typeEquals {
+			return fmt.Errorf("An = is necessary to separate the address from the value of a store micro operation")
+		} else if value, err := rest[2].microOperand(); err != nil {
+			return err
+		} else {
+			return this.installMicroWord(Word(NewMicroInstruction(MicroOpStore, 0, addr, value)))
+		}
+	case keywordPush:
+		if len(rest) != 1 {
+			return fmt.Errorf("The push micro operation requires a single source")
+		} else if s0, err := rest[0].microOperand(); err != nil {
+			return err
+		} else {
+			return this.installMicroWord(Word(NewMicroInstruction(MicroOpPush, 0, s0, 0)))
+		}
+	case keywordPop:
+		if len(rest) != 1 {
+			return fmt.Errorf("The pop micro operation requires a single destination")
+		} else if d, err := rest[0].microDestination(); err != nil {
+			return err
+		} else {
+			return this.installMicroWord(Word(NewMicroInstruction(MicroOpPop, d, 0, 0)))
+		}
+	case keywordSet:
+		if len(rest) != 3 {
+			return fmt.Errorf("The set micro operation requires a destination and an immediate or label")
+		} else if d, err := rest[0].microDestination(); err != nil {
+			return err
+		} else if rest[1].Type != typeEquals {
+			return fmt.Errorf("An = is necessary to separate the destination from the value of a set micro operation")
+		} else if err := this.installMicroWord(Word(NewMicroInstruction(MicroOpConstant, d, 0, 0))); err != nil {
+			return err
+		} else if rest[2].Type.immediate() {
+			return this.installMicroWord(rest[2].Value.(Word))
+		} else {
+			return this.installMicroAddress(rest[2])
+		}
+	case keywordBranch:
+		switch len(rest) {
+		case 1:
+			if err := this.installMicroWord(Word(NewMicroInstruction(MicroOpJump, 0, 0, 0))); err != nil {
+				return err
+			} else {
+				return this.installMicroAddress(rest[0])
+			}
+		case 3:
+			if rest[1].Type != keywordIf {
+				return fmt.Errorf("Expected an \"if\" statement following the target of a micro branch")
+			} else if s0, err := rest[2].microOperand(); err != nil {
+				return err
+			} else if err := this.installMicroWord(Word(NewMicroInstruction(MicroOpJumpIfNotZero, 0, s0, 0))); err != nil {
+				return err
+			} else {
+				return this.installMicroAddress(rest[0])
+			}
+		default:
+			return fmt.Errorf("A micro branch is either branch label or branch label if operand")
+		}
+	case keywordReturn:
+		if len(rest) != 0 {
+			return fmt.Errorf("The return micro operation takes in no arguments")
+		}
+		return this.installMicroWord(Word(NewMicroInstruction(MicroOpEnd, 0, 0, 0)))
+	default:
+		return fmt.Errorf("%s is not a micro operation", first.Value)
+	}
+}
+func (this *_parser) parseMacroInvocation(slot Word, rest []*node) error {
+	if this.currSegment != codeSegment {
+		return fmt.Errorf("Currently not in code segment, can't insert instruction")
+	}
+	var d DecodedInstruction
+	d.Group = byte(InstructionGroupExtendedBegin + slot/32)
+	d.Op = byte(slot % 32)
+	if len(rest) > 0 && rest[len(rest)-1].Type.comment() {
+		rest = rest[:len(rest)-1]
+	}
+	switch len(rest) {
+	case 0:
+	case 1:
+		if a, err := this.resolveRegister(rest[0]); err != nil {
+			return err
+		} else {
+			d.Data[0] = a
+		}
+	case 3:
+		if a, err := this.resolveRegister(rest[0]); err != nil {
+			return err
+		} else if rest[1].Type != typeEquals {
+			return fmt.Errorf("An = is necessary to separate the first operand of a macro from the rest")
+		} else {
+			d.Data[0] = a
+		}
+		switch src := rest[2]; {
+		case src.Type.registerOrAlias():
+			if b, err := this.resolveRegister(src); err != nil {
+				return err
+			} else {
+				d.Data[1] = b
+			}
+		case src.Type.immediate():
+			d.SetImmediate(src.Value.(Word))
+		case src.Type == typeId:
+			if v, err := this.resolveLabel(src.Value.(string)); err != nil {
+				// defer it for now
+				this.Defer(&d, src)
+				return nil
+			} else {
+				d.SetImmediate(v)
+			}
+		default:
+			return fmt.Errorf("The second operand of a macro must be a register, alias, immediate, or label")
+		}
+	case 5:
+		if a, err := this.resolveRegister(rest[0]); err != nil {
+			return err
+		} else if rest[1].Type != typeEquals {
+			return fmt.Errorf("An = is necessary to separate the first operand of a macro from the rest")
+		} else if b, err := this.resolveRegister(rest[2]); err != nil {
+			return err
+		} else if rest[3].Type != typeComma {
+			return fmt.Errorf("The last two operands of a macro must be separated by a comma")
+		} else if c, err := this.resolveRegister(rest[4]); err != nil {
+			return err
+		} else {
+			d.Data = [3]byte{a, b, c}
+		}
+	default:
+		return fmt.Errorf("Wrong number of operands provided to a macro")
+	}
+	return this.installInstruction(d.Encode())
+}
@@ -0,0 +1,57 @@
+package iris16
+
+import "testing"
+
+func Test_WordDirectiveLabels(t *testing.T) {
+	core, err := assemble(`
+	.code
+	set r6 = #1
+	target: set r7 = #2
+	.data
+	.org #x10
+	table: .word target
+	.word table
+	`)
+	if err != nil {
+		t.Fatalf("Couldn't assemble program: %s", err)
+	} else if value := core.DataMemory(0x10); value != 1 {
+		t.Errorf("Expected .word target to hold 1 but it holds %d", value)
+	} else if value := core.DataMemory(0x11); value != 0x10 {
+		t.Errorf("Expected .word table to hold 0x10 but it holds %#x", value)
+	}
+}
+
+func Test_SystemCallOperands(t *testing.T) {
+	core, err := assemble(`
+	.code
+	system #1, r8, r9
+	`)
+	if err != nil {
+		t.Fatalf("Couldn't assemble program: %s", err)
+	} else if inst, _ := core.CodeMemory(0).Decode(); inst.Data != [3]byte{1, 8, 9} {
+		t.Errorf("Expected the operands 1, 8 and 9 but got %v", inst.Data)
+	}
+	if _, err := assemble(".code\nsystem #1, r8, #9"); err == nil {
+		t.Errorf("A system call with an immediate third operand assembled")
+	}
+}
+
+func Test_PeekAndDoubleAssemble(t *testing.T) {
+	core, err := assemble(`
+	.code
+	set r6 = #21
+	push r6
+	peek r7
+	double r8 = r7
+	system #0, r0, r0
+	`)
+	if err != nil {
+		t.Fatalf("Couldn't assemble program: %s", err)
+	} else if err := core.Run(); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	} else if r7 := core.Register(7); r7 != 21 {
+		t.Errorf("peek should leave 21 in r7 but got %d", r7)
+	} else if r8 := core.Register(8); r8 != 42 {
+		t.Errorf("double should leave 42 in r8 but got %d", r8)
+	}
+}
@@ -0,0 +1,143 @@
+// io devices used by the cores of an smp machine to talk to each other
+package smp
+
+import (
+	"fmt"
+	"github.com/DrItanium/cores/iris16"
+	"sync"
+)
+
+const (
+	// io addresses of the mailbox registers, every core has its own view
+	MailboxTarget  = iota // the core that sends go to, reads back the current target
+	MailboxSend           // write a word into the target's mailbox, reads 1 when there is room
+	MailboxCount          // number of words waiting in this core's mailbox
+	MailboxReceive        // read the oldest word waiting in this core's mailbox
+	mailboxRegisterCount
+)
+
+const (
+	MailboxDepth = 16
+	// reading a semaphore tries to take it (1 on success, 0 when it isn't
+	// available) while writing a value gives that many units back
+	SemaphoreBase  = 0x0010
+	SemaphoreCount = 16
+)
+
+type mailboxes struct {
+	lock   sync.Mutex
+	queues [][]iris16.Word
+}
+
+func newMailboxes(count int) *mailboxes {
+	return &mailboxes{queues: make([][]iris16.Word, count)}
+}
+
+type mailboxPort struct {
+	id, target iris16.Word
+	boxes      *mailboxes
+}
+
+func (this *mailboxPort) Begin() iris16.Word {
+	return MailboxTarget
+}
+func (this *mailboxPort) End() iris16.Word {
+	return mailboxRegisterCount - 1
+}
+func (this *mailboxPort) RespondsTo(address iris16.Word) bool {
+	return address >= this.Begin() && address <= this.End()
+}
+func (this *mailboxPort) Startup() error {
+	return nil
+}
+func (this *mailboxPort) Shutdown() error {
+	return nil
+}
+func (this *mailboxPort) Load(address iris16.Word) (iris16.Word, error) {
+	this.boxes.lock.Lock()
+	defer this.boxes.lock.Unlock()
+	switch address {
+	case MailboxTarget:
+		return this.target, nil
+	case MailboxSend:
+		if len(this.boxes.queues[this.target]) < MailboxDepth {
+			return 1, nil
+		} else {
+			return 0, nil
+		}
+	case MailboxCount:
+		return iris16.Word(len(this.boxes.queues[this.id])), nil
+	case MailboxReceive:
+		queue := this.boxes.queues[this.id]
+		if len(queue) == 0 {
+			return 0, fmt.Errorf("Core %d attempted to receive from an empty mailbox", this.id)
+		} else {
+			this.boxes.queues[this.id] = queue[1:]
+			return queue[0], nil
+		}
+	default:
+		return 0, fmt.Errorf("Address %x is not a mailbox register", address)
+	}
+}
+func (this *mailboxPort) Store(address, value iris16.Word) error {
+	this.boxes.lock.Lock()
+	defer this.boxes.lock.Unlock()
+	switch address {
+	case MailboxTarget:
+		if int(value) >= len(this.boxes.queues) {
+			return fmt.Errorf("Core %d attempted to target non existent core %d", this.id, value)
+		} else {
+			this.target = value
+			return nil
+		}
+	case MailboxSend:
+		if queue := this.boxes.queues[this.target]; len(queue) >= MailboxDepth {
+			return fmt.Errorf("Core %d attempted to send to the full mailbox of core %d", this.id, this.target)
+		} else {
+			this.boxes.queues[this.target] = append(queue, value)
+			return nil
+		}
+	case MailboxCount, MailboxReceive:
+		return fmt.Errorf("Mailbox register %x is read only", address)
+	default:
+		return fmt.Errorf("Address %x is not a mailbox register", address)
+	}
+}
+
+// one bank of semaphores is shared by all cores
+type semaphores struct {
+	lock   sync.Mutex
+	counts [SemaphoreCount]iris16.Word
+}
+
+func (this *semaphores) Begin() iris16.Word {
+	return SemaphoreBase
+}
+func (this *semaphores) End() iris16.Word {
+	return SemaphoreBase + SemaphoreCount - 1
+}
+func (this *semaphores) RespondsTo(address iris16.Word) bool {
+	return address >= this.Begin() && address <= this.End()
+}
+func (this *semaphores) Startup() error {
+	return nil
+}
+func (this *semaphores) Shutdown() error {
+	return nil
+}
+func (this *semaphores) Load(address iris16.Word) (iris16.Word, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if count := &this.counts[address-SemaphoreBase]; *count == 0 {
+		return 0, nil
+	} else {
+		*count--
+		return 1, nil
+	}
+}
+func (this *semaphores) Store(address, value iris16.Word) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.counts[address-SemaphoreBase] += value
+	return nil
+}
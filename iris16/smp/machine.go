@@ -0,0 +1,247 @@
+// symmetric multiprocessing iris16, several iris16 cores sharing a data segment
+package smp
+
+import (
+	"fmt"
+	"github.com/DrItanium/cores/iris16"
+	"github.com/DrItanium/cores/registration/machine"
+	"github.com/DrItanium/cores/registration/parser"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+func RegistrationName() string {
+	return "iris16-smp"
+}
+
+// the free running flavor of the machine
+func FreeRunningRegistrationName() string {
+	return "iris16-smp-free"
+}
+
+func generateCore(a ...interface{}) (machine.Machine, error) {
+	return New(DefaultCoreCount, ScheduleRoundRobin)
+}
+func generateFreeRunningCore(a ...interface{}) (machine.Machine, error) {
+	return New(DefaultCoreCount, ScheduleFreeRunning)
+}
+
+// programs are normal iris16 programs so just use the iris16 assembler
+func generateParser(a ...interface{}) (parser.Parser, error) {
+	return parser.New(iris16.RegistrationName(), a...)
+}
+
+func init() {
+	machine.Register(RegistrationName(), machine.Registrar(generateCore))
+	machine.Register(FreeRunningRegistrationName(), machine.Registrar(generateFreeRunningCore))
+	parser.Register(RegistrationName(), parser.Registrar(generateParser))
+	parser.Register(FreeRunningRegistrationName(), parser.Registrar(generateParser))
+}
+
+const (
+	DefaultCoreCount = 4
+	MaxCoreCount     = 256
+)
+
+type Schedule int
+
+const (
+	// every core executes one instruction in turn, the same program will
+	// always interleave the same way
+	ScheduleRoundRobin Schedule = iota
+	// every core runs in its own goroutine
+	ScheduleFreeRunning
+)
+
+const (
+	// System calls on top of the ones every iris16 core has, both write to
+	// the first register argument. Programs encode these numbers so they
+	// are fixed instead of following the built in system calls.
+	SystemCallCoreId    = 3
+	SystemCallCoreCount = 4
+)
+
+type Machine struct {
+	cores      []*iris16.Core
+	schedule   Schedule
+	mailboxes  *mailboxes
+	semaphores *semaphores
+}
+
+// Build a machine out of count cores, every core gets its own registers, code,
+// microcode, stack and call segments while the data segment is shared
+func New(count int, schedule Schedule) (*Machine, error) {
+	if count < 1 || count > MaxCoreCount {
+		return nil, fmt.Errorf("An iris16 smp machine must have between 1 and %d cores, %d requested", MaxCoreCount, count)
+	}
+	var m Machine
+	m.schedule = schedule
+	m.mailboxes = newMailboxes(count)
+	m.semaphores = &semaphores{}
+	for i := 0; i < count; i++ {
+		id := iris16.Word(i)
+		core, err := iris16.New()
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			core.ShareDataSegment(m.cores[0])
+		}
+		core.InstallSystemCall(SystemCallCoreId, func(c *iris16.Core, inst *iris16.DecodedInstruction) error {
+			return c.SetRegister(inst.Data[1], id)
+		})
+		core.InstallSystemCall(SystemCallCoreCount, func(c *iris16.Core, inst *iris16.DecodedInstruction) error {
+			return c.SetRegister(inst.Data[1], iris16.Word(count))
+		})
+		if err := core.RegisterIoDevice(&mailboxPort{id: id, boxes: m.mailboxes}); err != nil {
+			return nil, err
+		} else if err := core.RegisterIoDevice(m.semaphores); err != nil {
+			return nil, err
+		}
+		m.cores = append(m.cores, core)
+	}
+	return &m, nil
+}
+
+func (this *Machine) Cores() []*iris16.Core {
+	return this.cores
+}
+
+// Every core gets the same memory image, the data segment is written once per
+// core but since it is shared the result is the same
+func (this *Machine) InstallProgram(input <-chan byte) error {
+	if err := this.cores[0].InstallProgram(input); err != nil {
+		return err
+	}
+	for _, core := range this.cores[1:] {
+		pipe := make(chan byte, 1024)
+		go func(pipe chan<- byte) {
+			this.cores[0].Dump(pipe)
+			close(pipe)
+		}(pipe)
+		err := core.InstallProgram(pipe)
+		// drain anything left over so the dumping goroutine can finish
+		for _ = range pipe {
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dumps the memory image as seen by the first core
+func (this *Machine) Dump(output chan<- byte) error {
+	return this.cores[0].Dump(output)
+}
+
+type lockedWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (this *lockedWriter) Write(p []byte) (int, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.w.Write(p)
+}
+
+// All cores share the same console
+func (this *Machine) SetOutput(w io.Writer) {
+	out := &lockedWriter{w: w}
+	for _, core := range this.cores {
+		core.SetOutput(out)
+	}
+}
+
+func (this *Machine) Startup() error {
+	for _, core := range this.cores {
+		if err := core.Startup(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (this *Machine) Shutdown() error {
+	for _, core := range this.cores {
+		if err := core.Shutdown(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *Machine) GetDebugStatus() bool {
+	return false
+}
+
+func (this *Machine) SetDebug(_ bool) {
+
+}
+
+func step(id int, core *iris16.Core) error {
+	if err := core.ExecuteCurrentInstruction(); err != nil {
+		return fmt.Errorf("ERROR during execution on core %d: %s\n", id, err)
+	} else if err := core.AdvanceProgramCounter(); err != nil {
+		return fmt.Errorf("ERROR during the advancement of the program counter on core %d: %s", id, err)
+	} else {
+		return nil
+	}
+}
+
+// The machine stops once every core has terminated or as soon as one of them
+// errors out
+func (this *Machine) Run() error {
+	switch this.schedule {
+	case ScheduleRoundRobin:
+		return this.runRoundRobin()
+	case ScheduleFreeRunning:
+		return this.runFreeRunning()
+	default:
+		return fmt.Errorf("Unknown schedule %d", this.schedule)
+	}
+}
+
+func (this *Machine) runRoundRobin() error {
+	for running := true; running; {
+		running = false
+		for id, core := range this.cores {
+			if core.TerminateExecution() {
+				continue
+			}
+			running = true
+			if err := step(id, core); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (this *Machine) runFreeRunning() error {
+	var wg sync.WaitGroup
+	var stop int32
+	errs := make([]error, len(this.cores))
+	for id, core := range this.cores {
+		wg.Add(1)
+		go func(id int, core *iris16.Core) {
+			defer wg.Done()
+			for !core.TerminateExecution() && atomic.LoadInt32(&stop) == 0 {
+				if err := step(id, core); err != nil {
+					errs[id] = err
+					// the other cores could be waiting on this one forever
+					atomic.StoreInt32(&stop, 1)
+					return
+				}
+			}
+		}(id, core)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package smp
+
+import (
+	"bytes"
+	"github.com/DrItanium/cores/registration/parser"
+	"strings"
+	"testing"
+)
+
+func machineWithProgram(t *testing.T, schedule Schedule, source string) *Machine {
+	asm, err := parser.New(RegistrationName())
+	if err != nil {
+		t.Fatalf("Couldn't create parser: %s", err)
+	}
+	lines := make(chan parser.Entry)
+	go func() {
+		for i, line := range strings.Split(source, "\n") {
+			if line = strings.TrimSpace(line); len(line) > 0 {
+				lines <- parser.Entry{Line: line, Index: i + 1}
+			}
+		}
+		close(lines)
+	}()
+	if err := asm.Parse(lines); err != nil {
+		t.Fatalf("Couldn't parse program: %s", err)
+	} else if err := asm.Process(); err != nil {
+		t.Fatalf("Couldn't process program: %s", err)
+	}
+	m, err := New(DefaultCoreCount, schedule)
+	if err != nil {
+		t.Fatalf("Couldn't create machine: %s", err)
+	}
+	image := make(chan byte, 1024)
+	go func() {
+		asm.Dump(image)
+		close(image)
+	}()
+	if err := m.InstallProgram(image); err != nil {
+		t.Fatalf("Couldn't install program: %s", err)
+	}
+	return m
+}
+
+func Test_RoundRobinIsDeterministic(t *testing.T) {
+	// every core prints its id twice, round robin scheduling interleaves them
+	// one instruction at a time
+	const program = `
+	.code
+	system #3, r6, r6
+	add r6 = r6, #48
+	system #2, r6, r6
+	system #2, r6, r6
+	system #0, r0, r0
+	`
+	for i := 0; i < 3; i++ {
+		var out bytes.Buffer
+		m := machineWithProgram(t, ScheduleRoundRobin, program)
+		m.SetOutput(&out)
+		if err := m.Run(); err != nil {
+			t.Fatalf("Run failed: %s", err)
+		} else if out.String() != "01230123" {
+			t.Fatalf("Expected 01230123 but got %q", out.String())
+		}
+	}
+}
+
+func Test_Mailboxes(t *testing.T) {
+	// every core other than 0 sends ten times its id to core 0 which adds them
+	// up into data[0]
+	m := machineWithProgram(t, ScheduleRoundRobin, `
+	.code
+	system #3, r6, r6
+	set r7 = #1
+	set r8 = #2
+	set r9 = #3
+	eq r10 = r6, r0
+	branch collect if r10
+	mul r11 = r6, #10
+	store r0 = r0, io
+	store r7 = r11, io
+	system #0, r0, r0
+	collect: system #4, r12, r12
+	decr r12 = r12
+	wait: load r13 = r8, io
+	ne r10 = r13, r12
+	branch wait if r10
+	loop: load r14 = r9, io
+	add r15 = r15, r14
+	decr r12 = r12
+	ne r10 = r12, r0
+	branch loop if r10
+	store r0 = r15
+	system #0, r0, r0
+	`)
+	if err := m.Run(); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	} else if sum := m.Cores()[0].DataMemory(0); sum != 60 {
+		t.Fatalf("Expected 60 but got %d", sum)
+	}
+}
+
+func Test_FreeRunningSemaphore(t *testing.T) {
+	// every core increments a shared counter a hundred times while holding
+	// semaphore 0, core 0 makes the semaphore available to begin with
+	m := machineWithProgram(t, ScheduleFreeRunning, `
+	.code
+	system #3, r6, r6
+	set r7 = #16
+	ne r8 = r6, r0
+	branch start if r8
+	store r7 = r1, io
+	start: set r9 = #100
+	acquire: load r10 = r7, io
+	eq r11 = r10, r0
+	branch acquire if r11
+	load r12 = r0
+	incr r12 = r12
+	store r0 = r12
+	store r7 = r1, io
+	decr r9 = r9
+	ne r11 = r9, r0
+	branch acquire if r11
+	system #0, r0, r0
+	`)
+	if err := m.Run(); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	} else if count := m.Cores()[0].DataMemory(0); count != 100*DefaultCoreCount {
+		t.Fatalf("Expected %d but got %d", 100*DefaultCoreCount, count)
+	}
+}
+
+func Test_ErrorsStopEveryCore(t *testing.T) {
+	// core 0 panics while the rest spin forever
+	for _, schedule := range []Schedule{ScheduleRoundRobin, ScheduleFreeRunning} {
+		m := machineWithProgram(t, schedule, `
+		.code
+		system #3, r6, r6
+		ne r7 = r6, r0
+		spin: branch spin if r7
+		system #1, r0, r0
+		`)
+		if err := m.Run(); err == nil {
+			t.Fatalf("Expected the panic on core 0 to stop the machine")
+		}
+	}
+}
@@ -2,6 +2,8 @@ package iris16
 
 import (
 	"fmt"
+	"io"
+	"os"
 )
 
 const (
@@ -9,7 +11,21 @@ const (
 	SystemCallTerminate = iota
 	SystemCallPanic
 	SystemCallPutc
-	NumberOfSystemCalls
+)
+const (
+	// 3 and 4 belong to the smp machine, programs encode these numbers so
+	// they never move
+	SystemCallGetStatus = 5
+	SystemCallSetStatus = 6
+	// extensions can only install system calls at this offset and above
+	NumberOfSystemCalls = 7
+)
+
+const (
+	// Status register bits, only add with carry (adc) and sub with borrow
+	// (sbb) set them. Plain add and sub leave the status register alone.
+	StatusCarry = 1 << iota
+	StatusOverflow
 )
 
 func init() {
@@ -32,9 +48,33 @@ func putcSystemCall(core *Core, inst *DecodedInstruction) error {
 		// make a rune out of it
 		r = rune((0x0000FFFF & uint32(core.Register(lower))) | (0xFFFF0000 & (uint32(core.Register(upper)) << 16)))
 	}
-	fmt.Printf("%c", r)
+	fmt.Fprintf(core.Output(), "%c", r)
 	return nil
 }
+
+// Set where the putc system call writes to, defaults to stdout
+func (this *Core) SetOutput(w io.Writer) {
+	this.output = w
+}
+func (this *Core) Output() io.Writer {
+	if this.output == nil {
+		return os.Stdout
+	} else {
+		return this.output
+	}
+}
+
+// Set where system calls which read input take it from, defaults to stdin
+func (this *Core) SetInput(r io.Reader) {
+	this.input = r
+}
+func (this *Core) Input() io.Reader {
+	if this.input == nil {
+		return os.Stdin
+	} else {
+		return this.input
+	}
+}
 func terminateSystemCall(core *Core, inst *DecodedInstruction) error {
 	core.terminateExecution = true
 	return nil
@@ -44,3 +84,15 @@ func panicSystemCall(core *Core, inst *DecodedInstruction) error {
 	// look at the data attached to the panic and encode it
 	return NewError(ErrorPanic, uint(inst.Immediate()))
 }
+
+// system #5, rA, rA copies the status register into rA
+func getStatusSystemCall(core *Core, inst *DecodedInstruction) error {
+	return core.SetRegister(inst.Data[1], core.Status())
+}
+
+// system #6, rA, rA replaces the status register with rA, clearing the carry
+// before the first add with carry of a multi-word sum
+func setStatusSystemCall(core *Core, inst *DecodedInstruction) error {
+	core.SetStatus(core.Register(inst.Data[1]))
+	return nil
+}
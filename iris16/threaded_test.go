@@ -0,0 +1,183 @@
+package iris16
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var differentialRegisters = []byte{FalseRegister, TrueRegister, StackPointer, PredicateRegister, CallPointer, 6, 7, 8, 9, 10}
+
+func randomSource(r *rand.Rand) byte {
+	if r.Intn(16) == 0 {
+		return InstructionPointer
+	} else {
+		return differentialRegisters[r.Intn(len(differentialRegisters))]
+	}
+}
+func randomDestination(r *rand.Rand) byte {
+	// mostly writable registers, writes to r0 and r1 are errors which both engines must agree on
+	if r.Intn(32) == 0 {
+		return differentialRegisters[r.Intn(2)]
+	} else {
+		return differentialRegisters[2+r.Intn(len(differentialRegisters)-2)]
+	}
+}
+
+// generate a program made up of arithmetic, compare and forward jumps which always terminates
+func randomProgram(r *rand.Rand, length int) []*DecodedInstruction {
+	var prog []*DecodedInstruction
+	for i := 0; i < length; i++ {
+		var di *DecodedInstruction
+		switch r.Intn(12) {
+		case 0, 1, 2, 3:
+			di, _ = NewDecodedInstruction(InstructionGroupArithmetic, byte(r.Intn(ArithmeticOpCount)), randomDestination(r), randomSource(r), randomSource(r))
+		case 4:
+			di, _ = NewDecodedInstructionImmediate(InstructionGroupMove, MoveOpSet, randomDestination(r), Word(r.Intn(65536)))
+		case 5:
+			di, _ = NewDecodedInstruction(InstructionGroupMove, MoveOpMove, randomDestination(r), randomSource(r), 0)
+		case 6, 7:
+			di, _ = NewDecodedInstruction(InstructionGroupCompare, byte(r.Intn(CompareOpCount)), randomDestination(r), randomSource(r), randomSource(r))
+		case 8, 9:
+			// memory traffic through every word segment
+			op := []byte{MoveOpLoad, MoveOpStore, MoveOpPush, MoveOpPop, MoveOpPeek, MoveOpSwap}[r.Intn(6)]
+			di, _ = NewDecodedInstruction(InstructionGroupMove, op, randomDestination(r), randomSource(r), byte(dataSegment)+byte(r.Intn(int(callSegment))))
+			if op == MoveOpSwap {
+				// swap writes both of its registers
+				di.Data[1] = randomDestination(r)
+			}
+		default:
+			var bb branchBits
+			bb.setImmediateForm(true)
+			bb.setConditionalForm(r.Intn(2) == 0)
+			target := Word(i + 1 + r.Intn(length-i))
+			di, _ = NewDecodedInstructionImmediate(InstructionGroupJump, byte(bb), randomDestination(r), target)
+			if !bb.conditionalForm() {
+				di.Data[0] = 0
+				di.SetImmediate(target)
+			}
+		}
+		prog = append(prog, di)
+	}
+	term, _ := NewDecodedInstruction(InstructionGroupMisc, MiscOpSystemCall, SystemCallTerminate, 0, 0)
+	return append(prog, term)
+}
+
+func coreWithProgram(t testing.TB, engine string, prog []*DecodedInstruction) *Core {
+	core, err := New()
+	if err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	} else if err := core.SetEngine(engine); err != nil {
+		t.Fatalf("Couldn't select engine %s: %s", engine, err)
+	}
+	for i, di := range prog {
+		core.SetCodeMemory(Word(i), *di.Encode())
+	}
+	return core
+}
+
+func compareCores(t *testing.T, seed int64, interp, threaded *Core, ierr, terr error) {
+	if (ierr == nil) != (terr == nil) {
+		t.Fatalf("seed %d: interpreter error %v but threaded error %v", seed, ierr, terr)
+	}
+	for i := 0; i < RegisterCount; i++ {
+		if a, b := interp.Register(byte(i)), threaded.Register(byte(i)); a != b {
+			t.Fatalf("seed %d: r%d is %d in the interpreter but %d in the threaded engine", seed, i, a, b)
+		}
+	}
+	if interp.TerminateExecution() != threaded.TerminateExecution() {
+		t.Fatalf("seed %d: engines disagree on termination", seed)
+	} else if a, b := interp.Status(), threaded.Status(); a != b {
+		t.Fatalf("seed %d: status is %x in the interpreter but %x in the threaded engine", seed, a, b)
+	}
+	for _, seg := range []segment{dataSegment, microcodeSegment, stackSegment, callSegment} {
+		imem, tmem := interp.wordSegment(seg), threaded.wordSegment(seg)
+		for i := range imem {
+			if imem[i] != tmem[i] {
+				t.Fatalf("seed %d: %s address %x is %x in the interpreter but %x in the threaded engine", seed, seg, i, imem[i], tmem[i])
+			}
+		}
+	}
+}
+
+func Test_ThreadedDifferential(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		prog := randomProgram(rand.New(rand.NewSource(seed)), 64)
+		interp := coreWithProgram(t, EngineInterpreter, prog)
+		threaded := coreWithProgram(t, EngineThreaded, prog)
+		ierr, terr := interp.Run(), threaded.Run()
+		compareCores(t, seed, interp, threaded, ierr, terr)
+	}
+}
+
+// r6 = 1000; loop: r7 = r7 + r6; r8 = r8 ^ r7; r6 = r6 - 1; r9 = r6 > r0; branch loop if r9; terminate
+func countdownProgram(count Word) []*DecodedInstruction {
+	set, _ := NewDecodedInstructionImmediate(InstructionGroupMove, MoveOpSet, 6, count)
+	add, _ := NewDecodedInstructionArithmetic(ArithmeticOpAdd, 7, 7, 6)
+	xor, _ := NewDecodedInstructionArithmetic(ArithmeticOpBinaryXor, 8, 8, 7)
+	decr, _ := NewDecodedInstructionArithmetic(ArithmeticOpDecrement, 6, 6, 0)
+	gt, _ := NewDecodedInstruction(InstructionGroupCompare, CompareOpGreaterThan, 9, 6, FalseRegister)
+	var bb branchBits
+	bb.setImmediateForm(true)
+	bb.setConditionalForm(true)
+	jmp, _ := NewDecodedInstructionImmediate(InstructionGroupJump, byte(bb), 9, 1)
+	term, _ := NewDecodedInstruction(InstructionGroupMisc, MiscOpSystemCall, SystemCallTerminate, 0, 0)
+	return []*DecodedInstruction{set, add, xor, decr, gt, jmp, term}
+}
+
+func Test_ThreadedLoopAndCodeWrites(t *testing.T) {
+	prog := countdownProgram(1000)
+	interp := coreWithProgram(t, EngineInterpreter, prog)
+	threaded := coreWithProgram(t, EngineThreaded, prog)
+	ierr, terr := interp.Run(), threaded.Run()
+	compareCores(t, 0, interp, threaded, ierr, terr)
+	// rewrite the xor into an or and run both again
+	or, _ := NewDecodedInstructionArithmetic(ArithmeticOpBinaryOr, 8, 8, 7)
+	for _, core := range []*Core{interp, threaded} {
+		core.SetCodeMemory(2, *or.Encode())
+		core.SetRegister(InstructionPointer, 0)
+		core.ResumeExecution()
+	}
+	ierr, terr = interp.Run(), threaded.Run()
+	compareCores(t, 1, interp, threaded, ierr, terr)
+}
+
+// The threaded engine is about six times as fast as the interpreter on this
+// loop, see the comment at the top of threaded.go
+func benchmarkEngine(b *testing.B, engine string) {
+	core := coreWithProgram(b, engine, countdownProgram(10000))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		core.SetRegister(InstructionPointer, 0)
+		core.ResumeExecution()
+		if err := core.Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEngineInterpreter(b *testing.B) {
+	benchmarkEngine(b, EngineInterpreter)
+}
+func BenchmarkEngineThreaded(b *testing.B) {
+	benchmarkEngine(b, EngineThreaded)
+}
+
+func Test_ThreadedWatchpointInstructionPointer(t *testing.T) {
+	addr, _ := NewDecodedInstructionImmediate(InstructionGroupMove, MoveOpSet, 6, 0x20)
+	value, _ := NewDecodedInstructionImmediate(InstructionGroupMove, MoveOpSet, 7, 9)
+	store, _ := NewDecodedInstruction(InstructionGroupMove, MoveOpStore, 6, 7, byte(dataSegment))
+	term, _ := NewDecodedInstruction(InstructionGroupMisc, MiscOpSystemCall, SystemCallTerminate, 0, 0)
+	for _, engine := range []string{EngineInterpreter, EngineThreaded} {
+		core := coreWithProgram(t, engine, []*DecodedInstruction{addr, value, store, term})
+		wp, _ := NewWatchpoint("data", 0x20, 0x20, WatchWrite)
+		wp.Break = true
+		core.AddWatchpoint(wp)
+		if err := core.Run(); err != nil {
+			t.Fatalf("%s: %s", engine, err)
+		} else if hit := core.LastWatchpointHit(); hit == nil {
+			t.Errorf("%s: the watchpoint never went off", engine)
+		} else if hit.InstructionPointer != 2 {
+			t.Errorf("%s: the store is at 2 but the hit reports %x", engine, hit.InstructionPointer)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// memory watchpoints for the iris16 core
+package iris16
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type WatchAccess byte
+
+const (
+	WatchRead WatchAccess = 1 << iota
+	WatchWrite
+	WatchAccessAny = WatchRead | WatchWrite
+)
+
+func (this WatchAccess) String() string {
+	switch this {
+	case WatchRead:
+		return "read"
+	case WatchWrite:
+		return "write"
+	case WatchAccessAny:
+		return "access"
+	default:
+		return fmt.Sprintf("WatchAccess(%d)", byte(this))
+	}
+}
+
+type WatchCondition int
+
+const (
+	// trigger on every matching access
+	WatchAlways WatchCondition = iota
+	// trigger when the value read or written is equal to Watchpoint.Value
+	WatchValueEquals
+	// trigger when the value at the address is different from the last time it was seen
+	WatchValueChanges
+)
+
+type Watchpoint struct {
+	seg       segment
+	Begin     Word
+	End       Word
+	Access    WatchAccess
+	Condition WatchCondition
+	Value     Word
+	// halt the core when triggered instead of just logging the access
+	Break bool
+	// number of times this watchpoint has been triggered
+	Hits int
+	last map[Word]Word
+}
+
+// Construct a new watchpoint which covers the inclusive range [begin, end] of
+// the named segment. The segment names are the same as the ones the assembler
+// accepts: data, microcode, stack, procedure and io.
+func NewWatchpoint(segmentName string, begin, end Word, access WatchAccess) (*Watchpoint, error) {
+	if seg, err := translateSegment(segmentName); err != nil {
+		return nil, err
+	} else if seg == codeSegment {
+		return nil, fmt.Errorf("Can't watch the code segment!")
+	} else if begin > end {
+		return nil, fmt.Errorf("Watchpoint start address %x is larger than end address %x", begin, end)
+	} else if access&WatchAccessAny == 0 {
+		return nil, fmt.Errorf("Watchpoint must watch reads, writes, or both")
+	} else {
+		return &Watchpoint{seg: seg, Begin: begin, End: end, Access: access, last: make(map[Word]Word)}, nil
+	}
+}
+
+func (this *Watchpoint) Segment() string {
+	return this.seg.String()
+}
+
+func (this *Watchpoint) covers(seg segment, access WatchAccess, address Word) bool {
+	return this.seg == seg && (this.Access&access) != 0 && this.Begin <= address && address <= this.End
+}
+
+func (this *Watchpoint) matches(access WatchAccess, address, old, value Word) bool {
+	switch this.Condition {
+	case WatchValueEquals:
+		return value == this.Value
+	case WatchValueChanges:
+		if access == WatchWrite {
+			return old != value
+		} else if prev, ok := this.last[address]; ok {
+			return prev != value
+		} else {
+			return false
+		}
+	default:
+		return true
+	}
+}
+
+func (this *Watchpoint) String() string {
+	return fmt.Sprintf("%s watchpoint on %s [%x, %x]", this.Access, this.seg, this.Begin, this.End)
+}
+
+type WatchpointHit struct {
+	Watchpoint         *Watchpoint
+	Access             WatchAccess
+	Address            Word
+	OldValue, Value    Word
+	InstructionPointer Word
+}
+
+func (this *WatchpointHit) String() string {
+	if this.Access == WatchWrite {
+		return fmt.Sprintf("%s: ip %x wrote %x (was %x) to %s address %x", this.Watchpoint, this.InstructionPointer, this.Value, this.OldValue, this.Watchpoint.seg, this.Address)
+	} else {
+		return fmt.Sprintf("%s: ip %x read %x from %s address %x", this.Watchpoint, this.InstructionPointer, this.Value, this.Watchpoint.seg, this.Address)
+	}
+}
+
+// Install a watchpoint into the core, the returned id can be used to remove it later
+func (this *Core) AddWatchpoint(wp *Watchpoint) int {
+	this.watchpoints = append(this.watchpoints, wp)
+	this.trackIo()
+	return len(this.watchpoints) - 1
+}
+func (this *Core) RemoveWatchpoint(id int) error {
+	if id < 0 || id >= len(this.watchpoints) || this.watchpoints[id] == nil {
+		return fmt.Errorf("Watchpoint %d does not exist!", id)
+	} else {
+		// keep the ids of the other watchpoints stable
+		this.watchpoints[id] = nil
+		this.trackIo()
+		return nil
+	}
+}
+func (this *Core) Watchpoints() []*Watchpoint {
+	var wps []*Watchpoint
+	for _, wp := range this.watchpoints {
+		if wp != nil {
+			wps = append(wps, wp)
+		}
+	}
+	return wps
+}
+func (this *Core) ClearWatchpoints() {
+	this.watchpoints = nil
+	this.ioValues = nil
+}
+
+// The most recent watchpoint hit that caused the core to halt execution
+func (this *Core) LastWatchpointHit() *WatchpointHit {
+	return this.lastWatchpointHit
+}
+
+// Set where non breaking watchpoint hits are logged to, defaults to stderr
+func (this *Core) SetWatchpointLog(w io.Writer) {
+	this.watchLog = w
+}
+
+func (this *Core) observeMemory(seg segment, access WatchAccess, address, old, value Word) {
+	for _, wp := range this.watchpoints {
+		if wp == nil || !wp.covers(seg, access, address) {
+			continue
+		}
+		if wp.matches(access, address, old, value) {
+			wp.Hits++
+			hit := &WatchpointHit{
+				Watchpoint:         wp,
+				Access:             access,
+				Address:            address,
+				OldValue:           old,
+				Value:              value,
+				InstructionPointer: this.instructionPointer,
+			}
+			if wp.Break {
+				this.lastWatchpointHit = hit
+				this.HaltExecution()
+			} else {
+				out := this.watchLog
+				if out == nil {
+					out = os.Stderr
+				}
+				fmt.Fprintln(out, hit)
+			}
+		}
+		if wp.Condition == WatchValueChanges {
+			wp.last[address] = value
+		}
+	}
+}
+
+func (this *Core) wordSegment(seg segment) *[MemorySize]Word {
+	switch seg {
+	case dataSegment:
+		return this.data
+	case microcodeSegment:
+		return &this.ucode
+	case stackSegment:
+		return &this.stack
+	case callSegment:
+		return &this.call
+	default:
+		panic(fmt.Sprintf("Programmer Failure! Segment %s is not backed by word memory!", seg))
+	}
+}
+
+// All word sized memory accesses made by the core go through these two
+// functions so that watchpoints see every access
+func (this *Core) loadSegmentWord(seg segment, address Word) Word {
+	value := this.wordSegment(seg)[address]
+	if len(this.watchpoints) > 0 {
+		this.observeMemory(seg, WatchRead, address, value, value)
+	}
+	return value
+}
+func (this *Core) storeSegmentWord(seg segment, address, value Word) {
+	mem := this.wordSegment(seg)
+	old := mem[address]
+	mem[address] = value
+	if len(this.watchpoints) > 0 {
+		this.observeMemory(seg, WatchWrite, address, old, value)
+	}
+}
+
+// Devices have no memory behind them so the previous value of an io address
+// is the last value read from or written to it since an io watchpoint was
+// installed, zero before that. Nothing is tracked while no io watchpoint is
+// installed.
+func (this *Core) trackIo() {
+	for _, wp := range this.watchpoints {
+		if wp != nil && wp.seg == ioSegment {
+			if this.ioValues == nil {
+				this.ioValues = make(map[Word]Word)
+			}
+			return
+		}
+	}
+	this.ioValues = nil
+}
+func (this *Core) observeIo(access WatchAccess, address, value Word) {
+	if this.ioValues == nil {
+		return
+	}
+	old := this.ioValues[address]
+	this.ioValues[address] = value
+	this.observeMemory(ioSegment, access, address, old, value)
+}
+
+func (this segment) String() string {
+	switch this {
+	case codeSegment:
+		return "code"
+	case dataSegment:
+		return "data"
+	case microcodeSegment:
+		return "microcode"
+	case stackSegment:
+		return "stack"
+	case callSegment:
+		return "procedure"
+	case ioSegment:
+		return "io"
+	default:
+		return fmt.Sprintf("segment(%d)", int(this))
+	}
+}
@@ -0,0 +1,182 @@
+package iris16
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_WatchpointBreakOnStore(t *testing.T) {
+	if core, err := New(); err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	} else if wp, err := NewWatchpoint("data", 0x10, 0x1F, WatchWrite); err != nil {
+		t.Fatalf("Couldn't create watchpoint: %s", err)
+	} else if di, err := NewDecodedInstruction(InstructionGroupMove, MoveOpStore, UserRegisterBegin, UserRegisterBegin+1, byte(dataSegment)); err != nil {
+		t.Fatalf("Couldn't construct store instruction: %s", err)
+	} else {
+		wp.Break = true
+		core.AddWatchpoint(wp)
+		core.SetRegister(UserRegisterBegin, 0x20)
+		core.SetRegister(UserRegisterBegin+1, 7)
+		if err := core.Invoke(di); err != nil {
+			t.Errorf("Execution failed: %s", err)
+		} else if core.TerminateExecution() {
+			t.Errorf("Store outside of the watched range triggered the watchpoint!")
+		}
+		core.SetRegister(UserRegisterBegin, 0x14)
+		if err := core.Invoke(di); err != nil {
+			t.Errorf("Execution failed: %s", err)
+		} else if !core.TerminateExecution() {
+			t.Errorf("Store inside of the watched range did not halt the core!")
+		} else if hit := core.LastWatchpointHit(); hit == nil || hit.Address != 0x14 || hit.Value != 7 || hit.Access != WatchWrite {
+			t.Errorf("Watchpoint hit was not recorded correctly: %v", hit)
+		} else if core.DataMemory(0x14) != 7 {
+			t.Errorf("Store did not complete before the watchpoint halted the core!")
+		}
+	}
+}
+
+func Test_WatchpointValueConditions(t *testing.T) {
+	if core, err := New(); err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	} else if changes, err := NewWatchpoint("stack", 0, 0xFFFF, WatchWrite); err != nil {
+		t.Fatalf("Couldn't create watchpoint: %s", err)
+	} else if equals, err := NewWatchpoint("stack", 0, 0xFFFF, WatchRead); err != nil {
+		t.Fatalf("Couldn't create watchpoint: %s", err)
+	} else {
+		var log bytes.Buffer
+		changes.Condition = WatchValueChanges
+		equals.Condition = WatchValueEquals
+		equals.Value = 9
+		core.SetWatchpointLog(&log)
+		core.AddWatchpoint(changes)
+		core.AddWatchpoint(equals)
+		core.Push(0) // stack memory is zeroed so this isn't a change
+		core.Push(9)
+		core.Pop()
+		core.Pop()
+		if changes.Hits != 1 {
+			t.Errorf("Value change watchpoint was hit %d times instead of once", changes.Hits)
+		}
+		if equals.Hits != 1 {
+			t.Errorf("Value equals watchpoint was hit %d times instead of once", equals.Hits)
+		}
+		if log.Len() == 0 {
+			t.Errorf("Non breaking watchpoints did not log anything!")
+		}
+		if core.TerminateExecution() {
+			t.Errorf("Non breaking watchpoints halted the core!")
+		}
+	}
+}
+
+func Test_WatchpointBadSegment(t *testing.T) {
+	if _, err := NewWatchpoint("code", 0, 1, WatchRead); err == nil {
+		t.Errorf("Was able to watch the code segment!")
+	} else if _, err := NewWatchpoint("nonsense", 0, 1, WatchRead); err == nil {
+		t.Errorf("Was able to watch a non existent segment!")
+	}
+}
+
+// the microcode of an extended instruction is fetched through the same
+// accessors as every other memory access
+func Test_WatchpointMicrocodeFetch(t *testing.T) {
+	core, err := assemble(`
+	.microcode
+	.macro triple
+	add t0 = b, b
+	add a = t0, b
+	.endmacro
+	.code
+	set r6 = #5
+	triple r7 = r6
+	system #0, r0, r0
+	`)
+	if err != nil {
+		t.Fatalf("Couldn't assemble program: %s", err)
+	} else if wp, err := NewWatchpoint("microcode", MicrocodeDispatchTableSize, MicrocodeDispatchTableSize, WatchRead); err != nil {
+		t.Fatalf("Couldn't create watchpoint: %s", err)
+	} else {
+		wp.Break = true
+		core.AddWatchpoint(wp)
+		if err := core.Run(); err != nil {
+			t.Fatalf("Run failed: %s", err)
+		} else if hit := core.LastWatchpointHit(); hit == nil || hit.Address != MicrocodeDispatchTableSize || hit.InstructionPointer != 1 {
+			t.Errorf("Fetching the macro's microcode didn't hit the watchpoint: %v", hit)
+		} else if r7 := core.Register(7); r7 != 15 {
+			t.Errorf("The macro should finish before the core halts but r7 is %d", r7)
+		}
+	}
+}
+
+// an io device which reads back the last value stored to it
+type latchDevice struct {
+	value Word
+}
+
+func (this *latchDevice) Begin() Word                    { return 0x40 }
+func (this *latchDevice) End() Word                      { return 0x40 }
+func (this *latchDevice) RespondsTo(address Word) bool   { return address == 0x40 }
+func (this *latchDevice) Startup() error                 { return nil }
+func (this *latchDevice) Shutdown() error                { return nil }
+func (this *latchDevice) Load(_ Word) (Word, error)      { return this.value, nil }
+func (this *latchDevice) Store(_ Word, value Word) error { this.value = value; return nil }
+
+func Test_WatchpointIoValueChanges(t *testing.T) {
+	if core, err := New(); err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	} else if err := core.RegisterIoDevice(&latchDevice{}); err != nil {
+		t.Fatalf("Couldn't register device: %s", err)
+	} else if wp, err := NewWatchpoint("io", 0x40, 0x40, WatchWrite); err != nil {
+		t.Fatalf("Couldn't create watchpoint: %s", err)
+	} else {
+		var log bytes.Buffer
+		wp.Condition = WatchValueChanges
+		core.SetWatchpointLog(&log)
+		core.AddWatchpoint(wp)
+		for _, value := range []Word{5, 5, 7, 7, 0} {
+			if err := core.SetIoMemory(0x40, value); err != nil {
+				t.Fatalf("Store failed: %s", err)
+			}
+		}
+		if wp.Hits != 3 {
+			t.Errorf("Value change watchpoint on an io address was hit %d times instead of 3", wp.Hits)
+		}
+		// a read of the same value isn't a change for the next write
+		if _, err := core.IoMemory(0x40); err != nil {
+			t.Fatalf("Load failed: %s", err)
+		} else if err := core.SetIoMemory(0x40, 0); err != nil {
+			t.Fatalf("Store failed: %s", err)
+		} else if wp.Hits != 3 {
+			t.Errorf("Storing the value already at an io address counted as a change")
+		}
+	}
+}
+
+func Test_IoUntrackedWithoutWatchpoints(t *testing.T) {
+	if core, err := New(); err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	} else if err := core.RegisterIoDevice(&latchDevice{}); err != nil {
+		t.Fatalf("Couldn't register device: %s", err)
+	} else if data, err := NewWatchpoint("data", 0, 0, WatchWrite); err != nil {
+		t.Fatalf("Couldn't create watchpoint: %s", err)
+	} else if io, err := NewWatchpoint("io", 0x40, 0x40, WatchWrite); err != nil {
+		t.Fatalf("Couldn't create watchpoint: %s", err)
+	} else {
+		core.AddWatchpoint(data)
+		if err := core.SetIoMemory(0x40, 5); err != nil {
+			t.Fatalf("Store failed: %s", err)
+		} else if core.ioValues != nil {
+			t.Errorf("Io values are tracked without an io watchpoint")
+		}
+		id := core.AddWatchpoint(io)
+		if err := core.SetIoMemory(0x40, 6); err != nil {
+			t.Fatalf("Store failed: %s", err)
+		} else if core.ioValues[0x40] != 6 {
+			t.Errorf("Io values aren't tracked with an io watchpoint")
+		} else if err := core.RemoveWatchpoint(id); err != nil {
+			t.Fatalf("Couldn't remove watchpoint: %s", err)
+		} else if core.ioValues != nil {
+			t.Errorf("Io values are still tracked after the io watchpoint was removed")
+		}
+	}
+}
@@ -32,67 +32,59 @@ const (
 	ArithmeticOpCount
 )
 
-var unimplementedBinaryOp = func(a, b Word) (Word, error) { return 0, fmt.Errorf("Operation not implemented!") }
-
 type ArithmeticOp struct {
 	ImmediateForm bool
-	Fn            func(Word, Word) (Word, error)
-}
-
-func (this *ArithmeticOp) Invoke(first, second Word) (Word, error) {
-	return this.Fn(first, second)
-
+	// the operation of the alu which carries out the instruction, negative
+	// for the slots which aren't implemented
+	Operation Word
+	// the unary forms fix the second source of the alu, nil passes the
+	// second operand through
+	Source1 func(first, second Word) Word
 }
 
 const (
 	DivideByZeroMessage = "Divide by zero error!"
+	unimplementedAluOp  = -1
 )
 
-type binOp func(Word, Word) (Word, error)
-
-func genericDivide(a, b Word, denomIsOne, otherwise binOp) (Word, error) {
-	if b == 0 {
-		return 0, fmt.Errorf(DivideByZeroMessage)
-	} else if b == 1 {
-		return denomIsOne(a, b)
-	} else {
-		return otherwise(a, b)
-	}
+func one(_, _ Word) Word {
+	return 1
 }
-func div(a, b Word) (Word, error) {
-	return genericDivide(a, b, func(a, _ Word) (Word, error) { return a, nil }, func(a, b Word) (Word, error) { return a / b, nil })
+func two(_, _ Word) Word {
+	return 2
 }
-func rem(a, b Word) (Word, error) {
-	return genericDivide(a, b, func(_, _ Word) (Word, error) { return 0, nil }, func(a, b Word) (Word, error) { return a % b, nil })
+func same(first, _ Word) Word {
+	return first
 }
 
 var unimplementedArithmeticOp = ArithmeticOp{
 	ImmediateForm: false,
-	Fn:            unimplementedBinaryOp,
+	Operation:     unimplementedAluOp,
 }
-var arithmeticOps = [31]ArithmeticOp{
-	ArithmeticOp{false, func(a, b Word) (Word, error) { return a + b, nil }},  // add
-	ArithmeticOp{false, func(a, b Word) (Word, error) { return a - b, nil }},  // sub
-	ArithmeticOp{false, func(a, b Word) (Word, error) { return a * b, nil }},  // mul
-	ArithmeticOp{false, div},                                                  // divide
-	ArithmeticOp{false, rem},                                                  // remainder
-	ArithmeticOp{false, func(a, b Word) (Word, error) { return a << b, nil }}, // shift left
-	ArithmeticOp{false, func(a, b Word) (Word, error) { return a >> b, nil }}, // shift right
-	ArithmeticOp{false, func(a, b Word) (Word, error) { return a & b, nil }},  // binary and
-	ArithmeticOp{false, func(a, b Word) (Word, error) { return a | b, nil }},  // binary or
-	ArithmeticOp{false, func(a, _ Word) (Word, error) { return ^a, nil }},     // unary not
-	ArithmeticOp{false, func(a, b Word) (Word, error) { return a ^ b, nil }},  // binary xor
-	ArithmeticOp{false, func(a, _ Word) (Word, error) { return a + 1, nil }},  // increment
-	ArithmeticOp{false, func(a, _ Word) (Word, error) { return a - 1, nil }},  // decrement
-	ArithmeticOp{false, func(a, _ Word) (Word, error) { return a + a, nil }},  // double
-	ArithmeticOp{false, func(a, _ Word) (Word, error) { return a / 2, nil }},  // halve
-	ArithmeticOp{true, func(a, b Word) (Word, error) { return a + b, nil }},   // immediate form of add
-	ArithmeticOp{true, func(a, b Word) (Word, error) { return a - b, nil }},   // immediate form of sub
-	ArithmeticOp{true, func(a, b Word) (Word, error) { return a * b, nil }},   // immediate form of mul
-	ArithmeticOp{true, div},                                                   // immediate form of div
-	ArithmeticOp{true, rem},                                                   // immediate form of rem
-	ArithmeticOp{true, func(a, b Word) (Word, error) { return a << b, nil }},  // immediate form of shift left
-	ArithmeticOp{true, func(a, b Word) (Word, error) { return a >> b, nil }},  // immediate form of shift right
+var arithmeticOps = [32]ArithmeticOp{
+	{false, IntegerAdd, nil},        // add
+	{false, IntegerSubtract, nil},   // sub
+	{false, IntegerMultiply, nil},   // mul
+	{false, IntegerDivide, nil},     // divide
+	{false, IntegerRemainder, nil},  // remainder
+	{false, IntegerShiftLeft, nil},  // shift left
+	{false, IntegerShiftRight, nil}, // shift right
+	{false, IntegerAnd, nil},        // binary and
+	{false, IntegerOr, nil},         // binary or
+	{false, IntegerNot, nil},        // unary not
+	{false, IntegerXor, nil},        // binary xor
+	{false, IntegerAdd, one},        // increment
+	{false, IntegerSubtract, one},   // decrement
+	{false, IntegerAdd, same},       // double
+	{false, IntegerDivide, two},     // halve
+	{true, IntegerAdd, nil},         // immediate form of add
+	{true, IntegerSubtract, nil},    // immediate form of sub
+	{true, IntegerMultiply, nil},    // immediate form of mul
+	{true, IntegerDivide, nil},      // immediate form of div
+	{true, IntegerRemainder, nil},   // immediate form of rem
+	{true, IntegerShiftLeft, nil},   // immediate form of shift left
+	{true, IntegerShiftRight, nil},  // immediate form of shift right
+	unimplementedArithmeticOp,
 	unimplementedArithmeticOp,
 	unimplementedArithmeticOp,
 	unimplementedArithmeticOp,
@@ -110,21 +102,37 @@ func init() {
 	}
 }
 
+// unlike iris16 the immediate forms get a full 32-bit immediate, it lives
+// in the upper half of the instruction instead of the last register byte.
+// The computation itself is done by the alu of the datapath, a zero divisor
+// is caught before it gets there.
 func arithmetic(core *Core, inst *DecodedInstruction) error {
-	var arg0, arg1 Word
-	var err error
-	dest := inst.Data[0]
-	arg0 = core.Register(inst.Data[1])
-	result := Word(0)
-	invoke := arithmeticOps[inst.Op]
-	if invoke.ImmediateForm {
+	var arg1 Word
+	op := arithmeticOps[inst.Op]
+	arg0 := core.Register(inst.Data[1])
+	if op.ImmediateForm {
 		arg1 = inst.Immediate()
 	} else {
 		arg1 = core.Register(inst.Data[2])
 	}
-	if result, err = arithmeticOps[inst.Op].Invoke(arg0, arg1); err != nil {
+	if op.Source1 != nil {
+		arg1 = op.Source1(arg0, arg1)
+	}
+	if op.Operation == unimplementedAluOp {
+		return fmt.Errorf("Operation not implemented!")
+	} else if (op.Operation == IntegerDivide || op.Operation == IntegerRemainder) && arg1 == 0 {
+		return fmt.Errorf(DivideByZeroMessage)
+	} else if result, err := core.evaluate(core.alu, op.Operation, arg0, arg1); err != nil {
 		return err
 	} else {
-		return core.SetRegister(dest, result)
+		return core.SetRegister(inst.Data[0], result)
+	}
+}
+
+func NewDecodedInstructionArithmetic(op, dest, src0, src1 byte) (*DecodedInstruction, error) {
+	if op >= ArithmeticOpCount {
+		return nil, NewError(ErrorOpValueOutOfRange, uint(op))
+	} else {
+		return NewDecodedInstruction(InstructionGroupArithmetic, op, dest, src0, src1)
 	}
 }
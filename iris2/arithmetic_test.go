@@ -2,10 +2,26 @@ package iris2
 
 import "testing"
 
-func Test_Add_1(t *testing.T) {
-	if core, err := New(); err != nil {
+// the arithmetic is done by the alu of the datapath so the core has to be
+// running
+func startCore(t *testing.T) *Core {
+	core, err := New()
+	if err != nil {
 		t.Fatalf("Couldn't create core %s", err)
-	} else if di, err := NewDecodedInstructionArithmetic(ArithmeticOpAdd, 32, TrueRegister, TrueRegister); err != nil {
+	} else if err := core.Startup(); err != nil {
+		t.Fatalf("Couldn't start core %s", err)
+	}
+	t.Cleanup(func() {
+		if err := core.Shutdown(); err != nil {
+			t.Errorf("Couldn't shut down core %s", err)
+		}
+	})
+	return core
+}
+
+func Test_Add_1(t *testing.T) {
+	core := startCore(t)
+	if di, err := NewDecodedInstructionArithmetic(ArithmeticOpAdd, 32, TrueRegister, TrueRegister); err != nil {
 		t.Errorf("Couldn't construct arithmetic instruction: %s", err)
 	} else if err := core.Invoke(di); err != nil {
 		t.Errorf("Execution failed: %s", err)
@@ -15,9 +31,8 @@ func Test_Add_1(t *testing.T) {
 }
 
 func Test_Sub_1(t *testing.T) {
-	if core, err := New(); err != nil {
-		t.Fatalf("Couldn't create core %s", err)
-	} else if di, err := NewDecodedInstructionArithmetic(ArithmeticOpSub, 32, TrueRegister, TrueRegister); err != nil {
+	core := startCore(t)
+	if di, err := NewDecodedInstructionArithmetic(ArithmeticOpSub, 32, TrueRegister, TrueRegister); err != nil {
 		t.Errorf("Couldn't construct arithmetic instruction: %s", err)
 	} else if err := core.Invoke(di); err != nil {
 		t.Errorf("Execution failed: %s", err)
@@ -27,9 +42,8 @@ func Test_Sub_1(t *testing.T) {
 }
 
 func Test_Mul_1(t *testing.T) {
-	if core, err := New(); err != nil {
-		t.Fatalf("Couldn't create core %s", err)
-	} else if di, err := NewDecodedInstructionArithmetic(ArithmeticOpMul, 32, UserRegisterBegin, UserRegisterBegin); err != nil {
+	core := startCore(t)
+	if di, err := NewDecodedInstructionArithmetic(ArithmeticOpMul, 32, UserRegisterBegin, UserRegisterBegin); err != nil {
 		t.Errorf("Couldn't construct arithmetic instruction: %s", err)
 	} else {
 		if err := core.SetRegister(UserRegisterBegin, 2); err != nil {
@@ -43,9 +57,8 @@ func Test_Mul_1(t *testing.T) {
 }
 
 func Test_Div_1(t *testing.T) {
-	if core, err := New(); err != nil {
-		t.Fatalf("Couldn't create core %s", err)
-	} else if di, err := NewDecodedInstructionArithmetic(ArithmeticOpDiv, UserRegisterBegin+1, UserRegisterBegin, UserRegisterBegin); err != nil {
+	core := startCore(t)
+	if di, err := NewDecodedInstructionArithmetic(ArithmeticOpDiv, UserRegisterBegin+1, UserRegisterBegin, UserRegisterBegin); err != nil {
 		t.Errorf("Couldn't construct arithmetic instruction: %s", err)
 	} else if err := core.SetRegister(UserRegisterBegin, 2); err != nil {
 		t.Errorf("Couldn't set register %d to 2: %s", UserRegisterBegin, err)
@@ -59,9 +72,8 @@ func Test_Div_1(t *testing.T) {
 }
 
 func Test_Div_2(t *testing.T) {
-	if core, err := New(); err != nil {
-		t.Fatalf("Couldn't create core %s", err)
-	} else if di, err := NewDecodedInstructionArithmetic(ArithmeticOpDiv, UserRegisterBegin+1, UserRegisterBegin, FalseRegister); err != nil {
+	core := startCore(t)
+	if di, err := NewDecodedInstructionArithmetic(ArithmeticOpDiv, UserRegisterBegin+1, UserRegisterBegin, FalseRegister); err != nil {
 		t.Errorf("Couldn't construct arithmetic instruction: %s", err)
 	} else if err := core.SetRegister(UserRegisterBegin, 2); err != nil {
 		t.Errorf("Couldn't set register %d to 2: %s", UserRegisterBegin, err)
@@ -77,9 +89,8 @@ func Test_Div_2(t *testing.T) {
 }
 
 func Test_Rem_1(t *testing.T) {
-	if core, err := New(); err != nil {
-		t.Fatalf("Couldn't create core %s", err)
-	} else if di, err := NewDecodedInstructionArithmetic(ArithmeticOpRem, UserRegisterBegin+1, UserRegisterBegin, UserRegisterBegin); err != nil {
+	core := startCore(t)
+	if di, err := NewDecodedInstructionArithmetic(ArithmeticOpRem, UserRegisterBegin+1, UserRegisterBegin, UserRegisterBegin); err != nil {
 		t.Errorf("Couldn't construct arithmetic instruction: %s", err)
 	} else if err := core.SetRegister(UserRegisterBegin, 2); err != nil {
 		t.Errorf("Couldn't set register %d to 2: %s", UserRegisterBegin, err)
@@ -93,9 +104,8 @@ func Test_Rem_1(t *testing.T) {
 }
 
 func Test_Rem_2(t *testing.T) {
-	if core, err := New(); err != nil {
-		t.Fatalf("Couldn't create core %s", err)
-	} else if di, err := NewDecodedInstructionArithmetic(ArithmeticOpRem, UserRegisterBegin+1, UserRegisterBegin, FalseRegister); err != nil {
+	core := startCore(t)
+	if di, err := NewDecodedInstructionArithmetic(ArithmeticOpRem, UserRegisterBegin+1, UserRegisterBegin, FalseRegister); err != nil {
 		t.Errorf("Couldn't construct arithmetic instruction: %s", err)
 	} else if err := core.SetRegister(UserRegisterBegin, 2); err != nil {
 		t.Errorf("Couldn't set register %d to 2: %s", UserRegisterBegin, err)
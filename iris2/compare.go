@@ -66,16 +66,14 @@ const (
 	CompareBodyError
 )
 
-type compareOpBody func(Word, Word) (bool, error)
-
-var bodyOps = []compareOpBody{
-	func(a, b Word) (bool, error) { return a == b, nil },
-	func(a, b Word) (bool, error) { return a != b, nil },
-	func(a, b Word) (bool, error) { return a < b, nil },
-	func(a, b Word) (bool, error) { return a > b, nil },
-	func(a, b Word) (bool, error) { return a <= b, nil },
-	func(a, b Word) (bool, error) { return a >= b, nil },
-	func(a, b Word) (bool, error) { return false, fmt.Errorf("Invalid compare body op!") },
+// The operation of the cond unit each compare body is carried out with
+var condOperations = []Word{
+	CompareBodyEq:  Equal,
+	CompareBodyNeq: NotEqual,
+	CompareBodyLt:  LessThan,
+	CompareBodyGt:  GreaterThan,
+	CompareBodyLe:  LessThanOrEqual,
+	CompareBodyGe:  GreaterThanOrEqual,
 }
 
 type compareOp struct {
@@ -84,14 +82,6 @@ type compareOp struct {
 
 var errorCompareOp = compareOp{Body: CompareBodyError, Combine: CombineError}
 
-func (this *compareOp) Invoke(oldVal bool, new0, new1 Word) (bool, error) {
-	if result, err := bodyOps[this.Body](new0, new1); err != nil {
-		return false, err
-	} else {
-		return combineOps[this.Combine](oldVal, result)
-	}
-}
-
 var compareOps = [32]compareOp{
 	{CompareBodyEq, CombineNone},
 	{CompareBodyEq, CombineAnd},
@@ -134,8 +124,16 @@ func boolToWord(val bool) Word {
 		return 0
 	}
 }
+
+// The comparison is done by the cond unit of the datapath, the core combines
+// its result with the old value of the destination
 func compare(core *Core, inst *DecodedInstruction) error {
-	if val, err := compareOps[inst.Op].Invoke(core.PredicateValue(inst.Data[0]), core.Register(inst.Data[1]), core.Register(inst.Data[2])); err != nil {
+	op := compareOps[inst.Op]
+	if op.Body == CompareBodyError {
+		return fmt.Errorf("Invalid compare body op!")
+	} else if result, err := core.evaluate(core.cond, condOperations[op.Body], core.Register(inst.Data[1]), core.Register(inst.Data[2])); err != nil {
+		return err
+	} else if val, err := combineOps[op.Combine](core.PredicateValue(inst.Data[0]), result != 0); err != nil {
 		return err
 	} else {
 		return core.SetRegister(inst.Data[0], boolToWord(val))
@@ -1,15 +1,16 @@
 package iris2
 
 import (
+	"context"
 	"fmt"
+	"github.com/DrItanium/cores/supervisor"
 )
 
 type CondUnit struct {
-	running                     bool
 	out                         chan Word
 	err                         chan error
 	Error                       <-chan error
-	Result, Control             <-chan Word
+	Result                      <-chan Word
 	operation, source0, source1 <-chan Word
 }
 
@@ -27,7 +28,7 @@ const (
 	NumberOfCondStates
 )
 
-func NewCondUnit(control, operation, source0, source1 <-chan Word) *CondUnit {
+func NewCondUnit(operation, source0, source1 <-chan Word) *CondUnit {
 	var s CondUnit
 	s.out = make(chan Word)
 	s.err = make(chan error)
@@ -35,7 +36,6 @@ func NewCondUnit(control, operation, source0, source1 <-chan Word) *CondUnit {
 	s.source0 = source0
 	s.source1 = source1
 	s.Error = s.err
-	s.Control = control
 	s.Result = s.out
 	return &s
 }
@@ -64,37 +64,20 @@ func init() {
 	dispatchTable[PassFalse] = func(_, _ Word) Word { return 0 }
 
 }
-func (this *CondUnit) body() {
-	for this.running {
-		select {
-		case op := <-this.operation:
-			if op >= NumberOfCondStates {
-				this.err <- fmt.Errorf("operation index %d is an undefined instruction!", op)
-			} else if op < 0 {
-				this.err <- fmt.Errorf("Send an operation index %d which is less than zero", op)
-			} else {
-				this.out <- dispatchTable[op](<-this.source0, <-this.source1)
-			}
+func (this *CondUnit) Run(ctx context.Context) error {
+	for {
+		if op, ok := supervisor.Receive(ctx, this.operation); !ok {
+			return nil
+		} else if a, ok := supervisor.Receive(ctx, this.source0); !ok {
+			return nil
+		} else if b, ok := supervisor.Receive(ctx, this.source1); !ok {
+			return nil
+		} else if op >= NumberOfCondStates {
+			supervisor.Send(ctx, this.err, fmt.Errorf("operation index %d is an undefined instruction!", op))
+		} else if op < 0 {
+			supervisor.Send(ctx, this.err, fmt.Errorf("Send an operation index %d which is less than zero", op))
+		} else {
+			supervisor.Send(ctx, this.out, dispatchTable[op](a, b))
 		}
 	}
 }
-
-func (this *CondUnit) controlQuery() {
-	<-this.Control
-	this.shutdown()
-}
-
-func (this *CondUnit) shutdown() {
-	this.running = false
-}
-
-func (this *CondUnit) Startup() error {
-	if this.running {
-		return fmt.Errorf("Given conditional unit is already running!")
-	} else {
-		this.running = true
-		go this.body()
-		go this.controlQuery()
-		return nil
-	}
-}
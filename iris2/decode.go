@@ -2,81 +2,56 @@
 package iris2
 
 import (
+	"context"
 	"fmt"
-	"github.com/DrItanium/cores/iris16"
+	"github.com/DrItanium/cores/supervisor"
 )
 
+// Turns raw instructions into decoded ones, this is the front of the iris2
+// datapath
 type DecoderUnit struct {
-	core    *iris16.Core
-	err     chan error
-	out     chan *DecodedInstruction
-	in      chan Word
-	Error   <-chan error
-	Input   chan<- Word
-	Control <-chan Word
-	Result  <-chan *DecodedInstruction
+	err    chan error
+	out    chan *DecodedInstruction
+	in     chan Instruction
+	Error  <-chan error
+	Input  chan<- Instruction
+	Result <-chan *DecodedInstruction
 }
 
-func NewDecoderUnit(control <-chan Word) (*DecoderUnit, error) {
+func NewDecoderUnit() *DecoderUnit {
 	var dc DecoderUnit
-	if core, err := iris16.New(); err != nil {
-		return nil, err
-	} else {
-		dc.core = core
-		// need to install the microcode somehow
-	}
-
 	dc.err = make(chan error)
 	dc.out = make(chan *DecodedInstruction)
-	dc.in = make(chan Word)
+	dc.in = make(chan Instruction)
 	dc.Error = dc.err
 	dc.Input = dc.in
 	dc.Result = dc.out
-	dc.Control = control
 	return &dc
 }
 
-func (this *DecoderUnit) Startup() error {
-	if this.running {
-		return fmt.Errorf("Can't startup an already running decoder unit")
-	} else {
-		this.running = true
-		go this.body()
-		go this.controlQuery()
-		return nil
-	}
-}
-func (this *DecoderUnit) controlQuery() {
-	<-this.Control
-	if err := this.shutdown(); err != nil {
-		this.err <- err
-	}
-}
-func (this *DecoderUnit) body() {
-	for this.running {
-		select {
-		case raw, more := <-this.in:
-			if more {
-				// an instruction in iris2 is 64-bits long but
-				// it can either be a packet or a single long
-				// instruction, this is determined by the group
-				// bits at the front of the instruction
-
-			}
+func (this *DecoderUnit) Run(ctx context.Context) error {
+	for {
+		if raw, ok := supervisor.Receive(ctx, this.in); !ok {
+			return nil
+		} else if di, err := raw.Decode(); err != nil {
+			supervisor.Send(ctx, this.err, err)
+		} else {
+			supervisor.Send(ctx, this.out, di)
 		}
 	}
 }
-func (this *DecoderUnit) shutdown() error {
-	if !this.running {
-		return fmt.Errorf("Can't shutdown an already shutdown decoder unit")
-	} else {
-		this.running = false
-		close(this.in)
-		return nil
-	}
-}
 
-type Instruction uint32
+// An iris2 instruction is 64-bits wide. The lower half has the same layout as
+// an iris16 instruction (group and op in the first byte followed by three
+// register bytes) while the upper half is a signed 32-bit immediate. Keeping
+// the immediate separate means immediate forms don't give up any of their
+// register operands.
+type Instruction uint64
+
+const (
+	ImmediateMin = -1 << 31
+	ImmediateMax = 1<<31 - 1
+)
 
 func (this Instruction) group() byte {
 	return byte(((this & 0x000000FF) & 0x7))
@@ -98,6 +73,9 @@ func (this Instruction) register(index int) (byte, error) {
 		return 0, fmt.Errorf("Register index: %d is out of range!", index)
 	}
 }
+func (this Instruction) immediate() Word {
+	return Word(int32(this >> 32))
+}
 
 func (this *Instruction) setGroup(group byte) {
 	*this = ((*this &^ 0x7) | Instruction(group))
@@ -118,11 +96,15 @@ func (this *Instruction) setByte(index int, value byte) error {
 	}
 	return nil
 }
+func (this *Instruction) setImmediate(value Word) {
+	*this = ((*this & 0xFFFFFFFF) | (Instruction(uint32(value)) << 32))
+}
 
 type DecodedInstruction struct {
-	Group, Op                     byte
-	Destination, Source0, Source1 byte
-	Immediate                     Word
+	Group     byte
+	Op        byte
+	Data      [3]byte
+	immediate Word
 }
 
 func (this Instruction) Decode() (*DecodedInstruction, error) {
@@ -144,15 +126,17 @@ func (this Instruction) Decode() (*DecodedInstruction, error) {
 	} else {
 		di.Data[2] = value
 	}
+	di.immediate = this.immediate()
 	return &di, nil
 }
 
+// Only the lower 32-bits of the value are kept, they are sign extended when
+// read back
 func (this *DecodedInstruction) SetImmediate(value Word) {
-	this.Data[1] = byte(value)
-	this.Data[2] = byte(value >> 8)
+	this.immediate = Word(int32(value))
 }
 func (this *DecodedInstruction) Immediate() Word {
-	return Word((Word(this.Data[2]) << 8) | Word(this.Data[1]))
+	return this.immediate
 }
 
 func (this *DecodedInstruction) Encode() *Instruction {
@@ -163,5 +147,6 @@ func (this *DecodedInstruction) Encode() *Instruction {
 	i.setByte(1, this.Data[0])
 	i.setByte(2, this.Data[1])
 	i.setByte(3, this.Data[2])
+	i.setImmediate(this.immediate)
 	return i
 }
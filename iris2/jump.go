@@ -110,47 +110,47 @@ func uncondOp(core *Core, call, ret, imm bool, inst *DecodedInstruction) error {
 	}
 	return branch(core, addr, call)
 }
+
+// The branch unit of the datapath picks the target of conditional jumps
+func selectBranch(core *Core, cond bool, onTrue, onFalse Word) (Word, error) {
+	return core.evaluate(core.branch, boolToWord(cond), onTrue, onFalse)
+}
+
+// a conditional return only pops the call stack once it is taken
 func condOp(core *Core, call, ret, imm bool, inst *DecodedInstruction) error {
-	addr := core.Register(InstructionPointer) + 1
+	var target Word
 	cond := core.Register(inst.Data[0]) == 1
-	shouldCall := call
 	if ret {
 		if imm {
 			return fmt.Errorf("A return instruction combined with an immediate makes no sense")
-		} else if cond {
-			addr = core.Return()
 		}
+		target = core.CallMemory(core.Register(CallPointer))
 		// we shouldn't even get here if call and ret are both true so no need to check again
+	} else if imm {
+		target = inst.Immediate()
 	} else {
-		if cond {
-			if imm {
-				addr = inst.Immediate()
-			} else {
-				addr = core.Register(inst.Data[1])
-			}
-		} else {
-			// it may turn out that the cond is false but we're a call instruction so don't call in this case
-			shouldCall = false
+		target = core.Register(inst.Data[1])
+	}
+	if addr, err := selectBranch(core, cond, target, core.NextInstructionAddress()); err != nil {
+		return err
+	} else {
+		if ret && cond {
+			core.Return()
 		}
+		// it may turn out that the cond is false but we're a call instruction so don't call in this case
+		return branch(core, addr, call && cond)
 	}
-	return branch(core, addr, shouldCall)
 }
 func ifThenElseOp(core *Core, call, ret, imm bool, inst *DecodedInstruction) error {
 	if imm {
 		return fmt.Errorf("The immediate flag should never be set with an if then else form")
 	} else if ret {
 		return fmt.Errorf("Can't mix return instructions and the if then else form")
+	} else if addr, err := selectBranch(core, core.Register(inst.Data[0]) == 1, core.Register(inst.Data[1]), core.Register(inst.Data[2])); err != nil {
+		return err
 	} else {
-		// extract the predicate condition
-		var addr Word
-		if core.Register(inst.Data[0]) == 1 {
-			addr = core.Register(inst.Data[1])
-		} else {
-			addr = core.Register(inst.Data[2])
-		}
 		return branch(core, addr, call)
 	}
-	return nil
 }
 func jump(core *Core, inst *DecodedInstruction) error {
 	bb := branchBits(inst.Op)
@@ -1,10 +1,14 @@
-// machine description of iris1
+// machine description of iris2, a 64-bit relative of iris16
 package iris2
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"github.com/DrItanium/cores/registration/machine"
+	"io"
+	"sort"
+	"strings"
 )
 
 func RegistrationName() string {
@@ -21,7 +25,7 @@ func init() {
 
 const (
 	RegisterCount            = 256
-	MemorySize               = 131072 // 131072 * 8 = 1 megabyte but we use a mmu to lay the memory out appropriately
+	MemorySize               = 131072 // 131072 * 8 = 1 megabyte per segment
 	MajorOperationGroupCount = 8
 	SystemCallCount          = 256
 )
@@ -96,8 +100,6 @@ func (this IrisError) Error() string {
 type ExecutionUnit func(*Core, *DecodedInstruction) error
 type SystemCall ExecutionUnit
 
-type wordMemory struct {
-}
 type Core struct {
 	code               [MemorySize]Instruction
 	data               [MemorySize]Word
@@ -109,62 +111,166 @@ type Core struct {
 	terminateExecution bool
 	groups             [MajorOperationGroupCount]ExecutionUnit
 	systemCalls        [SystemCallCount]SystemCall
-	gprMux             *Mux
 	gpr                *registerFile
-	alu                *Alu
-	bu                 *BranchUnit
-	cond               *CondUnit
-	control            chan Word
+	datapath           *Netlist
+	alu, cond, branch  *unitPorts
+	// closed once the datapath stops, nil until the core is started
+	halted <-chan struct{}
+	output io.Writer
+	mmu    *mmu
+}
+
+// The datapath a core is built with unless told otherwise. The core talks to
+// the register file named gpr directly, everything else goes through the
+// ports of the unit named core: arithmetic is carried out by the alu,
+// compares by cond and conditional jumps pick their target with branch.
+const DefaultDatapath = `
+unit core core
+unit gpr regfile
+unit alu alu
+unit cond cond
+unit branch branch
+wire core.aluop -> alu.operation
+wire core.alu0 -> alu.source0
+wire core.alu1 -> alu.source1
+wire alu.result -> core.aluresult
+wire core.condop -> cond.operation
+wire core.cond0 -> cond.source0
+wire core.cond1 -> cond.source1
+wire cond.result -> core.condresult
+wire core.condition -> branch.condition
+wire core.ontrue -> branch.ontrue
+wire core.onfalse -> branch.onfalse
+wire branch.result -> core.target
+`
+
+// The three operands the core sends for an operation and the port the result
+// comes back on
+type unitPorts struct {
+	inputs [3]chan<- Word
+	result <-chan Word
+}
+
+// The unit of kind core in a datapath, it has no behaviour of its own. The
+// core sends the operands of its alu, cond and branch operations out of the
+// ports aluop, alu0, alu1, condop, cond0, cond1, condition, ontrue and onfalse
+// and waits for the results on aluresult, condresult and target. Whatever the
+// netlist wires to those ports is what executes the instructions.
+type corePorts struct {
+	alu, cond, branch unitPorts
+}
+
+func (this *corePorts) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (this *Core) wireupUnits(datapath *Netlist) error {
+	if unit, err := datapath.Unit("gpr"); err != nil {
+		return err
+	} else if gpr, ok := unit.(*registerFile); !ok {
+		return fmt.Errorf("Unit gpr of the datapath must be a register file")
+	} else if unit, err := datapath.Unit("core"); err != nil {
+		return err
+	} else if ports, ok := unit.(*corePorts); !ok {
+		return fmt.Errorf("Unit core of the datapath must be of kind core")
+	} else {
+		// the core would wait forever on a port nothing is connected to
+		c := datapath.components["core"]
+		var names []string
+		for name := range c.ports {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if p := c.ports[name]; p.driver == nil && len(p.sinks) == 0 {
+				return fmt.Errorf("Port core.%s of the datapath isn't connected", name)
+			}
+		}
+		this.gpr = gpr
+		this.alu, this.cond, this.branch = &ports.alu, &ports.cond, &ports.branch
+		this.datapath = datapath
+		return nil
+	}
+}
+
+// Send the three inputs of an operation to a unit of the datapath and wait
+// for its result
+func (this *Core) evaluate(unit *unitPorts, a, b, c Word) (Word, error) {
+	if this.halted == nil {
+		return 0, fmt.Errorf("The datapath isn't running, the core has to be started first")
+	}
+	for i, value := range [3]Word{a, b, c} {
+		select {
+		case unit.inputs[i] <- value:
+		case <-this.halted:
+			return 0, fmt.Errorf("The datapath has stopped")
+		}
+	}
+	select {
+	case result := <-unit.result:
+		return result, nil
+	case <-this.halted:
+		return 0, fmt.Errorf("The datapath has stopped")
+	}
 }
 
-func (this *Core) wireupUnits() {
-	this.control = make(chan Word)
-	this.gpr = newRegisterFile(this.control, nil)
+func (this *Core) SetRegister(index byte, value Word) error {
+	return this.gpr.setRegister(index, value)
+}
+func (this *Core) Register(index byte) Word {
+	return this.gpr.getRegister(index)
+}
+
+// Addresses wrap around at the end of each segment the same way they do on
+// iris16
+func wrap(address Word) Word {
+	return address & (MemorySize - 1)
 }
 
 func (this *Core) CodeMemory(address Word) Instruction {
-	return this.code[address]
+	return this.code[wrap(address)]
 }
 func (this *Core) SetCodeMemory(address Word, value Instruction) error {
-	this.code[address] = value
+	this.code[wrap(address)] = value
 	return nil
 }
 func (this *Core) Call(addr Word) error {
-	this.callPointer++
-	this.call[this.callPointer] = this.NextInstructionAddress()
+	this.gpr.callPointer++
+	this.call[wrap(this.gpr.callPointer)] = this.NextInstructionAddress()
 	return this.SetRegister(InstructionPointer, addr)
 }
 func (this *Core) Return() Word {
-	value := this.call[this.callPointer]
-	this.callPointer--
+	value := this.call[wrap(this.gpr.callPointer)]
+	this.gpr.callPointer--
 	return value
 }
 func (this *Core) Push(value Word) {
-	this.stackPointer++
-	this.stack[this.stackPointer] = value
+	this.gpr.stackPointer++
+	this.stack[wrap(this.gpr.stackPointer)] = value
 }
 func (this *Core) Peek() Word {
-	return this.stack[this.stackPointer]
+	return this.stack[wrap(this.gpr.stackPointer)]
 }
 func (this *Core) Pop() Word {
-	value := this.stack[this.stackPointer]
-	this.stackPointer--
+	value := this.stack[wrap(this.gpr.stackPointer)]
+	this.gpr.stackPointer--
 	return value
 }
 func (this *Core) DataMemory(address Word) Word {
-	return this.data[address]
+	return this.data[wrap(address)]
 }
 func (this *Core) SetDataMemory(address, value Word) error {
-	this.data[address] = value
+	this.data[wrap(address)] = value
 	return nil
 }
 
 func (this *Core) MicrocodeMemory(address Word) Word {
-	return this.ucode[address]
+	return this.ucode[wrap(address)]
 }
 
 func (this *Core) SetMicrocodeMemory(address, value Word) error {
-	this.ucode[address] = value
+	this.ucode[wrap(address)] = value
 	return nil
 }
 
@@ -180,16 +286,30 @@ var defaultExecutionUnits = []struct {
 }
 
 func New() (*Core, error) {
+	if datapath, err := ParseNetlist(strings.NewReader(DefaultDatapath)); err != nil {
+		return nil, err
+	} else {
+		return NewWithDatapath(datapath)
+	}
+}
+
+// Build a core around the given netlist, it is started and stopped along with
+// the core
+func NewWithDatapath(datapath *Netlist) (*Core, error) {
 	var c Core
+	if err := c.wireupUnits(datapath); err != nil {
+		return nil, err
+	}
+	c.mmu = newMmu()
 	c.advancePc = true
 	c.terminateExecution = false
 	if err := c.SetRegister(InstructionPointer, 0); err != nil {
 		return nil, err
 	} else if err := c.SetRegister(PredicateRegister, 0); err != nil {
 		return nil, err
-	} else if err := c.SetRegister(StackPointer, 0xFFFF); err != nil {
+	} else if err := c.SetRegister(StackPointer, -1); err != nil {
 		return nil, err
-	} else if err := c.SetRegister(CallPointer, 0xFFFF); err != nil {
+	} else if err := c.SetRegister(CallPointer, -1); err != nil {
 		return nil, err
 	}
 	for i := 0; i < MajorOperationGroupCount; i++ {
@@ -210,6 +330,10 @@ func New() (*Core, error) {
 	c.InstallSystemCall(SystemCallTerminate, terminateSystemCall)
 	c.InstallSystemCall(SystemCallPanic, panicSystemCall)
 	c.InstallSystemCall(SystemCallPutc, putcSystemCall)
+	c.InstallSystemCall(SystemCallGetMmuRegister, getMmuRegisterSystemCall)
+	c.InstallSystemCall(SystemCallSetMmuRegister, setMmuRegisterSystemCall)
+	c.InstallSystemCall(SystemCallFlushTlb, flushTlbSystemCall)
+	c.InstallSystemCall(SystemCallReturnFromFault, returnFromFaultSystemCall)
 	return &c, nil
 }
 
@@ -278,7 +402,12 @@ func NewDecodedInstruction(group, op, data0, data1, data2 byte) (*DecodedInstruc
 	}
 }
 func NewDecodedInstructionImmediate(group, op, data0 byte, imm Word) (*DecodedInstruction, error) {
-	return NewDecodedInstruction(group, op, data0, byte(imm), byte(imm>>8))
+	if di, err := NewDecodedInstruction(group, op, data0, 0, 0); err != nil {
+		return nil, err
+	} else {
+		di.SetImmediate(imm)
+		return di, nil
+	}
 }
 
 func (this *Core) TerminateExecution() bool {
@@ -286,7 +415,7 @@ func (this *Core) TerminateExecution() bool {
 }
 
 func (this *Core) CurrentInstruction() Instruction {
-	return this.code[this.Register(InstructionPointer)]
+	return this.CodeMemory(this.Register(InstructionPointer))
 }
 
 func (this *Core) AdvanceProgramCounter() error {
@@ -301,7 +430,13 @@ func (this *Core) AdvanceProgramCounter() error {
 }
 
 func (this *Core) ExecuteCurrentInstruction() error {
-	return this.Dispatch(this.CurrentInstruction())
+	if inst, err := this.FetchInstruction(); err != nil {
+		return this.catchFault(err)
+	} else if err := this.Dispatch(inst); err != nil {
+		return this.catchFault(err)
+	} else {
+		return nil
+	}
 }
 
 func (this *Core) Run() error {
@@ -323,92 +458,78 @@ func (this *Core) SetDebug(_ bool) {
 
 }
 
-const (
-	sixteenBitMemory      = 65536
-	instructionMemorySize = sixteenBitMemory * 4
-	dataMemorySize        = sixteenBitMemory * 2
-)
-
-func readWord(input <-chan byte) (Word, error) {
-	if value, more := <-input; !more {
-		return 0, fmt.Errorf("Closed stream 0")
-	} else if value1, more0 := <-input; !more0 {
-		return 0, fmt.Errorf("Closed stream 1")
-	} else {
-		return Word(binary.LittleEndian.Uint16([]byte{value, value1})), nil
-	}
-}
-func readInstruction(input <-chan byte) (Instruction, error) {
-	if value, more := <-input; !more {
-		// closed early it seems :(
-		return 0, fmt.Errorf("Closed stream")
-	} else if value2, more0 := <-input; !more0 {
-		return 0, fmt.Errorf("Closed stream")
-	} else if value3, more1 := <-input; !more1 {
-		return 0, fmt.Errorf("Closed stream")
-	} else if value4, more2 := <-input; !more2 {
-		return 0, fmt.Errorf("Closed stream")
-	} else {
-		return Instruction(binary.LittleEndian.Uint32([]byte{value, value2, value3, value4})), nil
+// A memory image is the code segment followed by the data, microcode, stack
+// and call segments. Everything is stored as 64-bit little endian values.
+func readWord(input <-chan byte) (uint64, error) {
+	var buf [8]byte
+	for i := range buf {
+		if value, more := <-input; !more {
+			return 0, fmt.Errorf("Closed stream %d", i)
+		} else {
+			buf[i] = value
+		}
 	}
-
+	return binary.LittleEndian.Uint64(buf[:]), nil
 }
 func (this *Core) InstallProgram(input <-chan byte) error {
-	installWords := func(data [MemorySize]Word, input <-chan byte) error {
+	installWords := func(data *[MemorySize]Word, input <-chan byte) error {
 		for i := 0; i < MemorySize; i++ {
 			if val, err := readWord(input); err != nil {
 				return err
 			} else {
-				data[i] = val
+				data[i] = Word(val)
 			}
 		}
 		return nil
 	}
 	for i := 0; i < MemorySize; i++ {
-		if inst, err := readInstruction(input); err != nil {
+		if inst, err := readWord(input); err != nil {
 			return err
 		} else {
-			this.code[i] = inst
+			this.code[i] = Instruction(inst)
 		}
 	}
-	if err := installWords(this.data, input); err != nil {
+	if err := installWords(&this.data, input); err != nil {
 		return err
-	} else if err := installWords(this.ucode, input); err != nil {
+	} else if err := installWords(&this.ucode, input); err != nil {
 		return err
-	} else if err := installWords(this.stack, input); err != nil {
+	} else if err := installWords(&this.stack, input); err != nil {
 		return err
-	} else if err := installWords(this.call, input); err != nil {
+	} else if err := installWords(&this.call, input); err != nil {
 		return err
 	} else {
 		return nil
 	}
 }
 
+func dumpWord(value uint64, output chan<- byte) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], value)
+	for _, v := range buf {
+		output <- v
+	}
+}
 func (this *Core) Dump(output chan<- byte) error {
-	inst := make([]byte, 4)
-	dumpWords := func(data [MemorySize]Word, output chan<- byte) {
-		word := make([]byte, 2)
+	dumpWords := func(data *[MemorySize]Word, output chan<- byte) {
 		for _, dat := range data {
-			binary.LittleEndian.PutUint16(word, uint16(dat))
-			for _, v := range word {
-				output <- v
-			}
+			dumpWord(uint64(dat), output)
 		}
 	}
 	for _, dat := range this.code {
-		binary.LittleEndian.PutUint32(inst, uint32(dat))
-		for _, v := range inst {
-			output <- v
-		}
+		dumpWord(uint64(dat), output)
 	}
-	dumpWords(this.data, output)
-	dumpWords(this.ucode, output)
-	dumpWords(this.stack, output)
-	dumpWords(this.call, output)
+	dumpWords(&this.data, output)
+	dumpWords(&this.ucode, output)
+	dumpWords(&this.stack, output)
+	dumpWords(&this.call, output)
 	return nil
 }
 
 func (this *Core) Startup() error {
+	if err := this.datapath.Start(); err != nil {
+		return err
+	}
+	this.halted = this.datapath.Done()
 	for _, dev := range this.io {
 		if err := dev.Startup(); err != nil {
 			return err
@@ -422,7 +543,11 @@ func (this *Core) Shutdown() error {
 			return err
 		}
 	}
-	return nil
+	if this.datapath.Running() {
+		return this.datapath.Stop()
+	} else {
+		return nil
+	}
 }
 
 type segment int
@@ -450,19 +575,19 @@ func (this segment) acceptsWords() bool {
 }
 
 func (this *Core) StackMemory(address Word) Word {
-	return this.stack[address]
+	return this.stack[wrap(address)]
 }
 
 func (this *Core) SetStackMemory(address, value Word) error {
-	this.stack[address] = value
+	this.stack[wrap(address)] = value
 	return nil
 }
 func (this *Core) CallMemory(address Word) Word {
-	return this.call[address]
+	return this.call[wrap(address)]
 }
 
 func (this *Core) SetCallMemory(address, value Word) error {
-	this.call[address] = value
+	this.call[wrap(address)] = value
 	return nil
 }
 func (this *Core) RegisterIoDevice(dev IoDevice) error {
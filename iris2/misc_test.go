@@ -15,3 +15,60 @@ func Test_TerminateCall(t *testing.T) {
 		t.Logf("Terminate system call did tell core to terminate!")
 	}
 }
+
+func Test_ImmediateEncoding(t *testing.T) {
+	var di DecodedInstruction
+	di.Group = InstructionGroupMove
+	di.Op = MoveOpSet
+	di.Data = [3]byte{UserRegisterBegin, 0, 0}
+	di.SetImmediate(ImmediateMin)
+	if decoded, err := di.Encode().Decode(); err != nil {
+		t.Errorf("Couldn't decode instruction: %s", err)
+	} else if *decoded != di {
+		t.Errorf("Encoding and then decoding an instruction yielded %v instead of %v", *decoded, di)
+	} else if decoded.Immediate() != ImmediateMin {
+		t.Errorf("The immediate was not sign extended, got %d", decoded.Immediate())
+	}
+}
+
+func Test_DatapathMustBeRunning(t *testing.T) {
+	if core, err := New(); err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	} else if di, err := NewDecodedInstructionArithmetic(ArithmeticOpAdd, UserRegisterBegin, TrueRegister, TrueRegister); err != nil {
+		t.Fatalf("Couldn't construct arithmetic instruction: %s", err)
+	} else if err := core.Invoke(di); err == nil {
+		t.Errorf("A core which wasn't started executed an add")
+	} else if err := core.Startup(); err != nil {
+		t.Fatalf("Couldn't start core %s", err)
+	} else if err := core.Invoke(di); err != nil {
+		t.Errorf("Execution failed: %s", err)
+	} else if err := core.Shutdown(); err != nil {
+		t.Errorf("Couldn't shut down core %s", err)
+	} else if err := core.Invoke(di); err == nil {
+		t.Errorf("A core which was shut down executed an add")
+	}
+}
+
+// a conditional return only pops the call stack when it is taken
+func Test_ConditionalReturn(t *testing.T) {
+	core := startCore(t)
+	core.SetRegister(InstructionPointer, 10)
+	core.SetRegister(CallPointer, 0)
+	core.SetCallMemory(0, 40)
+	var bits branchBits
+	bits.setConditionalForm(true)
+	bits.setReturnForm(true)
+	for _, test := range []struct {
+		cond, ip, cp Word
+	}{{0, 11, 0}, {1, 40, -1}} {
+		if err := core.SetRegister(UserRegisterBegin, test.cond); err != nil {
+			t.Fatal(err)
+		} else if di, err := NewDecodedInstruction(InstructionGroupJump, byte(bits), UserRegisterBegin, 0, 0); err != nil {
+			t.Fatal(err)
+		} else if err := core.Invoke(di); err != nil {
+			t.Errorf("Execution failed: %s", err)
+		} else if ip, cp := core.Register(InstructionPointer), core.Register(CallPointer); ip != test.ip || cp != test.cp {
+			t.Errorf("Expected ip %d and call pointer %d but got %d and %d", test.ip, test.cp, ip, cp)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+// paged memory management unit for the iris2 core
+package iris2
+
+import (
+	"fmt"
+)
+
+// When translation is enabled, code fetches and data segment loads and stores
+// go through a pair of single level page tables, one for code and one for
+// data. The microcode, stack, call and io segments are never translated.
+//
+// Page tables live in physical data memory, each entry is a single word
+// indexed by virtual page number:
+//
+//	bit 0      present
+//	bit 1      readable
+//	bit 2      writable
+//	bit 3      executable
+//	bits 16-63 physical frame number
+//
+// Faults are precise, the faulting instruction has no side effects. If a fault
+// handler is installed then the core turns translation off, records the fault
+// and jumps to the handler which can fix things up and use the return from
+// fault system call to retry the instruction. Without a handler the fault is
+// returned from Run as a *PageFault.
+const (
+	PageShift      = 10
+	PageSize       = 1 << PageShift
+	PageFrameShift = 16
+	PageCount      = MemorySize / PageSize
+	TlbSize        = 16
+)
+
+type PagePermission Word
+
+const (
+	PagePresent PagePermission = 1 << iota
+	PageRead
+	PageWrite
+	PageExecute
+)
+
+// Control registers of the mmu, read and written with the get and set mmu
+// register system calls
+const (
+	MmuRegisterEnable = iota
+	MmuRegisterCodeTable
+	MmuRegisterCodeTableLength
+	MmuRegisterDataTable
+	MmuRegisterDataTableLength
+	// physical code address of the fault handler, negative means no handler
+	MmuRegisterFaultHandler
+	MmuRegisterFaultAddress
+	MmuRegisterFaultCause
+	MmuRegisterFaultInstruction
+	MmuRegisterCount
+)
+
+const (
+	PageFaultNotPresent = iota
+	PageFaultRead
+	PageFaultWrite
+	PageFaultExecute
+)
+
+var pageFaultCauses = []string{
+	"page not present",
+	"page not readable",
+	"page not writable",
+	"page not executable",
+}
+
+type PageFault struct {
+	Cause       Word
+	Address     Word
+	Instruction Word
+}
+
+func (this *PageFault) Error() string {
+	if this.Cause < 0 || this.Cause >= Word(len(pageFaultCauses)) {
+		return fmt.Sprintf("Page fault %d at address %x by instruction %x", this.Cause, this.Address, this.Instruction)
+	} else {
+		return fmt.Sprintf("Page fault (%s) at address %x by instruction %x", pageFaultCauses[this.Cause], this.Address, this.Instruction)
+	}
+}
+
+type addressSpace int
+
+const (
+	codeSpace addressSpace = iota
+	dataSpace
+)
+
+type TlbStatistics struct {
+	Hits, Misses, Flushes, Faults uint64
+}
+
+func (this TlbStatistics) HitRate() float64 {
+	if total := this.Hits + this.Misses; total == 0 {
+		return 0
+	} else {
+		return float64(this.Hits) / float64(total)
+	}
+}
+
+func (this TlbStatistics) String() string {
+	return fmt.Sprintf("tlb: %d hits, %d misses (%.2f%% hit rate), %d flushes, %d faults", this.Hits, this.Misses, this.HitRate()*100, this.Flushes, this.Faults)
+}
+
+type tlbEntry struct {
+	valid bool
+	space addressSpace
+	page  Word
+	entry Word
+}
+
+// fully associative, entries are replaced round robin
+type mmu struct {
+	registers [MmuRegisterCount]Word
+	tlb       [TlbSize]tlbEntry
+	next      int
+	stats     TlbStatistics
+}
+
+func newMmu() *mmu {
+	var m mmu
+	m.registers[MmuRegisterFaultHandler] = -1
+	return &m
+}
+
+func (this *mmu) enabled() bool {
+	return this.registers[MmuRegisterEnable] != 0
+}
+
+func (this *mmu) flush() {
+	for i := range this.tlb {
+		this.tlb[i].valid = false
+	}
+	this.stats.Flushes++
+}
+
+func (this *mmu) lookup(space addressSpace, page Word) (Word, bool) {
+	for _, e := range this.tlb {
+		if e.valid && e.space == space && e.page == page {
+			this.stats.Hits++
+			return e.entry, true
+		}
+	}
+	this.stats.Misses++
+	return 0, false
+}
+
+func (this *mmu) insert(space addressSpace, page, entry Word) {
+	this.tlb[this.next] = tlbEntry{valid: true, space: space, page: page, entry: entry}
+	this.next = (this.next + 1) % TlbSize
+}
+
+func (this *mmu) table(space addressSpace) (base, length Word) {
+	if space == codeSpace {
+		return this.registers[MmuRegisterCodeTable], this.registers[MmuRegisterCodeTableLength]
+	} else {
+		return this.registers[MmuRegisterDataTable], this.registers[MmuRegisterDataTableLength]
+	}
+}
+
+func (this *Core) MmuRegister(index Word) (Word, error) {
+	if index < 0 || index >= MmuRegisterCount {
+		return 0, fmt.Errorf("Mmu register %d does not exist!", index)
+	} else {
+		return this.mmu.registers[index], nil
+	}
+}
+
+// Changing the enable flag or either page table flushes the tlb
+func (this *Core) SetMmuRegister(index, value Word) error {
+	if index < 0 || index >= MmuRegisterCount {
+		return fmt.Errorf("Mmu register %d does not exist!", index)
+	}
+	this.mmu.registers[index] = value
+	if index < MmuRegisterFaultHandler {
+		this.mmu.flush()
+	}
+	return nil
+}
+
+func (this *Core) FlushTlb() {
+	this.mmu.flush()
+}
+
+func (this *Core) TlbStatistics() TlbStatistics {
+	return this.mmu.stats
+}
+
+func (this *Core) pageFault(cause, address Word) error {
+	this.mmu.stats.Faults++
+	return &PageFault{Cause: cause, Address: address, Instruction: this.InstructionAddress()}
+}
+
+// Turn a virtual address into a physical one, page table entries which aren't
+// present are never cached so a handler only has to flush the tlb when it
+// changes an entry that was already present.
+func (this *Core) translate(space addressSpace, address Word, access PagePermission) (Word, error) {
+	if !this.mmu.enabled() {
+		return address, nil
+	}
+	page, offset := Word(uint64(address)>>PageShift), address&(PageSize-1)
+	entry, ok := this.mmu.lookup(space, page)
+	if !ok {
+		if base, length := this.mmu.table(space); page >= length {
+			return 0, this.pageFault(PageFaultNotPresent, address)
+		} else if entry = this.DataMemory(base + page); PagePermission(entry)&PagePresent == 0 {
+			return 0, this.pageFault(PageFaultNotPresent, address)
+		} else {
+			this.mmu.insert(space, page, entry)
+		}
+	}
+	if PagePermission(entry)&access == 0 {
+		switch access {
+		case PageRead:
+			return 0, this.pageFault(PageFaultRead, address)
+		case PageWrite:
+			return 0, this.pageFault(PageFaultWrite, address)
+		default:
+			return 0, this.pageFault(PageFaultExecute, address)
+		}
+	}
+	return Word(uint64(entry)>>PageFrameShift)<<PageShift | offset, nil
+}
+
+// Fetch the instruction at the instruction pointer through the mmu
+func (this *Core) FetchInstruction() (Instruction, error) {
+	if addr, err := this.translate(codeSpace, this.InstructionAddress(), PageExecute); err != nil {
+		return 0, err
+	} else {
+		return this.CodeMemory(addr), nil
+	}
+}
+
+// Read from the data segment through the mmu
+func (this *Core) LoadData(address Word) (Word, error) {
+	if addr, err := this.translate(dataSpace, address, PageRead); err != nil {
+		return 0, err
+	} else {
+		return this.DataMemory(addr), nil
+	}
+}
+
+// Write to the data segment through the mmu
+func (this *Core) StoreData(address, value Word) error {
+	if addr, err := this.translate(dataSpace, address, PageWrite); err != nil {
+		return err
+	} else {
+		return this.SetDataMemory(addr, value)
+	}
+}
+
+// Hand a page fault to the installed handler, anything else is passed through
+func (this *Core) catchFault(err error) error {
+	if pf, ok := err.(*PageFault); !ok {
+		return err
+	} else if handler := this.mmu.registers[MmuRegisterFaultHandler]; handler < 0 {
+		return err
+	} else {
+		this.mmu.registers[MmuRegisterEnable] = 0
+		this.mmu.registers[MmuRegisterFaultCause] = pf.Cause
+		this.mmu.registers[MmuRegisterFaultAddress] = pf.Address
+		this.mmu.registers[MmuRegisterFaultInstruction] = pf.Instruction
+		this.advancePc = false
+		return this.SetRegister(InstructionPointer, handler)
+	}
+}
+
+// Turn translation back on and retry the instruction which faulted
+func (this *Core) ReturnFromFault() error {
+	this.mmu.registers[MmuRegisterEnable] = 1
+	this.advancePc = false
+	return this.SetRegister(InstructionPointer, this.mmu.registers[MmuRegisterFaultInstruction])
+}
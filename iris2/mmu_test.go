@@ -0,0 +1,43 @@
+package iris2
+
+import "testing"
+
+// map virtual data page 1 to frame 3 read only and make sure translation,
+// permissions and the tlb counters all line up
+func Test_MmuTranslate(t *testing.T) {
+	const table = 0x1000
+	if core, err := New(); err != nil {
+		t.Fatalf("Couldn't create core %s", err)
+	} else if err := core.SetDataMemory(table+1, 3<<PageFrameShift|Word(PagePresent|PageRead)); err != nil {
+		t.Fatalf("Couldn't install page table entry: %s", err)
+	} else if err := core.SetMmuRegister(MmuRegisterDataTable, table); err != nil {
+		t.Fatalf("Couldn't set the data page table: %s", err)
+	} else if err := core.SetMmuRegister(MmuRegisterDataTableLength, 2); err != nil {
+		t.Fatalf("Couldn't set the data page table length: %s", err)
+	} else if err := core.SetMmuRegister(MmuRegisterEnable, 1); err != nil {
+		t.Fatalf("Couldn't enable the mmu: %s", err)
+	} else if err := core.SetDataMemory(3*PageSize+7, 99); err != nil {
+		t.Fatalf("Couldn't write physical memory: %s", err)
+	} else {
+		for i := 0; i < 2; i++ {
+			if value, err := core.LoadData(PageSize + 7); err != nil {
+				t.Errorf("Load through the mmu failed: %s", err)
+			} else if value != 99 {
+				t.Errorf("Expected 99 but got %d", value)
+			}
+		}
+		if stats := core.TlbStatistics(); stats.Hits != 1 || stats.Misses != 1 {
+			t.Errorf("Expected one tlb hit and one miss, got %s", stats)
+		}
+		if err := core.StoreData(PageSize+7, 1); err == nil {
+			t.Errorf("Writing to a read only page didn't fault")
+		} else if pf, ok := err.(*PageFault); !ok {
+			t.Errorf("Expected a page fault but got %s", err)
+		} else if pf.Cause != PageFaultWrite || pf.Address != PageSize+7 {
+			t.Errorf("Unexpected page fault: %s", pf)
+		}
+		if _, err := core.LoadData(0); err == nil {
+			t.Errorf("Reading from a page which isn't present didn't fault")
+		}
+	}
+}
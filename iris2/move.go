@@ -65,14 +65,14 @@ func init() {
 func moveOpStoreCode(core *Core, inst *DecodedInstruction) error {
 	// this one is a little odd since we have to use the contents of two registers
 	// build an instruction from lower and upper
-	return core.SetCodeMemory(core.Register(inst.Data[0]), (Instruction(core.Register(inst.Data[1])) | (Instruction(core.Register(inst.Data[2])) << 16)))
+	return core.SetCodeMemory(core.Register(inst.Data[0]), (Instruction(uint32(core.Register(inst.Data[1]))) | (Instruction(core.Register(inst.Data[2])) << 32)))
 }
 func moveOpLoadCode(core *Core, inst *DecodedInstruction) error {
 	// in this case we need to load an Instruction from memory and store it into the upper and lower
 	// registers. The syntax is dest, src0 are lower and upper with src1 being dest
 	idat := core.CodeMemory(core.Register(inst.Data[0]))
 	// set the lower and upper halves
-	lowerHalf, upperHalf := Word(idat), Word(idat>>16)
+	lowerHalf, upperHalf := Word(uint32(idat)), Word(uint32(idat>>32))
 	if err := core.SetRegister(inst.Data[1], lowerHalf); err != nil {
 		return err
 	} else if err := core.SetRegister(inst.Data[2], upperHalf); err != nil {
@@ -106,7 +106,11 @@ func load(core *Core, inst *DecodedInstruction) error {
 	dest, addr, seg := inst.Data[0], core.Register(inst.Data[1]), segment(inst.Data[2])
 	switch seg {
 	case dataSegment:
-		val = core.DataMemory(addr)
+		if q, err := core.LoadData(addr); err != nil {
+			return err
+		} else {
+			val = q
+		}
 	case microcodeSegment:
 		val = core.MicrocodeMemory(addr)
 	case stackSegment:
@@ -131,7 +135,7 @@ func store(core *Core, inst *DecodedInstruction) error {
 	dest, src, seg := core.Register(inst.Data[0]), core.Register(inst.Data[1]), segment(inst.Data[2])
 	switch segment(seg) {
 	case dataSegment:
-		return core.SetDataMemory(dest, src)
+		return core.StoreData(dest, src)
 	case microcodeSegment:
 		return core.SetMicrocodeMemory(dest, src)
 	case stackSegment:
@@ -0,0 +1,529 @@
+// declarative wiring of the iris2 dataflow units
+package iris2
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/DrItanium/cores/supervisor"
+	"github.com/DrItanium/cores/trace"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A netlist instantiates dataflow units, connects their ports with nets and
+// starts and stops all of them together. Every net has a single driver (an
+// output port) and up to MaxFanOut sinks (input ports), a value sent by the
+// driver is delivered to every sink in the order they were connected.
+//
+// The text form has one statement per line, # starts a comment:
+//
+//	unit <name> <kind> [param=value ...]
+//	wire <unit>.<output> -> <unit>.<input>[, <unit>.<input> ...]
+//
+// Ports which aren't part of a net can be driven or read from outside with
+// Input and Output. A core is connected to its datapath through a unit of
+// kind core, see corePorts.
+const (
+	MaxFanOut = 8
+)
+
+type PortDirection int
+
+const (
+	PortInput PortDirection = iota
+	PortOutput
+)
+
+func (this PortDirection) String() string {
+	switch this {
+	case PortInput:
+		return "input"
+	case PortOutput:
+		return "output"
+	default:
+		return fmt.Sprintf("PortDirection(%d)", int(this))
+	}
+}
+
+type port struct {
+	direction PortDirection
+	// chan<- T for inputs and <-chan T for outputs
+	channel reflect.Value
+	// the net this port is part of, if any
+	driver *port
+	sinks  []*port
+	path   string
+}
+
+func (this *port) elem() reflect.Type {
+	return this.channel.Type().Elem()
+}
+
+func input(ch interface{}) *port {
+	v := reflect.ValueOf(ch)
+	return &port{direction: PortInput, channel: v.Convert(reflect.ChanOf(reflect.SendDir, v.Type().Elem()))}
+}
+func output(ch interface{}) *port {
+	v := reflect.ValueOf(ch)
+	return &port{direction: PortOutput, channel: v.Convert(reflect.ChanOf(reflect.RecvDir, v.Type().Elem()))}
+}
+
+type component struct {
+	name, kind string
+	unit       supervisor.Component
+	ports      map[string]*port
+	errors     <-chan error
+}
+
+type componentFactory func(params map[string]int) (*component, error)
+
+func param(kind string, params map[string]int, name string, def int) (int, error) {
+	for key := range params {
+		if key != name {
+			return 0, fmt.Errorf("Unit kind %s has no parameter named %s", kind, key)
+		}
+	}
+	if value, ok := params[name]; !ok {
+		return def, nil
+	} else if value <= 0 {
+		return 0, fmt.Errorf("Parameter %s of unit kind %s must be greater than zero", name, kind)
+	} else {
+		return value, nil
+	}
+}
+func noParams(kind string, params map[string]int) error {
+	for key := range params {
+		return fmt.Errorf("Unit kind %s has no parameter named %s", kind, key)
+	}
+	return nil
+}
+
+var unitKinds = map[string]componentFactory{
+	"alu": func(params map[string]int) (*component, error) {
+		if err := noParams("alu", params); err != nil {
+			return nil, err
+		}
+		alu := NewAlu()
+		return &component{unit: alu, errors: alu.Error, ports: map[string]*port{
+			"operation": input(alu.Operation),
+			"source0":   input(alu.Source0),
+			"source1":   input(alu.Source1),
+			"result":    output(alu.Result),
+		}}, nil
+	},
+	"fpu": func(params map[string]int) (*component, error) {
+		if err := noParams("fpu", params); err != nil {
+			return nil, err
+		}
+		op, src0, src1 := make(chan Word), make(chan float64), make(chan float64)
+		fpu := NewFpu(op, src0, src1)
+		return &component{unit: fpu, errors: fpu.Error, ports: map[string]*port{
+			"operation": input(op),
+			"source0":   input(src0),
+			"source1":   input(src1),
+			"result":    output(fpu.Result),
+		}}, nil
+	},
+	"cond": func(params map[string]int) (*component, error) {
+		if err := noParams("cond", params); err != nil {
+			return nil, err
+		}
+		op, src0, src1 := make(chan Word), make(chan Word), make(chan Word)
+		cond := NewCondUnit(op, src0, src1)
+		return &component{unit: cond, errors: cond.Error, ports: map[string]*port{
+			"operation": input(op),
+			"source0":   input(src0),
+			"source1":   input(src1),
+			"result":    output(cond.Result),
+		}}, nil
+	},
+	"branch": func(params map[string]int) (*component, error) {
+		if err := noParams("branch", params); err != nil {
+			return nil, err
+		}
+		bu := NewBranchUnit()
+		return &component{unit: bu, ports: map[string]*port{
+			"condition": input(bu.Condition),
+			"ontrue":    input(bu.OnTrue),
+			"onfalse":   input(bu.OnFalse),
+			"result":    output(bu.Result),
+		}}, nil
+	},
+	"mux": func(params map[string]int) (*component, error) {
+		count, err := param("mux", params, "sources", 2)
+		if err != nil {
+			return nil, err
+		}
+		mux := NewMux()
+		c := &component{unit: mux, errors: mux.Error, ports: map[string]*port{
+			"select":      input(mux.Select),
+			"destination": output(mux.Destination),
+		}}
+		for i := 0; i < count; i++ {
+			src := make(chan interface{})
+			mux.AddSource(src)
+			c.ports[fmt.Sprintf("source%d", i)] = input(src)
+		}
+		return c, nil
+	},
+	"demux": func(params map[string]int) (*component, error) {
+		count, err := param("demux", params, "destinations", 2)
+		if err != nil {
+			return nil, err
+		}
+		demux := NewDemux()
+		c := &component{unit: demux, errors: demux.Error, ports: map[string]*port{
+			"select": input(demux.Select),
+			"source": input(demux.Source),
+		}}
+		for i := 0; i < count; i++ {
+			dest := make(chan interface{})
+			demux.AddDestination(dest)
+			c.ports[fmt.Sprintf("destination%d", i)] = output(dest)
+		}
+		return c, nil
+	},
+	"decoder": func(params map[string]int) (*component, error) {
+		if err := noParams("decoder", params); err != nil {
+			return nil, err
+		}
+		dc := NewDecoderUnit()
+		return &component{unit: dc, errors: dc.Error, ports: map[string]*port{
+			"input":  input(dc.Input),
+			"result": output(dc.Result),
+		}}, nil
+	},
+	"regfile": func(params map[string]int) (*component, error) {
+		if err := noParams("regfile", params); err != nil {
+			return nil, err
+		}
+		op := make(chan byte)
+		rf := newRegisterFile(op)
+		return &component{unit: rf, errors: rf.Error, ports: map[string]*port{
+			"operation": input(op),
+			"index":     input(rf.Index),
+			"value":     input(rf.Value),
+			"result":    output(rf.Result),
+		}}, nil
+	},
+	"core": func(params map[string]int) (*component, error) {
+		if err := noParams("core", params); err != nil {
+			return nil, err
+		}
+		cp := &corePorts{}
+		c := &component{unit: cp, ports: make(map[string]*port)}
+		for _, u := range []struct {
+			ports  *unitPorts
+			inputs [3]string
+			result string
+		}{
+			{&cp.alu, [3]string{"aluop", "alu0", "alu1"}, "aluresult"},
+			{&cp.cond, [3]string{"condop", "cond0", "cond1"}, "condresult"},
+			{&cp.branch, [3]string{"condition", "ontrue", "onfalse"}, "target"},
+		} {
+			for i, name := range u.inputs {
+				ch := make(chan Word)
+				u.ports.inputs[i] = ch
+				c.ports[name] = output(ch)
+			}
+			result := make(chan Word)
+			u.ports.result = result
+			c.ports[u.result] = input(result)
+		}
+		return c, nil
+	},
+}
+
+func GetUnitKinds() []string {
+	var kinds []string
+	for kind := range unitKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+type Netlist struct {
+	components map[string]*component
+	// in the order they were added so that startup is deterministic
+	order      []*component
+	nets       []*port
+	supervisor *supervisor.Supervisor
+	// set when the nets are being traced
+	recorder *trace.Recorder
+}
+
+func NewNetlist() *Netlist {
+	var nl Netlist
+	nl.components = make(map[string]*component)
+	nl.supervisor = supervisor.New()
+	return &nl
+}
+
+func (this *Netlist) AddUnit(name, kind string, params map[string]int) error {
+	if this.Running() {
+		return fmt.Errorf("Can't add unit %s to a running netlist", name)
+	} else if name == "" || strings.ContainsAny(name, ". \t,") {
+		return fmt.Errorf("Illegal unit name %q", name)
+	} else if _, ok := this.components[name]; ok {
+		return fmt.Errorf("Unit %s is already defined", name)
+	} else if factory, ok := unitKinds[kind]; !ok {
+		return fmt.Errorf("Unknown unit kind %s", kind)
+	} else {
+		if c, err := factory(params); err != nil {
+			return err
+		} else {
+			c.name, c.kind = name, kind
+			for pname, p := range c.ports {
+				p.path = name + "." + pname
+			}
+			this.components[name] = c
+			this.order = append(this.order, c)
+			return nil
+		}
+	}
+}
+
+func (this *Netlist) lookupPort(path string) (*port, error) {
+	if dot := strings.Index(path, "."); dot == -1 {
+		return nil, fmt.Errorf("Port %s must be of the form unit.port", path)
+	} else if c, ok := this.components[path[:dot]]; !ok {
+		return nil, fmt.Errorf("Unit %s is not defined", path[:dot])
+	} else if p, ok := c.ports[path[dot+1:]]; !ok {
+		return nil, fmt.Errorf("Unit %s (%s) has no port named %s", c.name, c.kind, path[dot+1:])
+	} else {
+		return p, nil
+	}
+}
+
+// An output carrying interface{} values can drive any input, the dynamic type
+// is checked as values flow through the net
+func compatible(from, to reflect.Type) bool {
+	return from.AssignableTo(to) || from.Kind() == reflect.Interface
+}
+
+// Connect an output port to one or more input ports
+func (this *Netlist) Connect(from string, to ...string) error {
+	if this.Running() {
+		return fmt.Errorf("Can't change the wiring of a running netlist")
+	}
+	driver, err := this.lookupPort(from)
+	if err != nil {
+		return err
+	} else if driver.direction != PortOutput {
+		return fmt.Errorf("%s is an input and can't drive a net", from)
+	} else if len(driver.sinks)+len(to) > MaxFanOut {
+		return fmt.Errorf("%s would drive %d inputs, the limit is %d", from, len(driver.sinks)+len(to), MaxFanOut)
+	}
+	var sinks []*port
+	for _, path := range to {
+		if sink, err := this.lookupPort(path); err != nil {
+			return err
+		} else if sink.direction != PortInput {
+			return fmt.Errorf("%s is an output and can't be driven by %s", path, from)
+		} else if sink.driver != nil {
+			return fmt.Errorf("%s is already driven by %s", path, sink.driver.path)
+		} else if !compatible(driver.elem(), sink.elem()) {
+			return fmt.Errorf("Can't connect %s (%s) to %s (%s)", from, driver.elem(), path, sink.elem())
+		} else {
+			for _, s := range sinks {
+				if s == sink {
+					return fmt.Errorf("%s is listed more than once", path)
+				}
+			}
+			sinks = append(sinks, sink)
+		}
+	}
+	if len(driver.sinks) == 0 {
+		this.nets = append(this.nets, driver)
+	}
+	for _, sink := range sinks {
+		sink.driver = driver
+	}
+	driver.sinks = append(driver.sinks, sinks...)
+	return nil
+}
+
+// Get the unit with the given name, the result has to be type asserted to
+// the unit's type (e.g. *Alu)
+func (this *Netlist) Unit(name string) (interface{}, error) {
+	if c, ok := this.components[name]; !ok {
+		return nil, fmt.Errorf("Unit %s is not defined", name)
+	} else {
+		return c.unit, nil
+	}
+}
+
+// Get the sending end (chan<- T) of an input port which isn't driven by a net
+func (this *Netlist) Input(path string) (interface{}, error) {
+	if p, err := this.lookupPort(path); err != nil {
+		return nil, err
+	} else if p.direction != PortInput {
+		return nil, fmt.Errorf("%s is not an input", path)
+	} else if p.driver != nil {
+		return nil, fmt.Errorf("%s is already driven by %s", path, p.driver.path)
+	} else {
+		return p.channel.Interface(), nil
+	}
+}
+
+// Get the receiving end (<-chan T) of an output port which doesn't drive a net
+func (this *Netlist) Output(path string) (interface{}, error) {
+	if p, err := this.lookupPort(path); err != nil {
+		return nil, err
+	} else if p.direction != PortOutput {
+		return nil, fmt.Errorf("%s is not an output", path)
+	} else if len(p.sinks) != 0 {
+		return nil, fmt.Errorf("%s already drives a net", path)
+	} else {
+		return p.channel.Interface(), nil
+	}
+}
+
+func (this *Netlist) wire(ctx context.Context, driver *port) error {
+	done := reflect.ValueOf(ctx.Done())
+	receive := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: done},
+		{Dir: reflect.SelectRecv, Chan: driver.channel},
+	}
+	for {
+		chosen, value, more := reflect.Select(receive)
+		if chosen == 0 || !more {
+			return nil
+		}
+		if this.recorder != nil {
+			this.recorder.Record(driver.path, traceValue(value))
+		}
+		for _, sink := range driver.sinks {
+			v := value
+			if v.Kind() == reflect.Interface && sink.elem().Kind() != reflect.Interface {
+				v = v.Elem()
+			}
+			if !v.IsValid() || !v.Type().AssignableTo(sink.elem()) {
+				return fmt.Errorf("Net %s can't deliver %v to %s (%s)", driver.path, value, sink.path, sink.elem())
+			}
+			send := []reflect.SelectCase{
+				{Dir: reflect.SelectRecv, Chan: done},
+				{Dir: reflect.SelectSend, Chan: sink.channel, Send: v},
+			}
+			if chosen, _, _ := reflect.Select(send); chosen == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// Start every unit and net under a supervisor, errors reported by the units
+// are collected and returned by Stop
+func (this *Netlist) Start() error {
+	if this.Running() {
+		return fmt.Errorf("Netlist is already running")
+	}
+	for _, c := range this.order {
+		if err := this.supervisor.Add(c.name, c.unit); err != nil {
+			return err
+		} else if c.errors != nil {
+			if err := this.supervisor.Watch(c.name, c.errors); err != nil {
+				return err
+			}
+		}
+	}
+	for _, driver := range this.nets {
+		driver := driver
+		if err := this.supervisor.Add("net "+driver.path, supervisor.Func(func(ctx context.Context) error {
+			return this.wire(ctx, driver)
+		})); err != nil {
+			return err
+		}
+	}
+	return this.supervisor.Start(context.Background())
+}
+
+func (this *Netlist) Running() bool {
+	return this.supervisor.Running()
+}
+
+// Closed once the netlist is stopped or one of its nets fails, nil if the
+// netlist was never started
+func (this *Netlist) Done() <-chan struct{} {
+	return this.supervisor.Done()
+}
+
+// Errors reported so far by the units and nets
+func (this *Netlist) Errors() []error {
+	return this.supervisor.Errors()
+}
+
+// Stop every unit and net, a stopped netlist can't be restarted
+func (this *Netlist) Stop() error {
+	return this.supervisor.Shutdown()
+}
+
+func parseNetlistUnit(nl *Netlist, fields []string) error {
+	if len(fields) < 3 {
+		return fmt.Errorf("A unit needs a name and a kind")
+	}
+	params := make(map[string]int)
+	for _, field := range fields[3:] {
+		if eq := strings.Index(field, "="); eq == -1 {
+			return fmt.Errorf("Unit parameter %s must be of the form name=value", field)
+		} else if value, err := strconv.Atoi(field[eq+1:]); err != nil {
+			return fmt.Errorf("Unit parameter %s: %s", field[:eq], err)
+		} else {
+			params[field[:eq]] = value
+		}
+	}
+	return nl.AddUnit(fields[1], fields[2], params)
+}
+
+func parseNetlistWire(nl *Netlist, rest string) error {
+	if arrow := strings.Index(rest, "->"); arrow == -1 {
+		return fmt.Errorf("A wire needs a -> between the driver and its inputs")
+	} else {
+		var sinks []string
+		for _, sink := range strings.Split(rest[arrow+2:], ",") {
+			if sink = strings.TrimSpace(sink); sink == "" {
+				return fmt.Errorf("Empty input in wire")
+			} else {
+				sinks = append(sinks, sink)
+			}
+		}
+		return nl.Connect(strings.TrimSpace(rest[:arrow]), sinks...)
+	}
+}
+
+// Build a netlist from its text form
+func ParseNetlist(input io.Reader) (*Netlist, error) {
+	nl := NewNetlist()
+	scanner := bufio.NewScanner(input)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if hash := strings.Index(text, "#"); hash != -1 {
+			text = text[:hash]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		var err error
+		switch fields[0] {
+		case "unit":
+			err = parseNetlistUnit(nl, fields)
+		case "wire":
+			err = parseNetlistWire(nl, strings.TrimPrefix(strings.TrimSpace(text), "wire"))
+		default:
+			err = fmt.Errorf("Unknown statement %s", fields[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %s", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nl, nil
+}
@@ -0,0 +1,223 @@
+package iris2
+
+import (
+	"github.com/DrItanium/cores/registration/parser"
+	"github.com/DrItanium/cores/supervisor/leaktest"
+	"github.com/DrItanium/cores/trace"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testNetlist = `
+# compare two numbers and pick between two values with both branch units
+unit alu alu
+unit pick0 branch
+unit pick1 branch
+wire alu.result -> pick0.condition, pick1.condition
+`
+
+func Test_NetlistFanOut(t *testing.T) {
+	defer leaktest.Check(t)()
+	nl, err := ParseNetlist(strings.NewReader(testNetlist))
+	if err != nil {
+		t.Fatalf("Couldn't parse netlist: %s", err)
+	} else if err := nl.Start(); err != nil {
+		t.Fatalf("Couldn't start netlist: %s", err)
+	}
+	defer nl.Stop()
+	inputs := make(map[string]chan<- Word)
+	for _, path := range []string{"alu.operation", "alu.source0", "alu.source1", "pick0.ontrue", "pick0.onfalse", "pick1.ontrue", "pick1.onfalse"} {
+		if ch, err := nl.Input(path); err != nil {
+			t.Fatalf("Couldn't get input %s: %s", path, err)
+		} else {
+			inputs[path] = ch.(chan<- Word)
+		}
+	}
+	go func() {
+		inputs["alu.operation"] <- IntegerSubtract
+		inputs["alu.source0"] <- 3
+		inputs["alu.source1"] <- 3
+		inputs["pick0.ontrue"] <- 10
+		inputs["pick0.onfalse"] <- 20
+		inputs["pick1.ontrue"] <- 30
+		inputs["pick1.onfalse"] <- 40
+	}()
+	for name, expected := range map[string]Word{"pick0.result": 20, "pick1.result": 40} {
+		if ch, err := nl.Output(name); err != nil {
+			t.Errorf("Couldn't get output %s: %s", name, err)
+		} else if got := <-ch.(<-chan Word); got != expected {
+			t.Errorf("Expected %s to be %d but got %d", name, expected, got)
+		}
+	}
+}
+
+func Test_NetlistValidation(t *testing.T) {
+	for _, text := range []string{
+		"unit alu alu\nunit alu alu",
+		"unit a alu\nunit d decoder\nwire d.result -> a.source0",
+		"unit a alu\nunit b alu\nwire a.result -> b.source0\nwire b.result -> b.source0",
+		"unit a alu\nwire a.source0 -> a.source1",
+		"unit m mux sources=0",
+		"unit m mux width=2",
+		"unit x flux",
+	} {
+		if _, err := ParseNetlist(strings.NewReader(text)); err == nil {
+			t.Errorf("Netlist %q should have been rejected", text)
+		}
+	}
+}
+
+func Test_NetlistTrace(t *testing.T) {
+	defer leaktest.Check(t)()
+	nl, err := ParseNetlist(strings.NewReader("unit alu alu\nunit pick branch\nwire alu.result -> pick.condition\n"))
+	if err != nil {
+		t.Fatalf("Couldn't parse netlist: %s", err)
+	}
+	g := nl.Graph("test")
+	if len(g.Nodes) != 2 || g.Nodes[0].Kind != "alu" || len(g.Edges) != 1 || g.Edges[0] != (trace.Edge{From: "alu.result", To: "pick.condition"}) {
+		t.Errorf("Unexpected graph %+v", g)
+	}
+	recorder := trace.NewRecorder()
+	if err := nl.Trace(recorder); err != nil {
+		t.Fatalf("Couldn't trace netlist: %s", err)
+	} else if err := nl.Start(); err != nil {
+		t.Fatalf("Couldn't start netlist: %s", err)
+	} else if err := nl.Trace(trace.NewRecorder()); err == nil {
+		t.Errorf("A running netlist can't be traced")
+	}
+	inputs := make(map[string]chan<- Word)
+	for _, path := range []string{"alu.operation", "alu.source0", "alu.source1", "pick.ontrue", "pick.onfalse"} {
+		ch, _ := nl.Input(path)
+		inputs[path] = ch.(chan<- Word)
+	}
+	result, _ := nl.Output("pick.result")
+	// the second subtraction can only start once the first one went through
+	// the net so the values have to be recorded in order
+	for _, sources := range [][2]Word{{3, 3}, {5, 3}, {3, 5}} {
+		go func(a, b Word) {
+			inputs["alu.operation"] <- IntegerSubtract
+			inputs["alu.source0"] <- a
+			inputs["alu.source1"] <- b
+			inputs["pick.ontrue"] <- 1
+			inputs["pick.onfalse"] <- 0
+		}(sources[0], sources[1])
+		<-result.(<-chan Word)
+	}
+	if err := nl.Stop(); err != nil {
+		t.Errorf("Netlist failed: %s", err)
+	}
+	if got := recorder.Values("alu.result"); !reflect.DeepEqual(got, []uint64{0, 2, ^uint64(1)}) {
+		t.Errorf("alu.result recorded %v", got)
+	} else if recorder.Len() != 3 {
+		t.Errorf("Only the net should be recorded but %d values were", recorder.Len())
+	}
+}
+
+func Test_DatapathChangesExecution(t *testing.T) {
+	// the operands of the alu are crossed so sub computes b - a
+	crossed := strings.NewReplacer("core.alu0 -> alu.source0", "core.alu0 -> alu.source1", "core.alu1 -> alu.source1", "core.alu1 -> alu.source0").Replace(DefaultDatapath)
+	for _, test := range []struct {
+		datapath string
+		expected Word
+	}{{DefaultDatapath, 2}, {crossed, -2}} {
+		datapath, err := ParseNetlist(strings.NewReader(test.datapath))
+		if err != nil {
+			t.Fatalf("Couldn't parse datapath: %s", err)
+		}
+		core, err := NewWithDatapath(datapath)
+		if err != nil {
+			t.Fatalf("Couldn't create core %s", err)
+		} else if err := core.Startup(); err != nil {
+			t.Fatalf("Couldn't start core %s", err)
+		}
+		core.SetRegister(UserRegisterBegin, 5)
+		core.SetRegister(UserRegisterBegin+1, 3)
+		if di, err := NewDecodedInstructionArithmetic(ArithmeticOpSub, UserRegisterBegin+2, UserRegisterBegin, UserRegisterBegin+1); err != nil {
+			t.Errorf("Couldn't construct arithmetic instruction: %s", err)
+		} else if err := core.Invoke(di); err != nil {
+			t.Errorf("Execution failed: %s", err)
+		} else if result := core.Register(UserRegisterBegin + 2); result != test.expected {
+			t.Errorf("Expected 5 - 3 to be %d but got %d", test.expected, result)
+		}
+		if err := core.Shutdown(); err != nil {
+			t.Errorf("Couldn't shut down core %s", err)
+		}
+	}
+	for _, text := range []string{
+		"unit gpr regfile",
+		"unit gpr regfile\nunit core alu",
+		strings.Replace(DefaultDatapath, "wire branch.result -> core.target", "", 1),
+	} {
+		if datapath, err := ParseNetlist(strings.NewReader(text)); err != nil {
+			t.Errorf("Couldn't parse datapath %q: %s", text, err)
+		} else if _, err := NewWithDatapath(datapath); err == nil {
+			t.Errorf("Datapath %q should have been rejected", text)
+		}
+	}
+}
+
+// count to three, every add, compare and conditional jump goes through a net
+const countdown = `
+set r10 = #0
+set r12 = #3
+loop:
+incr r10 = r10
+lt r13 = r10, r12
+branch loop if r13
+system #0, r0, r0
+`
+
+func Test_CoreTrace(t *testing.T) {
+	defer leaktest.Check(t)()
+	p, err := generateParser()
+	if err != nil {
+		t.Fatalf("Couldn't create parser %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(countdown), "\n")
+	entries := make(chan parser.Entry, len(lines))
+	for i, line := range lines {
+		entries <- parser.Entry{Line: line, Index: i + 1}
+	}
+	close(entries)
+	if err := p.Parse(entries); err != nil {
+		t.Fatalf("Couldn't parse program %s", err)
+	} else if err := p.Process(); err != nil {
+		t.Fatalf("Couldn't assemble program %s", err)
+	}
+	core := p.(*_parser).core
+	var dot strings.Builder
+	if err := core.WriteDot(&dot); err != nil {
+		t.Fatalf("Couldn't write the datapath %s", err)
+	} else if !strings.Contains(dot.String(), "core") || strings.Count(dot.String(), "->") != 12 {
+		t.Errorf("The datapath should have the core and twelve nets:\n%s", dot.String())
+	}
+	recorder := trace.NewRecorder()
+	if err := core.Trace(recorder); err != nil {
+		t.Fatalf("Couldn't trace core %s", err)
+	} else if err := core.Startup(); err != nil {
+		t.Fatalf("Couldn't start core %s", err)
+	} else if err := core.Run(); err != nil {
+		t.Fatalf("Execution failed: %s", err)
+	} else if err := core.Shutdown(); err != nil {
+		t.Fatalf("Couldn't shut down core %s", err)
+	}
+	for signal, expected := range map[string][]uint64{
+		"core.aluop":     {IntegerAdd, IntegerAdd, IntegerAdd},
+		"core.alu0":      {0, 1, 2},
+		"core.alu1":      {1, 1, 1},
+		"alu.result":     {1, 2, 3},
+		"core.condop":    {LessThan, LessThan, LessThan},
+		"core.cond0":     {1, 2, 3},
+		"core.cond1":     {3, 3, 3},
+		"cond.result":    {1, 1, 0},
+		"core.condition": {1, 1, 0},
+		"core.ontrue":    {2, 2, 2},
+		"core.onfalse":   {5, 5, 5},
+		"branch.result":  {2, 2, 5},
+	} {
+		if got := recorder.Values(signal); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s recorded %v instead of %v", signal, got, expected)
+		}
+	}
+}
@@ -117,18 +117,30 @@ type node struct {
 	Type  nodeType
 }
 
+// hex and binary immediates are bit patterns so they can fill a whole word,
+// decimal immediates can be negative
 func parseHexImmediate(str string) (Word, error) {
-	val, err := strconv.ParseUint(str, 16, 16)
+	val, err := strconv.ParseUint(str, 16, 64)
 	return Word(val), err
 }
 func parseBinaryImmediate(str string) (Word, error) {
-	val, err := strconv.ParseUint(str, 2, 16)
+	val, err := strconv.ParseUint(str, 2, 64)
 	return Word(val), err
 }
 func parseDecimalImmediate(str string) (Word, error) {
-	val, err := strconv.ParseUint(str, 10, 16)
+	val, err := strconv.ParseInt(str, 10, 64)
 	return Word(val), err
 }
+
+// instructions only have room for a signed 32-bit immediate, larger values
+// need to go through the data segment
+func checkImmediate(value Word) error {
+	if value < ImmediateMin || value > ImmediateMax {
+		return fmt.Errorf("Immediate %d does not fit into a signed 32-bit immediate", value)
+	} else {
+		return nil
+	}
+}
 func parseRegisterValue(str string) (byte, error) {
 	val, err := strconv.ParseUint(str, 10, 8)
 	return byte(val), err
@@ -148,7 +160,7 @@ func (this *node) parseLabel(val string) error {
 	nVal := strings.TrimSuffix(val, ":")
 	q, _ := utf8.DecodeRuneInString(nVal)
 	if !unicode.IsLetter(q) {
-		return fmt.Errorf("Label %s starts with a non letter %s!", nVal, q)
+		return fmt.Errorf("Label %s starts with a non letter %c!", nVal, q)
 	} else {
 		this.Type = typeLabel
 		this.Value = nVal
@@ -262,6 +274,7 @@ var keywords = map[string]nodeType{
 	"ge":         keywordGreaterThanOrEqualTo,
 	"push":       keywordPush,
 	"pop":        keywordPop,
+	"peek":       keywordPeek,
 }
 
 func (this *node) parseGeneric(str string) error {
@@ -333,7 +346,7 @@ func (this *statement) AddUnknown(value string) {
 func (this *statement) String() string {
 	str := fmt.Sprintf("%d: ", this.index)
 	for _, n := range this.contents {
-		str += fmt.Sprintf(" %T: %s ", n, *n)
+		str += fmt.Sprintf(" %T: %v ", n, *n)
 	}
 	return str
 }
@@ -509,13 +522,13 @@ func (this *_parser) setData(nodes []*node) error {
 			val := addr.Value.(Word)
 			switch this.currSegment {
 			case dataSegment:
-				this.core.data[t] = val
+				this.core.SetDataMemory(t, val)
 			case microcodeSegment:
-				this.core.ucode[t] = val
+				this.core.SetMicrocodeMemory(t, val)
 			case callSegment:
-				this.core.call[t] = val
+				this.core.SetCallMemory(t, val)
 			case stackSegment:
-				this.core.stack[t] = val
+				this.core.SetStackMemory(t, val)
 			}
 		case typeLabel:
 			this.indirectAddresses = append(this.indirectAddresses, indirectAddress{label: addr.Value.(string), seg: this.currSegment, address: this.addrs[this.currSegment]})
@@ -561,7 +574,7 @@ func (this *_parser) installInstruction(inst *Instruction) error {
 	if this.currSegment != codeSegment {
 		return fmt.Errorf("Must install instructions to the code segment")
 	} else {
-		this.core.code[this.addrs[this.currSegment]] = *inst
+		this.core.SetCodeMemory(this.addrs[this.currSegment], *inst)
 		this.addrs[this.currSegment]++
 		return nil
 	}
@@ -620,7 +633,7 @@ func (this *_parser) Process() error {
 			q := d.inst
 			q.SetImmediate(v)
 			z := q.Encode()
-			this.core.code[d.addr] = *z
+			this.core.SetCodeMemory(d.addr, *z)
 		}
 	}
 	for _, ind := range this.indirectAddresses {
@@ -629,13 +642,13 @@ func (this *_parser) Process() error {
 		} else {
 			switch ind.seg {
 			case dataSegment:
-				this.core.data[ind.address] = v
+				this.core.SetDataMemory(ind.address, v)
 			case microcodeSegment:
-				this.core.ucode[ind.address] = v
+				this.core.SetMicrocodeMemory(ind.address, v)
 			case callSegment:
-				this.core.call[ind.address] = v
+				this.core.SetCallMemory(ind.address, v)
 			case stackSegment:
-				this.core.stack[ind.address] = v
+				this.core.SetStackMemory(ind.address, v)
 			default:
 				return fmt.Errorf("Can't store words to the current segment!")
 			}
@@ -669,9 +682,9 @@ func (this *_parser) parseStatement(stmt *statement) error {
 		} else {
 			return nil
 		}
-	case keywordAdd, keywordSub, keywordMul, keywordDiv, keywordRem, keywordShiftLeft, keywordShiftRight, keywordAnd, keywordOr, keywordNot, keywordXor, keywordIncrement, keywordDecrement, keywordHalve:
+	case keywordAdd, keywordSub, keywordMul, keywordDiv, keywordRem, keywordShiftLeft, keywordShiftRight, keywordAnd, keywordOr, keywordNot, keywordXor, keywordIncrement, keywordDecrement, keywordHalve, keywordDouble:
 		return this.parseArithmetic(first, rest)
-	case keywordMove, keywordSet, keywordSwap, keywordLoad, keywordStore, keywordPop, keywordPush:
+	case keywordMove, keywordSet, keywordSwap, keywordLoad, keywordStore, keywordPop, keywordPush, keywordPeek:
 		return this.parseMove(first, rest)
 	case keywordEqual, keywordNotEqual, keywordLessThan, keywordGreaterThan, keywordLessThanOrEqualTo, keywordGreaterThanOrEqualTo:
 		return this.parseCompare(first, rest)
@@ -705,7 +718,6 @@ func (this *_parser) parseStatement(stmt *statement) error {
 	default:
 		return fmt.Errorf("Unhandled nodeType %d: %s", first.Type, first.Value)
 	}
-	return nil
 }
 
 var segments = map[string]segment{
@@ -800,6 +812,9 @@ func (this *_parser) parseMove(first *node, rest []*node) error {
 				}
 			} else {
 				if src.Type.immediate() {
+					if err := checkImmediate(src.Value.(Word)); err != nil {
+						return err
+					}
 					d.SetImmediate(src.Value.(Word))
 				} else if src.Type == typeId {
 					if v, err := this.resolveLabel(src.Value.(string)); err != nil {
@@ -964,7 +979,7 @@ func (this *_parser) parseSystem(d *DecodedInstruction, rest []*node) error {
 			return err
 		} else if !rest[3].Type.isComma() {
 			return fmt.Errorf("second and third arguments in a system operation must be separated by a comma")
-		} else if sv1 := rest[2]; !sv1.Type.registerOrAlias() {
+		} else if sv1 := rest[4]; !sv1.Type.registerOrAlias() {
 			return fmt.Errorf("Third argument in system operation must be a register or alias")
 		} else if s1, err := this.resolveRegister(sv1); err != nil {
 			return err
@@ -1051,7 +1066,7 @@ func (this *_parser) parseArithmetic(t *node, nodes []*node) error {
 		} else if nodes[3].Type != typeComma {
 			return fmt.Errorf("The source operands of an arithmetic instruction must be separated by a comma")
 		} else if src1 := nodes[4]; !src1.Type.registerOrAlias() && !src1.Type.immediate() {
-			return fmt.Errorf("The second source operand must be a register, alias, or 8-bit immediate")
+			return fmt.Errorf("The second source operand must be a register, alias, or 32-bit immediate")
 		} else {
 			if dv, err := this.resolveRegister(dest); err != nil {
 				return err
@@ -1062,10 +1077,10 @@ func (this *_parser) parseArithmetic(t *node, nodes []*node) error {
 			}
 			if src1.Type.immediate() {
 				// immediate form
-				if immediate := src1.Value.(Word); immediate > 255 {
-					return fmt.Errorf("Immediate value for arithmetic operation is too large: %d > 255", immediate)
+				if err := checkImmediate(src1.Value.(Word)); err != nil {
+					return err
 				} else {
-					inst.Data[2] = byte(immediate)
+					inst.SetImmediate(src1.Value.(Word))
 				}
 				switch t.Type {
 				case keywordAdd:
@@ -1228,6 +1243,9 @@ func (this *_parser) parseJump(first *node, rest []*node) error {
 						d.Data[1] = q
 					}
 				} else if dest.Type.immediate() {
+					if err := checkImmediate(dest.Value.(Word)); err != nil {
+						return err
+					}
 					d.SetImmediate(dest.Value.(Word))
 				} else if dest.Type == typeId {
 					// check for label and defer if necessary
@@ -1261,6 +1279,9 @@ func (this *_parser) parseJump(first *node, rest []*node) error {
 						d.Data[0] = q
 					}
 				} else if dest.Type.immediate() {
+					if err := checkImmediate(dest.Value.(Word)); err != nil {
+						return err
+					}
 					d.SetImmediate(dest.Value.(Word))
 				} else {
 					if v, err := this.resolveLabel(dest.Value.(string)); err != nil {
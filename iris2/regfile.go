@@ -1,7 +1,11 @@
 // register file execution unit
 package iris2
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"github.com/DrItanium/cores/supervisor"
+)
 
 type registerFile struct {
 	// internal registers that should be easy to find
@@ -17,14 +21,12 @@ type registerFile struct {
 	value chan interface{}
 
 	Error     <-chan error
-	Control   <-chan Word
 	Index     chan<- byte
 	Value     chan<- interface{}
 	Result    <-chan Word
 	Operation <-chan byte
 
-	running bool
-	temp    Word // temporary storage for internal swap operations
+	temp Word // temporary storage for internal swap operations
 }
 
 const (
@@ -45,7 +47,7 @@ const (
 	registerFileOpCount
 )
 
-func newRegisterFile(control <-chan Word, op <-chan byte) *registerFile {
+func newRegisterFile(op <-chan byte) *registerFile {
 	var this registerFile
 	this.err = make(chan error)
 	this.out = make(chan Word)
@@ -53,7 +55,6 @@ func newRegisterFile(control <-chan Word, op <-chan byte) *registerFile {
 	this.value = make(chan interface{})
 
 	this.Error = this.err
-	this.Control = control
 	this.Operation = op
 	this.Result = this.out
 	this.Index = this.index
@@ -121,74 +122,50 @@ func (this *registerFile) moveRegister(a, b byte) error {
 		return nil
 	}
 }
-func (this *registerFile) Startup() error {
-	if this.running {
-		return fmt.Errorf("This register file is already running!")
-	} else {
-		this.running = true
-		go this.body()
-		go this.controlQuery()
-		return nil
-	}
-}
-func (this *registerFile) body() {
-	for this.running {
-		select {
-		case op := <-this.Operation:
-			if op >= registerFileOpCount {
+
+// Serve register file operations sent through the channels, every operation
+// is an index followed by a value (ignored by get)
+func (this *registerFile) Run(ctx context.Context) error {
+	for {
+		op, ok := supervisor.Receive(ctx, this.Operation)
+		if !ok {
+			return nil
+		}
+		arg0, ok := supervisor.Receive(ctx, this.index)
+		if !ok {
+			return nil
+		}
+		arg1, ok := supervisor.Receive(ctx, this.value)
+		if !ok {
+			return nil
+		}
+		var err error
+		switch op {
+		case registerFileGet:
+			supervisor.Send(ctx, this.out, this.getRegister(arg0))
+		case registerFileSet:
+			if value, ok := arg1.(Word); !ok {
+				err = fmt.Errorf("Only words are allowed in a register file set operation!")
 			} else {
-				arg0 := <-this.index
-				arg1 := <-this.value
-				switch op {
-				case registerFileGet:
-					this.out <- this.getRegister(arg0)
-				case registerFileSet:
-					switch arg1.(type) {
-					case Word:
-						if err := this.setRegister(arg1.(Word)); err != nil {
-							this.err <- err
-						}
-					default:
-						this.err <- fmt.Errorf("Only words are allowed in a register file set operation!")
-					}
-				case registerFileSwap:
-					switch arg1.(type) {
-					case byte:
-						if err := this.swapRegisters(arg0, arg1.(byte)); err != nil {
-							this.err <- err
-						}
-					default:
-						this.err <- fmt.Errorf("Only bytes are allowed in a register file swap operation!")
-					}
-				case registerFileMove:
-					switch arg1.(type) {
-					case byte:
-						if err := this.moveRegister(arg0, arg1.(byte)); err != nil {
-							this.err <- err
-						}
-					default:
-						this.err <- fmt.Errorf("Only bytes are allowed in a register file move operation!")
-					}
-				default:
-					this.err <- fmt.Errorf("Illegal register file operation %d", op)
-				}
+				err = this.setRegister(arg0, value)
 			}
+		case registerFileSwap:
+			if other, ok := arg1.(byte); !ok {
+				err = fmt.Errorf("Only bytes are allowed in a register file swap operation!")
+			} else {
+				err = this.swapRegisters(arg0, other)
+			}
+		case registerFileMove:
+			if other, ok := arg1.(byte); !ok {
+				err = fmt.Errorf("Only bytes are allowed in a register file move operation!")
+			} else {
+				err = this.moveRegister(arg0, other)
+			}
+		default:
+			err = fmt.Errorf("Illegal register file operation %d", op)
+		}
+		if err != nil {
+			supervisor.Send(ctx, this.err, err)
 		}
-	}
-}
-
-func (this *registerFile) controlQuery() {
-	<-this.Control
-	if err := this.shutdown(); err != nil {
-		this.err <- err
-	}
-}
-
-func (this *registerFile) shutdown() error {
-	if !this.running {
-		return fmt.Errorf("Can't shutdown a register file that isn't running!")
-	} else {
-		this.running = false
-		return nil
 	}
 }
@@ -2,6 +2,8 @@ package iris2
 
 import (
 	"fmt"
+	"io"
+	"os"
 )
 
 const (
@@ -9,6 +11,10 @@ const (
 	SystemCallTerminate = iota
 	SystemCallPanic
 	SystemCallPutc
+	SystemCallGetMmuRegister
+	SystemCallSetMmuRegister
+	SystemCallFlushTlb
+	SystemCallReturnFromFault
 	NumberOfSystemCalls
 )
 
@@ -32,9 +38,21 @@ func putcSystemCall(core *Core, inst *DecodedInstruction) error {
 		// make a rune out of it
 		r = rune((0x0000FFFF & uint32(core.Register(lower))) | (0xFFFF0000 & (uint32(core.Register(upper)) << 16)))
 	}
-	fmt.Printf("%c", r)
+	fmt.Fprintf(core.Output(), "%c", r)
 	return nil
 }
+
+// Set where the putc system call writes to, defaults to stdout
+func (this *Core) SetOutput(w io.Writer) {
+	this.output = w
+}
+func (this *Core) Output() io.Writer {
+	if this.output == nil {
+		return os.Stdout
+	} else {
+		return this.output
+	}
+}
 func terminateSystemCall(core *Core, inst *DecodedInstruction) error {
 	core.terminateExecution = true
 	return nil
@@ -44,3 +62,22 @@ func panicSystemCall(core *Core, inst *DecodedInstruction) error {
 	// look at the data attached to the panic and encode it
 	return NewError(ErrorPanic, uint(inst.Immediate()))
 }
+
+// the mmu register index is held in the second register
+func getMmuRegisterSystemCall(core *Core, inst *DecodedInstruction) error {
+	if value, err := core.MmuRegister(core.Register(inst.Data[2])); err != nil {
+		return err
+	} else {
+		return core.SetRegister(inst.Data[1], value)
+	}
+}
+func setMmuRegisterSystemCall(core *Core, inst *DecodedInstruction) error {
+	return core.SetMmuRegister(core.Register(inst.Data[1]), core.Register(inst.Data[2]))
+}
+func flushTlbSystemCall(core *Core, inst *DecodedInstruction) error {
+	core.FlushTlb()
+	return nil
+}
+func returnFromFaultSystemCall(core *Core, inst *DecodedInstruction) error {
+	return core.ReturnFromFault()
+}
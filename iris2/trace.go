@@ -0,0 +1,113 @@
+package iris2
+
+import (
+	"fmt"
+	"github.com/DrItanium/cores/trace"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// The topology of the netlist, ports which aren't part of a net are still
+// shown so that the inputs and outputs left for the outside world are visible
+func (this *Netlist) Graph(name string) *trace.Graph {
+	g := &trace.Graph{Name: name}
+	for _, c := range this.order {
+		n := trace.Node{Name: c.name, Kind: c.kind}
+		for pname, p := range c.ports {
+			if p.direction == PortInput {
+				n.Inputs = append(n.Inputs, pname)
+			} else {
+				n.Outputs = append(n.Outputs, pname)
+			}
+		}
+		sort.Strings(n.Inputs)
+		sort.Strings(n.Outputs)
+		g.Nodes = append(g.Nodes, n)
+	}
+	for _, driver := range this.nets {
+		for _, sink := range driver.sinks {
+			g.Edges = append(g.Edges, trace.Edge{From: driver.path, To: sink.path})
+		}
+	}
+	return g
+}
+
+func (this *Netlist) WriteDot(w io.Writer, name string) error {
+	return this.Graph(name).WriteDot(w)
+}
+
+func traceWidth(t reflect.Type) int {
+	switch t.Kind() {
+	case reflect.Int8, reflect.Uint8, reflect.Int16, reflect.Uint16, reflect.Int32, reflect.Uint32, reflect.Int64, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return t.Bits()
+	case reflect.Bool:
+		return 1
+	default:
+		return 64
+	}
+}
+
+// Turn a value travelling through a net into something a waveform viewer can
+// show, floats are recorded as their bit patterns
+func traceValue(v reflect.Value) uint64 {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return 0
+		}
+		v = v.Elem()
+	}
+	switch value := v.Interface().(type) {
+	case *DecodedInstruction:
+		return uint64(*value.Encode())
+	case float64:
+		return math.Float64bits(value)
+	case float32:
+		return uint64(math.Float32bits(value))
+	case bool:
+		if value {
+			return 1
+		} else {
+			return 0
+		}
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	default:
+		return 0
+	}
+}
+
+// Record every value which travels through a net, the value is recorded
+// against the output port which drove it. Only nets are traced, ports used
+// through Input and Output are not.
+func (this *Netlist) Trace(recorder *trace.Recorder) error {
+	if this.Running() {
+		return fmt.Errorf("A netlist has to be traced before it is started")
+	}
+	for _, driver := range this.nets {
+		if err := recorder.Signal(driver.path, traceWidth(driver.elem())); err != nil {
+			return err
+		}
+	}
+	this.recorder = recorder
+	return nil
+}
+
+func (this *Core) Datapath() *Netlist {
+	return this.datapath
+}
+
+func (this *Core) WriteDot(w io.Writer) error {
+	return this.datapath.WriteDot(w, RegistrationName())
+}
+
+// Record every value sent between the core and the units of its datapath,
+// the core has to be traced before it is started
+func (this *Core) Trace(recorder *trace.Recorder) error {
+	return this.datapath.Trace(recorder)
+}
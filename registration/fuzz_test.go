@@ -0,0 +1,112 @@
+package registration
+
+import (
+	"bytes"
+	"github.com/DrItanium/cores"
+	"github.com/DrItanium/cores/registration/machine"
+	"github.com/DrItanium/cores/registration/parser"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// collect everything a dumper emits
+func dump(d cores.Dumper) ([]byte, error) {
+	out := make(chan byte, 4096)
+	done := make(chan []byte)
+	go func() {
+		var b []byte
+		for v := range out {
+			b = append(b, v)
+		}
+		done <- b
+	}()
+	err := d.Dump(out)
+	close(out)
+	return <-done, err
+}
+
+func registered(names []string) []string {
+	sort.Strings(names)
+	return names
+}
+
+// parsers which hold onto running hardware need to be stopped
+type shutdowner interface {
+	Shutdown() error
+}
+
+func FuzzParsers(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		".code\nstart:\n  set r6 = #10\n  branch start",
+		".data\n.org #x10\n.word #b1010\n.alias foo = r7\nincr ?foo",
+		"eq r6 = r7, r8\nif r6 then r7 else r8\ncall r7\nreturn\nsystem #1 r6 r7",
+		"label: #1 #2 label\nxand #1 #-1 label\n...",
+		"a:\nb:\n0 -1 a\n#x1 #b1 ;comment",
+		"#\n#x\n#b\nr\n:\n.\n?\n=\n,",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		lines := strings.Split(src, "\n")
+		for _, name := range registered(parser.GetRegistered()) {
+			p, err := parser.New(name)
+			if err != nil {
+				t.Fatalf("%s: couldn't construct parser: %s", name, err)
+			}
+			entries := make(chan parser.Entry, len(lines))
+			for i, line := range lines {
+				if line = strings.TrimSpace(line); len(line) > 0 {
+					entries <- parser.Entry{Line: line, Index: i + 1}
+				}
+			}
+			close(entries)
+			// errors are fine, panics are not
+			if err := p.Parse(entries); err == nil {
+				if err := p.Process(); err == nil {
+					dump(p)
+				}
+			}
+			if s, ok := p.(shutdowner); ok {
+				s.Shutdown()
+			}
+		}
+	})
+}
+
+func FuzzImageRoundTrip(f *testing.F) {
+	f.Add([]byte{0})
+	f.Add([]byte{0x11, 0x06, 0x41, 0x00, 0xff, 0xfe})
+	f.Fuzz(func(t *testing.T, pattern []byte) {
+		if len(pattern) == 0 {
+			return
+		}
+		for _, name := range registered(machine.GetRegistered()) {
+			m, err := machine.New(name)
+			if err != nil {
+				t.Fatalf("%s: couldn't construct machine: %s", name, err)
+			}
+			blank, err := dump(m)
+			if err != nil {
+				t.Fatalf("%s: couldn't dump an empty machine: %s", name, err)
+			}
+			// fill a whole image with the pattern
+			image := bytes.Repeat(pattern, len(blank)/len(pattern)+1)[:len(blank)]
+			in := make(chan byte, len(image))
+			for _, b := range image {
+				in <- b
+			}
+			close(in)
+			if err := m.InstallProgram(in); err != nil {
+				t.Fatalf("%s: couldn't install image: %s", name, err)
+			} else if out, err := dump(m); err != nil {
+				t.Fatalf("%s: couldn't dump image: %s", name, err)
+			} else if !bytes.Equal(image, out) {
+				t.Fatalf("%s: image did not survive an install and dump round trip", name)
+			} else if err := m.Shutdown(); err != nil {
+				t.Fatalf("%s: couldn't shut down: %s", name, err)
+			}
+		}
+	})
+}
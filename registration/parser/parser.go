@@ -4,6 +4,7 @@ package parser
 import (
 	"fmt"
 	"github.com/DrItanium/cores"
+	"strings"
 )
 
 var parsers map[string]Registration
@@ -70,3 +71,36 @@ type Registrar func(...interface{}) (Parser, error)
 func (this Registrar) New(args ...interface{}) (Parser, error) {
 	return this(args)
 }
+
+// Assemble source held in memory with the named parser and give back the
+// image, this is how compilers hand their output to an assembler
+func Assemble(name, src string) ([]byte, error) {
+	p, err := New(name)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(src, "\n")
+	entries := make(chan Entry, len(lines))
+	for i, line := range lines {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			entries <- Entry{Line: line, Index: i}
+		}
+	}
+	close(entries)
+	if err := p.Parse(entries); err != nil {
+		return nil, err
+	} else if err := p.Process(); err != nil {
+		return nil, err
+	}
+	image := make(chan byte, 4096)
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Dump(image)
+		close(image)
+	}()
+	var out []byte
+	for b := range image {
+		out = append(out, b)
+	}
+	return out, <-done
+}
@@ -3,7 +3,9 @@ package registration
 
 import (
 	_ "github.com/DrItanium/cores/iris16"
+	_ "github.com/DrItanium/cores/iris16/extensions"
+	_ "github.com/DrItanium/cores/iris16/smp"
+	_ "github.com/DrItanium/cores/iris2"
 	_ "github.com/DrItanium/cores/xand"
-	_ "github.com/DrItanium/cores/xand16"
 	_ "github.com/DrItanium/cores/xand8"
 )
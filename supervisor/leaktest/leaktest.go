@@ -0,0 +1,61 @@
+// goroutine leak checking for tests
+package leaktest
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// how long goroutines get to wind down before they count as leaked
+var Timeout = 5 * time.Second
+
+func goroutines() map[string]string {
+	buf := make([]byte, 1<<20)
+	for {
+		if n := runtime.Stack(buf, true); n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+	found := make(map[string]string)
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		header := strings.SplitN(stack, "\n", 2)[0]
+		// goroutine 12 [chan receive]:
+		if fields := strings.Fields(header); len(fields) >= 2 && fields[0] == "goroutine" {
+			found[fields[1]] = stack
+		}
+	}
+	return found
+}
+
+// Snapshot the running goroutines, the returned function fails the test if
+// any new goroutines are still around once it is called. Use it as
+//
+//	defer leaktest.Check(t)()
+func Check(t testing.TB) func() {
+	before := goroutines()
+	return func() {
+		t.Helper()
+		deadline := time.Now().Add(Timeout)
+		for {
+			var leaked []string
+			for id, stack := range goroutines() {
+				if _, ok := before[id]; !ok {
+					leaked = append(leaked, stack)
+				}
+			}
+			if len(leaked) == 0 {
+				return
+			} else if time.Now().After(deadline) {
+				sort.Strings(leaked)
+				t.Errorf("%d goroutines leaked:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
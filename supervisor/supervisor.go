@@ -0,0 +1,187 @@
+// lifecycle management for channel based units
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// A component runs until the context it is given is cancelled. Every blocking
+// send or receive inside of Run has to give up once the context is done (see
+// Send and Receive), that is what guarantees nothing is left behind on
+// shutdown. Returning an error cancels every other component of the
+// supervisor.
+type Component interface {
+	Run(ctx context.Context) error
+}
+
+// Turn a plain function into a component
+type Func func(ctx context.Context) error
+
+func (this Func) Run(ctx context.Context) error {
+	return this(ctx)
+}
+
+// Send a value unless the context is cancelled first, returns false if the
+// value wasn't sent
+func Send[T any](ctx context.Context, ch chan<- T, value T) bool {
+	select {
+	case ch <- value:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Receive a value unless the context is cancelled first, ok is false if the
+// context was cancelled or the channel is closed
+func Receive[T any](ctx context.Context, ch <-chan T) (value T, ok bool) {
+	select {
+	case value, ok = <-ch:
+		return value, ok
+	case <-ctx.Done():
+		return value, false
+	}
+}
+
+type member struct {
+	name      string
+	component Component
+}
+
+type Supervisor struct {
+	mutex   sync.Mutex
+	members []member
+	ctx     context.Context
+	cancel  context.CancelFunc
+	group   sync.WaitGroup
+	errors  []error
+	stopped bool
+}
+
+func New() *Supervisor {
+	return &Supervisor{}
+}
+
+func (this *Supervisor) report(name string, err error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.errors = append(this.errors, fmt.Errorf("%s: %s", name, err))
+}
+
+func (this *Supervisor) launch(m member) {
+	this.group.Add(1)
+	go func() {
+		defer this.group.Done()
+		if err := m.component.Run(this.ctx); err != nil {
+			this.report(m.name, err)
+			this.cancel()
+		}
+	}()
+}
+
+// Add a component, it is started right away if the supervisor is already
+// running
+func (this *Supervisor) Add(name string, component Component) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.stopped {
+		return fmt.Errorf("Can't add %s to a supervisor which has been shut down", name)
+	}
+	m := member{name: name, component: component}
+	this.members = append(this.members, m)
+	if this.ctx != nil {
+		this.launch(m)
+	}
+	return nil
+}
+
+// Collect every error sent on the given channel, nil errors are ignored
+func (this *Supervisor) Watch(name string, errors <-chan error) error {
+	return this.Add(name+" errors", Func(func(ctx context.Context) error {
+		for {
+			if err, ok := Receive(ctx, errors); !ok {
+				return nil
+			} else if err != nil {
+				this.report(name, err)
+			}
+		}
+	}))
+}
+
+// Start every component, cancelling the parent context shuts them down too
+func (this *Supervisor) Start(parent context.Context) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.stopped {
+		return fmt.Errorf("A supervisor can't be restarted")
+	} else if this.ctx != nil {
+		return fmt.Errorf("Supervisor is already running")
+	}
+	this.ctx, this.cancel = context.WithCancel(parent)
+	for _, m := range this.members {
+		this.launch(m)
+	}
+	return nil
+}
+
+func (this *Supervisor) Running() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.ctx != nil && !this.stopped
+}
+
+// Closed once the components are cancelled, either by Shutdown or because one
+// of them failed. Nil if the supervisor was never started.
+func (this *Supervisor) Done() <-chan struct{} {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.ctx == nil {
+		return nil
+	} else {
+		return this.ctx.Done()
+	}
+}
+
+// Cancel every component and wait for all of them to return. The errors
+// collected while running are returned as a single error.
+func (this *Supervisor) Shutdown() error {
+	this.mutex.Lock()
+	if this.ctx == nil {
+		this.mutex.Unlock()
+		return fmt.Errorf("Supervisor is not running")
+	} else if this.stopped {
+		this.mutex.Unlock()
+		return fmt.Errorf("Supervisor is already shut down")
+	}
+	this.stopped = true
+	this.cancel()
+	this.mutex.Unlock()
+	this.group.Wait()
+	return this.Err()
+}
+
+// The errors collected so far
+func (this *Supervisor) Errors() []error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return append([]error(nil), this.errors...)
+}
+
+func (this *Supervisor) Err() error {
+	errs := this.Errors()
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return fmt.Errorf("%d errors: %s", len(errs), strings.Join(msgs, "; "))
+	}
+}
@@ -0,0 +1,67 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"github.com/DrItanium/cores/supervisor/leaktest"
+	"testing"
+)
+
+type echo struct {
+	in, out chan int
+}
+
+func (this *echo) Run(ctx context.Context) error {
+	for {
+		if v, ok := Receive(ctx, this.in); !ok {
+			return nil
+		} else if v < 0 {
+			return fmt.Errorf("Negative value %d", v)
+		} else if !Send(ctx, this.out, v) {
+			return nil
+		}
+	}
+}
+
+func Test_Shutdown(t *testing.T) {
+	defer leaktest.Check(t)()
+	s := New()
+	e := &echo{in: make(chan int), out: make(chan int)}
+	if err := s.Add("echo", e); err != nil {
+		t.Fatal(err)
+	} else if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	e.in <- 1
+	if v := <-e.out; v != 1 {
+		t.Errorf("Expected 1 but got %d", v)
+	}
+	// nobody reads the second value, the echo has to give up on shutdown
+	e.in <- 2
+	if err := s.Shutdown(); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	} else if err := s.Start(context.Background()); err == nil {
+		t.Errorf("Restarting a supervisor should fail")
+	}
+}
+
+func Test_FailureCancelsEverything(t *testing.T) {
+	defer leaktest.Check(t)()
+	s := New()
+	failing := &echo{in: make(chan int), out: make(chan int)}
+	idle := &echo{in: make(chan int), out: make(chan int)}
+	errs := make(chan error)
+	s.Add("failing", failing)
+	s.Add("idle", idle)
+	s.Watch("errors", errs)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	errs <- fmt.Errorf("first")
+	failing.in <- -1
+	if err := s.Shutdown(); err == nil {
+		t.Errorf("Expected the errors to be reported")
+	} else if got := len(s.Errors()); got != 2 {
+		t.Errorf("Expected two errors but got %d: %s", got, err)
+	}
+}
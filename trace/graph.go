@@ -0,0 +1,78 @@
+// topology graphs and value recordings of channel based datapaths
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A unit in a datapath, the ports are the channels it receives from (inputs)
+// and sends on (outputs)
+type Node struct {
+	Name, Kind      string
+	Inputs, Outputs []string
+}
+
+// An edge endpoint is either a node name or node.port
+type Edge struct {
+	From, To string
+}
+
+type Graph struct {
+	Name  string
+	Nodes []Node
+	Edges []Edge
+}
+
+func quote(id string) string {
+	return "\"" + strings.Replace(strings.Replace(id, "\\", "\\\\", -1), "\"", "\\\"", -1) + "\""
+}
+
+var recordEscaper = strings.NewReplacer("{", "\\{", "}", "\\}", "|", "\\|", "<", "\\<", ">", "\\>", "\"", "\\\"")
+
+func ports(names []string) string {
+	fields := make([]string, len(names))
+	for i, name := range names {
+		fields[i] = fmt.Sprintf("<%s> %s", recordEscaper.Replace(name), recordEscaper.Replace(name))
+	}
+	return "{" + strings.Join(fields, "|") + "}"
+}
+
+func endpoint(str string) string {
+	if dot := strings.Index(str, "."); dot == -1 {
+		return quote(str)
+	} else {
+		return quote(str[:dot]) + ":" + quote(str[dot+1:])
+	}
+}
+
+// Write the graph in Graphviz DOT form, data flows from left to right with the
+// inputs of each unit on its left side and the outputs on its right side
+func (this *Graph) WriteDot(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "digraph %s {\n", quote(this.Name))
+	fmt.Fprintln(out, "\trankdir=LR;")
+	fmt.Fprintln(out, "\tnode [shape=record];")
+	for _, n := range this.Nodes {
+		label := recordEscaper.Replace(n.Name)
+		if n.Kind != "" {
+			label += "\\n" + recordEscaper.Replace(n.Kind)
+		}
+		var fields []string
+		if len(n.Inputs) > 0 {
+			fields = append(fields, ports(n.Inputs))
+		}
+		fields = append(fields, label)
+		if len(n.Outputs) > 0 {
+			fields = append(fields, ports(n.Outputs))
+		}
+		fmt.Fprintf(out, "\t%s [label=\"{%s}\"];\n", quote(n.Name), strings.Join(fields, "|"))
+	}
+	for _, e := range this.Edges {
+		fmt.Fprintf(out, "\t%s -> %s;\n", endpoint(e.From), endpoint(e.To))
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
@@ -0,0 +1,224 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A recorder collects every value sent on a set of named signals. Time is
+// logical, each value gets its own tick and the signal goes back to high
+// impedance on the following tick so that repeated values show up as separate
+// pulses in a waveform viewer. Signal names use dots to separate scopes, e.g.
+// memory.op.
+//
+// Every value is kept in memory until WriteVcd is called, a long run should
+// use Stream instead so that values go straight to the dump.
+type Recorder struct {
+	mutex   sync.Mutex
+	clock   uint64
+	signals map[string]*signal
+	events  []event
+	count   int
+	// set once the recorder is streaming
+	stream *bufio.Writer
+}
+
+type signal struct {
+	name  string
+	width int
+	id    string
+}
+
+type event struct {
+	time   uint64
+	signal *signal
+	value  uint64
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{signals: make(map[string]*signal)}
+}
+
+// Declare a signal of the given width in bits, declaring the same signal
+// twice is an error
+func (this *Recorder) Signal(name string, width int) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.stream != nil {
+		return fmt.Errorf("Signal %s can't be declared once the recorder is streaming", name)
+	} else if width < 1 || width > 64 {
+		return fmt.Errorf("Signal %s has an illegal width of %d", name, width)
+	} else if _, ok := this.signals[name]; ok {
+		return fmt.Errorf("Signal %s is already declared", name)
+	} else {
+		this.signals[name] = &signal{name: name, width: width}
+		return nil
+	}
+}
+
+// Record a value on a declared signal, the value is truncated to the width
+// of the signal
+func (this *Recorder) Record(name string, value uint64) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if s, ok := this.signals[name]; !ok {
+		return fmt.Errorf("Signal %s is not declared", name)
+	} else {
+		if s.width < 64 {
+			value &= 1<<uint(s.width) - 1
+		}
+		e := event{time: this.clock, signal: s, value: value}
+		if this.stream != nil {
+			e.write(this.stream)
+		} else {
+			this.events = append(this.events, e)
+		}
+		this.clock += 2
+		this.count++
+		return nil
+	}
+}
+
+// The number of values recorded so far
+func (this *Recorder) Len() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.count
+}
+
+// The values recorded on a signal in the order they were recorded, values
+// which were streamed aren't kept
+func (this *Recorder) Values(name string) []uint64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	var values []uint64
+	for _, e := range this.events {
+		if e.signal.name == name {
+			values = append(values, e.value)
+		}
+	}
+	return values
+}
+
+// vcd identifiers are made up of the printable ascii characters
+func identifier(index int) string {
+	var id []byte
+	for {
+		id = append(id, byte('!'+index%94))
+		if index /= 94; index == 0 {
+			return string(id)
+		}
+		index--
+	}
+}
+
+func (this *signal) value(value uint64) string {
+	if this.width == 1 {
+		return fmt.Sprintf("%d%s", value, this.id)
+	} else {
+		return fmt.Sprintf("b%b %s", value, this.id)
+	}
+}
+func (this *signal) idle() string {
+	if this.width == 1 {
+		return "z" + this.id
+	} else {
+		return "bz " + this.id
+	}
+}
+
+// Write everything recorded so far as a value change dump
+func (this *Recorder) WriteVcd(w io.Writer, top string) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.stream != nil {
+		return fmt.Errorf("A streaming recorder has already written its values")
+	}
+	out := bufio.NewWriter(w)
+	this.writeHeader(out, top)
+	for _, e := range this.events {
+		e.write(out)
+	}
+	return out.Flush()
+}
+
+// Write the header of the dump along with everything recorded so far and send
+// every value recorded from now on straight to w, nothing is kept in memory.
+// No more signals can be declared and Flush has to be called once recording
+// is done.
+func (this *Recorder) Stream(w io.Writer, top string) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.stream != nil {
+		return fmt.Errorf("The recorder is already streaming")
+	}
+	this.stream = bufio.NewWriter(w)
+	this.writeHeader(this.stream, top)
+	for _, e := range this.events {
+		e.write(this.stream)
+	}
+	this.events = nil
+	return nil
+}
+
+// Push any buffered values of a streaming recorder out to its writer
+func (this *Recorder) Flush() error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.stream == nil {
+		return fmt.Errorf("The recorder is not streaming")
+	}
+	return this.stream.Flush()
+}
+
+func (this *Recorder) writeHeader(out *bufio.Writer, top string) {
+	var names []string
+	for name := range this.signals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(out, "$timescale 1ns $end")
+	fmt.Fprintf(out, "$scope module %s $end\n", top)
+	var scope []string
+	for i, name := range names {
+		s := this.signals[name]
+		s.id = identifier(i)
+		path := strings.Split(name, ".")
+		common := 0
+		for common < len(scope) && common < len(path)-1 && scope[common] == path[common] {
+			common++
+		}
+		for ; len(scope) > common; scope = scope[:len(scope)-1] {
+			fmt.Fprintln(out, "$upscope $end")
+		}
+		for _, part := range path[common : len(path)-1] {
+			fmt.Fprintf(out, "$scope module %s $end\n", part)
+			scope = append(scope, part)
+		}
+		fmt.Fprintf(out, "$var wire %d %s %s $end\n", s.width, s.id, path[len(path)-1])
+	}
+	for range scope {
+		fmt.Fprintln(out, "$upscope $end")
+	}
+	fmt.Fprintln(out, "$upscope $end")
+	fmt.Fprintln(out, "$enddefinitions $end")
+	fmt.Fprintln(out, "#0")
+	fmt.Fprintln(out, "$dumpvars")
+	for _, name := range names {
+		fmt.Fprintln(out, this.signals[name].idle())
+	}
+	fmt.Fprintln(out, "$end")
+}
+
+func (this event) write(out *bufio.Writer) {
+	if this.time != 0 {
+		fmt.Fprintf(out, "#%d\n", this.time)
+	}
+	fmt.Fprintln(out, this.signal.value(this.value))
+	fmt.Fprintf(out, "#%d\n", this.time+1)
+	fmt.Fprintln(out, this.signal.idle())
+}
@@ -0,0 +1,97 @@
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_WriteVcd(t *testing.T) {
+	r := NewRecorder()
+	if err := r.Signal("memory.op", 8); err != nil {
+		t.Fatal(err)
+	} else if err := r.Signal("memory.error", 1); err != nil {
+		t.Fatal(err)
+	} else if err := r.Signal("memory.op", 8); err == nil {
+		t.Errorf("Declaring a signal twice should fail")
+	}
+	r.Record("memory.op", 0x1FF)
+	r.Record("memory.op", 0x1FF)
+	r.Record("memory.error", 1)
+	if err := r.Record("alu.op", 1); err == nil {
+		t.Errorf("Recording an undeclared signal should fail")
+	}
+	var buf bytes.Buffer
+	if err := r.WriteVcd(&buf, "top"); err != nil {
+		t.Fatal(err)
+	}
+	expected := `$timescale 1ns $end
+$scope module top $end
+$scope module memory $end
+$var wire 1 ! error $end
+$var wire 8 " op $end
+$upscope $end
+$upscope $end
+$enddefinitions $end
+#0
+$dumpvars
+z!
+bz "
+$end
+b11111111 "
+#1
+bz "
+#2
+b11111111 "
+#3
+bz "
+#4
+1!
+#5
+z!
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("Unexpected vcd output:\n%s", got)
+	}
+}
+
+func Test_WriteDot(t *testing.T) {
+	g := &Graph{
+		Name:  "test",
+		Nodes: []Node{{Name: "a", Kind: "alu", Inputs: []string{"x"}, Outputs: []string{"y"}}},
+		Edges: []Edge{{From: "a.y", To: "a.x"}},
+	}
+	var buf bytes.Buffer
+	if err := g.WriteDot(&buf); err != nil {
+		t.Fatal(err)
+	} else if out := buf.String(); !strings.Contains(out, `"a" [label="{{<x> x}|a\nalu|{<y> y}}"];`) || !strings.Contains(out, `"a":"y" -> "a":"x";`) {
+		t.Errorf("Unexpected dot output:\n%s", out)
+	}
+}
+
+func Test_StreamVcd(t *testing.T) {
+	kept, streamed := NewRecorder(), NewRecorder()
+	kept.Signal("alu.result", 8)
+	streamed.Signal("alu.result", 8)
+	kept.Record("alu.result", 3)
+	kept.Record("alu.result", 300)
+	var expected, got bytes.Buffer
+	if err := kept.WriteVcd(&expected, "top"); err != nil {
+		t.Fatal(err)
+	}
+	// values recorded before streaming starts are written out as well
+	streamed.Record("alu.result", 3)
+	if err := streamed.Stream(&got, "top"); err != nil {
+		t.Fatal(err)
+	}
+	streamed.Record("alu.result", 300)
+	if err := streamed.Flush(); err != nil {
+		t.Fatal(err)
+	} else if got.String() != expected.String() {
+		t.Errorf("Streamed vcd differs:\n%s\nfrom\n%s", got.String(), expected.String())
+	} else if streamed.Len() != 2 || len(streamed.Values("alu.result")) != 0 {
+		t.Errorf("A streaming recorder should count values without keeping them")
+	} else if err := streamed.Signal("alu.op", 8); err == nil {
+		t.Errorf("Signals can't be declared once streaming")
+	}
+}
@@ -0,0 +1,115 @@
+package xand
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Programs talk to the outside world through two memory cells which every
+// xand core places at the top of memory by default. Storing to the output
+// cell writes the low byte of the value, loading from the input cell takes
+// the next byte of input and gives -1 once the input runs dry. The output cell
+// always loads as zero and stores to the input cell are dropped. Instruction
+// fetches, installs and dumps see the plain memory cells.
+//
+// Setting either address to a negative value turns that half of the console
+// off, the addresses are changed with the console.output and console.input
+// target options.
+type Console struct {
+	OutputAddress, InputAddress Word
+	output                      io.Writer
+	input                       io.Reader
+	size                        int
+}
+
+func NewConsole(config Config) *Console {
+	return &Console{
+		OutputAddress: Word(config.MemorySize - 1),
+		InputAddress:  Word(config.MemorySize - 2),
+		size:          config.MemorySize,
+	}
+}
+
+// Defaults to stdout
+func (this *Console) SetOutput(w io.Writer) {
+	this.output = w
+}
+
+// Defaults to stdin
+func (this *Console) SetInput(r io.Reader) {
+	this.input = r
+}
+
+func (this *Console) Output() io.Writer {
+	if this.output == nil {
+		return os.Stdout
+	} else {
+		return this.output
+	}
+}
+
+func (this *Console) Input() io.Reader {
+	if this.input == nil {
+		return os.Stdin
+	} else {
+		return this.input
+	}
+}
+
+func (this *Console) SetOption(name, value string) error {
+	var address *Word
+	switch name {
+	case "console.output":
+		address = &this.OutputAddress
+	case "console.input":
+		address = &this.InputAddress
+	default:
+		return fmt.Errorf("Unknown option %s", name)
+	}
+	if v, err := strconv.ParseInt(value, 0, 64); err != nil {
+		return err
+	} else if v >= int64(this.size) {
+		return fmt.Errorf("Address %d for %s is outside of memory", v, name)
+	} else {
+		*address = Word(v)
+		return nil
+	}
+}
+
+// Load from a console cell, handled is false for every other address
+func (this *Console) Load(address Word) (value Word, handled bool, err error) {
+	switch {
+	case address < 0:
+		return 0, false, nil
+	case address == this.InputAddress:
+		var b [1]byte
+		if _, err := io.ReadFull(this.Input(), b[:]); err == io.EOF {
+			return -1, true, nil
+		} else if err != nil {
+			return 0, true, err
+		} else {
+			return Word(b[0]), true, nil
+		}
+	case address == this.OutputAddress:
+		return 0, true, nil
+	default:
+		return 0, false, nil
+	}
+}
+
+// Store to a console cell, handled is false for every other address
+func (this *Console) Store(address, value Word) (handled bool, err error) {
+	switch {
+	case address < 0:
+		return false, nil
+	case address == this.OutputAddress:
+		_, err := this.Output().Write([]byte{byte(value)})
+		return true, err
+	case address == this.InputAddress:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
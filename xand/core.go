@@ -1,426 +1,212 @@
-// implementation of ajvondrak's xand core
+// implementation of ajvondrak's xand core, the word width and memory size are
+// parameters so one core and one assembler back every xand variant
 package xand
 
 import (
 	"fmt"
 	"github.com/DrItanium/cores/registration/machine"
 	"github.com/DrItanium/cores/registration/parser"
-	"strconv"
-	"strings"
-	"unicode"
-	"unicode/utf8"
+	"io"
 )
 
-type Word int8
+// Values are kept sign extended from the width of the configuration
+type Word int64
 
-const MemorySize = 128
-
-func RegistrationName() string {
-	return "xand"
-}
-
-func generateCore(a ...interface{}) (machine.Machine, error) {
-	return New()
+// Images are MemorySize words, each Bits wide and stored little endian.
+// Addresses are positive words so memory can't be larger than the largest
+// positive word.
+type Config struct {
+	Bits       uint
+	MemorySize int
 }
 
-func init() {
-	machine.Register(RegistrationName(), machine.Registrar(generateCore))
-}
-
-type Core struct {
-	pc     Word
-	ir     [3]Word
-	memory [MemorySize]Word
-}
+var (
+	Xand   = Config{Bits: 8, MemorySize: 128}
+	Xand16 = Config{Bits: 16, MemorySize: 32768}
+	Xand32 = Config{Bits: 32, MemorySize: 1 << 18}
+	Xand64 = Config{Bits: 64, MemorySize: 1 << 18}
+)
 
-func (this *Core) fetch() bool {
-	if (this.pc < 0) || (int(this.pc+2) >= len(this.memory)) {
-		return false
+func (this Config) Validate() error {
+	if this.Bits < 8 || this.Bits > 64 || this.Bits%8 != 0 {
+		return fmt.Errorf("Words of %d bits aren't a whole number of bytes between 8 and 64", this.Bits)
+	} else if this.MemorySize < 3 {
+		return fmt.Errorf("A memory of %d words can't hold an instruction", this.MemorySize)
+	} else if this.Bits < 64 && uint64(this.MemorySize) > 1<<(this.Bits-1) {
+		return fmt.Errorf("A memory of %d words can't be addressed with %d-bit words", this.MemorySize, this.Bits)
 	} else {
-		this.ir[0] = this.memory[this.pc]
-		this.ir[1] = this.memory[this.pc+1]
-		this.ir[2] = this.memory[this.pc+2]
-		return this.ir[0] >= 0 && this.ir[1] >= 0 && this.ir[2] >= 0
-	}
-}
-
-func (this *Core) Run() error {
-	for this.fetch() {
-		// the xand operation it self
-		this.memory[this.ir[0]] = this.memory[this.ir[0]] - this.memory[this.ir[1]]
-		if this.memory[this.ir[0]] <= 0 {
-			this.pc = this.ir[2]
-		} else {
-			this.pc += 3
-		}
+		return nil
 	}
-	return nil
 }
 
-func (this *Core) Startup() error {
-	return nil
+// wrap around the same way a Bits wide two's complement number does
+func (this Config) truncate(value Word) Word {
+	shift := 64 - this.Bits
+	return value << shift >> shift
 }
 
-func (this *Core) Shutdown() error {
-	return nil
+func (this Config) width() int {
+	return int(this.Bits / 8)
 }
 
-func (this *Core) GetDebugStatus() bool {
-	return false
+// Register a machine and a parser for the given configuration
+func Register(name string, config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	} else if err := machine.Register(name, machine.Registrar(func(a ...interface{}) (machine.Machine, error) {
+		return New(config)
+	})); err != nil {
+		return err
+	} else {
+		return parser.Register(name, parser.Registrar(func(a ...interface{}) (parser.Parser, error) {
+			return NewParser(config)
+		}))
+	}
 }
 
-func (this *Core) SetDebug(_ bool) {
-
+// the variants registered as targets
+var Targets = map[string]Config{
+	"xand":   Xand,
+	"xand16": Xand16,
+	"xand32": Xand32,
+	"xand64": Xand64,
 }
 
-func (this *Core) InstallProgram(input <-chan byte) error {
-	// read 128 bytes
-	for i := 0; i < MemorySize; i++ {
-		if value, more := <-input; !more {
-			return fmt.Errorf("Not a complete xand memory image")
-		} else {
-			this.memory[i] = Word(value)
+func init() {
+	for name, config := range Targets {
+		if err := Register(name, config); err != nil {
+			panic(err)
 		}
 	}
-	return nil
-}
-
-func (this *Core) Dump(output chan<- byte) error {
-	for _, value := range this.memory {
-		output <- byte(value)
-	}
-	return nil
 }
 
-func New() (*Core, error) {
-	return &Core{}, nil
+type Core struct {
+	config  Config
+	pc      Word
+	ir      [3]Word
+	memory  []Word
+	console *Console
 }
 
-func generateParser(a ...interface{}) (parser.Parser, error) {
-	var p _parser
-	if core, err := New(); err != nil {
+func New(config Config) (*Core, error) {
+	if err := config.Validate(); err != nil {
 		return nil, err
 	} else {
-		p.core = core
-		p.labels = make(map[string]Word)
-		return &p, nil
+		return &Core{config: config, memory: make([]Word, config.MemorySize), console: NewConsole(config)}, nil
 	}
 }
 
-func init() {
-	parser.Register(RegistrationName(), parser.Registrar(generateParser))
-}
-
-type deferredAddress struct {
-	addr  Word
-	title string
-}
-
-type _parser struct {
-	core       *Core
-	labels     map[string]Word
-	statements []*statement
-	deferred   []deferredAddress
-}
-
-func (this *_parser) Dump(pipe chan<- byte) error {
-	return this.core.Dump(pipe)
+func (this *Core) inRange(address Word) bool {
+	return address >= 0 && address < Word(len(this.memory))
 }
 
-func (this *_parser) Parse(lines <-chan parser.Entry) error {
-	for line := range lines {
-		stmt := carveLine(line.Line)
-		stmt.index = line.Index
-		this.statements = append(this.statements, stmt)
-		for _, str := range stmt.contents {
-			if err := str.Parse(); err != nil {
-				return fmt.Errorf("Error: line: %d : %s\n", line.Index, err)
-			}
-		}
+// an instruction which doesn't fit in memory or names an address outside of
+// memory halts the machine
+func (this *Core) fetch() bool {
+	if !this.inRange(this.pc) || !this.inRange(this.pc+2) {
+		return false
+	} else {
+		this.ir[0] = this.memory[this.pc]
+		this.ir[1] = this.memory[this.pc+1]
+		this.ir[2] = this.memory[this.pc+2]
+		return this.inRange(this.ir[0]) && this.inRange(this.ir[1]) && this.ir[2] >= 0
 	}
-	return nil
 }
 
-type nodeType int
-
-func (this nodeType) String() string {
-	switch this {
-	case typeId:
-		return "id"
-	case typeImmediate:
-		return "immediate"
-	case typeLabel:
-		return "label"
-	case typeComment:
-		return "comment"
-	case keywordXand:
-		return "xand"
-	case keywordDotDotDot:
-		return "..."
-	default:
-		return fmt.Sprintf("%d", this)
+func (this *Core) load(address Word) (Word, error) {
+	if value, handled, err := this.console.Load(address); err != nil {
+		return 0, err
+	} else if handled {
+		return this.config.truncate(value), nil
+	} else {
+		return this.memory[address], nil
 	}
 }
-func (this nodeType) immediate() bool {
-	return this == typeImmediate
-}
-func (this nodeType) comment() bool {
-	return this == typeComment
-}
 
-const (
-	typeId nodeType = iota
-	typeImmediate
-	typeLabel
-	typeComment
-	keywordXand
-	keywordDotDotDot
-)
-
-type node struct {
-	Value interface{}
-	Type  nodeType
-}
-
-func parseDecimalImmediate(str string) (Word, error) {
-	val, err := strconv.ParseInt(str, 10, 8)
-	return Word(val), err
+func (this *Core) store(address, value Word) error {
+	if handled, err := this.console.Store(address, value); err != nil {
+		return err
+	} else if !handled {
+		this.memory[address] = value
+	}
+	return nil
 }
 
-func (this *node) parseLabel(val string) error {
-	nVal := strings.TrimSuffix(val, ":")
-	q, _ := utf8.DecodeRuneInString(nVal)
-	if !unicode.IsLetter(q) {
-		return fmt.Errorf("Label %s starts with a non letter %s!", nVal, q)
-	} else {
-		this.Type = typeLabel
-		this.Value = nVal
-		// now parse the label as a entirely new node and see if we get a register back
-		if nVal == "xand" {
-			return fmt.Errorf("Can't name a label xand")
+func (this *Core) Run() error {
+	for this.fetch() {
+		// the xand operation it self, a is loaded before b
+		a, err := this.load(this.ir[0])
+		if err != nil {
+			return err
+		}
+		b, err := this.load(this.ir[1])
+		if err != nil {
+			return err
+		}
+		result := this.config.truncate(a - b)
+		if err := this.store(this.ir[0], result); err != nil {
+			return err
+		} else if result <= 0 {
+			this.pc = this.ir[2]
 		} else {
-			return nil
+			this.pc = this.config.truncate(this.pc + 3)
 		}
 	}
+	return nil
 }
 
-func (this *node) parseImmediate(val string) error {
-	this.Type = typeImmediate
-	if v, err := parseDecimalImmediate(val[1:]); err != nil {
-		return err
-	} else {
-		this.Value = v
-		return nil
-	}
+func (this *Core) Console() *Console {
+	return this.console
 }
 
-var keywords = map[string]nodeType{
-	"xand": keywordXand,
-	"...":  keywordDotDotDot,
+func (this *Core) SetOutput(w io.Writer) {
+	this.console.SetOutput(w)
 }
 
-func (this *node) parseGeneric(val string) error {
-	if v, ok := keywords[val]; ok {
-		this.Type = v
-		return nil
-	} else {
-		return fmt.Errorf("Unknown statement %s", val)
-	}
+func (this *Core) SetInput(r io.Reader) {
+	this.console.SetInput(r)
 }
 
-func (this *node) Parse() error {
-	if this.Type == typeId {
-		val := this.Value.(string)
-		if this.parseGeneric(val) == nil {
-
-		} else if strings.HasSuffix(val, ":") {
-			return this.parseLabel(val)
-		} else if strings.HasPrefix(val, ";") {
-			this.Type = typeComment
-			this.Value = strings.TrimPrefix(val, ";")
-		} else if strings.HasPrefix(val, "#") {
-			return this.parseImmediate(val)
-		}
-	}
-	return nil
+func (this *Core) SetOption(name, value string) error {
+	return this.console.SetOption(name, value)
 }
 
-func (this *node) isComment() bool {
-	return this.Type == typeComment
+func (this *Core) Startup() error {
+	return nil
 }
 
-func (this *node) isLabel() bool {
-	return this.Type == typeLabel
+func (this *Core) Shutdown() error {
+	return nil
 }
 
-type statement struct {
-	contents []*node
-	index    int
+func (this *Core) GetDebugStatus() bool {
+	return false
 }
 
-func (this *statement) Add(value string, t nodeType) {
-	// always trim before adding
-	str := strings.TrimSpace(value)
-	if len(str) > 0 {
-		this.contents = append(this.contents, &node{Value: str, Type: t})
-	}
-}
-func (this *statement) AddUnknown(value string) {
-	this.Add(value, typeId)
-}
-func (this *statement) String() string {
-	str := fmt.Sprintf("%d: ", this.index)
-	for _, n := range this.contents {
-		str += fmt.Sprintf(" %T: %s ", n, *n)
-	}
-	return str
-}
-func (this *statement) First() (*node, error) {
-	if len(this.contents) == 0 {
-		return nil, fmt.Errorf("Empty statement!")
-	} else {
-		return this.contents[0], nil
-	}
-}
-func (this *statement) Rest() []*node {
-	return this.contents[1:]
-}
+func (this *Core) SetDebug(_ bool) {
 
-func carveLine(line string) *statement {
-	// trim the damn line first
-	data := strings.TrimSpace(line)
-	var s statement
-	if len(data) == 0 {
-		return &s
-	}
-	oldStart := 0
-	start := 0
-	// skip the strings at the beginning
-	for width := 0; start < len(data); start += width {
-		var r rune
-		next := data[start:]
-		r, width = utf8.DecodeRuneInString(next)
-		if unicode.IsSpace(r) {
-			s.AddUnknown(data[oldStart:start])
-			oldStart = start
-		} else if r == ';' {
-			// consume the rest of the data
-			s.AddUnknown(data[oldStart:start])
-			// then capture the comment
-			s.Add(data[start:], typeComment)
-			oldStart = start
-			break
-		}
-	}
-	if oldStart < start {
-		s.AddUnknown(data[oldStart:])
-	}
-	return &s
 }
 
-func (this *_parser) Process() error {
-	for _, stmt := range this.statements {
-		if err := this.parseStatement(stmt); err != nil {
-			return fmt.Errorf("Error: line %d: msg: %s", stmt.index, err)
-		}
-	}
-	for _, d := range this.deferred {
-		if entry, ok := this.labels[d.title]; !ok {
-			return fmt.Errorf("Label %s not defined!", d.title)
-		} else {
-			this.core.memory[d.addr] = entry
+func (this *Core) InstallProgram(input <-chan byte) error {
+	for i := range this.memory {
+		var value uint64
+		for b := 0; b < this.config.width(); b++ {
+			if v, more := <-input; !more {
+				return fmt.Errorf("Not a complete xand memory image")
+			} else {
+				value |= uint64(v) << uint(8*b)
+			}
 		}
+		this.memory[i] = this.config.truncate(Word(value))
 	}
 	return nil
 }
 
-func (this *_parser) newLabel(n *node) error {
-	name := n.Value.(string)
-	if _, ok := this.labels[name]; ok {
-		return fmt.Errorf("Label %s is already defined!", name)
-	} else {
-		this.labels[name] = this.core.pc
-		return nil
-	}
-}
-
-func (this *_parser) parseStatement(stmt *statement) error {
-	first, err := stmt.First()
-	if err != nil {
-		return err
-	}
-	rest := stmt.Rest()
-	switch first.Type {
-	case typeComment:
-		if len(rest) > 0 {
-			panic("Programmer Failure! Found something following a comment node in a statement. This is impossible!!!!")
-		} else {
-			return nil
-		}
-	case typeLabel:
-		if err := this.newLabel(first); err != nil {
-			return err
-		} else if len(rest) > 0 {
-			if this.core.pc < 0 {
-				return fmt.Errorf("Too many instructions defined!")
-			}
-			// if there are more entries on the line then check them out
-			var s statement
-			s.index = stmt.index
-			s.contents = rest
-			return this.parseStatement(&s)
-		}
-	case keywordXand:
-		if len(rest) == 3 {
-			if this.core.pc < 0 {
-				return fmt.Errorf("Too many instructions defined!")
-			}
-			var s statement
-			s.index = stmt.index
-			s.contents = rest
-			return this.parseStatement(&s)
-		} else {
-			return fmt.Errorf("xand requires three arguments")
-		}
-	case keywordDotDotDot:
-		this.core.memory[this.core.pc] = this.core.pc + 1
-		this.core.pc++
-		// hmmm should we allow this to continue on?...nope
-		if len(rest) > 0 {
-			return fmt.Errorf("... has to terminate a statement")
-		}
-	case typeImmediate:
-		// just install the value to the current address
-		this.core.memory[this.core.pc] = first.Value.(Word)
-		this.core.pc++
-		if len(rest) > 0 {
-			if this.core.pc < 0 {
-				return fmt.Errorf("Too many instructions defined!")
-			}
-			var s statement
-			s.index = stmt.index
-			s.contents = rest
-			return this.parseStatement(&s)
-		}
-	case typeId:
-		// defer statement for the time being
-		if addr, ok := this.labels[first.Value.(string)]; !ok {
-			this.deferred = append(this.deferred, deferredAddress{addr: this.core.pc, title: first.Value.(string)})
-		} else {
-			this.core.memory[this.core.pc] = addr
-		}
-		this.core.pc++
-		if len(rest) > 0 {
-			if this.core.pc < 0 {
-				return fmt.Errorf("Too many instructions defined!")
-			}
-			var s statement
-			s.index = stmt.index
-			s.contents = rest
-			return this.parseStatement(&s)
+func (this *Core) Dump(output chan<- byte) error {
+	for _, value := range this.memory {
+		for b := 0; b < this.config.width(); b++ {
+			output <- byte(uint64(value) >> uint(8*b))
 		}
-	default:
-		return fmt.Errorf("Unhandled nodeType %d: %s", first.Type, first.Value)
 	}
 	return nil
 }
@@ -0,0 +1,169 @@
+package xand
+
+import (
+	"bytes"
+	"github.com/DrItanium/cores/registration/parser"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func assemble(config Config, src string) error {
+	_, err := assembleWithOptions(config, nil, src)
+	return err
+}
+
+func assembleWithOptions(config Config, options [][2]string, src string) (*_parser, error) {
+	p, err := NewParser(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, option := range options {
+		if err := p.(*_parser).SetOption(option[0], option[1]); err != nil {
+			return nil, err
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(src), "\n")
+	entries := make(chan parser.Entry, len(lines))
+	for i, line := range lines {
+		entries <- parser.Entry{Line: line, Index: i + 1}
+	}
+	close(entries)
+	if err := p.Parse(entries); err != nil {
+		return nil, err
+	} else {
+		return p.(*_parser), p.Process()
+	}
+}
+
+func Test_ParserRejectsOverflowingPrograms(t *testing.T) {
+	for _, config := range []Config{Xand, Xand16, {Bits: 32, MemorySize: 1000}} {
+		for _, line := range []string{"#1", "...", "label"} {
+			if err := assemble(config, "label:\n"+strings.Repeat(line+"\n", config.MemorySize+1)); err == nil {
+				t.Errorf("%d-bit program of %d %q lines was accepted", config.Bits, config.MemorySize+1, line)
+			}
+		}
+	}
+}
+
+func Test_LabelErrorNamesCharacter(t *testing.T) {
+	if err := assemble(Xand, "1abc: #0"); err == nil {
+		t.Errorf("A label starting with a digit was accepted")
+	} else if !strings.Contains(err.Error(), "non letter 1!") {
+		t.Errorf("The error doesn't name the offending character: %s", err)
+	}
+}
+
+func Test_ConfigValidation(t *testing.T) {
+	for _, config := range []Config{Xand, Xand16, Xand32, Xand64, {Bits: 24, MemorySize: 3}} {
+		if err := config.Validate(); err != nil {
+			t.Errorf("%+v was rejected: %s", config, err)
+		}
+	}
+	for _, config := range []Config{{Bits: 12, MemorySize: 128}, {Bits: 72, MemorySize: 128}, {Bits: 8, MemorySize: 129}, {Bits: 64, MemorySize: 2}} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("%+v was accepted", config)
+		}
+	}
+}
+
+func Test_MacroMisuse(t *testing.T) {
+	for _, src := range []string{
+		"mov a",
+		"halt a",
+		"jz a b c",
+		"Z: #0",
+		"add: #0",
+		strings.Repeat("#0\n", Xand.MemorySize-6) + "jz a a\na: #0",
+	} {
+		if err := assemble(Xand, src); err == nil {
+			t.Errorf("%q was accepted", src)
+		}
+	}
+}
+
+func Test_DirectiveMisuse(t *testing.T) {
+	for _, src := range []string{
+		".org 128",
+		".org later\nlater:",
+		".org -1",
+		".word",
+		".word 1,,2",
+		".fill -1",
+		".fill 1, 2, 3",
+		".byte 1",
+		"#200",
+		"#x1FF",
+		"#'\u00e9'",
+		".word later+127\nlater:",
+		"#0 missing+1 #0",
+		".org 127\n#0\nend:",
+		"a+b: #0",
+	} {
+		if err := assemble(Xand, src); err == nil {
+			t.Errorf("%q was accepted", src)
+		}
+	}
+}
+
+func Test_ConsoleOptions(t *testing.T) {
+	c := NewConsole(Xand)
+	if err := c.SetOption("console.output", "0x10"); err != nil {
+		t.Error(err)
+	} else if c.OutputAddress != 16 {
+		t.Errorf("Expected the output cell to move to 16 but it is at %d", c.OutputAddress)
+	}
+	for _, option := range [][2]string{{"console.input", "128"}, {"console.input", "x"}, {"console.error", "1"}} {
+		if err := c.SetOption(option[0], option[1]); err == nil {
+			t.Errorf("%s=%s was accepted", option[0], option[1])
+		}
+	}
+}
+
+func Test_AssemblerConsoleOptions(t *testing.T) {
+	options := [][2]string{{"console.output", "16"}, {"console.input", "-1"}}
+	if p, err := assembleWithOptions(Xand, options, "putc a\na: #65"); err != nil {
+		t.Error(err)
+	} else if out := p.core.memory[6]; out != 16 {
+		t.Errorf("putc stored to %d instead of the output cell at 16", out)
+	}
+	// the input half of the console is off so IN is not defined
+	if _, err := assembleWithOptions(Xand, options, "getc a\na: #0"); err == nil {
+		t.Errorf("getc was accepted without a console input cell")
+	}
+}
+
+// testdata/listing.lst is what rlasm -listing writes for testdata/listing.asm
+func Test_WriteListing(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/listing.asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := ioutil.ReadFile("testdata/listing.lst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewParser(Xand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(src), "\n")
+	entries := make(chan parser.Entry, len(lines))
+	for i, line := range lines {
+		// numbered the same way rlasm numbers them
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			entries <- parser.Entry{Line: line, Index: i}
+		}
+	}
+	close(entries)
+	var out bytes.Buffer
+	if err := p.Parse(entries); err != nil {
+		t.Fatal(err)
+	} else if err := p.Process(); err != nil {
+		t.Fatal(err)
+	} else if err := p.(*_parser).WriteListing(&out); err != nil {
+		t.Fatal(err)
+	} else if out.String() != string(expect) {
+		t.Errorf("Unexpected listing:\n%s", out.String())
+	}
+}
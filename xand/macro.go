@@ -0,0 +1,237 @@
+package xand
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The macro library, every macro expands to plain xand instructions which go
+// through the assembler like any other line. Operands are labels or
+// immediates and name memory cells, the jump targets of jmp and jz are
+// addresses.
+//
+//	clr a      a = 0
+//	mov a b    a = b
+//	add a b    a = a + b
+//	inc a      a = a + 1
+//	dec a      a = a - 1
+//	jmp l      goto l
+//	jz a l     if a == 0 goto l
+//	putc a     write a to the console
+//	getc a     a = the next byte of console input, -1 once it runs out
+//	halt       stop the machine
+//
+// The expansions share a handful of cells which are placed after the program
+// the first time something refers to them. T is scratch space which any
+// macro may clobber, the rest are constants that must never be written to.
+// OUT and IN name the console cells, the console.output and console.input
+// options move them for the assembler the same way they do for a core.
+type macro struct {
+	operands int
+	expand   func(pc Word, args []string) []string
+}
+
+var macros = map[string]macro{
+	"clr": {1, func(_ Word, args []string) []string {
+		return []string{
+			fmt.Sprintf("xand %s %s ...", args[0], args[0]),
+		}
+	}},
+	// T is loaded first so that moving a cell onto itself works
+	"mov": {2, func(_ Word, args []string) []string {
+		return []string{
+			"xand T T ...",
+			fmt.Sprintf("xand T %s ...", args[1]),
+			fmt.Sprintf("xand %s %s ...", args[0], args[0]),
+			fmt.Sprintf("xand %s T ...", args[0]),
+		}
+	}},
+	"add": {2, func(_ Word, args []string) []string {
+		return []string{
+			"xand T T ...",
+			fmt.Sprintf("xand T %s ...", args[1]),
+			fmt.Sprintf("xand %s T ...", args[0]),
+		}
+	}},
+	"inc": {1, func(_ Word, args []string) []string {
+		return []string{
+			fmt.Sprintf("xand %s NEGONE ...", args[0]),
+		}
+	}},
+	"dec": {1, func(_ Word, args []string) []string {
+		return []string{
+			fmt.Sprintf("xand %s ONE ...", args[0]),
+		}
+	}},
+	"jmp": {1, func(_ Word, args []string) []string {
+		return []string{
+			fmt.Sprintf("xand Z Z %s", args[0]),
+		}
+	}},
+	// Once a is known to be at most zero it is bumped by one, only zero ends
+	// up positive. Both paths put a back the way it was and adding one to a
+	// value which is at most zero can't overflow.
+	"jz": {2, func(pc Word, args []string) []string {
+		a := args[0]
+		return []string{
+			fmt.Sprintf("xand %s Z #%d", a, pc+6),
+			fmt.Sprintf("xand Z Z #%d", pc+15),
+			fmt.Sprintf("xand %s NEGONE #%d", a, pc+12),
+			fmt.Sprintf("xand %s ONE %s", a, args[1]),
+			fmt.Sprintf("xand %s ONE #%d", a, pc+15),
+		}
+	}},
+	"putc": {1, func(_ Word, args []string) []string {
+		return []string{
+			"xand T T ...",
+			fmt.Sprintf("xand T %s ...", args[0]),
+			"xand OUT T ...",
+		}
+	}},
+	"getc": {1, func(_ Word, args []string) []string {
+		return []string{
+			fmt.Sprintf("xand %s %s ...", args[0], args[0]),
+			"xand T T ...",
+			"xand T IN ...",
+			fmt.Sprintf("xand %s T ...", args[0]),
+		}
+	}},
+	// a negative operand halts the machine before the instruction runs
+	"halt": {0, func(_ Word, _ []string) []string {
+		return []string{
+			"xand #-1 #-1 #-1",
+		}
+	}},
+}
+
+// the shared cells in the order they are placed
+var poolCells = []string{"T", "Z", "ONE", "NEGONE"}
+
+var poolValues = map[string]Word{
+	"T":      0,
+	"Z":      0,
+	"ONE":    1,
+	"NEGONE": -1,
+}
+
+func reserved(name string) bool {
+	_, isMacro := macros[name]
+	_, isCell := poolValues[name]
+	return isMacro || isCell || name == "OUT" || name == "IN"
+}
+
+// Reserved reports if a label can't be given the name, for anything that
+// generates assembly
+func Reserved(name string) bool {
+	return name == "xand" || reserved(name)
+}
+
+// the start of a statement and anything following a label can be a macro
+func (this *_parser) parseLine(stmt *statement) error {
+	if first, err := stmt.First(); err != nil || first.Type != typeId {
+		return this.parseStatement(stmt)
+	} else if m, ok := macros[first.Value.(string)]; !ok {
+		return this.parseStatement(stmt)
+	} else {
+		return this.expand(first.Value.(string), m, stmt)
+	}
+}
+
+func (this *_parser) expand(name string, m macro, stmt *statement) error {
+	var args []string
+	for _, n := range stmt.Rest() {
+		switch n.Type {
+		case typeComment:
+		case typeId:
+			args = append(args, n.Value.(string))
+		case typeImmediate:
+			args = append(args, fmt.Sprintf("#%d", n.Value.(Word)))
+		case typeExpression:
+			args = append(args, n.Value.(*expression).text)
+		default:
+			return fmt.Errorf("%s can't take %s as an operand", name, n.Type)
+		}
+	}
+	if len(args) != m.operands {
+		return fmt.Errorf("%s requires %d operands but was given %d", name, m.operands, len(args))
+	}
+	lines := m.expand(this.core.pc, args)
+	if end := this.core.pc + Word(3*len(lines)); end > Word(len(this.core.memory)) {
+		return fmt.Errorf("Too many instructions defined!")
+	}
+	// the words belong to the expansions
+	this.listing[len(this.listing)-1].expanded = true
+	for _, line := range lines {
+		expansion := carveLine(line)
+		expansion.index = stmt.index
+		for _, n := range expansion.contents {
+			if err := n.Parse(this.core.config); err != nil {
+				return err
+			}
+		}
+		entry := this.list(stmt.index, line, true)
+		if err := this.parseStatement(expansion); err != nil {
+			return err
+		}
+		this.listing[entry].end = this.core.pc
+	}
+	return nil
+}
+
+// place the shared cells which were referred to at the end of the program
+func (this *_parser) emitPool() error {
+	for _, name := range poolCells {
+		if !this.pool[name] {
+			continue
+		} else if this.full() {
+			return fmt.Errorf("No room left for the %s cell of the macro library", name)
+		}
+		text := fmt.Sprintf("%s: #%d", name, poolValues[name])
+		entry := this.list(0, text, true)
+		this.labels[name] = this.core.pc
+		this.core.memory[this.core.pc] = poolValues[name]
+		this.advance()
+		this.listing[entry].end = this.core.pc
+	}
+	return nil
+}
+
+// A line of the listing covers the words from its start up to its end, .org
+// moves the start along. Macros cover nothing themselves, their expansions
+// follow them.
+type listingEntry struct {
+	line       int
+	text       string
+	start, end Word
+	expansion  bool
+	expanded   bool
+}
+
+func (this *_parser) list(line int, text string, expansion bool) int {
+	this.listing = append(this.listing, listingEntry{line: line, text: text, start: this.core.pc, expansion: expansion})
+	return len(this.listing) - 1
+}
+
+// Write out where every line of the program ended up along with the words it
+// assembled into
+func (this *_parser) WriteListing(w io.Writer) error {
+	for _, entry := range this.listing {
+		end := entry.end
+		if entry.expanded {
+			end = entry.start
+		}
+		var words []string
+		for addr := entry.start; addr < end && this.core.inRange(addr); addr++ {
+			words = append(words, fmt.Sprintf("%d", this.core.memory[addr]))
+		}
+		line, text := fmt.Sprintf("%5d", entry.line), entry.text
+		if entry.expansion {
+			line, text = "     ", "    "+text
+		}
+		if _, err := fmt.Fprintf(w, "%s  %6d  %-20s %s\n", line, entry.start, strings.Join(words, " "), text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,770 @@
+// assembler shared by every xand core
+package xand
+
+import (
+	"errors"
+	"fmt"
+	"github.com/DrItanium/cores/registration/parser"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Besides instructions the assembler understands a few directives
+//
+//	.org 16            continue assembling at address 16
+//	.word 1, x, -x     place words one after another
+//	.fill 4, #'a'      place four copies of a word, zero if it is left out
+//
+// Wherever a word goes an expression can be used instead, it is a sum of
+// labels and literals like loop+3, end-start or -x. Literals are decimal
+// (#12 or just 12 inside of an expression), hex (#x1F), binary (#b101) or
+// characters (#'a', #'\n'). Hex and binary literals are bit patterns so #xFF
+// is -1 on an 8-bit core. Every expression has to fit into a word of the
+// target and every label has to name an address inside of memory.
+func NewParser(config Config) (parser.Parser, error) {
+	var p _parser
+	if core, err := New(config); err != nil {
+		return nil, err
+	} else {
+		p.core = core
+		p.labels = make(map[string]Word)
+		p.bindConsole()
+		p.pool = make(map[string]bool)
+		return &p, nil
+	}
+}
+
+// OUT and IN follow the console cells, a console half which is turned off
+// leaves its name undefined
+func (this *_parser) bindConsole() {
+	for name, address := range map[string]Word{
+		"OUT": this.core.console.OutputAddress,
+		"IN":  this.core.console.InputAddress,
+	} {
+		if address < 0 {
+			delete(this.labels, name)
+		} else {
+			this.labels[name] = address
+		}
+	}
+}
+
+// Takes the same console options as the core so that programs assembled with
+// them talk to the cells the core was given, they have to be set before
+// parsing
+func (this *_parser) SetOption(name, value string) error {
+	if len(this.statements) > 0 {
+		return fmt.Errorf("Option %s has to be set before anything is parsed", name)
+	} else if err := this.core.SetOption(name, value); err != nil {
+		return err
+	} else {
+		this.bindConsole()
+		return nil
+	}
+}
+
+// a word which refers to labels that weren't defined yet
+type deferredAddress struct {
+	addr Word
+	expr *expression
+	line int
+}
+
+type _parser struct {
+	core       *Core
+	labels     map[string]Word
+	statements []*statement
+	deferred   []deferredAddress
+	pool       map[string]bool
+	listing    []listingEntry
+	line       int
+}
+
+func (this *_parser) Dump(pipe chan<- byte) error {
+	return this.core.Dump(pipe)
+}
+
+func (this *_parser) Parse(lines <-chan parser.Entry) error {
+	for line := range lines {
+		stmt := carveLine(line.Line)
+		stmt.index = line.Index
+		stmt.text = line.Line
+		this.statements = append(this.statements, stmt)
+		for i, str := range stmt.contents {
+			if err := str.Parse(this.core.config); err != nil {
+				return fmt.Errorf("Error: line: %d : %s\n", line.Index, err)
+			} else if str.Type == typeDirective {
+				if args, err := directiveArguments(stmt.contents[i+1:], this.core.config); err != nil {
+					return fmt.Errorf("Error: line: %d : %s\n", line.Index, err)
+				} else {
+					stmt.contents = append(stmt.contents[:i+1], args...)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+type nodeType int
+
+func (this nodeType) String() string {
+	switch this {
+	case typeId:
+		return "id"
+	case typeImmediate:
+		return "immediate"
+	case typeExpression:
+		return "expression"
+	case typeLabel:
+		return "label"
+	case typeComment:
+		return "comment"
+	case typeDirective:
+		return "directive"
+	case keywordXand:
+		return "xand"
+	case keywordDotDotDot:
+		return "..."
+	default:
+		return fmt.Sprintf("%d", this)
+	}
+}
+func (this nodeType) immediate() bool {
+	return this == typeImmediate
+}
+func (this nodeType) comment() bool {
+	return this == typeComment
+}
+
+const (
+	typeId nodeType = iota
+	typeImmediate
+	typeExpression
+	typeLabel
+	typeComment
+	typeDirective
+	keywordXand
+	keywordDotDotDot
+)
+
+type node struct {
+	Value interface{}
+	Type  nodeType
+}
+
+// characters which have a meaning inside of expressions and directives
+const reservedCharacters = "+-,'#;:"
+
+func validName(name string) bool {
+	q, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsLetter(q) && !strings.ContainsAny(name, reservedCharacters) && !strings.ContainsFunc(name, unicode.IsSpace)
+}
+
+func (this *node) parseLabel(val string) error {
+	nVal := strings.TrimSuffix(val, ":")
+	q, _ := utf8.DecodeRuneInString(nVal)
+	if !unicode.IsLetter(q) {
+		return fmt.Errorf("Label %s starts with a non letter %c!", nVal, q)
+	} else if !validName(nVal) {
+		return fmt.Errorf("Label %s can't contain any of %s", nVal, reservedCharacters)
+	} else {
+		this.Type = typeLabel
+		this.Value = nVal
+		// now parse the label as a entirely new node and see if we get a register back
+		if nVal == "xand" {
+			return fmt.Errorf("Can't name a label xand")
+		} else if reserved(nVal) {
+			return fmt.Errorf("Can't name a label %s, the name is reserved by the macro library", nVal)
+		} else {
+			return nil
+		}
+	}
+}
+
+var directives = map[string]bool{
+	"org":  true,
+	"word": true,
+	"fill": true,
+}
+
+func (this *node) parseDirective(val string) error {
+	if name := val[1:]; !directives[name] {
+		return fmt.Errorf("Unknown directive %s", val)
+	} else {
+		this.Type = typeDirective
+		this.Value = name
+		return nil
+	}
+}
+
+// Expressions without any labels are immediates
+func (this *node) parseExpression(val string, config Config) error {
+	if e, err := parseExpression(val, config); err != nil {
+		return err
+	} else if len(e.labels()) > 0 {
+		this.Type = typeExpression
+		this.Value = e
+		return nil
+	} else if v, err := e.evaluate(nil, config); err != nil {
+		return err
+	} else {
+		this.Type = typeImmediate
+		this.Value = v
+		return nil
+	}
+}
+
+var keywords = map[string]nodeType{
+	"xand": keywordXand,
+	"...":  keywordDotDotDot,
+}
+
+func (this *node) parseGeneric(val string) error {
+	if v, ok := keywords[val]; ok {
+		this.Type = v
+		return nil
+	} else {
+		return fmt.Errorf("Unknown statement %s", val)
+	}
+}
+
+func isExpression(val string) bool {
+	q, _ := utf8.DecodeRuneInString(val)
+	return q == '#' || q == '-' || unicode.IsDigit(q) || strings.ContainsAny(val, "+-")
+}
+
+func (this *node) Parse(config Config) error {
+	if this.Type == typeId {
+		val := this.Value.(string)
+		if this.parseGeneric(val) == nil {
+
+		} else if strings.HasSuffix(val, ":") {
+			return this.parseLabel(val)
+		} else if strings.HasPrefix(val, ";") {
+			this.Type = typeComment
+			this.Value = strings.TrimPrefix(val, ";")
+		} else if strings.HasPrefix(val, ".") {
+			return this.parseDirective(val)
+		} else if isExpression(val) {
+			return this.parseExpression(val, config)
+		}
+	}
+	return nil
+}
+
+// a bare label is an expression with a single term
+func (this *node) expression() *expression {
+	if this.Type == typeExpression {
+		return this.Value.(*expression)
+	} else {
+		name := this.Value.(string)
+		return &expression{text: name, terms: []term{{label: name}}}
+	}
+}
+
+func (this *node) isComment() bool {
+	return this.Type == typeComment
+}
+
+func (this *node) isLabel() bool {
+	return this.Type == typeLabel
+}
+
+// The arguments of a directive are a comma separated list of words, it is
+// carved up again since a comma doesn't need any space around it
+func directiveArguments(nodes []*node, config Config) ([]*node, error) {
+	var raw []string
+	var comment *node
+	for _, n := range nodes {
+		if n.Type == typeComment {
+			comment = n
+		} else {
+			raw = append(raw, n.Value.(string))
+		}
+	}
+	var args []*node
+	if joined := strings.TrimSpace(strings.Join(raw, " ")); joined != "" {
+		for _, arg := range splitOutsideQuotes(joined, ',') {
+			if arg = strings.TrimSpace(arg); arg == "" {
+				return nil, fmt.Errorf("Empty directive argument in %s", joined)
+			}
+			n := &node{Value: arg, Type: typeId}
+			if err := n.Parse(config); err != nil {
+				return nil, err
+			}
+			args = append(args, n)
+		}
+	}
+	if comment != nil {
+		args = append(args, comment)
+	}
+	return args, nil
+}
+
+type statement struct {
+	contents []*node
+	index    int
+	text     string
+}
+
+func (this *statement) Add(value string, t nodeType) {
+	// always trim before adding
+	str := strings.TrimSpace(value)
+	if len(str) > 0 {
+		this.contents = append(this.contents, &node{Value: str, Type: t})
+	}
+}
+func (this *statement) AddUnknown(value string) {
+	this.Add(value, typeId)
+}
+func (this *statement) String() string {
+	str := fmt.Sprintf("%d: ", this.index)
+	for _, n := range this.contents {
+		str += fmt.Sprintf(" %T: %s ", n, *n)
+	}
+	return str
+}
+func (this *statement) First() (*node, error) {
+	if len(this.contents) == 0 {
+		return nil, fmt.Errorf("Empty statement!")
+	} else {
+		return this.contents[0], nil
+	}
+}
+func (this *statement) Rest() []*node {
+	return this.contents[1:]
+}
+
+// Spaces and semicolons inside of character literals don't split the line
+func carveLine(line string) *statement {
+	// trim the damn line first
+	data := strings.TrimSpace(line)
+	var s statement
+	if len(data) == 0 {
+		return &s
+	}
+	oldStart := 0
+	start := 0
+	quoted, escaped := false, false
+	// skip the strings at the beginning
+	for width := 0; start < len(data); start += width {
+		var r rune
+		next := data[start:]
+		r, width = utf8.DecodeRuneInString(next)
+		if quoted {
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '\'' {
+				quoted = false
+			}
+		} else if r == '\'' {
+			quoted = true
+		} else if unicode.IsSpace(r) {
+			s.AddUnknown(data[oldStart:start])
+			oldStart = start
+		} else if r == ';' {
+			// consume the rest of the data
+			s.AddUnknown(data[oldStart:start])
+			// then capture the comment
+			s.Add(data[start:], typeComment)
+			oldStart = start
+			break
+		}
+	}
+	if oldStart < start {
+		s.AddUnknown(data[oldStart:])
+	}
+	return &s
+}
+
+func (this *_parser) Process() error {
+	for _, stmt := range this.statements {
+		this.line = stmt.index
+		entry := this.list(stmt.index, stmt.text, false)
+		if err := this.parseLine(stmt); err != nil {
+			return fmt.Errorf("Error: line %d: msg: %s", stmt.index, err)
+		}
+		this.listing[entry].end = this.core.pc
+	}
+	if err := this.emitPool(); err != nil {
+		return err
+	}
+	for _, d := range this.deferred {
+		if name := d.expr.missing(this.labels); name != "" {
+			return fmt.Errorf("Error: line %d: msg: Label %s not defined!", d.line, name)
+		} else if value, err := d.expr.evaluate(this.labels, this.core.config); err != nil {
+			return fmt.Errorf("Error: line %d: msg: %s", d.line, err)
+		} else {
+			this.core.memory[d.addr] = value
+		}
+	}
+	return nil
+}
+
+// once the program counter leaves memory there is no more room for the
+// program
+func (this *_parser) advance() {
+	this.core.pc++
+}
+
+func (this *_parser) full() bool {
+	return !this.core.inRange(this.core.pc)
+}
+
+func (this *_parser) newLabel(n *node) error {
+	name := n.Value.(string)
+	if _, ok := this.labels[name]; ok {
+		return fmt.Errorf("Label %s is already defined!", name)
+	} else if this.full() {
+		return fmt.Errorf("Label %s would be at address %d which is outside of the %d words of memory", name, this.core.pc, len(this.core.memory))
+	} else {
+		this.labels[name] = this.core.pc
+		return nil
+	}
+}
+
+// Put a single word at the program counter, expressions which refer to
+// labels that aren't defined yet are filled in at the end
+func (this *_parser) emit(n *node) error {
+	if this.full() {
+		return fmt.Errorf("Too many instructions defined!")
+	}
+	switch n.Type {
+	case typeImmediate:
+		this.core.memory[this.core.pc] = n.Value.(Word)
+	case typeId, typeExpression:
+		e := n.expression()
+		for _, name := range e.labels() {
+			if _, ok := poolValues[name]; ok {
+				this.pool[name] = true
+			}
+		}
+		if e.missing(this.labels) != "" {
+			this.deferred = append(this.deferred, deferredAddress{addr: this.core.pc, expr: e, line: this.line})
+		} else if value, err := e.evaluate(this.labels, this.core.config); err != nil {
+			return err
+		} else {
+			this.core.memory[this.core.pc] = value
+		}
+	default:
+		return fmt.Errorf("A %s can't be used as a word", n.Type)
+	}
+	this.advance()
+	return nil
+}
+
+// a value which has to be known right away
+func (this *_parser) constant(n *node) (Word, error) {
+	switch n.Type {
+	case typeImmediate:
+		return n.Value.(Word), nil
+	case typeId, typeExpression:
+		if e := n.expression(); e.missing(this.labels) != "" {
+			return 0, fmt.Errorf("%s can only refer to labels defined before it", e.text)
+		} else {
+			return e.evaluate(this.labels, this.core.config)
+		}
+	default:
+		return 0, fmt.Errorf("A %s can't be used as a value", n.Type)
+	}
+}
+
+func (this *_parser) directive(name string, nodes []*node) error {
+	var args []*node
+	for _, n := range nodes {
+		if !n.isComment() {
+			args = append(args, n)
+		}
+	}
+	switch name {
+	case "org":
+		if len(args) != 1 {
+			return fmt.Errorf("org directive requires a single address")
+		} else if addr, err := this.constant(args[0]); err != nil {
+			return err
+		} else if !this.core.inRange(addr) {
+			return fmt.Errorf("Can't move to address %d, memory only has %d words", addr, len(this.core.memory))
+		} else {
+			this.core.pc = addr
+			// the listing shows where things continue from
+			this.listing[len(this.listing)-1].start = addr
+			return nil
+		}
+	case "word":
+		if len(args) == 0 {
+			return fmt.Errorf("word directive requires at least one value")
+		}
+		for _, arg := range args {
+			if err := this.emit(arg); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "fill":
+		value := &node{Type: typeImmediate, Value: Word(0)}
+		if len(args) == 2 {
+			value = args[1]
+		} else if len(args) != 1 {
+			return fmt.Errorf("fill directive requires a count and an optional value")
+		}
+		if count, err := this.constant(args[0]); err != nil {
+			return err
+		} else if count < 0 {
+			return fmt.Errorf("Can't fill a negative number (%d) of words", count)
+		} else {
+			for i := Word(0); i < count; i++ {
+				if err := this.emit(value); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default:
+		return fmt.Errorf("Unknown directive %s", name)
+	}
+}
+
+func (this *_parser) parseStatement(stmt *statement) error {
+	first, err := stmt.First()
+	if err != nil {
+		return err
+	}
+	rest := stmt.Rest()
+	switch first.Type {
+	case typeComment:
+		if len(rest) > 0 {
+			panic("Programmer Failure! Found something following a comment node in a statement. This is impossible!!!!")
+		} else {
+			return nil
+		}
+	case typeLabel:
+		if err := this.newLabel(first); err != nil {
+			return err
+		} else if len(rest) > 0 {
+			// if there are more entries on the line then check them out
+			var s statement
+			s.index = stmt.index
+			s.contents = rest
+			return this.parseLine(&s)
+		}
+	case typeDirective:
+		return this.directive(first.Value.(string), rest)
+	case keywordXand:
+		if len(rest) == 3 {
+			if this.full() {
+				return fmt.Errorf("Too many instructions defined!")
+			}
+			var s statement
+			s.index = stmt.index
+			s.contents = rest
+			return this.parseStatement(&s)
+		} else {
+			return fmt.Errorf("xand requires three arguments")
+		}
+	case keywordDotDotDot:
+		if this.full() {
+			return fmt.Errorf("Too many instructions defined!")
+		}
+		// the address of the next instruction, past the end of memory it
+		// wraps around and halts the machine
+		this.core.memory[this.core.pc] = this.core.config.truncate(this.core.pc + 1)
+		this.advance()
+		// hmmm should we allow this to continue on?...nope
+		if len(rest) > 0 {
+			return fmt.Errorf("... has to terminate a statement")
+		}
+	case typeImmediate, typeId, typeExpression:
+		if err := this.emit(first); err != nil {
+			return err
+		} else if len(rest) > 0 {
+			var s statement
+			s.index = stmt.index
+			s.contents = rest
+			return this.parseStatement(&s)
+		}
+	default:
+		return fmt.Errorf("Unhandled nodeType %d: %s", first.Type, first.Value)
+	}
+	return nil
+}
+
+type term struct {
+	negative bool
+	label    string
+	value    Word
+}
+
+type expression struct {
+	text  string
+	terms []term
+}
+
+// Split on the separator unless it is part of a character literal
+func splitOutsideQuotes(text string, separator rune) []string {
+	var parts []string
+	quoted, escaped, start := false, false, 0
+	for i, r := range text {
+		if quoted {
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '\'' {
+				quoted = false
+			}
+		} else if r == '\'' {
+			quoted = true
+		} else if r == separator {
+			parts = append(parts, text[start:i])
+			start = i + utf8.RuneLen(r)
+		}
+	}
+	return append(parts, text[start:])
+}
+
+func parseExpression(text string, config Config) (*expression, error) {
+	e := &expression{text: text}
+	rest, negative := text, false
+	if strings.HasPrefix(rest, "-") {
+		rest, negative = rest[1:], true
+	}
+	for {
+		end := termLength(rest)
+		if t, err := parseTerm(rest[:end], config); err != nil {
+			return nil, err
+		} else {
+			t.negative = negative
+			e.terms = append(e.terms, t)
+		}
+		if end == len(rest) {
+			return e, nil
+		}
+		negative = rest[end] == '-'
+		rest = rest[end+1:]
+	}
+}
+
+// terms run up to the next plus or minus, a minus right after the # of a
+// literal is its sign
+func termLength(text string) int {
+	start := 0
+	if strings.HasPrefix(text, "#-") {
+		start = 2
+	}
+	quoted, escaped := false, false
+	for i, r := range text[start:] {
+		if quoted {
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '\'' {
+				quoted = false
+			}
+		} else if r == '\'' {
+			quoted = true
+		} else if r == '+' || r == '-' {
+			return start + i
+		}
+	}
+	return len(text)
+}
+
+func parseTerm(text string, config Config) (term, error) {
+	q, _ := utf8.DecodeRuneInString(text)
+	switch {
+	case text == "":
+		return term{}, fmt.Errorf("Missing term in expression")
+	case q == '#':
+		v, err := parseLiteral(text[1:], config)
+		return term{value: v}, err
+	case unicode.IsDigit(q):
+		v, err := parseLiteral(text, config)
+		return term{value: v}, err
+	case validName(text):
+		return term{label: text}, nil
+	default:
+		return term{}, fmt.Errorf("%s is neither a label nor a literal", text)
+	}
+}
+
+func parseLiteral(text string, config Config) (Word, error) {
+	bits := int(config.Bits)
+	var value int64
+	var err error
+	switch {
+	case strings.HasPrefix(text, "x"):
+		var v uint64
+		v, err = strconv.ParseUint(text[1:], 16, bits)
+		value = int64(v)
+	case strings.HasPrefix(text, "b"):
+		var v uint64
+		v, err = strconv.ParseUint(text[1:], 2, bits)
+		value = int64(v)
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 3 || !strings.HasSuffix(text, "'") {
+			return 0, fmt.Errorf("Malformed character literal %s", text)
+		} else if r, _, tail, err := strconv.UnquoteChar(text[1:len(text)-1], '\''); err != nil || tail != "" {
+			return 0, fmt.Errorf("Malformed character literal %s", text)
+		} else if config.truncate(Word(r)) != Word(r) {
+			return 0, fmt.Errorf("Character %s doesn't fit in a %d-bit word", text, bits)
+		} else {
+			return Word(r), nil
+		}
+	default:
+		value, err = strconv.ParseInt(text, 10, bits)
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("Literal %s doesn't fit in a %d-bit word", text, bits)
+	} else if err != nil {
+		return 0, fmt.Errorf("Malformed literal %s", text)
+	} else {
+		// hex and binary literals are bit patterns
+		return config.truncate(Word(value)), nil
+	}
+}
+
+func (this *expression) labels() []string {
+	var names []string
+	for _, t := range this.terms {
+		if t.label != "" {
+			names = append(names, t.label)
+		}
+	}
+	return names
+}
+
+// the first label which isn't defined yet
+func (this *expression) missing(labels map[string]Word) string {
+	for _, name := range this.labels() {
+		if _, ok := labels[name]; !ok {
+			return name
+		}
+	}
+	return ""
+}
+
+func (this *expression) evaluate(labels map[string]Word, config Config) (Word, error) {
+	var sum Word
+	for _, t := range this.terms {
+		v := t.value
+		if t.label != "" {
+			v = labels[t.label]
+		}
+		if t.negative {
+			sum -= v
+		} else {
+			sum += v
+		}
+	}
+	if config.truncate(sum) != sum {
+		return 0, fmt.Errorf("%s is %d which doesn't fit in a %d-bit word", this.text, sum, config.Bits)
+	} else {
+		return sum, nil
+	}
+}
@@ -0,0 +1,8 @@
+; count down from three
+loop: jz n done
+dec n
+mov last n
+jmp loop
+done: halt
+n: #3
+last: #0
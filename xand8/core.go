@@ -2,193 +2,155 @@
 package xand
 
 import (
+	"context"
 	"fmt"
 	"github.com/DrItanium/cores/registration/machine"
 	"github.com/DrItanium/cores/registration/parser"
-	"strconv"
-	"strings"
-	"unicode"
-	"unicode/utf8"
+	"github.com/DrItanium/cores/supervisor"
+	"github.com/DrItanium/cores/xand"
+	"io"
 )
 
 type Word int8
 type BranchUnit struct {
-	running                             bool
-	control, cond, onTrue, onFalse      chan Word
-	out                                 chan Word
-	Control, Condition, OnTrue, OnFalse chan<- Word
-	Result                              <-chan Word
+	cond, onTrue, onFalse      chan Word
+	out                        chan Word
+	Condition, OnTrue, OnFalse chan<- Word
+	Result                     <-chan Word
 }
 
-func NewBranchUnit() *BranchUnit {
+func NewBranchUnit(depth int) *BranchUnit {
 	var b BranchUnit
-	b.control = make(chan Word)
-	b.cond = make(chan Word, 4)
-	b.onTrue = make(chan Word, 4)
-	b.onFalse = make(chan Word, 4)
-	b.out = make(chan Word, 4)
-	b.Control = b.control
+	b.cond = make(chan Word, depth)
+	b.onTrue = make(chan Word, depth)
+	b.onFalse = make(chan Word, depth)
+	b.out = make(chan Word, depth)
 	b.Condition = b.cond
 	b.OnTrue = b.onTrue
 	b.OnFalse = b.onFalse
 	b.Result = b.out
-	go b.controlInvoke()
 	return &b
 }
 
-func (this *BranchUnit) controlInvoke() {
-	// wait until we get the init signal
-	<-this.control
-	this.running = true
-	go this.body()
-	// wait until we get another signal to terminate
-	<-this.control
-	// shut the machine down
-	this.running = false
-	close(this.cond)
-	close(this.onTrue)
-	close(this.onFalse)
-	// wait until body finishes
-	<-this.control
-	close(this.control)
-}
-
-func (this *BranchUnit) body() {
-	for this.running {
-		select {
-		case cond := <-this.cond:
-			if t, f := <-this.onTrue, <-this.onFalse; cond <= 0 {
-				this.out <- t
-			} else {
-				this.out <- f
-			}
-		default:
+func (this *BranchUnit) Run(ctx context.Context) error {
+	for {
+		if cond, ok := supervisor.Receive(ctx, this.cond); !ok {
+			return nil
+		} else if t, ok := supervisor.Receive(ctx, this.onTrue); !ok {
+			return nil
+		} else if f, ok := supervisor.Receive(ctx, this.onFalse); !ok {
+			return nil
+		} else if cond <= 0 {
+			supervisor.Send(ctx, this.out, t)
+		} else {
+			supervisor.Send(ctx, this.out, f)
 		}
 	}
-	// send the I'm done signal
-	this.control <- 1
 }
 
 type MemoryUnit struct {
-	running                  bool
-	memory                   [MemorySize]Word
-	control, op, addr, value chan Word
-	out                      chan Word
-	err                      chan error
-	Control, Op, Addr, Value chan<- Word
-	Result                   <-chan Word
-	Error                    <-chan error
+	memory          [MemorySize]Word
+	console         *xand.Console
+	op, addr, value chan Word
+	out             chan Word
+	err             chan error
+	Op, Addr, Value chan<- Word
+	Result          <-chan Word
+	Error           <-chan error
 }
 
-func NewMemoryUnit() *MemoryUnit {
+func NewMemoryUnit(depth int) *MemoryUnit {
 	var b MemoryUnit
-	b.err = make(chan error, 4)
-	b.control = make(chan Word)
-	b.op = make(chan Word, MemorySize)
-	b.addr = make(chan Word, MemorySize)
-	b.value = make(chan Word, MemorySize)
-	b.out = make(chan Word, MemorySize)
-	b.Control = b.control
+	b.console = xand.NewConsole(xand.Xand)
+	b.err = make(chan error, depth)
+	b.op = make(chan Word, depth)
+	b.addr = make(chan Word, depth)
+	b.value = make(chan Word, depth)
+	b.out = make(chan Word, depth)
 	b.Error = b.err
 	b.Op = b.op
 	b.Addr = b.addr
 	b.Value = b.value
 	b.Result = b.out
-	go b.controlInvoke()
 	return &b
 }
 
-func (this *MemoryUnit) controlInvoke() {
-	// wait until we get the init signal
-	<-this.control
-	this.running = true
-	go this.body()
-	// wait until we get another signal to terminate
-	<-this.control
-	// shut the machine down
-	this.running = false
-	close(this.op)
-	close(this.addr)
-	close(this.value)
-	// wait until body finishes
-	<-this.control
-	close(this.control)
-	close(this.err)
-}
-
+// Loads and stores see the plain memory cells, reads and writes go through
+// the console the same way the other xand cores do
 const (
 	MemoryLoad = iota
 	MemoryStore
+	MemoryRead
+	MemoryWrite
 )
 
-func (this *MemoryUnit) body() {
-	for this.running {
-		select {
-		case op := <-this.op:
-			addr := <-this.addr
-			if addr < 0 {
-				this.err <- fmt.Errorf("Out of range!")
-			} else {
-				switch op {
-				case MemoryLoad: // load
-					this.out <- this.memory[addr]
-					this.err <- nil
-				case MemoryStore: // store
-					this.memory[addr] = <-this.value
-					this.err <- nil
-				default:
-					this.err <- fmt.Errorf("Illegal signal")
+// Every operation is acknowledged on the error channel, nil means it worked.
+// A read always produces a result, even when the console fails.
+func (this *MemoryUnit) Run(ctx context.Context) error {
+	for {
+		op, ok := supervisor.Receive(ctx, this.op)
+		if !ok {
+			return nil
+		}
+		addr, ok := supervisor.Receive(ctx, this.addr)
+		if !ok {
+			return nil
+		} else if addr < 0 {
+			supervisor.Send(ctx, this.err, fmt.Errorf("Out of range!"))
+			continue
+		}
+		switch op {
+		case MemoryLoad: // load
+			if supervisor.Send(ctx, this.out, this.memory[addr]) {
+				supervisor.Send(ctx, this.err, nil)
+			}
+		case MemoryStore: // store
+			if value, ok := supervisor.Receive(ctx, this.value); ok {
+				this.memory[addr] = value
+				supervisor.Send(ctx, this.err, nil)
+			}
+		case MemoryRead:
+			value, handled, err := this.console.Load(xand.Word(addr))
+			if !handled {
+				value = xand.Word(this.memory[addr])
+			}
+			if supervisor.Send(ctx, this.out, Word(value)) {
+				supervisor.Send(ctx, this.err, err)
+			}
+		case MemoryWrite:
+			if value, ok := supervisor.Receive(ctx, this.value); ok {
+				handled, err := this.console.Store(xand.Word(addr), xand.Word(value))
+				if !handled {
+					this.memory[addr] = value
 				}
+				supervisor.Send(ctx, this.err, err)
 			}
 		default:
+			supervisor.Send(ctx, this.err, fmt.Errorf("Illegal signal"))
 		}
 	}
-	// send the I'm done signal
-	this.control <- 1
 }
 
 type Alu struct {
-	running                bool
-	control, op, a, b, out chan Word
-	Control                chan<- Word
-	First                  chan<- Word
-	Second                 chan<- Word
-	Op                     chan<- Word
-	Result                 <-chan Word
+	op, a, b, out chan Word
+	First         chan<- Word
+	Second        chan<- Word
+	Op            chan<- Word
+	Result        <-chan Word
 }
 
-func NewAlu() *Alu {
+func NewAlu(depth int) *Alu {
 	var a Alu
-	a.control = make(chan Word)
-	a.op = make(chan Word, 4)
-	a.a = make(chan Word, 4)
-	a.b = make(chan Word, 4)
-	a.out = make(chan Word, 4)
-	a.Control = a.control
+	a.op = make(chan Word, depth)
+	a.a = make(chan Word, depth)
+	a.b = make(chan Word, depth)
+	a.out = make(chan Word, depth)
 	a.Op = a.op
 	a.First = a.a
 	a.Second = a.b
 	a.Result = a.out
-	go a.controlInvoke()
 	return &a
 }
-func (this *Alu) controlInvoke() {
-	// wait until we get the init signal
-	<-this.control
-	this.running = true
-	go this.body()
-	// wait until we get another signal to terminate
-	<-this.control
-	// shut the machine down
-	this.running = false
-	close(this.op)
-	close(this.a)
-	close(this.b)
-	close(this.out)
-	// wait until body finishes
-	<-this.control
-	close(this.control)
-}
 
 const (
 	AluSubtract = iota
@@ -196,30 +158,36 @@ const (
 	AluLessThanOrEqualToZero
 )
 
-func (this *Alu) body() {
-	for this.running {
-		select {
-		case op := <-this.op:
-			a := <-this.a
-			switch op {
-			case AluSubtract:
-				result := (a - <-this.b)
-				this.out <- result
-				this.out <- result
-			case AluLessThanZero:
-				if a < 0 {
-					this.out <- 1
-				} else {
-					this.out <- 0
-				}
-			case AluLessThanOrEqualToZero:
-				if a <= 0 {
-					this.out <- 1
-				} else {
-					this.out <- 0
+func (this *Alu) Run(ctx context.Context) error {
+	for {
+		op, ok := supervisor.Receive(ctx, this.op)
+		if !ok {
+			return nil
+		}
+		a, ok := supervisor.Receive(ctx, this.a)
+		if !ok {
+			return nil
+		}
+		switch op {
+		case AluSubtract:
+			if b, ok := supervisor.Receive(ctx, this.b); ok {
+				result := a - b
+				if supervisor.Send(ctx, this.out, result) {
+					supervisor.Send(ctx, this.out, result)
 				}
 			}
-		default:
+		case AluLessThanZero:
+			if a < 0 {
+				supervisor.Send(ctx, this.out, 1)
+			} else {
+				supervisor.Send(ctx, this.out, 0)
+			}
+		case AluLessThanOrEqualToZero:
+			if a <= 0 {
+				supervisor.Send(ctx, this.out, 1)
+			} else {
+				supervisor.Send(ctx, this.out, 0)
+			}
 		}
 	}
 }
@@ -245,56 +213,98 @@ type Core struct {
 	memory *MemoryUnit
 	alu    *Alu
 	debug  bool
+	traced bool
+	units  *supervisor.Supervisor
 }
 
+// How many values each channel of a unit can hold before the sender blocks.
+// Run queues up three requests for a unit before it collects any results, the
+// unit takes the first one straight away and the other two have to fit.
+type Depths struct {
+	Branch, Memory, Alu int
+}
+
+const MinimumDepth = 2
+
+var DefaultDepths = Depths{Branch: 4, Memory: MemorySize, Alu: 4}
+
 func New() (*Core, error) {
+	return NewWithDepths(DefaultDepths)
+}
+
+// The units are started right away, the assembler needs the memory unit
+func NewWithDepths(depths Depths) (*Core, error) {
+	if depths.Branch < MinimumDepth || depths.Memory < MinimumDepth || depths.Alu < MinimumDepth {
+		return nil, fmt.Errorf("Unit depths %+v must be at least %d", depths, MinimumDepth)
+	}
 	var c Core
-	c.branch = NewBranchUnit()
-	c.memory = NewMemoryUnit()
-	c.alu = NewAlu()
-	c.branch.Control <- 0
-	c.memory.Control <- 0
-	c.alu.Control <- 0
-	return &c, nil
+	c.branch = NewBranchUnit(depths.Branch)
+	c.memory = NewMemoryUnit(depths.Memory)
+	c.alu = NewAlu(depths.Alu)
+	c.units = supervisor.New()
+	if err := c.units.Add("branch", c.branch); err != nil {
+		return nil, err
+	} else if err := c.units.Add("memory", c.memory); err != nil {
+		return nil, err
+	} else if err := c.units.Add("alu", c.alu); err != nil {
+		return nil, err
+	} else if err := c.units.Start(context.Background()); err != nil {
+		return nil, err
+	} else {
+		return &c, nil
+	}
 }
 
 func (this *Core) Run() error {
 	// this xand8 core is now data driven like hardware microcode is (I
 	// think)
 	for {
-		this.branch.OnFalse <- this.pc + 3 // the onFalse branch will always be pc + 3 so just compute it now
-		// at some point in the future these commands will be executed,
-		// we know what the commands will be so tell the corresponding
-		// units this
+		this.memory.Op <- MemoryLoad    // tell the memory unit that we are going to load memory[pc]
+		this.memory.Op <- MemoryLoad    // tell the memory unit that we are going to load memory[pc + 1]
+		this.memory.Op <- MemoryLoad    // tell the memory unit that we are going to load memory[pc + 2]
+		this.memory.Addr <- this.pc     // load the contents of memory[pc]
+		this.memory.Addr <- this.pc + 1 // load the contents of memory[pc + 1]
+		this.memory.Addr <- this.pc + 2 // load the contents of memory[pc + 2]
+		// every load has to be accounted for, even after a failure, so
+		// that nothing is left behind in the memory unit when we halt
+		var ir [3]Word
+		halt := false
+		for i := 0; i < 3; i++ {
+			if err := <-this.memory.Error; err != nil {
+				halt = true
+			} else {
+				ir[i] = <-this.memory.Result
+			}
+		}
+		if halt {
+			return nil
+		}
+		this.ir = ir
+		a, b, c := ir[0], ir[1], ir[2]
 		this.alu.Op <- AluLessThanZero // tell the alu that we are going to check and see if 'a' is less than zero
-		this.memory.Op <- MemoryLoad   // tell the memory unit that we are going to load memory[pc]
 		this.alu.Op <- AluLessThanZero // tell the alu that we are going to check and see if 'b' is less than zero
-		this.memory.Op <- MemoryLoad   // tell the memory unit that we are going to load memory[pc + 1]
 		this.alu.Op <- AluLessThanZero // tell the alu that we are going to check and see if 'c' is less than zero
-		this.memory.Op <- MemoryLoad   // tell the memory unit that we are going to load memory[pc + 2]
-
-		this.memory.Addr <- this.pc                                                                 // load the contents of memory[pc]
-		this.memory.Addr <- this.pc + 1                                                             // load the contents of memory[pc + 1]
-		this.memory.Addr <- this.pc + 2                                                             // load the contents of memory[pc + 2]
-		if <-this.memory.Error != nil || <-this.memory.Error != nil || <-this.memory.Error != nil { // see if the loads were successful
-			return nil
+		this.alu.First <- a            // check and see if a is less than zero
+		this.alu.First <- b            // check and see if b is less than zero
+		this.alu.First <- c            // check and see if c is less than zero
+		for i := 0; i < 3; i++ {       // check the results of a, b, c
+			if <-this.alu.Result == 1 {
+				halt = true
+			}
 		}
-		// we can cache these commands ahead of time
-		this.memory.Op <- MemoryLoad                                                    // command the memory unit to load the contents of memory[a]
-		this.memory.Op <- MemoryLoad                                                    // command the memory unit to load the contents of memory[b]
-		this.memory.Op <- MemoryStore                                                   // tell the memory unit to perform a store at memory[a]
-		this.alu.Op <- AluSubtract                                                      // tell the alu to perform the subtraction and load two copies of the result into the Result channel
-		a, b, c := <-this.memory.Result, <-this.memory.Result, <-this.memory.Result     // extrac the result of the original three memory loads
-		this.alu.First <- a                                                             // check and see if a is less than zero
-		this.memory.Addr <- a                                                           // denote that we want to load memory[a]
-		this.alu.First <- b                                                             // check and see if b is less than zero
-		this.memory.Addr <- b                                                           // denote that we want to load memory[b]
-		this.alu.First <- c                                                             // check and see if c is less than zero
-		this.memory.Addr <- a                                                           // put a into the address queue ahead of time here for the memory[a] store later on
-		this.branch.OnTrue <- c                                                         // if memory[a] <= 0 then c
-		if <-this.alu.Result == 1 || <-this.alu.Result == 1 || <-this.alu.Result == 1 { // check the results of a, b, c
+		if halt {
 			return nil
 		}
+		// the instruction is valid so commit to executing it
+		this.branch.OnFalse <- this.pc + 3 // the onFalse branch will always be pc + 3
+		this.branch.OnTrue <- c            // if memory[a] <= 0 then c
+		this.memory.Op <- MemoryRead       // command the memory unit to read the contents of memory[a]
+		this.memory.Op <- MemoryRead       // command the memory unit to read the contents of memory[b]
+		this.memory.Op <- MemoryWrite      // tell the memory unit to perform a write to memory[a]
+		this.alu.Op <- AluSubtract         // tell the alu to perform the subtraction and load two copies of the result into the Result channel
+		this.memory.Addr <- a              // denote that we want to load memory[a]
+		this.memory.Addr <- b              // denote that we want to load memory[b]
+		this.memory.Addr <- a              // put a into the address queue ahead of time here for the memory[a] store later on
 		// setup the conditional check ahead of time since we are
 		// dependent on the resulting condition, nothing more
 		this.alu.First <- <-this.memory.Result     // load memory[a] into the alu first "register"
@@ -319,10 +329,25 @@ func (this *Core) Startup() error {
 }
 
 func (this *Core) Shutdown() error {
-	this.branch.Control <- 0
-	this.memory.Control <- 0
-	this.alu.Control <- 0
-	return nil
+	return this.units.Shutdown()
+}
+
+// The console belongs to the memory unit, it must not be changed while the
+// core is running
+func (this *Core) Console() *xand.Console {
+	return this.memory.console
+}
+
+func (this *Core) SetOutput(w io.Writer) {
+	this.memory.console.SetOutput(w)
+}
+
+func (this *Core) SetInput(r io.Reader) {
+	this.memory.console.SetInput(r)
+}
+
+func (this *Core) SetOption(name, value string) error {
+	return this.memory.console.SetOption(name, value)
 }
 
 func (this *Core) GetDebugStatus() bool {
@@ -356,367 +381,32 @@ func (this *Core) InstallProgram(input <-chan byte) error {
 	return <-err
 }
 
-func (this *Core) dump0(output chan<- byte, done chan<- bool) {
+func (this *Core) dump0(output chan<- byte, done chan<- error) {
 	for i := 0; i < MemorySize; i++ {
+		if err := <-this.memory.Error; err != nil {
+			done <- err
+			return
+		}
 		output <- byte(<-this.memory.Result)
 	}
-	done <- true
+	done <- nil
 }
 func (this *Core) Dump(output chan<- byte) error {
-	done := make(chan bool)
+	done := make(chan error)
 	go this.dump0(output, done)
 	for i := 0; i < MemorySize; i++ {
-
+		this.memory.Op <- MemoryLoad
+		this.memory.Addr <- Word(i)
 	}
-	<-done
-	return nil
+	return <-done
 }
 
+// The image format is the same as the one of the 8-bit xand core so its
+// assembler is used as is
 func generateParser(a ...interface{}) (parser.Parser, error) {
-	var p _parser
-	if core, err := New(); err != nil {
-		return nil, err
-	} else {
-		p.core = core
-		p.labels = make(map[string]Word)
-		return &p, nil
-	}
+	return xand.NewParser(xand.Xand)
 }
 
 func init() {
 	parser.Register(RegistrationName(), parser.Registrar(generateParser))
 }
-
-type deferredAddress struct {
-	addr  Word
-	title string
-}
-
-type _parser struct {
-	core       *Core
-	labels     map[string]Word
-	statements []*statement
-	deferred   []deferredAddress
-}
-
-func (this *_parser) Dump(pipe chan<- byte) error {
-	return this.core.Dump(pipe)
-}
-
-func (this *_parser) Parse(lines <-chan parser.Entry) error {
-	for line := range lines {
-		stmt := carveLine(line.Line)
-		stmt.index = line.Index
-		this.statements = append(this.statements, stmt)
-		for _, str := range stmt.contents {
-			if err := str.Parse(); err != nil {
-				return fmt.Errorf("Error: line: %d : %s\n", line.Index, err)
-			}
-		}
-	}
-	return nil
-}
-
-type nodeType int
-
-func (this nodeType) String() string {
-	switch this {
-	case typeId:
-		return "id"
-	case typeImmediate:
-		return "immediate"
-	case typeLabel:
-		return "label"
-	case typeComment:
-		return "comment"
-	case keywordXand:
-		return "xand"
-	case keywordDotDotDot:
-		return "..."
-	default:
-		return fmt.Sprintf("%d", this)
-	}
-}
-func (this nodeType) immediate() bool {
-	return this == typeImmediate
-}
-func (this nodeType) comment() bool {
-	return this == typeComment
-}
-
-const (
-	typeId nodeType = iota
-	typeImmediate
-	typeLabel
-	typeComment
-	keywordXand
-	keywordDotDotDot
-)
-
-type node struct {
-	Value interface{}
-	Type  nodeType
-}
-
-func parseDecimalImmediate(str string) (Word, error) {
-	val, err := strconv.ParseInt(str, 10, 8)
-	return Word(val), err
-}
-
-func (this *node) parseLabel(val string) error {
-	nVal := strings.TrimSuffix(val, ":")
-	q, _ := utf8.DecodeRuneInString(nVal)
-	if !unicode.IsLetter(q) {
-		return fmt.Errorf("Label %s starts with a non letter %s!", nVal, q)
-	} else {
-		this.Type = typeLabel
-		this.Value = nVal
-		// now parse the label as a entirely new node and see if we get a register back
-		if nVal == "xand" {
-			return fmt.Errorf("Can't name a label xand")
-		} else {
-			return nil
-		}
-	}
-}
-
-func (this *node) parseImmediate(val string) error {
-	this.Type = typeImmediate
-	if v, err := parseDecimalImmediate(val[1:]); err != nil {
-		return err
-	} else {
-		this.Value = v
-		return nil
-	}
-}
-
-var keywords = map[string]nodeType{
-	"xand": keywordXand,
-	"...":  keywordDotDotDot,
-}
-
-func (this *node) parseGeneric(val string) error {
-	if v, ok := keywords[val]; ok {
-		this.Type = v
-		return nil
-	} else {
-		return fmt.Errorf("Unknown statement %s", val)
-	}
-}
-
-func (this *node) Parse() error {
-	if this.Type == typeId {
-		val := this.Value.(string)
-		if this.parseGeneric(val) == nil {
-
-		} else if strings.HasSuffix(val, ":") {
-			return this.parseLabel(val)
-		} else if strings.HasPrefix(val, ";") {
-			this.Type = typeComment
-			this.Value = strings.TrimPrefix(val, ";")
-		} else if strings.HasPrefix(val, "#") {
-			return this.parseImmediate(val)
-		}
-	}
-	return nil
-}
-
-func (this *node) isComment() bool {
-	return this.Type == typeComment
-}
-
-func (this *node) isLabel() bool {
-	return this.Type == typeLabel
-}
-
-type statement struct {
-	contents []*node
-	index    int
-}
-
-func (this *statement) Add(value string, t nodeType) {
-	// always trim before adding
-	str := strings.TrimSpace(value)
-	if len(str) > 0 {
-		this.contents = append(this.contents, &node{Value: str, Type: t})
-	}
-}
-func (this *statement) AddUnknown(value string) {
-	this.Add(value, typeId)
-}
-func (this *statement) String() string {
-	str := fmt.Sprintf("%d: ", this.index)
-	for _, n := range this.contents {
-		str += fmt.Sprintf(" %T: %s ", n, *n)
-	}
-	return str
-}
-func (this *statement) First() (*node, error) {
-	if len(this.contents) == 0 {
-		return nil, fmt.Errorf("Empty statement!")
-	} else {
-		return this.contents[0], nil
-	}
-}
-func (this *statement) Rest() []*node {
-	return this.contents[1:]
-}
-
-func carveLine(line string) *statement {
-	// trim the damn line first
-	data := strings.TrimSpace(line)
-	var s statement
-	if len(data) == 0 {
-		return &s
-	}
-	oldStart := 0
-	start := 0
-	// skip the strings at the beginning
-	for width := 0; start < len(data); start += width {
-		var r rune
-		next := data[start:]
-		r, width = utf8.DecodeRuneInString(next)
-		if unicode.IsSpace(r) {
-			s.AddUnknown(data[oldStart:start])
-			oldStart = start
-		} else if r == ';' {
-			// consume the rest of the data
-			s.AddUnknown(data[oldStart:start])
-			// then capture the comment
-			s.Add(data[start:], typeComment)
-			oldStart = start
-			break
-		}
-	}
-	if oldStart < start {
-		s.AddUnknown(data[oldStart:])
-	}
-	return &s
-}
-
-func (this *_parser) Process() error {
-	for _, stmt := range this.statements {
-		if err := this.parseStatement(stmt); err != nil {
-			return fmt.Errorf("Error: line %d: msg: %s", stmt.index, err)
-		}
-	}
-	for _, d := range this.deferred {
-		if entry, ok := this.labels[d.title]; !ok {
-			return fmt.Errorf("Label %s not defined!", d.title)
-		} else {
-			this.core.memory.Addr <- d.addr
-			this.core.memory.Value <- entry
-			this.core.memory.Op <- 1
-			if err := <-this.core.memory.Error; err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
-func (this *_parser) newLabel(n *node) error {
-	name := n.Value.(string)
-	if _, ok := this.labels[name]; ok {
-		return fmt.Errorf("Label %s is already defined!", name)
-	} else {
-		this.labels[name] = this.core.pc
-		return nil
-	}
-}
-
-func (this *_parser) parseStatement(stmt *statement) error {
-	first, err := stmt.First()
-	if err != nil {
-		return err
-	}
-	rest := stmt.Rest()
-	switch first.Type {
-	case typeComment:
-		if len(rest) > 0 {
-			panic("Programmer Failure! Found something following a comment node in a statement. This is impossible!!!!")
-		} else {
-			return nil
-		}
-	case typeLabel:
-		if err := this.newLabel(first); err != nil {
-			return err
-		} else if len(rest) > 0 {
-			if this.core.pc < 0 {
-				return fmt.Errorf("Too many instructions defined!")
-			}
-			// if there are more entries on the line then check them out
-			var s statement
-			s.index = stmt.index
-			s.contents = rest
-			return this.parseStatement(&s)
-		}
-	case keywordXand:
-		if len(rest) == 3 {
-			if this.core.pc < 0 {
-				return fmt.Errorf("Too many instructions defined!")
-			}
-			var s statement
-			s.index = stmt.index
-			s.contents = rest
-			return this.parseStatement(&s)
-		} else {
-			return fmt.Errorf("xand requires three arguments")
-		}
-	case keywordDotDotDot:
-		this.core.memory.Addr <- this.core.pc
-		this.core.memory.Value <- this.core.pc + 1
-		this.core.memory.Op <- 1
-		if err := <-this.core.memory.Error; err != nil {
-			return err
-		}
-		this.core.pc++
-		// hmmm should we allow this to continue on?...nope
-		if len(rest) > 0 {
-			return fmt.Errorf("... has to terminate a statement")
-		}
-	case typeImmediate:
-		// just install the value to the current address
-		this.core.memory.Addr <- this.core.pc
-		this.core.memory.Value <- first.Value.(Word)
-		this.core.memory.Op <- 1
-		if err := <-this.core.memory.Error; err != nil {
-			return err
-		}
-		this.core.pc++
-		if len(rest) > 0 {
-			if this.core.pc < 0 {
-				return fmt.Errorf("Too many instructions defined!")
-			}
-			var s statement
-			s.index = stmt.index
-			s.contents = rest
-			return this.parseStatement(&s)
-		}
-	case typeId:
-		// defer statement for the time being
-		if addr, ok := this.labels[first.Value.(string)]; !ok {
-			this.deferred = append(this.deferred, deferredAddress{addr: this.core.pc, title: first.Value.(string)})
-		} else {
-			this.core.memory.Addr <- this.core.pc
-			this.core.memory.Value <- addr
-			this.core.memory.Op <- 1
-			if err := <-this.core.memory.Error; err != nil {
-				return err
-			}
-		}
-		this.core.pc++
-		if len(rest) > 0 {
-			if this.core.pc < 0 {
-				return fmt.Errorf("Too many instructions defined!")
-			}
-			var s statement
-			s.index = stmt.index
-			s.contents = rest
-			return this.parseStatement(&s)
-		}
-	default:
-		return fmt.Errorf("Unhandled nodeType %d: %s", first.Type, first.Value)
-	}
-	return nil
-}
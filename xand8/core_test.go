@@ -0,0 +1,210 @@
+package xand
+
+import (
+	"github.com/DrItanium/cores/registration/parser"
+	"github.com/DrItanium/cores/supervisor/leaktest"
+	"github.com/DrItanium/cores/trace"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// multiply 3 by 4, acc ends up at address 15
+const multiply = `
+loop: xand acc neg4 ...
+xand n one stop
+xand t t loop
+stop: xand t t #-1
+n: #3
+one: #1
+neg4: #-4
+acc: #0
+t: #0
+`
+
+func image(src string) ([]byte, error) {
+	p, err := generateParser()
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(src), "\n")
+	entries := make(chan parser.Entry, len(lines))
+	for i, line := range lines {
+		entries <- parser.Entry{Line: line, Index: i + 1}
+	}
+	close(entries)
+	if err := p.Parse(entries); err != nil {
+		return nil, err
+	} else if err := p.Process(); err != nil {
+		return nil, err
+	}
+	out := make(chan byte, MemorySize)
+	if err := p.Dump(out); err != nil {
+		return nil, err
+	}
+	close(out)
+	var b []byte
+	for v := range out {
+		b = append(b, v)
+	}
+	return b, nil
+}
+
+func Test_MinimumDepth(t *testing.T) {
+	defer leaktest.Check(t)()
+	img, err := image(multiply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWithDepths(Depths{Branch: MinimumDepth - 1, Memory: MinimumDepth, Alu: MinimumDepth}); err == nil {
+		t.Errorf("A branch unit shallower than %d was accepted", MinimumDepth)
+	}
+	core, err := NewWithDepths(Depths{Branch: MinimumDepth, Memory: MinimumDepth, Alu: MinimumDepth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := make(chan byte, len(img))
+	for _, b := range img {
+		input <- b
+	}
+	close(input)
+	if err := core.InstallProgram(input); err != nil {
+		t.Fatal(err)
+	} else if err := core.Run(); err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan byte, MemorySize)
+	if err := core.Dump(out); err != nil {
+		t.Fatal(err)
+	} else if err := core.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	var final []byte
+	for v := range out {
+		final = append(final, v)
+	}
+	if final[15] != 12 {
+		t.Errorf("Expected 12 but got %d", final[15])
+	}
+}
+
+func Test_Trace(t *testing.T) {
+	defer leaktest.Check(t)()
+	img, err := image(multiply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the probes add a goroutine between the core and each unit, the
+	// shallowest units are the most likely to deadlock
+	core, err := NewWithDepths(Depths{Branch: MinimumDepth, Memory: MinimumDepth, Alu: MinimumDepth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := trace.NewRecorder()
+	if err := core.Trace(recorder); err != nil {
+		t.Fatal(err)
+	} else if err := core.Trace(trace.NewRecorder()); err == nil {
+		t.Errorf("A core can only be traced once")
+	}
+	input := make(chan byte, len(img))
+	for _, b := range img {
+		input <- b
+	}
+	close(input)
+	if err := core.InstallProgram(input); err != nil {
+		t.Fatal(err)
+	} else if err := core.Run(); err != nil {
+		t.Fatal(err)
+	} else if err := core.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	// each signal has to see its values in the order the core sent them,
+	// the three iterations of the loop followed by the halt
+	for name, expect := range map[string][]uint64{
+		"alu.second":       {252, 1, 0, 252, 1, 0, 252, 1},
+		"branch.condition": {4, 2, 0, 8, 1, 0, 12, 0},
+		"branch.ontrue":    {3, 9, 0, 3, 9, 0, 3, 9},
+		"branch.onfalse":   {3, 6, 9, 3, 6, 9, 3, 6},
+		"branch.result":    {3, 6, 0, 3, 6, 0, 3, 9},
+	} {
+		if got := recorder.Values(name); !reflect.DeepEqual(got, expect) {
+			t.Errorf("%s recorded %v instead of %v", name, got, expect)
+		}
+	}
+	// installing the program writes every cell before the first fetch
+	if ops := recorder.Values("memory.op"); len(ops) < MemorySize || ops[MemorySize-1] != 1 || ops[MemorySize] != 0 {
+		t.Errorf("The install wasn't recorded ahead of the run: %v", ops)
+	} else if errs := recorder.Values("memory.error"); len(errs) != len(ops) {
+		t.Errorf("%d memory operations but %d memory results", len(ops), len(errs))
+	}
+}
+
+// halts on the second instruction because its first operand is negative
+const haltOnFirstOperand = `
+xand t t next
+next: #-1
+#0
+#0
+t: #0
+`
+
+// the same program with the second instruction fixed, n becomes 2
+const fixedSecondInstruction = `
+xand t t next
+next: xand n one stop
+stop: xand t t #-1
+n: #3
+one: #1
+t: #0
+`
+
+func install(core *Core, src string) error {
+	if img, err := image(src); err != nil {
+		return err
+	} else {
+		input := make(chan byte, len(img))
+		for _, b := range img {
+			input <- b
+		}
+		close(input)
+		return core.InstallProgram(input)
+	}
+}
+
+// halting has to collect every result the halting instruction asked for, a
+// leftover value would be picked up by the next request to the unit
+func Test_HaltLeavesUnitsIdle(t *testing.T) {
+	defer leaktest.Check(t)()
+	core, err := NewWithDepths(Depths{Branch: MinimumDepth, Memory: MinimumDepth, Alu: MinimumDepth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := install(core, haltOnFirstOperand); err != nil {
+		t.Fatal(err)
+	} else if err := core.Run(); err != nil {
+		t.Fatal(err)
+	} else if core.pc != 3 {
+		t.Fatalf("The core should halt at 3 but halted at %d", core.pc)
+	} else if err := install(core, fixedSecondInstruction); err != nil {
+		t.Fatal(err)
+	} else if err := core.Run(); err != nil {
+		t.Fatal(err)
+	} else if core.pc != 6 {
+		t.Errorf("The fixed program should halt at 6 but halted at %d", core.pc)
+	}
+	out := make(chan byte, MemorySize)
+	if err := core.Dump(out); err != nil {
+		t.Fatal(err)
+	} else if err := core.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	var final []byte
+	for v := range out {
+		final = append(final, v)
+	}
+	if final[9] != 2 {
+		t.Errorf("Expected 2 but got %d", final[9])
+	}
+}
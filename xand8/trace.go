@@ -0,0 +1,164 @@
+package xand
+
+import (
+	"context"
+	"fmt"
+	"github.com/DrItanium/cores/supervisor"
+	"github.com/DrItanium/cores/trace"
+	"io"
+	"reflect"
+)
+
+// The topology of the core, the control logic in Run is the only thing which
+// sends to or receives from the units
+func (this *Core) Graph() *trace.Graph {
+	g := &trace.Graph{
+		Name: "xand8",
+		Nodes: []trace.Node{
+			{Name: "core", Kind: "control"},
+			{Name: "memory", Kind: "MemoryUnit", Inputs: []string{"op", "addr", "value"}, Outputs: []string{"result", "error"}},
+			{Name: "alu", Kind: "Alu", Inputs: []string{"op", "first", "second"}, Outputs: []string{"result"}},
+			{Name: "branch", Kind: "BranchUnit", Inputs: []string{"condition", "ontrue", "onfalse"}, Outputs: []string{"result"}},
+		},
+	}
+	for _, n := range g.Nodes[1:] {
+		for _, in := range n.Inputs {
+			g.Edges = append(g.Edges, trace.Edge{From: "core", To: n.Name + "." + in})
+		}
+		for _, out := range n.Outputs {
+			g.Edges = append(g.Edges, trace.Edge{From: n.Name + "." + out, To: "core"})
+		}
+	}
+	return g
+}
+
+func (this *Core) WriteDot(w io.Writer) error {
+	return this.Graph().WriteDot(w)
+}
+
+// Probes sit between the core and its units. All of the inputs share a single
+// goroutine and unbuffered channels, the core is the only sender so the values
+// are recorded in exactly the order the core sent them. Each output gets its
+// own probe.
+type probedInput struct {
+	name     string
+	public   chan Word
+	internal chan<- Word
+}
+
+func probeInputs(recorder *trace.Recorder, ports []probedInput) supervisor.Func {
+	return func(ctx context.Context) error {
+		cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}}
+		for _, p := range ports {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(p.public)})
+		}
+		for {
+			chosen, value, _ := reflect.Select(cases)
+			if chosen == 0 {
+				return nil
+			}
+			p := ports[chosen-1]
+			v := value.Interface().(Word)
+			recorder.Record(p.name, uint64(v))
+			if !supervisor.Send(ctx, p.internal, v) {
+				return nil
+			}
+		}
+	}
+}
+
+func probeOutput(recorder *trace.Recorder, name string, internal <-chan Word, public chan<- Word) supervisor.Func {
+	return func(ctx context.Context) error {
+		for {
+			if v, ok := supervisor.Receive(ctx, internal); !ok {
+				return nil
+			} else {
+				recorder.Record(name, uint64(v))
+				if !supervisor.Send(ctx, public, v) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func probeErrors(recorder *trace.Recorder, name string, internal <-chan error, public chan<- error) supervisor.Func {
+	return func(ctx context.Context) error {
+		for {
+			if err, ok := supervisor.Receive(ctx, internal); !ok {
+				return nil
+			} else {
+				if err != nil {
+					recorder.Record(name, 1)
+				} else {
+					recorder.Record(name, 0)
+				}
+				if !supervisor.Send(ctx, public, err) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// Record every value sent to and from the units of the core from now on
+func (this *Core) Trace(recorder *trace.Recorder) error {
+	if this.traced {
+		return fmt.Errorf("Core is already being traced")
+	}
+	inputs := []struct {
+		name string
+		port *chan<- Word
+	}{
+		{"memory.op", &this.memory.Op},
+		{"memory.addr", &this.memory.Addr},
+		{"memory.value", &this.memory.Value},
+		{"alu.op", &this.alu.Op},
+		{"alu.first", &this.alu.First},
+		{"alu.second", &this.alu.Second},
+		{"branch.condition", &this.branch.Condition},
+		{"branch.ontrue", &this.branch.OnTrue},
+		{"branch.onfalse", &this.branch.OnFalse},
+	}
+	outputs := []struct {
+		name string
+		port *<-chan Word
+	}{
+		{"memory.result", &this.memory.Result},
+		{"alu.result", &this.alu.Result},
+		{"branch.result", &this.branch.Result},
+	}
+	for _, in := range inputs {
+		if err := recorder.Signal(in.name, 8); err != nil {
+			return err
+		}
+	}
+	for _, out := range outputs {
+		if err := recorder.Signal(out.name, 8); err != nil {
+			return err
+		}
+	}
+	if err := recorder.Signal("memory.error", 1); err != nil {
+		return err
+	}
+	var probed []probedInput
+	for _, in := range inputs {
+		public := make(chan Word)
+		probed = append(probed, probedInput{name: in.name, public: public, internal: *in.port})
+		*in.port = public
+	}
+	if err := this.units.Add("input probe", probeInputs(recorder, probed)); err != nil {
+		return err
+	}
+	for _, out := range outputs {
+		internal, public := *out.port, make(chan Word, cap(*out.port))
+		*out.port = public
+		if err := this.units.Add(out.name+" probe", probeOutput(recorder, out.name, internal, public)); err != nil {
+			return err
+		}
+	}
+	errInternal, errPublic := this.memory.Error, make(chan error, cap(this.memory.Error))
+	this.memory.Error = errPublic
+	this.traced = true
+	return this.units.Add("memory.error probe", probeErrors(recorder, "memory.error", errInternal, errPublic))
+}
@@ -0,0 +1,742 @@
+package xc
+
+import (
+	"fmt"
+	"github.com/DrItanium/cores/registration/parser"
+	"github.com/DrItanium/cores/xand"
+	"sort"
+	"strings"
+)
+
+// Compile turns an xc program into assembly for an xand core. The program is
+// made of globals and functions, execution starts by calling main and the
+// machine halts once it returns.
+//
+//	var total;             globals start out as zero
+//	var limit = 10;        or as a number
+//	var squares[10];       arrays have a fixed size
+//
+//	func square(n) {
+//		var i = 0;
+//		var result = 0;
+//		while (i < n) {
+//			result = result + n;
+//			i = i + 1;
+//		}
+//		return result;
+//	}
+//
+//	func main() {
+//		var i = 0;
+//		while (i < limit) {
+//			squares[i] = square(i);
+//			if (squares[i] > 50 && !(i == 9)) {
+//				putc('!');
+//			} else if (i != 0) {
+//				total = total + squares[i];
+//			}
+//			i = i + 1;
+//		}
+//		putc(getc());
+//	}
+//
+// Expressions have + and -, the comparisons, && and || which short circuit,
+// unary - and ! as well as calls and array elements. Numbers are decimal, hex
+// (0x1F) or characters ('a') and have to fit into a word. Comparisons are
+// signed.
+//
+// Every variable and temporary is a fixed memory cell, a function returns by
+// jumping through an instruction which each call patches with its own return
+// address. That means functions can't recurse, directly or otherwise, and
+// local variables keep their cells between calls. Local scalars declared
+// without a value are cleared each time the declaration runs. Array elements
+// are read and written by patching the operands of an instruction with the
+// address of the element, indices aren't checked.
+//
+// putc(c) writes a byte to the console and getc() reads one, -1 once the
+// input runs out.
+func Compile(src string, config xand.Config) (string, error) {
+	if err := config.Validate(); err != nil {
+		return "", err
+	} else if tokens, err := lex(src); err != nil {
+		return "", err
+	} else if prog, err := parse(tokens); err != nil {
+		return "", err
+	} else {
+		g := generator{
+			config:    config,
+			globals:   make(map[string]*variable),
+			functions: make(map[string]*function),
+			constants: make(map[int64]bool),
+		}
+		return g.program(prog)
+	}
+}
+
+// the calls into main are made from a context which can't clash with a user
+// function since names never contain a $
+const entry = "xc$entry"
+
+var builtins = map[string]int{
+	"putc": 1,
+	"getc": 0,
+}
+
+type generator struct {
+	config    xand.Config
+	globals   map[string]*variable
+	functions map[string]*function
+	constants map[int64]bool
+	code      []string
+	data      []string
+	// the function being compiled
+	context string
+	locals  map[string]*variable
+	temps   map[string]bool
+	labels  int
+}
+
+func errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("Error: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (this *generator) emit(format string, args ...interface{}) {
+	this.code = append(this.code, "\t"+fmt.Sprintf(format, args...))
+}
+
+func (this *generator) place(label string) {
+	this.code = append(this.code, label+":")
+}
+
+func (this *generator) cell(name string, format string, args ...interface{}) {
+	this.data = append(this.data, fmt.Sprintf("%s: %s", name, fmt.Sprintf(format, args...)))
+}
+
+func (this *generator) label() string {
+	this.labels++
+	return fmt.Sprintf("%s$L%d", this.context, this.labels)
+}
+
+// Temporaries are only written once but loops run their code again so temp
+// clears them first, fresh is for ones which get a value right away
+func (this *generator) fresh() string {
+	name := fmt.Sprintf("%s$t%d", this.context, len(this.temps))
+	this.temps[name] = true
+	this.cell(name, "#0")
+	return name
+}
+
+func (this *generator) temp() string {
+	name := this.fresh()
+	this.emit("clr %s", name)
+	return name
+}
+
+// Numbers are checked against the word width, anything past the largest
+// signed value is taken as a bit pattern
+func (this *generator) fit(value int64, line int) (int64, error) {
+	if bits := this.config.Bits; bits == 64 {
+		return value, nil
+	} else if value < -(1<<(bits-1)) || value >= 1<<bits {
+		return 0, errorf(line, "%d doesn't fit in a %d-bit word", value, bits)
+	} else {
+		return value << (64 - bits) >> (64 - bits), nil
+	}
+}
+
+func (this *generator) constant(value int64, line int) (string, error) {
+	if v, err := this.fit(value, line); err != nil {
+		return "", err
+	} else {
+		this.constants[v] = true
+		return constantName(v), nil
+	}
+}
+
+func constantName(value int64) string {
+	if value < 0 {
+		return fmt.Sprintf("k$m%d", -value)
+	} else {
+		return fmt.Sprintf("k$%d", value)
+	}
+}
+
+func (this *generator) declareGlobal(name string, line int) error {
+	if xand.Reserved(name) {
+		return errorf(line, "%s is reserved by the assembler", name)
+	} else if _, ok := builtins[name]; ok {
+		return errorf(line, "%s is a builtin function", name)
+	} else if _, ok := this.globals[name]; ok {
+		return errorf(line, "%s is already defined", name)
+	} else if _, ok := this.functions[name]; ok {
+		return errorf(line, "%s is already defined", name)
+	} else {
+		return nil
+	}
+}
+
+func (this *generator) program(prog *program) (string, error) {
+	for _, v := range prog.globals {
+		if err := this.declareGlobal(v.name, v.line); err != nil {
+			return "", err
+		} else if value, err := this.fit(v.value, v.line); err != nil {
+			return "", err
+		} else {
+			this.globals[v.name] = v
+			this.storage(v.name, v.size, value)
+		}
+	}
+	for _, f := range prog.functions {
+		if err := this.declareGlobal(f.name, f.line); err != nil {
+			return "", err
+		}
+		this.functions[f.name] = f
+	}
+	if main, ok := this.functions["main"]; !ok {
+		return "", fmt.Errorf("Error: No main function")
+	} else if len(main.params) != 0 {
+		return "", errorf(main.line, "main can't take any parameters")
+	} else if err := this.checkRecursion(prog); err != nil {
+		return "", err
+	}
+	this.enter(entry)
+	if _, err := this.call(&expr{kind: exprCall, name: "main"}); err != nil {
+		return "", err
+	}
+	this.emit("halt")
+	for _, f := range prog.functions {
+		if err := this.function(f); err != nil {
+			return "", err
+		}
+	}
+	var values []int64
+	for value := range this.constants {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	for _, value := range values {
+		this.cell(constantName(value), "#%d", value)
+	}
+	return strings.Join(append(this.code, this.data...), "\n") + "\n", nil
+}
+
+// arrays get an extra cell holding their address
+func (this *generator) storage(name string, size int, value int64) {
+	if size == 0 {
+		this.cell(name, "#%d", value)
+	} else {
+		this.cell(name, ".fill %d", size)
+		this.cell(name+"$addr", ".word %s", name)
+	}
+}
+
+func calls(body []*stmt, visit func(*expr)) {
+	var walk func(e *expr)
+	walk = func(e *expr) {
+		if e == nil {
+			return
+		} else if e.kind == exprCall {
+			visit(e)
+		}
+		for _, arg := range e.args {
+			walk(arg)
+		}
+	}
+	for _, s := range body {
+		walk(s.index)
+		walk(s.value)
+		calls(s.body, visit)
+		calls(s.orElse, visit)
+	}
+}
+
+func (this *generator) checkRecursion(prog *program) error {
+	for _, f := range prog.functions {
+		seen := make(map[string]bool)
+		var visit func(name string, path []string) error
+		visit = func(name string, path []string) error {
+			callee, ok := this.functions[name]
+			if !ok || seen[name] {
+				return nil
+			}
+			seen[name] = true
+			var err error
+			calls(callee.body, func(e *expr) {
+				if err != nil {
+					return
+				} else if e.name == f.name {
+					err = errorf(f.line, "%s calls itself through %s, functions can't recurse", f.name, strings.Join(append(path, e.name), " -> "))
+				} else {
+					err = visit(e.name, append(path, e.name))
+				}
+			})
+			return err
+		}
+		if err := visit(f.name, []string{f.name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *generator) enter(context string) {
+	this.context = context
+	this.locals = make(map[string]*variable)
+	this.temps = make(map[string]bool)
+	this.labels = 0
+}
+
+func (this *generator) local(name string) string {
+	return this.context + "." + name
+}
+
+// The return jump is the last instruction of every function, callers patch
+// its target
+func (this *generator) function(f *function) error {
+	this.enter(f.name)
+	for _, param := range f.params {
+		if _, ok := this.locals[param]; ok {
+			return errorf(f.line, "Parameter %s is given twice", param)
+		}
+		this.locals[param] = &variable{name: param, line: f.line}
+		this.cell(this.local(param), "#0")
+	}
+	this.cell(f.name+"$rv", "#0")
+	this.place(f.name)
+	this.emit("clr %s$rv", f.name)
+	if err := this.block(f.body); err != nil {
+		return err
+	}
+	this.place(f.name + "$exit")
+	this.emit("xand Z Z #0")
+	return nil
+}
+
+func (this *generator) block(body []*stmt) error {
+	for _, s := range body {
+		if err := this.statement(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *generator) mov(dst, src string) {
+	if dst != src {
+		this.emit("mov %s %s", dst, src)
+	}
+}
+
+func (this *generator) statement(s *stmt) error {
+	switch s.kind {
+	case stmtVar:
+		if _, ok := this.locals[s.name]; ok {
+			return errorf(s.line, "%s is already defined", s.name)
+		}
+		this.locals[s.name] = &variable{name: s.name, size: s.size, line: s.line}
+		this.storage(this.local(s.name), s.size, 0)
+		if s.value != nil {
+			return this.assign(s)
+		} else if s.size == 0 {
+			this.emit("clr %s", this.local(s.name))
+		}
+		return nil
+	case stmtAssign:
+		return this.assign(s)
+	case stmtIf:
+		otherwise, end := this.label(), this.label()
+		if err := this.branch(s.value, otherwise, false); err != nil {
+			return err
+		} else if err := this.block(s.body); err != nil {
+			return err
+		} else if len(s.orElse) > 0 {
+			this.emit("jmp %s", end)
+		}
+		this.place(otherwise)
+		if err := this.block(s.orElse); err != nil {
+			return err
+		}
+		this.place(end)
+		return nil
+	case stmtWhile:
+		top, end := this.label(), this.label()
+		this.place(top)
+		if err := this.branch(s.value, end, false); err != nil {
+			return err
+		} else if err := this.block(s.body); err != nil {
+			return err
+		}
+		this.emit("jmp %s", top)
+		this.place(end)
+		return nil
+	case stmtReturn:
+		if s.value != nil {
+			if v, err := this.value(s.value); err != nil {
+				return err
+			} else {
+				this.mov(this.context+"$rv", v)
+			}
+		}
+		this.emit("jmp %s$exit", this.context)
+		return nil
+	case stmtExpr:
+		_, err := this.value(s.value)
+		return err
+	default:
+		return errorf(s.line, "Unknown statement")
+	}
+}
+
+// the cell a variable lives in, locals hide globals
+func (this *generator) lookup(name string, line int) (*variable, string, error) {
+	if v, ok := this.locals[name]; ok {
+		return v, this.local(name), nil
+	} else if v, ok := this.globals[name]; ok {
+		return v, name, nil
+	} else if _, ok := this.functions[name]; ok {
+		return nil, "", errorf(line, "%s is a function", name)
+	} else {
+		return nil, "", errorf(line, "%s is not defined", name)
+	}
+}
+
+func (this *generator) assign(s *stmt) error {
+	v, cell, err := this.lookup(s.name, s.line)
+	if err != nil {
+		return err
+	} else if s.index == nil && v.size != 0 {
+		return errorf(s.line, "Can't assign to the array %s", s.name)
+	} else if s.index == nil {
+		if value, err := this.value(s.value); err != nil {
+			return err
+		} else {
+			this.mov(cell, value)
+			return nil
+		}
+	}
+	if v.size == 0 {
+		return errorf(s.line, "%s is not an array", s.name)
+	} else if s.index.kind == exprNumber {
+		// constant indices become plain label arithmetic
+		if s.index.value < 0 || s.index.value >= int64(v.size) {
+			return errorf(s.line, "Index %d is outside of %s", s.index.value, s.name)
+		} else if value, err := this.value(s.value); err != nil {
+			return err
+		} else {
+			this.mov(fmt.Sprintf("%s+%d", cell, s.index.value), value)
+			return nil
+		}
+	} else if negAddress, err := this.address(cell, s.index); err != nil {
+		return err
+	} else if value, err := this.value(s.value); err != nil {
+		return err
+	} else {
+		negValue := this.negate(value)
+		target := this.label()
+		this.patch(target, negAddress)
+		this.patch(target+"+1", negAddress)
+		this.patch(target+"+3", negAddress)
+		this.place(target)
+		this.emit("xand #0 #0 ...")
+		this.emit("xand #0 %s ...", negValue)
+		return nil
+	}
+}
+
+// a fresh temporary holding the negated value
+func (this *generator) negate(cell string) string {
+	t := this.temp()
+	this.emit("xand %s %s ...", t, cell)
+	return t
+}
+
+// the negated address of an array element
+func (this *generator) address(array string, index *expr) (string, error) {
+	if i, err := this.value(index); err != nil {
+		return "", err
+	} else {
+		p := this.owned(i)
+		this.emit("add %s %s$addr", p, array)
+		return this.negate(p), nil
+	}
+}
+
+// overwrite a cell in the code with an address, given negated
+func (this *generator) patch(target, negAddress string) {
+	this.emit("xand %s %s ...", target, target)
+	this.emit("xand %s %s ...", target, negAddress)
+}
+
+// a temporary which can be changed freely, variables get copied
+func (this *generator) owned(cell string) string {
+	if this.temps[cell] {
+		return cell
+	} else {
+		t := this.fresh()
+		this.mov(t, cell)
+		return t
+	}
+}
+
+// Evaluate an expression and give back the cell holding its value, variables
+// are used in place
+func (this *generator) value(e *expr) (string, error) {
+	switch e.kind {
+	case exprNumber:
+		return this.constant(e.value, e.line)
+	case exprVariable:
+		if v, cell, err := this.lookup(e.name, e.line); err != nil {
+			return "", err
+		} else if v.size != 0 {
+			return "", errorf(e.line, "The array %s can only be used through its elements", e.name)
+		} else {
+			return cell, nil
+		}
+	case exprIndex:
+		if v, cell, err := this.lookup(e.name, e.line); err != nil {
+			return "", err
+		} else if v.size == 0 {
+			return "", errorf(e.line, "%s is not an array", e.name)
+		} else if index := e.args[0]; index.kind == exprNumber {
+			if index.value < 0 || index.value >= int64(v.size) {
+				return "", errorf(e.line, "Index %d is outside of %s", index.value, e.name)
+			}
+			t := this.fresh()
+			this.mov(t, fmt.Sprintf("%s+%d", cell, index.value))
+			return t, nil
+		} else if negAddress, err := this.address(cell, index); err != nil {
+			return "", err
+		} else {
+			// T ends up as the negated element
+			source := this.label()
+			this.patch(source+"+1", negAddress)
+			t := this.temp()
+			this.emit("xand T T ...")
+			this.place(source)
+			this.emit("xand T #0 ...")
+			this.emit("xand %s T ...", t)
+			return t, nil
+		}
+	case exprCall:
+		return this.call(e)
+	case exprUnary:
+		if e.op == "-" {
+			if v, err := this.value(e.args[0]); err != nil {
+				return "", err
+			} else {
+				return this.negate(v), nil
+			}
+		}
+		return this.truth(e)
+	case exprBinary:
+		switch e.op {
+		case "+", "-":
+			if lhs, rhs, err := this.operands(e); err != nil {
+				return "", err
+			} else if e.op == "+" {
+				this.emit("add %s %s", lhs, rhs)
+				return lhs, nil
+			} else {
+				this.emit("xand %s %s ...", lhs, rhs)
+				return lhs, nil
+			}
+		default:
+			return this.truth(e)
+		}
+	default:
+		return "", errorf(e.line, "Unknown expression")
+	}
+}
+
+// the left operand is copied before the right one is evaluated in case the
+// right one changes it
+func (this *generator) operands(e *expr) (string, string, error) {
+	if lhs, err := this.value(e.args[0]); err != nil {
+		return "", "", err
+	} else {
+		lhs = this.owned(lhs)
+		rhs, err := this.value(e.args[1])
+		return lhs, rhs, err
+	}
+}
+
+// conditions used as values are one when true and zero otherwise
+func (this *generator) truth(e *expr) (string, error) {
+	t, skip := this.temp(), this.label()
+	if err := this.branch(e, skip, false); err != nil {
+		return "", err
+	}
+	this.emit("inc %s", t)
+	this.place(skip)
+	return t, nil
+}
+
+// jump to the label when the condition is the same as when
+func (this *generator) branch(e *expr, label string, when bool) error {
+	if e.kind == exprUnary && e.op == "!" {
+		return this.branch(e.args[0], label, !when)
+	} else if e.kind != exprBinary {
+		return this.test(e, label, when)
+	}
+	switch e.op {
+	case "&&", "||":
+		// a short circuit happens when the left side is false for && and
+		// true for ||
+		short := e.op == "||"
+		if when == short {
+			if err := this.branch(e.args[0], label, when); err != nil {
+				return err
+			}
+			return this.branch(e.args[1], label, when)
+		}
+		skip := this.label()
+		if err := this.branch(e.args[0], skip, short); err != nil {
+			return err
+		} else if err := this.branch(e.args[1], label, when); err != nil {
+			return err
+		}
+		this.place(skip)
+		return nil
+	case "==", "!=", "<", "<=", ">", ">=":
+		// everything is turned into == != or <= so that only one kind of
+		// ordering has to be generated
+		op, args := e.op, e.args
+		switch op {
+		case "<":
+			op, args, when = "<=", []*expr{args[1], args[0]}, !when
+		case ">":
+			op, when = "<=", !when
+		case ">=":
+			op, args = "<=", []*expr{args[1], args[0]}
+		}
+		lhs, rhs, err := this.operands(&expr{args: args})
+		if err != nil {
+			return err
+		}
+		switch op {
+		case "==", "!=":
+			// the difference wraps around but is only zero when the two
+			// are equal
+			this.emit("xand %s %s ...", lhs, rhs)
+			this.zero(lhs, label, (op == "==") == when)
+		case "<=":
+			this.lessOrEqual(lhs, rhs, label, when)
+		}
+		return nil
+	default:
+		return this.test(e, label, when)
+	}
+}
+
+// The difference of two numbers with different signs can overflow so the
+// signs decide those, the operands are only subtracted when their signs match.
+// lhs is a temporary and ends up as the difference.
+func (this *generator) lessOrEqual(lhs, rhs, label string, when bool) {
+	local := this.label()
+	yes, no := label, local
+	if !when {
+		yes, no = local, label
+	}
+	lhsNegative, same := this.label(), this.label()
+	this.negative(lhs, lhsNegative)
+	this.negative(rhs, no)
+	this.emit("jmp %s", same)
+	this.place(lhsNegative)
+	this.negative(rhs, same)
+	this.emit("jmp %s", yes)
+	this.place(same)
+	this.emit("xand %s %s %s", lhs, rhs, yes)
+	if !when {
+		this.emit("jmp %s", no)
+	}
+	this.place(local)
+}
+
+// jump to the label when the cell is below zero, the cell is left alone
+func (this *generator) negative(cell, label string) {
+	atMostZero, skip := this.label(), this.label()
+	this.emit("xand %s Z %s", cell, atMostZero)
+	this.emit("jmp %s", skip)
+	this.place(atMostZero)
+	this.emit("jz %s %s", cell, skip)
+	this.emit("jmp %s", label)
+	this.place(skip)
+}
+
+// anything but zero is true
+func (this *generator) test(e *expr, label string, when bool) error {
+	if v, err := this.value(e); err != nil {
+		return err
+	} else {
+		this.zero(v, label, !when)
+		return nil
+	}
+}
+
+// jump to the label when the zeroness of the cell is the same as when
+func (this *generator) zero(cell, label string, when bool) {
+	if when {
+		this.emit("jz %s %s", cell, label)
+	} else {
+		skip := this.label()
+		this.emit("jz %s %s", cell, skip)
+		this.emit("jmp %s", label)
+		this.place(skip)
+	}
+}
+
+// Arguments are all evaluated before any parameter is set since they can
+// call the same function. The return jump of the callee is pointed back here
+// before jumping in.
+func (this *generator) call(e *expr) (string, error) {
+	if arity, ok := builtins[e.name]; ok {
+		if len(e.args) != arity {
+			return "", errorf(e.line, "%s takes %d arguments but was given %d", e.name, arity, len(e.args))
+		}
+		if e.name == "getc" {
+			t := this.fresh()
+			this.emit("getc %s", t)
+			return t, nil
+		} else if v, err := this.value(e.args[0]); err != nil {
+			return "", err
+		} else {
+			this.emit("putc %s", v)
+			return this.temp(), nil
+		}
+	}
+	f, ok := this.functions[e.name]
+	if !ok {
+		if _, _, err := this.lookup(e.name, e.line); err == nil {
+			return "", errorf(e.line, "%s is not a function", e.name)
+		} else {
+			return "", errorf(e.line, "Function %s is not defined", e.name)
+		}
+	} else if len(e.args) != len(f.params) {
+		return "", errorf(e.line, "%s takes %d arguments but was given %d", e.name, len(f.params), len(e.args))
+	}
+	var args []string
+	for _, arg := range e.args {
+		if v, err := this.value(arg); err != nil {
+			return "", err
+		} else {
+			args = append(args, v)
+		}
+	}
+	for i, param := range f.params {
+		this.mov(f.name+"."+param, args[i])
+	}
+	back := this.label()
+	this.cell(back+"$neg", ".word -%s", back)
+	this.patch(f.name+"$exit+2", back+"$neg")
+	this.emit("jmp %s", f.name)
+	this.place(back)
+	t := this.fresh()
+	this.mov(t, f.name+"$rv")
+	return t, nil
+}
+
+// Assemble runs the output of Compile through the parser of the target and
+// gives back the image
+func Assemble(asm, target string) ([]byte, error) {
+	return parser.Assemble(target, asm)
+}
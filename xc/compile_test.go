@@ -0,0 +1,182 @@
+package xc
+
+import (
+	"bytes"
+	"github.com/DrItanium/cores/xand"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, src, input string) string {
+	asm, err := Compile(src, xand.Xand16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, err := Assemble(asm, "xand16")
+	if err != nil {
+		t.Fatalf("%s\n%s", err, asm)
+	}
+	core, err := xand.New(xand.Xand16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make(chan byte, len(image))
+	for _, b := range image {
+		in <- b
+	}
+	close(in)
+	var out bytes.Buffer
+	core.SetOutput(&out)
+	core.SetInput(strings.NewReader(input))
+	if err := core.InstallProgram(in); err != nil {
+		t.Fatal(err)
+	} else if err := core.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+const printn = `
+func printn(n) {
+	var digits[6];
+	var count = 0;
+	if (n < 0) {
+		putc('-');
+		n = -n;
+	}
+	while (n > 0 || count == 0) {
+		var d = 0;
+		while (n > 9) {
+			n = n - 10;
+			d = d + 1;
+		}
+		digits[count] = n;
+		count = count + 1;
+		n = d;
+	}
+	while (count > 0) {
+		count = count - 1;
+		putc('0' + digits[count]);
+	}
+	putc(' ');
+}
+`
+
+func Test_Programs(t *testing.T) {
+	for _, test := range []struct {
+		name, src, input, expect string
+	}{
+		{"arithmetic", `
+var x = -7;
+func main() {
+	printn(x + 3 - -2);
+	printn(0x7FFF);
+	printn(0xFFFF);
+	printn(-(1 - 5));
+}`, "", "-2 32767 -1 4 "},
+		{"comparisons", `
+func show(c) { if (c) { putc('t'); } else { putc('f'); } }
+func main() {
+	var a = 3;
+	var b = 5;
+	show(a < b); show(a <= b); show(a > b); show(a >= b); show(a == b); show(a != b);
+	show(b < a); show(a <= a); show(a >= a); show(-b < a);
+	putc(' ');
+	show(a < b && b < a); show(a < b || b < a); show(!(a < b)); show(!a); show(!0);
+	show(a && 0 || b);
+	printn(a < b);
+}`, "", "ttffftfttt ftfftt1 "},
+		{"signed comparisons", `
+func show(c) { if (c) { putc('t'); } else { putc('f'); } }
+func main() {
+	// the differences of these overflow a word
+	var a = 30000;
+	var b = -30000;
+	show(a < b); show(a <= b); show(a > b); show(a >= b); show(b < a); show(a == b); show(a != b);
+	putc(' ');
+	var min = -32768;
+	var max = 32767;
+	show(min < max); show(max < min); show(min <= min); show(max >= max); show(min < -1); show(-1 < 0);
+}`, "", "fftttft tftttt"},
+		{"calls", `
+func sum(a, b) { return a + b; }
+func twice(x) { return sum(x, x); }
+func nothing() { }
+func main() {
+	printn(sum(sum(1, 2), sum(3, 4)));
+	printn(twice(twice(5)));
+	printn(nothing());
+}`, "", "10 20 0 "},
+		{"arrays", `
+var sorted[8];
+func main() {
+	var data[8];
+	var i = 0;
+	while (i < 8) {
+		data[i] = 8 - i;
+		i = i + 1;
+	}
+	data[0] = 5;
+	i = 0;
+	// insertion sort into the global array
+	while (i < 8) {
+		var j = i;
+		while (j > 0 && sorted[j - 1] > data[i]) {
+			sorted[j] = sorted[j - 1];
+			j = j - 1;
+		}
+		sorted[j] = data[i];
+		i = i + 1;
+	}
+	i = 0;
+	while (i < 8) {
+		printn(sorted[i]);
+		i = i + 1;
+	}
+	printn(sorted[7]);
+}`, "", "1 2 3 4 5 5 6 7 7 "},
+		{"echo", `
+func main() {
+	var c = getc();
+	while (c != -1) {
+		if (c >= 'a' && c <= 'z') {
+			c = c - 32;
+		} else if (c == ' ') {
+			c = '_';
+		}
+		putc(c);
+		c = getc();
+	}
+}`, "hi there", "HI_THERE"},
+	} {
+		if output := run(t, test.src+printn, test.input); output != test.expect {
+			t.Errorf("%s: expected %q but got %q", test.name, test.expect, output)
+		}
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	for _, src := range []string{
+		"func f() { }",
+		"func main(a) { }",
+		"func main() { x = 1; }",
+		"var x; var x; func main() { }",
+		"var mov; func main() { }",
+		"func putc(c) { } func main() { }",
+		"func main() { main(); }",
+		"func a() { b(); } func b() { a(); } func main() { }",
+		"func f(a) { } func main() { f(); }",
+		"var a[2]; func main() { a = 1; }",
+		"var a[2]; func main() { a[2] = 1; }",
+		"var a; func main() { a[0] = 1; }",
+		"func main() { var x = 70000; }",
+		"var x = 1 + 2; func main() { }",
+		"func main() { if (1) { }",
+		"func main() { 1 = 2; }",
+		"func main() { var _x; }",
+	} {
+		if _, err := Compile(src, xand.Xand16); err == nil {
+			t.Errorf("%q was accepted", src)
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package xc
+
+import (
+	"fmt"
+)
+
+type exprKind int
+
+const (
+	exprNumber exprKind = iota
+	exprVariable
+	exprIndex
+	exprCall
+	exprUnary
+	exprBinary
+)
+
+// op is only used by unary and binary expressions, for an index the array
+// is name and the index is the first argument
+type expr struct {
+	kind  exprKind
+	op    string
+	name  string
+	value int64
+	args  []*expr
+	line  int
+}
+
+type stmtKind int
+
+const (
+	stmtVar stmtKind = iota
+	stmtAssign
+	stmtIf
+	stmtWhile
+	stmtReturn
+	stmtExpr
+)
+
+type stmt struct {
+	kind   stmtKind
+	name   string
+	size   int
+	index  *expr
+	value  *expr
+	body   []*stmt
+	orElse []*stmt
+	line   int
+}
+
+// Scalars have a size of zero
+type variable struct {
+	name  string
+	size  int
+	value int64
+	line  int
+}
+
+type function struct {
+	name   string
+	params []string
+	body   []*stmt
+	line   int
+}
+
+type program struct {
+	globals   []*variable
+	functions []*function
+}
+
+type _parser struct {
+	tokens []token
+	pos    int
+}
+
+func (this *_parser) peek() token {
+	return this.tokens[this.pos]
+}
+
+func (this *_parser) next() token {
+	t := this.tokens[this.pos]
+	if t.Type != tokenEOF {
+		this.pos++
+	}
+	return t
+}
+
+func (this *_parser) is(text string) bool {
+	t := this.peek()
+	return (t.Type == tokenPunct || t.Type == tokenName) && t.Text == text
+}
+
+// consume the token if it is the given text
+func (this *_parser) accept(text string) bool {
+	if this.is(text) {
+		this.next()
+		return true
+	} else {
+		return false
+	}
+}
+
+func (this *_parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Error: line %d: %s", this.peek().line, fmt.Sprintf(format, args...))
+}
+
+func (this *_parser) expect(text string) error {
+	if !this.accept(text) {
+		return this.errorf("Expected %s but found %s", text, this.peek())
+	} else {
+		return nil
+	}
+}
+
+var keywords = map[string]bool{
+	"var":    true,
+	"func":   true,
+	"if":     true,
+	"else":   true,
+	"while":  true,
+	"return": true,
+}
+
+func (this *_parser) name() (string, error) {
+	if t := this.peek(); t.Type != tokenName || keywords[t.Text] {
+		return "", this.errorf("Expected a name but found %s", t)
+	} else {
+		this.next()
+		return t.Text, nil
+	}
+}
+
+func (this *_parser) number() (int64, error) {
+	negative := this.accept("-")
+	if t := this.peek(); t.Type != tokenNumber {
+		return 0, this.errorf("Expected a number but found %s", t)
+	} else if this.next(); negative {
+		return -t.Value, nil
+	} else {
+		return t.Value, nil
+	}
+}
+
+func parse(tokens []token) (*program, error) {
+	p := _parser{tokens: tokens}
+	var prog program
+	for p.peek().Type != tokenEOF {
+		if p.is("var") {
+			if v, err := p.variable(true); err != nil {
+				return nil, err
+			} else {
+				prog.globals = append(prog.globals, &variable{name: v.name, size: v.size, value: v.value.value, line: v.line})
+			}
+		} else if p.accept("func") {
+			if f, err := p.function(); err != nil {
+				return nil, err
+			} else {
+				prog.functions = append(prog.functions, f)
+			}
+		} else {
+			return nil, p.errorf("Expected var or func but found %s", p.peek())
+		}
+	}
+	return &prog, nil
+}
+
+// var name; var name[size]; var name = value; globals can only be
+// initialized with numbers
+func (this *_parser) variable(global bool) (*stmt, error) {
+	s := &stmt{kind: stmtVar, line: this.next().line}
+	var err error
+	if s.name, err = this.name(); err != nil {
+		return nil, err
+	} else if this.accept("[") {
+		if size, err := this.number(); err != nil {
+			return nil, err
+		} else if size < 1 {
+			return nil, this.errorf("Array %s needs at least one element", s.name)
+		} else {
+			s.size = int(size)
+		}
+		if err := this.expect("]"); err != nil {
+			return nil, err
+		}
+	} else if this.accept("=") {
+		if global {
+			if value, err := this.number(); err != nil {
+				return nil, err
+			} else {
+				s.value = &expr{kind: exprNumber, value: value, line: s.line}
+			}
+		} else if s.value, err = this.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if s.value == nil && global {
+		s.value = &expr{kind: exprNumber, line: s.line}
+	}
+	return s, this.expect(";")
+}
+
+func (this *_parser) function() (*function, error) {
+	f := &function{line: this.peek().line}
+	var err error
+	if f.name, err = this.name(); err != nil {
+		return nil, err
+	} else if err := this.expect("("); err != nil {
+		return nil, err
+	}
+	for !this.accept(")") {
+		if len(f.params) > 0 {
+			if err := this.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		if name, err := this.name(); err != nil {
+			return nil, err
+		} else {
+			f.params = append(f.params, name)
+		}
+	}
+	f.body, err = this.block()
+	return f, err
+}
+
+func (this *_parser) block() ([]*stmt, error) {
+	if err := this.expect("{"); err != nil {
+		return nil, err
+	}
+	var body []*stmt
+	for !this.accept("}") {
+		if this.peek().Type == tokenEOF {
+			return nil, this.errorf("Missing }")
+		} else if s, err := this.statement(); err != nil {
+			return nil, err
+		} else {
+			body = append(body, s)
+		}
+	}
+	return body, nil
+}
+
+func (this *_parser) statement() (*stmt, error) {
+	line := this.peek().line
+	switch {
+	case this.is("var"):
+		return this.variable(false)
+	case this.accept("if"):
+		s := &stmt{kind: stmtIf, line: line}
+		var err error
+		if s.value, err = this.condition(); err != nil {
+			return nil, err
+		} else if s.body, err = this.block(); err != nil {
+			return nil, err
+		} else if !this.accept("else") {
+			return s, nil
+		} else if this.is("if") {
+			// else if chains nest
+			if elseIf, err := this.statement(); err != nil {
+				return nil, err
+			} else {
+				s.orElse = []*stmt{elseIf}
+				return s, nil
+			}
+		} else {
+			s.orElse, err = this.block()
+			return s, err
+		}
+	case this.accept("while"):
+		s := &stmt{kind: stmtWhile, line: line}
+		var err error
+		if s.value, err = this.condition(); err != nil {
+			return nil, err
+		} else {
+			s.body, err = this.block()
+			return s, err
+		}
+	case this.accept("return"):
+		s := &stmt{kind: stmtReturn, line: line}
+		if !this.is(";") {
+			var err error
+			if s.value, err = this.expression(); err != nil {
+				return nil, err
+			}
+		}
+		return s, this.expect(";")
+	}
+	e, err := this.expression()
+	if err != nil {
+		return nil, err
+	}
+	s := &stmt{kind: stmtExpr, value: e, line: line}
+	if this.accept("=") {
+		switch e.kind {
+		case exprVariable:
+			s.kind, s.name = stmtAssign, e.name
+		case exprIndex:
+			s.kind, s.name, s.index = stmtAssign, e.name, e.args[0]
+		default:
+			return nil, this.errorf("Can only assign to variables and array elements")
+		}
+		if s.value, err = this.expression(); err != nil {
+			return nil, err
+		}
+	}
+	return s, this.expect(";")
+}
+
+func (this *_parser) condition() (*expr, error) {
+	if err := this.expect("("); err != nil {
+		return nil, err
+	} else if e, err := this.expression(); err != nil {
+		return nil, err
+	} else {
+		return e, this.expect(")")
+	}
+}
+
+// binary operators from the loosest binding to the tightest
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+}
+
+func (this *_parser) expression() (*expr, error) {
+	return this.binary(0)
+}
+
+func (this *_parser) binary(level int) (*expr, error) {
+	if level == len(precedence) {
+		return this.unary()
+	}
+	lhs, err := this.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		found := false
+		for _, op := range precedence[level] {
+			if this.is(op) {
+				line := this.next().line
+				if rhs, err := this.binary(level + 1); err != nil {
+					return nil, err
+				} else {
+					lhs = &expr{kind: exprBinary, op: op, args: []*expr{lhs, rhs}, line: line}
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return lhs, nil
+		}
+	}
+}
+
+func (this *_parser) unary() (*expr, error) {
+	line := this.peek().line
+	if this.is("-") || this.is("!") {
+		op := this.next().Text
+		if e, err := this.unary(); err != nil {
+			return nil, err
+		} else if op == "-" && e.kind == exprNumber {
+			e.value = -e.value
+			return e, nil
+		} else {
+			return &expr{kind: exprUnary, op: op, args: []*expr{e}, line: line}, nil
+		}
+	}
+	return this.primary()
+}
+
+func (this *_parser) primary() (*expr, error) {
+	t := this.peek()
+	switch {
+	case t.Type == tokenNumber:
+		this.next()
+		return &expr{kind: exprNumber, value: t.Value, line: t.line}, nil
+	case this.accept("("):
+		if e, err := this.expression(); err != nil {
+			return nil, err
+		} else {
+			return e, this.expect(")")
+		}
+	}
+	name, err := this.name()
+	if err != nil {
+		return nil, err
+	}
+	if this.accept("[") {
+		if index, err := this.expression(); err != nil {
+			return nil, err
+		} else {
+			return &expr{kind: exprIndex, name: name, args: []*expr{index}, line: t.line}, this.expect("]")
+		}
+	} else if this.accept("(") {
+		e := &expr{kind: exprCall, name: name, line: t.line}
+		for !this.accept(")") {
+			if len(e.args) > 0 {
+				if err := this.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			if arg, err := this.expression(); err != nil {
+				return nil, err
+			} else {
+				e.args = append(e.args, arg)
+			}
+		}
+		return e, nil
+	} else {
+		return &expr{kind: exprVariable, name: name, line: t.line}, nil
+	}
+}